@@ -0,0 +1,405 @@
+// analysis package computes the filament used by a gcode file and the statistics of his extrusions.
+//
+// The Analyzer follows the extruder position, the positioning modes, the units and the active tool with an interpreter.Interpreter,
+// and tracks the firmware retractions (G10 and G11, with the lengths set by M207 and M208) by itself.
+//
+// The filament used by each extruder is the furthest length that it has pushed: a retraction followed by his recovery doesn't count twice,
+// and the filament unloaded at the end of the file doesn't reduce the length used.
+// The length is converted to volume and weight with the diameter and the density of the Filament loaded in each extruder.
+//
+// The volumetric flow rate of each extruding move is computed at his requested feedrate, without accelerations,
+// and the maximum of each feature is reported, see the feature package.
+package analysis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/feature"
+	"github.com/mauroalderete/gcode-core/gcode"
+	"github.com/mauroalderete/gcode-core/interpreter"
+)
+
+const (
+	// FIRMWARE_RETRACT_LENGTH is the default length retracted by G10, the same as Marlin
+	FIRMWARE_RETRACT_LENGTH = 3.0
+
+	// DEFAULT_FEEDRATE is the feedrate in mm/s used until the file sets one
+	DEFAULT_FEEDRATE = 25.0
+)
+
+// DEFAULT_FILAMENT is a PLA filament of 1.75mm
+var DEFAULT_FILAMENT = Filament{Diameter: 1.75, Density: 1.24}
+
+//#region filament struct
+
+// Filament describes the filament loaded in an extruder.
+type Filament struct {
+	// Diameter is the diameter of the filament in mm
+	Diameter float64
+
+	// Density is the density of the material in g/cm³
+	Density float64
+}
+
+// Area returns the area of the section of the filament in mm².
+func (f Filament) Area() float64 {
+	return math.Pi * f.Diameter * f.Diameter / 4
+}
+
+//#endregion
+//#region statistics struct
+
+// Usage is the filament used by an extruder.
+type Usage struct {
+	// Length is the length of filament in mm
+	Length float64
+
+	// Volume is the volume of filament in mm³
+	Volume float64
+
+	// Weight is the weight of filament in g
+	Weight float64
+}
+
+// Statistics are the filament used and the extrusion statistics of a gcode file.
+type Statistics struct {
+	// Extruders stores the filament used by each extruder, indexed by his tool number, up to the highest tool used
+	Extruders []Usage
+
+	// Retractions is the number of retractions, the consecutive retracting moves count as one
+	Retractions int
+
+	// RetractLength is the total length retracted in mm
+	RetractLength float64
+
+	// MaxFlow stores the maximum volumetric flow rate, in mm³/s, reached by each feature
+	MaxFlow map[feature.FeatureType]float64
+}
+
+// Total returns the sum of the filament used by all extruders.
+func (s *Statistics) Total() Usage {
+
+	var total Usage
+
+	for _, u := range s.Extruders {
+		total.Length += u.Length
+		total.Volume += u.Volume
+		total.Weight += u.Weight
+	}
+
+	return total
+}
+
+//#endregion
+//#region analyzer struct
+
+// Analyzer receives the lines of a gcode file one by one and computes his Statistics.
+type Analyzer struct {
+	// filaments stores the filament of each extruder
+	filaments []Filament
+
+	// firmware retraction lengths
+	firmwareLength float64
+	firmwareExtra  float64
+
+	// tagger finds the feature of each line
+	tagger *feature.Tagger
+
+	// extruded stores the net length extruded by each extruder and used the furthest one
+	extruded []float64
+	used     []float64
+
+	// retracting is true after a retraction, until the next extrusion
+	retracting bool
+
+	// firmwareRetracted is true between G10 and G11
+	firmwareRetracted bool
+
+	// statistics computed until now
+	retractions   int
+	retractLength float64
+	maxFlow       map[feature.FeatureType]float64
+
+	// machine tracks the state of the machine
+	machine interpreter.Interpreter
+}
+
+// Add receives the next line of the gcode file.
+//
+// b is the block parsed from the line, it can be nil if the line doesn't contain gcode or it couldn't be parsed.
+// The lines without gcode must be added too, to find the features.
+func (a *Analyzer) Add(line string, b block.Blocker) {
+
+	kind := a.tagger.Tag(line, b)
+
+	if b = interpreter.Block(b); b == nil {
+		return
+	}
+
+	before := a.machine.State()
+	a.machine.Add(line, b)
+
+	command := b.Command()
+	number, ok := gcode.NumericAddress(command)
+	if !ok {
+		return
+	}
+
+	switch command.Word() {
+	case 'T':
+		a.extruder(a.tool())
+
+	case 'G':
+		switch number {
+		case 0, 1, 2, 3:
+			a.move(b, kind, before)
+		case 10:
+			// G10 with L or P sets offsets in other dialects
+			if !interpreter.Has(b, 'L') && !interpreter.Has(b, 'P') && !a.firmwareRetracted {
+				a.firmwareRetracted = true
+				a.extrude(-a.firmwareLength)
+			}
+		case 11:
+			if a.firmwareRetracted {
+				a.firmwareRetracted = false
+				a.extrude(a.firmwareLength + a.firmwareExtra)
+			}
+		}
+
+	case 'M':
+		switch number {
+		case 207:
+			if s, ok := interpreter.Parameter(b, 'S'); ok && s >= 0 {
+				a.firmwareLength = s
+			}
+		case 208:
+			if s, ok := interpreter.Parameter(b, 'S'); ok {
+				a.firmwareExtra = s
+			}
+		}
+	}
+}
+
+// Statistics returns the statistics computed from the lines added until now.
+func (a *Analyzer) Statistics() *Statistics {
+
+	s := &Statistics{
+		Extruders:     make([]Usage, len(a.used)),
+		Retractions:   a.retractions,
+		RetractLength: a.retractLength,
+		MaxFlow:       make(map[feature.FeatureType]float64, len(a.maxFlow)),
+	}
+
+	for tool, length := range a.used {
+		f := a.filament(tool)
+		s.Extruders[tool] = Usage{
+			Length: length,
+			Volume: length * f.Area(),
+			Weight: length * f.Area() / 1000 * f.Density,
+		}
+	}
+
+	for kind, flow := range a.maxFlow {
+		s.MaxFlow[kind] = flow
+	}
+
+	return s
+}
+
+// move executes a linear move or an arc, before is the state of the machine before the move
+func (a *Analyzer) move(b block.Blocker, kind feature.FeatureType, before interpreter.State) {
+
+	position, target := before.Machine(before.Position), before.Machine(before.Target(b))
+
+	dx, dy, dz := target[0]-position[0], target[1]-position[1], target[2]-position[2]
+	distance := math.Sqrt(dx*dx + dy*dy + dz*dz)
+	if commandIs(b, 2) || commandIs(b, 3) {
+		distance = arcLength(b, position, target, before.Scale())
+	}
+
+	de := target[3] - position[3]
+
+	a.extrude(de)
+
+	if de > 0 && distance > 0 {
+		flow := de * a.filament(a.tool()).Area() * a.feedrate() / distance
+		if flow > a.maxFlow[kind] {
+			a.maxFlow[kind] = flow
+		}
+	}
+}
+
+// feedrate returns the feedrate of the moves in mm/s
+func (a *Analyzer) feedrate() float64 {
+
+	s := a.machine.State()
+	if s.Feedrate == 0 {
+		return DEFAULT_FEEDRATE
+	}
+
+	return s.Feedrate * s.Scale() / 60
+}
+
+// tool returns the active tool
+func (a *Analyzer) tool() int {
+	return a.machine.State().Tool
+}
+
+// extrude accounts a length extruded, or retracted if it's negative, by the current tool
+func (a *Analyzer) extrude(length float64) {
+
+	if length == 0 {
+		return
+	}
+
+	a.extruder(a.tool())
+
+	if length < 0 {
+		if !a.retracting {
+			a.retractions++
+			a.retracting = true
+		}
+		a.retractLength -= length
+	} else {
+		a.retracting = false
+	}
+
+	a.extruded[a.tool()] += length
+	if a.extruded[a.tool()] > a.used[a.tool()] {
+		a.used[a.tool()] = a.extruded[a.tool()]
+	}
+}
+
+// extruder makes room for the counters of a tool
+func (a *Analyzer) extruder(tool int) {
+	for len(a.used) <= tool {
+		a.used = append(a.used, 0)
+		a.extruded = append(a.extruded, 0)
+	}
+}
+
+// filament returns the filament loaded in a tool
+func (a *Analyzer) filament(tool int) Filament {
+	if tool < len(a.filaments) {
+		return a.filaments[tool]
+	}
+	return a.filaments[len(a.filaments)-1]
+}
+
+//#endregion
+//#region constructor
+
+// New returns an Analyzer ready to receive the first line of a gcode file.
+func New(options ...AnalyzerConfigurationCallbackable) (*Analyzer, error) {
+
+	analyzer := &Analyzer{
+		filaments:      []Filament{DEFAULT_FILAMENT},
+		firmwareLength: FIRMWARE_RETRACT_LENGTH,
+		tagger:         feature.New(),
+		maxFlow:        make(map[feature.FeatureType]float64),
+	}
+
+	configurator := &analyzerConfigurator{analyzer: analyzer}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	return analyzer, nil
+}
+
+//#endregion
+//#region package functions
+
+// Analyze reads a gcode file and returns his statistics.
+//
+// Each line is parsed with gcodeblock.Parse. The lines that can't be parsed, like a M117 with an unquoted message,
+// are taken as lines without gcode.
+func Analyze(r io.Reader, options ...AnalyzerConfigurationCallbackable) (*Statistics, error) {
+
+	a, err := New(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), gcodeblock.SCANNER_MAX_LINE_SIZE)
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		var b block.Blocker
+		if gcodeblock.HasGcode(line) {
+			if parsed, err := gcodeblock.Parse(line); err == nil {
+				b = parsed
+			}
+		}
+
+		a.Add(line, b)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read gcode: %w", err)
+	}
+
+	return a.Statistics(), nil
+}
+
+//#endregion
+//#region private functions
+
+// arcLength returns the length of an arc from the start to the target, defined by his center offset or his radius.
+// The positions are in millimeters and the scale converts the parameters of the block to millimeters.
+func arcLength(b block.Blocker, start [4]float64, target [4]float64, scale float64) float64 {
+
+	dx, dy, dz := target[0]-start[0], target[1]-start[1], target[2]-start[2]
+	chord := math.Hypot(dx, dy)
+
+	var flat float64
+
+	if r, ok := interpreter.Parameter(b, 'R'); ok && !interpreter.Has(b, 'I') && !interpreter.Has(b, 'J') {
+		r *= scale
+		angle := 2 * math.Asin(math.Min(1, chord/(2*math.Abs(r))))
+		if r < 0 {
+			angle = 2*math.Pi - angle
+		}
+		flat = math.Abs(r) * angle
+	} else {
+		i, _ := interpreter.Parameter(b, 'I')
+		j, _ := interpreter.Parameter(b, 'J')
+		i, j = i*scale, j*scale
+
+		// angle between the vectors from the center to the start and to the target
+		tx, ty := dx-i, dy-j
+		angle := math.Atan2(-i*ty+j*tx, -i*tx-j*ty)
+		if angle < 0 {
+			angle += 2 * math.Pi
+		}
+		if commandIs(b, 2) {
+			angle = 2*math.Pi - angle
+		}
+		if chord == 0 {
+			angle = 2 * math.Pi
+		}
+		flat = math.Hypot(i, j) * angle
+	}
+
+	return math.Hypot(flat, dz)
+}
+
+// commandIs returns true if the command of the block has the given number
+func commandIs(b block.Blocker, number float64) bool {
+	n, ok := gcode.NumericAddress(b.Command())
+	return ok && n == number
+}
+
+//#endregion
@@ -0,0 +1,57 @@
+// This file defines an analyzerConfigurator as an object that implement the AnalyzerConfigurer
+// interface to allow the caller to configure a new Analyzer.
+package analysis
+
+import "fmt"
+
+// AnalyzerConfigurer contains the configurable options that define the filaments and the firmware retraction of an Analyzer.
+type AnalyzerConfigurer interface {
+	// Set the filament loaded in each extruder, the first one in T0, the second one in T1 and so on
+	SetFilaments(filaments ...Filament) error
+
+	// Set the length retracted by G10 and the extra length recovered by G11
+	SetFirmwareRetraction(length float64, extra float64) error
+}
+
+// AnalyzerConfigurationCallbackable is the signature of the callbacks that the New constructor waiting receives to configure the new Analyzer instance.
+type AnalyzerConfigurationCallbackable func(config AnalyzerConfigurer) error
+
+// analyzerConfigurator satisfy AnalyzerConfigurer, it applies each option directly over the Analyzer in construction.
+type analyzerConfigurator struct {
+	analyzer *Analyzer
+}
+
+// SetFilaments sets the filament of each extruder. The extruders without a filament use the last one.
+// By default all extruders use DEFAULT_FILAMENT.
+//
+// The diameters and the densities must be positive.
+func (ac *analyzerConfigurator) SetFilaments(filaments ...Filament) error {
+
+	if len(filaments) == 0 {
+		return fmt.Errorf("failed set filaments, at least one is required")
+	}
+
+	for i, f := range filaments {
+		if f.Diameter <= 0 || f.Density <= 0 {
+			return fmt.Errorf("failed set filaments, the diameter and the density of the filament %d must be positive", i)
+		}
+	}
+
+	ac.analyzer.filaments = append([]Filament{}, filaments...)
+
+	return nil
+}
+
+// SetFirmwareRetraction sets the lengths of the firmware retraction, like M207 S and M208 S do.
+// By default are FIRMWARE_RETRACT_LENGTH and 0.
+func (ac *analyzerConfigurator) SetFirmwareRetraction(length float64, extra float64) error {
+
+	if length < 0 {
+		return fmt.Errorf("failed set firmware retraction, the length mustn't be negative")
+	}
+
+	ac.analyzer.firmwareLength = length
+	ac.analyzer.firmwareExtra = extra
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package analysis
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/mauroalderete/gcode-core/feature"
+)
+
+func analyze(t *testing.T, source string, options ...AnalyzerConfigurationCallbackable) *Statistics {
+	t.Helper()
+
+	s, err := Analyze(strings.NewReader(source), options...)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	return s
+}
+
+func near(got float64, want float64) bool {
+	return math.Abs(got-want) < 1e-6
+}
+
+func TestAnalyze_Extrusion(t *testing.T) {
+
+	var cases = map[string]struct {
+		source        string
+		lengths       []float64
+		retractions   int
+		retractLength float64
+	}{
+		"absolute": {
+			source: `M82
+G92 E0
+G1 X10 E5
+G1 E4
+G1 X20 E6
+G92 E0
+G1 X30 E2`,
+			lengths:       []float64{8},
+			retractions:   1,
+			retractLength: 1,
+		},
+		"absolute with G90": {
+			source: `G91
+G90
+G1 X10 E5
+G1 X20 E10`,
+			lengths: []float64{10},
+		},
+		"relative": {
+			source: `M83
+G1 X10 E5
+G1 E-1
+G1 X12 E-0.5
+G1 E1.5
+G1 X20 E2
+G1 E-1
+G1 E1`,
+			lengths:       []float64{7},
+			retractions:   2,
+			retractLength: 2.5,
+		},
+		"unload": {
+			source: `M83
+G1 X10 E5
+G1 E-50`,
+			lengths:       []float64{5},
+			retractions:   1,
+			retractLength: 50,
+		},
+		"firmware retraction": {
+			source: `M83
+M207 S2
+M208 S0.1
+G1 X10 E5
+G10
+G10
+G11
+G11
+G1 X20 E1
+G10 L2 P1 X0`,
+			lengths:       []float64{6.1},
+			retractions:   1,
+			retractLength: 2,
+		},
+		"tools": {
+			source: `M83
+T1
+G1 X10 E3
+T0
+G1 X20 E5
+T1
+G1 X30 E1`,
+			lengths: []float64{5, 4},
+		},
+		"inches": {
+			source: `M83
+G20
+G1 X1 E0.1`,
+			lengths: []float64{2.54},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := analyze(t, tc.source)
+
+			if len(s.Extruders) != len(tc.lengths) {
+				t.Fatalf("got %d extruders, want %d", len(s.Extruders), len(tc.lengths))
+			}
+
+			for i, want := range tc.lengths {
+				if !near(s.Extruders[i].Length, want) {
+					t.Errorf("got extruder %d length %v, want %v", i, s.Extruders[i].Length, want)
+				}
+			}
+
+			if s.Retractions != tc.retractions {
+				t.Errorf("got %d retractions, want %d", s.Retractions, tc.retractions)
+			}
+
+			if !near(s.RetractLength, tc.retractLength) {
+				t.Errorf("got retract length %v, want %v", s.RetractLength, tc.retractLength)
+			}
+		})
+	}
+}
+
+func TestAnalyze_Filaments(t *testing.T) {
+
+	s := analyze(t, "M83\nG1 X10 E1000\nT1\nG1 X20 E100\n", func(config AnalyzerConfigurer) error {
+		return config.SetFilaments(Filament{Diameter: 1.75, Density: 1.24}, Filament{Diameter: 2.85, Density: 1.27})
+	})
+
+	pla := math.Pi * 1.75 * 1.75 / 4 * 1000
+	petg := math.Pi * 2.85 * 2.85 / 4 * 100
+
+	if !near(s.Extruders[0].Volume, pla) || !near(s.Extruders[0].Weight, pla/1000*1.24) {
+		t.Errorf("got %+v, want volume %v and weight %v", s.Extruders[0], pla, pla/1000*1.24)
+	}
+
+	if !near(s.Extruders[1].Volume, petg) || !near(s.Extruders[1].Weight, petg/1000*1.27) {
+		t.Errorf("got %+v, want volume %v and weight %v", s.Extruders[1], petg, petg/1000*1.27)
+	}
+
+	total := s.Total()
+	if !near(total.Length, 1100) || !near(total.Weight, pla/1000*1.24+petg/1000*1.27) {
+		t.Errorf("got total %+v, want the sum of the extruders", total)
+	}
+
+	// the extruders without filament use the last one
+	s = analyze(t, "M83\nT2\nG1 X10 E100\n")
+	if !near(s.Extruders[2].Volume, DEFAULT_FILAMENT.Area()*100) || s.Extruders[0].Length != 0 {
+		t.Errorf("got %+v, want the default filament", s.Extruders)
+	}
+}
+
+func TestAnalyze_MaxFlow(t *testing.T) {
+
+	s := analyze(t, `M83
+;TYPE:WALL-OUTER
+G1 X10 E1 F600
+G1 X20 E0.5
+;TYPE:FILL
+G1 X40 E4 F1200
+G1 E-1 F2400
+G0 X0 F9000
+;TYPE:SKIRT
+G1 X10 Y0 F600
+G2 X10 Y0 I-10 J0 E6.2831853
+`)
+
+	area := DEFAULT_FILAMENT.Area()
+
+	want := map[feature.FeatureType]float64{
+		feature.FEATURE_OUTER_WALL: area * 1,
+		feature.FEATURE_INFILL:     area * 4,
+		feature.FEATURE_SKIRT:      area * 1,
+	}
+
+	if len(s.MaxFlow) != len(want) {
+		t.Errorf("got %v, want %v", s.MaxFlow, want)
+	}
+
+	for kind, flow := range want {
+		if math.Abs(s.MaxFlow[kind]-flow) > 1e-3 {
+			t.Errorf("got %s flow %v, want %v", kind, s.MaxFlow[kind], flow)
+		}
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+
+	var cases = map[string]AnalyzerConfigurationCallbackable{
+		"without filaments": func(config AnalyzerConfigurer) error {
+			return config.SetFilaments()
+		},
+		"invalid filament": func(config AnalyzerConfigurer) error {
+			return config.SetFilaments(DEFAULT_FILAMENT, Filament{Diameter: 1.75})
+		},
+		"negative retraction": func(config AnalyzerConfigurer) error {
+			return config.SetFirmwareRetraction(-1, 0)
+		},
+	}
+
+	for name, option := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(option); err == nil {
+				t.Errorf("got nil error, want error")
+			}
+		})
+	}
+}
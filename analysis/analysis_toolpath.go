@@ -0,0 +1,433 @@
+// This file defines a Toolpath that computes the bounding boxes of the moves of a gcode file
+// and validates them against the envelope of the machine.
+package analysis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/gcode"
+	"github.com/mauroalderete/gcode-core/interpreter"
+)
+
+const (
+	// BOUNDS_TOLERANCE is the distance in mm that a move can exceed the envelope without being a violation, to absorb rounding errors
+	BOUNDS_TOLERANCE = 1e-6
+
+	// ARC_SEGMENT_LENGTH is the length in mm of the segments in which the arcs are split to compute the bounding boxes
+	ARC_SEGMENT_LENGTH = 1.0
+)
+
+//#region box struct
+
+// Box is an axis aligned box, defined by his minimum and maximum corners in X, Y and Z.
+//
+// A box is empty when any coordinate of Min is greater than the same coordinate of Max.
+type Box struct {
+	Min [3]float64
+	Max [3]float64
+}
+
+// Empty returns true if the box doesn't contain any point.
+func (b Box) Empty() bool {
+	return b.Min[0] > b.Max[0] || b.Min[1] > b.Max[1] || b.Min[2] > b.Max[2]
+}
+
+// Contains returns true if the point is inside the box, borders included.
+func (b Box) Contains(p [3]float64) bool {
+	for axis := range p {
+		if p[axis] < b.Min[axis]-BOUNDS_TOLERANCE || p[axis] > b.Max[axis]+BOUNDS_TOLERANCE {
+			return false
+		}
+	}
+	return true
+}
+
+// Intersects returns true if the segment from a to b passes through the box, borders excluded.
+func (b Box) Intersects(from [3]float64, to [3]float64) bool {
+
+	// clip the segment against the slab of each axis
+	enter, exit := 0.0, 1.0
+
+	for axis := range from {
+		d := to[axis] - from[axis]
+		min, max := b.Min[axis]+BOUNDS_TOLERANCE, b.Max[axis]-BOUNDS_TOLERANCE
+
+		if d == 0 {
+			if from[axis] <= min || from[axis] >= max {
+				return false
+			}
+			continue
+		}
+
+		t1, t2 := (min-from[axis])/d, (max-from[axis])/d
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		enter, exit = math.Max(enter, t1), math.Min(exit, t2)
+		if enter >= exit {
+			return false
+		}
+	}
+
+	return true
+}
+
+// extend grows the box to include the point
+func (b *Box) extend(p [3]float64) {
+	for axis := range p {
+		b.Min[axis] = math.Min(b.Min[axis], p[axis])
+		b.Max[axis] = math.Max(b.Max[axis], p[axis])
+	}
+}
+
+// emptyBox returns a box that grows from the first point extended
+func emptyBox() Box {
+	inf := math.Inf(1)
+	return Box{
+		Min: [3]float64{inf, inf, inf},
+		Max: [3]float64{-inf, -inf, -inf},
+	}
+}
+
+//#endregion
+//#region envelope struct
+
+// Zone is a named region of the machine where the tool mustn't enter, like the clips that hold the bed.
+type Zone struct {
+	Box
+
+	// Name identifies the zone in the violations
+	Name string
+}
+
+// Envelope is the space where the tool of a machine can move.
+type Envelope struct {
+	// Volume is the reachable volume, usually the bed size and the maximum Z
+	Volume Box
+
+	// Home is the position of the tool after homing
+	Home [3]float64
+
+	// Forbidden stores the zones where the tool mustn't enter
+	Forbidden []Zone
+}
+
+//#endregion
+//#region toolpath struct
+
+// Bounds are the bounding boxes of the moves of a gcode file, in machine coordinates.
+type Bounds struct {
+	// Extruding is the box of the moves that extrude
+	Extruding Box
+
+	// Travel is the box of the moves that don't extrude
+	Travel Box
+
+	// All is the box of all moves
+	All Box
+}
+
+// Violation describes the first move that leaves the envelope.
+type Violation struct {
+	// Line is the index of the line of the move, starting at 0, and Text is the line
+	Line int
+	Text string
+
+	// Position is the point of the move that violates the envelope
+	Position [3]float64
+
+	// Reason describes the violation
+	Reason string
+}
+
+// Error returns the violation as an error message.
+func (v *Violation) Error() string {
+	return fmt.Sprintf("line %d '%s': %s", v.Line, v.Text, v.Reason)
+}
+
+// Toolpath receives the lines of a gcode file one by one, computes the bounding boxes of his moves
+// and validates them against an Envelope.
+//
+// It follows the state of the machine with an interpreter.Interpreter, like the Analyzer, and converts the positions
+// with the offsets set by G92 and the units, so the boxes are in machine coordinates.
+// The arcs (G2 and G3) are split into segments of ARC_SEGMENT_LENGTH.
+// Before the first G28 the tool is taken to be at the home position.
+type Toolpath struct {
+	// envelope is validated only if it's present
+	envelope *Envelope
+
+	// bounds computed until now
+	bounds Bounds
+
+	// violation stores the first violation found
+	violation *Violation
+
+	// lines counts the lines added
+	lines int
+
+	// machine tracks the state of the machine, homed at the home position of the envelope
+	machine *interpreter.Interpreter
+}
+
+// Add receives the next line of the gcode file.
+//
+// b is the block parsed from the line, it can be nil if the line doesn't contain gcode or it couldn't be parsed.
+// The lines without gcode must be added too, to keep the count of the lines.
+func (t *Toolpath) Add(line string, b block.Blocker) {
+
+	index := t.lines
+	t.lines++
+
+	if b = interpreter.Block(b); b == nil {
+		return
+	}
+
+	before := t.machine.State()
+	t.machine.Add(line, b)
+
+	number, ok := gcode.NumericAddress(b.Command())
+	if !ok || b.Command().Word() != 'G' {
+		return
+	}
+
+	from, to := before.Machine(before.Position), before.Machine(before.Target(b))
+
+	switch number {
+	case 0, 1:
+		t.moveTo(index, line, from, to)
+	case 2, 3:
+		for _, point := range arcPoints(from, to, number == 2, b, before.Scale()) {
+			t.moveTo(index, line, from, point)
+			from = point
+		}
+	}
+}
+
+// Bounds returns the bounding boxes of the moves added until now.
+// The boxes without moves are empty.
+func (t *Toolpath) Bounds() Bounds {
+	return t.bounds
+}
+
+// Violation returns the first move that leaves the envelope, or nil if there isn't any or the Toolpath hasn't an envelope.
+func (t *Toolpath) Violation() *Violation {
+	return t.violation
+}
+
+// home returns the home position
+func (t *Toolpath) home() [3]float64 {
+	if t.envelope == nil {
+		return [3]float64{}
+	}
+	return t.envelope.Home
+}
+
+// moveTo moves the tool between two positions in machine coordinates, extending the boxes and validating the move
+func (t *Toolpath) moveTo(index int, line string, start [4]float64, target [4]float64) {
+
+	from := [3]float64{start[0], start[1], start[2]}
+	to := [3]float64{target[0], target[1], target[2]}
+	extrudes := target[3] > start[3]
+
+	if from == to {
+		return
+	}
+
+	if extrudes {
+		t.bounds.Extruding.extend(from)
+		t.bounds.Extruding.extend(to)
+	} else {
+		t.bounds.Travel.extend(from)
+		t.bounds.Travel.extend(to)
+	}
+	t.bounds.All.extend(from)
+	t.bounds.All.extend(to)
+
+	if t.envelope == nil || t.violation != nil {
+		return
+	}
+
+	for _, p := range [][3]float64{from, to} {
+		if !t.envelope.Volume.Contains(p) {
+			t.violation = &Violation{
+				Line:     index,
+				Text:     line,
+				Position: p,
+				Reason:   fmt.Sprintf("position X%.3f Y%.3f Z%.3f is out of the volume of the machine", p[0], p[1], p[2]),
+			}
+			return
+		}
+	}
+
+	for _, zone := range t.envelope.Forbidden {
+		if zone.Intersects(from, to) {
+			t.violation = &Violation{
+				Line:     index,
+				Text:     line,
+				Position: to,
+				Reason:   fmt.Sprintf("move enters the forbidden zone %s", zone.Name),
+			}
+			return
+		}
+	}
+}
+
+//#endregion
+//#region constructor
+
+// NewToolpath returns a Toolpath ready to receive the first line of a gcode file.
+func NewToolpath(options ...ToolpathConfigurationCallbackable) (*Toolpath, error) {
+
+	toolpath := &Toolpath{
+		bounds: Bounds{
+			Extruding: emptyBox(),
+			Travel:    emptyBox(),
+			All:       emptyBox(),
+		},
+	}
+
+	configurator := &toolpathConfigurator{toolpath: toolpath}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	home := toolpath.home()
+	machine, err := interpreter.New(func(config interpreter.InterpreterConfigurer) error {
+		return config.SetHome(home[0], home[1], home[2])
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create toolpath: %w", err)
+	}
+	toolpath.machine = machine
+
+	return toolpath, nil
+}
+
+//#endregion
+//#region package functions
+
+// Measure reads a gcode file and returns the bounding boxes of his moves and the first violation of the envelope, if any.
+//
+// Each line is parsed with gcodeblock.Parse. The lines that can't be parsed, like a M117 with an unquoted message,
+// are taken as lines without gcode.
+func Measure(r io.Reader, options ...ToolpathConfigurationCallbackable) (Bounds, *Violation, error) {
+
+	t, err := NewToolpath(options...)
+	if err != nil {
+		return Bounds{}, nil, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), gcodeblock.SCANNER_MAX_LINE_SIZE)
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		var b block.Blocker
+		if gcodeblock.HasGcode(line) {
+			if parsed, err := gcodeblock.Parse(line); err == nil {
+				b = parsed
+			}
+		}
+
+		t.Add(line, b)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Bounds{}, nil, fmt.Errorf("failed to read gcode: %w", err)
+	}
+
+	return t.Bounds(), t.Violation(), nil
+}
+
+//#endregion
+//#region private functions
+
+// arcPoints returns the points that split an arc into segments of ARC_SEGMENT_LENGTH, the last one is the target
+func arcPoints(start [4]float64, target [4]float64, clockwise bool, b block.Blocker, scale float64) [][4]float64 {
+
+	i, hasI := interpreter.Parameter(b, 'I')
+	j, hasJ := interpreter.Parameter(b, 'J')
+	i, j = i*scale, j*scale
+
+	if r, ok := interpreter.Parameter(b, 'R'); ok && !hasI && !hasJ {
+		r *= scale
+		dx, dy := target[0]-start[0], target[1]-start[1]
+		d := math.Hypot(dx, dy)
+		if d == 0 {
+			return [][4]float64{target}
+		}
+
+		sign := 1.0
+		if clockwise != (r < 0) {
+			sign = -1
+		}
+		h := math.Sqrt(math.Max(0, r*r-d*d/4))
+		i = (start[0]+target[0])/2 - sign*h*dy/d - start[0]
+		j = (start[1]+target[1])/2 + sign*h*dx/d - start[1]
+	}
+
+	cx, cy := start[0]+i, start[1]+j
+	radius := math.Hypot(i, j)
+
+	// angle between the vectors from the center to the start and to the target
+	tX, tY := target[0]-cx, target[1]-cy
+	angle := math.Atan2(-i*tY+j*tX, -i*tX-j*tY)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	if clockwise {
+		angle -= 2 * math.Pi
+	}
+	if target[0] == start[0] && target[1] == start[1] && (angle == 0 || angle == -2*math.Pi) {
+		angle = 2 * math.Pi
+		if clockwise {
+			angle = -angle
+		}
+	}
+
+	length := math.Hypot(radius*angle, target[2]-start[2])
+	segments := int(math.Max(1, math.Floor(length/ARC_SEGMENT_LENGTH)))
+	startAngle := math.Atan2(-j, -i)
+
+	// the points where the arc crosses an axis are added too, so the bounding boxes include the extremes of the arc
+	fractions := make([]float64, 0, segments+4)
+	for n := 1; n < segments; n++ {
+		fractions = append(fractions, float64(n)/float64(segments))
+	}
+	lowest, highest := math.Min(startAngle, startAngle+angle), math.Max(startAngle, startAngle+angle)
+	for quadrant := math.Ceil(lowest / (math.Pi / 2)); quadrant*math.Pi/2 < highest; quadrant++ {
+		if fraction := (quadrant*math.Pi/2 - startAngle) / angle; fraction > 0 && fraction < 1 {
+			fractions = append(fractions, fraction)
+		}
+	}
+	sort.Float64s(fractions)
+
+	points := make([][4]float64, 0, len(fractions)+1)
+
+	for _, fraction := range fractions {
+		a := startAngle + angle*fraction
+
+		points = append(points, [4]float64{
+			cx + radius*math.Cos(a),
+			cy + radius*math.Sin(a),
+			start[2] + (target[2]-start[2])*fraction,
+			start[3] + (target[3]-start[3])*fraction,
+		})
+	}
+
+	return append(points, target)
+}
+
+//#endregion
@@ -0,0 +1,45 @@
+// This file defines a toolpathConfigurator as an object that implement the ToolpathConfigurer
+// interface to allow the caller to configure a new Toolpath.
+package analysis
+
+import "fmt"
+
+// ToolpathConfigurer contains the configurable options that define the envelope validated by a Toolpath.
+type ToolpathConfigurer interface {
+	// Set the envelope of the machine where the moves must fit
+	SetEnvelope(envelope Envelope) error
+}
+
+// ToolpathConfigurationCallbackable is the signature of the callbacks that the NewToolpath constructor waiting receives to configure the new Toolpath instance.
+type ToolpathConfigurationCallbackable func(config ToolpathConfigurer) error
+
+// toolpathConfigurator satisfy ToolpathConfigurer, it applies each option directly over the Toolpath in construction.
+type toolpathConfigurator struct {
+	toolpath *Toolpath
+}
+
+// SetEnvelope sets the envelope of the machine. By default the Toolpath hasn't an envelope,
+// it only computes the bounding boxes and the home position is the origin.
+//
+// The volume and the forbidden zones mustn't be empty and the home position must be inside the volume.
+func (tc *toolpathConfigurator) SetEnvelope(envelope Envelope) error {
+
+	if envelope.Volume.Empty() {
+		return fmt.Errorf("failed set envelope, the volume is empty")
+	}
+
+	if !envelope.Volume.Contains(envelope.Home) {
+		return fmt.Errorf("failed set envelope, the home position is out of the volume")
+	}
+
+	for i, zone := range envelope.Forbidden {
+		if zone.Empty() {
+			return fmt.Errorf("failed set envelope, the forbidden zone %d is empty", i)
+		}
+	}
+
+	envelope.Forbidden = append([]Zone{}, envelope.Forbidden...)
+	tc.toolpath.envelope = &envelope
+
+	return nil
+}
@@ -0,0 +1,302 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func measure(t *testing.T, source string, options ...ToolpathConfigurationCallbackable) (Bounds, *Violation) {
+	t.Helper()
+
+	b, v, err := Measure(strings.NewReader(source), options...)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	return b, v
+}
+
+func withEnvelope(envelope Envelope) ToolpathConfigurationCallbackable {
+	return func(config ToolpathConfigurer) error {
+		return config.SetEnvelope(envelope)
+	}
+}
+
+func nearBox(got Box, want Box) bool {
+	for axis := 0; axis < 3; axis++ {
+		if got.Min[axis] != want.Min[axis] && !near(got.Min[axis], want.Min[axis]) {
+			return false
+		}
+		if got.Max[axis] != want.Max[axis] && !near(got.Max[axis], want.Max[axis]) {
+			return false
+		}
+	}
+	return true
+}
+
+var testEnvelope = Envelope{
+	Volume: Box{Min: [3]float64{0, -4, 0}, Max: [3]float64{250, 210, 200}},
+	Home:   [3]float64{0, -4, 0},
+	Forbidden: []Zone{
+		{Name: "clip", Box: Box{Min: [3]float64{100, -4, 0}, Max: [3]float64{110, 5, 2}}},
+	},
+}
+
+func TestMeasure_Bounds(t *testing.T) {
+
+	var cases = map[string]struct {
+		source    string
+		extruding Box
+		travel    Box
+		all       Box
+	}{
+		"absolute": {
+			source: `G28
+G1 X10 Y10 Z0.2 F3000
+G1 X20 Y10 E1
+G1 X20 Y30 E2
+G1 X50 Y50
+`,
+			extruding: Box{Min: [3]float64{10, 10, 0.2}, Max: [3]float64{20, 30, 0.2}},
+			travel:    Box{Min: [3]float64{0, 0, 0}, Max: [3]float64{50, 50, 0.2}},
+			all:       Box{Min: [3]float64{0, 0, 0}, Max: [3]float64{50, 50, 0.2}},
+		},
+		"relative": {
+			source: `G28
+G91
+G1 X10 Y10 Z1
+G1 X5 E1
+G1 Y-20 E1
+`,
+			extruding: Box{Min: [3]float64{10, -10, 1}, Max: [3]float64{15, 10, 1}},
+			travel:    Box{Min: [3]float64{0, 0, 0}, Max: [3]float64{10, 10, 1}},
+			all:       Box{Min: [3]float64{0, -10, 0}, Max: [3]float64{15, 10, 1}},
+		},
+		"offset": {
+			source: `G28
+G1 X100 Y100
+G92 X0 Y0
+G1 X10 Y-10 E1
+`,
+			extruding: Box{Min: [3]float64{100, 90, 0}, Max: [3]float64{110, 100, 0}},
+			travel:    Box{Min: [3]float64{0, 0, 0}, Max: [3]float64{100, 100, 0}},
+			all:       Box{Min: [3]float64{0, 0, 0}, Max: [3]float64{110, 100, 0}},
+		},
+		"inches": {
+			source: `G20
+G1 X1 Y2 E0.1
+`,
+			extruding: Box{Min: [3]float64{0, 0, 0}, Max: [3]float64{25.4, 50.8, 0}},
+			travel:    emptyBox(),
+			all:       Box{Min: [3]float64{0, 0, 0}, Max: [3]float64{25.4, 50.8, 0}},
+		},
+		"arc": {
+			source: `G1 X10 Y0
+G2 X-10 Y0 I-10 J0 E1
+`,
+			extruding: Box{Min: [3]float64{-10, -10, 0}, Max: [3]float64{10, 0, 0}},
+			travel:    Box{Min: [3]float64{0, 0, 0}, Max: [3]float64{10, 0, 0}},
+			all:       Box{Min: [3]float64{-10, -10, 0}, Max: [3]float64{10, 0, 0}},
+		},
+		"retraction isn't a move": {
+			source: `G1 E-1
+G1 E1
+`,
+			extruding: emptyBox(),
+			travel:    emptyBox(),
+			all:       emptyBox(),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			b, v := measure(t, tc.source)
+
+			if v != nil {
+				t.Errorf("got violation %v, want nil", v)
+			}
+			if !nearBox(b.Extruding, tc.extruding) {
+				t.Errorf("got extruding %v, want %v", b.Extruding, tc.extruding)
+			}
+			if !nearBox(b.Travel, tc.travel) {
+				t.Errorf("got travel %v, want %v", b.Travel, tc.travel)
+			}
+			if !nearBox(b.All, tc.all) {
+				t.Errorf("got all %v, want %v", b.All, tc.all)
+			}
+		})
+	}
+}
+
+func TestMeasure_Empty(t *testing.T) {
+
+	b, _ := measure(t, "; only a comment\n")
+
+	if !b.Extruding.Empty() || !b.Travel.Empty() || !b.All.Empty() {
+		t.Errorf("got bounds %v, want empty boxes", b)
+	}
+}
+
+func TestMeasure_Violation(t *testing.T) {
+
+	var cases = map[string]struct {
+		source string
+		line   int
+		zone   bool
+	}{
+		"fits": {
+			source: `G28
+G1 Z0.3 F600
+G1 X20 Y20
+G1 X240 Y200 E10
+`,
+			line: -1,
+		},
+		"out of the bed": {
+			source: `G28
+G1 X20 Y20 Z0.3
+G1 X260 E10
+G1 X300
+`,
+			line: 2,
+		},
+		"below the bed": {
+			source: `G28
+G1 X20 Y20
+G1 Z-1
+`,
+			line: 2,
+		},
+		"over the maximum Z": {
+			source: `G28
+G91
+G1 X20 Y20
+G1 Z150
+G1 Z60
+`,
+			line: 4,
+		},
+		"home position": {
+			source: `G28
+G1 Y-4.5
+`,
+			line: 1,
+		},
+		"through the clip": {
+			source: `G28
+G1 X20 Y0 Z1
+G1 X200 Y0
+`,
+			line: 2,
+			zone: true,
+		},
+		"over the clip": {
+			source: `G28
+G1 Z5
+G1 X200 Y0
+`,
+			line: -1,
+		},
+		"first violation": {
+			source: `G28
+G1 X20 Y20 Z1
+G1 X-1
+G1 X300
+`,
+			line: 2,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, v := measure(t, tc.source, withEnvelope(testEnvelope))
+
+			if tc.line < 0 {
+				if v != nil {
+					t.Errorf("got violation %v, want nil", v)
+				}
+				return
+			}
+
+			if v == nil {
+				t.Fatalf("got nil violation, want a violation at line %d", tc.line)
+			}
+			if v.Line != tc.line {
+				t.Errorf("got violation at line %d, want %d", v.Line, tc.line)
+			}
+			if lines := strings.Split(tc.source, "\n"); v.Text != lines[tc.line] {
+				t.Errorf("got text '%s', want '%s'", v.Text, lines[tc.line])
+			}
+			if got := strings.Contains(v.Reason, "clip"); got != tc.zone {
+				t.Errorf("got reason '%s', want zone %v", v.Reason, tc.zone)
+			}
+		})
+	}
+}
+
+func TestNewToolpath_Envelope(t *testing.T) {
+
+	var cases = map[string]struct {
+		envelope Envelope
+		valid    bool
+	}{
+		"valid": {
+			envelope: testEnvelope,
+			valid:    true,
+		},
+		"empty volume": {
+			envelope: Envelope{Volume: Box{Min: [3]float64{10, 0, 0}, Max: [3]float64{0, 10, 10}}},
+		},
+		"home out of the volume": {
+			envelope: Envelope{
+				Volume: Box{Max: [3]float64{10, 10, 10}},
+				Home:   [3]float64{-5, 0, 0},
+			},
+		},
+		"empty zone": {
+			envelope: Envelope{
+				Volume:    Box{Max: [3]float64{10, 10, 10}},
+				Forbidden: []Zone{{Box: emptyBox()}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewToolpath(withEnvelope(tc.envelope))
+
+			if tc.valid && err != nil {
+				t.Errorf("got error %v, want nil error", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("got nil error, want an error")
+			}
+		})
+	}
+}
+
+func TestBox_Intersects(t *testing.T) {
+
+	box := Box{Min: [3]float64{0, 0, 0}, Max: [3]float64{10, 10, 10}}
+
+	var cases = map[string]struct {
+		from [3]float64
+		to   [3]float64
+		want bool
+	}{
+		"through":       {from: [3]float64{-5, 5, 5}, to: [3]float64{15, 5, 5}, want: true},
+		"inside":        {from: [3]float64{2, 2, 2}, to: [3]float64{3, 3, 3}, want: true},
+		"outside":       {from: [3]float64{-5, 15, 5}, to: [3]float64{15, 15, 5}, want: false},
+		"on the border": {from: [3]float64{-5, 10, 5}, to: [3]float64{15, 10, 5}, want: false},
+		"diagonal":      {from: [3]float64{-5, 6, 5}, to: [3]float64{6, -5, 5}, want: true},
+		"short":         {from: [3]float64{-5, 5, 5}, to: [3]float64{-1, 5, 5}, want: false},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := box.Intersects(tc.from, tc.to); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
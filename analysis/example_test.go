@@ -0,0 +1,69 @@
+package analysis_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/analysis"
+	"github.com/mauroalderete/gcode-core/feature"
+)
+
+func ExampleAnalyze() {
+
+	gcode := `M83
+;TYPE:External perimeter
+G1 X100 E3.3 F1200
+G1 E-0.8
+G1 X0 Y0 F9000
+G1 E0.8
+;TYPE:Solid infill
+G1 X100 Y100 E5 F1800
+`
+
+	s, err := analysis.Analyze(strings.NewReader(gcode), func(config analysis.AnalyzerConfigurer) error {
+		return config.SetFilaments(analysis.Filament{Diameter: 1.75, Density: 1.27})
+	})
+	if err != nil {
+		fmt.Printf("failed to analyze: %v", err)
+		return
+	}
+
+	used := s.Total()
+	fmt.Printf("%.1fmm, %.1fmm³, %.3fg\n", used.Length, used.Volume, used.Weight)
+	fmt.Printf("%d retractions of %.1fmm\n", s.Retractions, s.RetractLength)
+	fmt.Printf("max flow of the outer walls %.1fmm³/s\n", s.MaxFlow[feature.FEATURE_OUTER_WALL])
+
+	// Output:
+	// 8.3mm, 20.0mm³, 0.025g
+	// 1 retractions of 0.8mm
+	// max flow of the outer walls 1.6mm³/s
+}
+
+func ExampleMeasure() {
+
+	gcode := `G28
+G1 Z0.3 F600
+G1 X20 Y20 F9000
+G1 X120 Y20 E5 F1800
+G1 X120 Y-2 E6
+`
+
+	envelope := analysis.Envelope{
+		Volume: analysis.Box{Max: [3]float64{250, 210, 200}},
+	}
+
+	bounds, violation, err := analysis.Measure(strings.NewReader(gcode), func(config analysis.ToolpathConfigurer) error {
+		return config.SetEnvelope(envelope)
+	})
+	if err != nil {
+		fmt.Printf("failed to measure: %v", err)
+		return
+	}
+
+	fmt.Printf("extruding from %v to %v\n", bounds.Extruding.Min, bounds.Extruding.Max)
+	fmt.Println(violation)
+
+	// Output:
+	// extruding from [20 -2 0.3] to [120 20 0.3]
+	// line 4 'G1 X120 Y-2 E6': position X120.000 Y-2.000 Z0.300 is out of the volume of the machine
+}
@@ -0,0 +1,169 @@
+// bgcode package reads and writes files in the Prusa binary G-code format (.bgcode).
+//
+// A bgcode file starts with a file header that contains a magic number, the version of the format
+// and the kind of checksum used to protect each block.
+// Then there is a sequence of blocks. Each block has a header with his type, his compression and his size,
+// a few parameters that depend on the type, the data and, optionally, a CRC32 checksum.
+//
+// The blocks are written in order: file metadata, printer metadata, thumbnails, print metadata,
+// slicer metadata and, at the end, the gcode split in one or more gcode blocks.
+//
+// The Reader decodes the blocks that precede the gcode into a Header struct and exposes the gcode as an io.Reader,
+// so it can be read line by line with a gcodeblock.Scanner to get a stream of block.Blocker instances.
+//
+// The Writer does the opposite, it receives a Header and a stream of blocks or lines, and produces a bgcode file.
+//
+// For more information about the format visit [libbgcode]
+//
+// [libbgcode]: https://github.com/prusa3d/libbgcode/blob/main/doc/specifications.md
+package bgcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// MAGIC is the first four bytes of any bgcode file
+	MAGIC = "GCDE"
+
+	// VERSION is the version of the format supported
+	VERSION = 1
+
+	// MAX_GCODE_BLOCK_SIZE is the maximum size in bytes of uncompressed gcode stored in a single gcode block
+	MAX_GCODE_BLOCK_SIZE = 65536
+
+	// MAX_BLOCK_SIZE is the maximum size in bytes of the data of a block accepted by the Reader, compressed or not.
+	// The sizes are read from the header of each block, the larger ones are rejected before allocating any memory for them.
+	MAX_BLOCK_SIZE = 64 << 20
+)
+
+//#region types
+
+// ChecksumType identifies the algorithm used to verify the integrity of each block.
+type ChecksumType uint16
+
+const (
+	CHECKSUM_NONE ChecksumType = iota
+	CHECKSUM_CRC32
+)
+
+// BlockType identifies the content of a block.
+type BlockType uint16
+
+const (
+	BLOCK_FILE_METADATA BlockType = iota
+	BLOCK_GCODE
+	BLOCK_SLICER_METADATA
+	BLOCK_PRINTER_METADATA
+	BLOCK_PRINT_METADATA
+	BLOCK_THUMBNAIL
+)
+
+// Compression identifies the algorithm used to compress the data of a block.
+type Compression uint16
+
+const (
+	COMPRESSION_NONE Compression = iota
+	COMPRESSION_DEFLATE
+	COMPRESSION_HEATSHRINK_11_4
+	COMPRESSION_HEATSHRINK_12_4
+)
+
+// Encoding identifies how the gcode text is encoded before it is compressed.
+//
+// The MeatPack encodings are implemented by the meatpack package.
+type Encoding uint16
+
+const (
+	ENCODING_NONE Encoding = iota
+	ENCODING_MEATPACK
+	ENCODING_MEATPACK_COMMENTS
+)
+
+// ThumbnailFormat identifies the image format of a thumbnail.
+type ThumbnailFormat uint16
+
+const (
+	THUMBNAIL_PNG ThumbnailFormat = iota
+	THUMBNAIL_JPG
+	THUMBNAIL_QOI
+)
+
+//#endregion
+//#region metadata
+
+// MetadataEntry is a single key and value pair of a metadata block.
+type MetadataEntry struct {
+	Key   string
+	Value string
+}
+
+// Metadata is the ordered list of key and value pairs stored in a metadata block.
+type Metadata []MetadataEntry
+
+// Value returns the value stored with the key and true, or an empty string and false if the key doesn't exist.
+func (m Metadata) Value(key string) (string, bool) {
+	for _, e := range m {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return "", false
+}
+
+// encode returns the metadata in the INI encoding, one "key=value" pair per line.
+func (m Metadata) encode() []byte {
+	var sb strings.Builder
+	for _, e := range m {
+		sb.WriteString(e.Key)
+		sb.WriteByte('=')
+		sb.WriteString(e.Value)
+		sb.WriteByte('\n')
+	}
+	return []byte(sb.String())
+}
+
+// decodeMetadata parses the INI encoding of a metadata block.
+func decodeMetadata(data []byte) (Metadata, error) {
+
+	var m Metadata
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("metadata line '%s' hasn't the format key=value", line)
+		}
+
+		m = append(m, MetadataEntry{Key: key, Value: value})
+	}
+
+	return m, nil
+}
+
+//#endregion
+//#region header
+
+// Thumbnail is an image stored in a thumbnail block, as is, without decoding.
+type Thumbnail struct {
+	Format ThumbnailFormat
+	Width  uint16
+	Height uint16
+	Data   []byte
+}
+
+// Header groups the content of all blocks of a bgcode file that aren't gcode blocks.
+type Header struct {
+	FileMetadata    Metadata
+	PrinterMetadata Metadata
+	PrintMetadata   Metadata
+	SlicerMetadata  Metadata
+	Thumbnails      []Thumbnail
+}
+
+//#endregion
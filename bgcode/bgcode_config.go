@@ -0,0 +1,100 @@
+// This file defines a writerConfigurator as an object that implement the WriterConfigurer
+// interface to allow the caller to configure a new Writer.
+package bgcode
+
+import "fmt"
+
+// WriterConfigurer contains the configurable options that define how a Writer encodes a bgcode file.
+type WriterConfigurer interface {
+	// Set the kind of checksum appended to each block
+	SetChecksumType(checksumType ChecksumType) error
+
+	// Set the compression used by the metadata blocks
+	SetMetadataCompression(compression Compression) error
+
+	// Set the compression used by the gcode blocks
+	SetGcodeCompression(compression Compression) error
+
+	// Set the encoding applied to the gcode text before it is compressed
+	SetGcodeEncoding(encoding Encoding) error
+
+	// Set the format used to export each block as a line, using the verbs of the block.Blocker ToLine method
+	SetBlockFormat(format string) error
+}
+
+// WriterConfigurationCallbackable is the signature of the callbacks that the NewWriter constructor waiting receives to configure the new Writer instance.
+type WriterConfigurationCallbackable func(config WriterConfigurer) error
+
+// writerConfigurator satisfy WriterConfigurer, it applies each option directly over the Writer in construction.
+type writerConfigurator struct {
+	writer *Writer
+}
+
+// SetChecksumType sets the checksum appended to each block. By default is CHECKSUM_CRC32.
+func (wc *writerConfigurator) SetChecksumType(checksumType ChecksumType) error {
+
+	if checksumType != CHECKSUM_NONE && checksumType != CHECKSUM_CRC32 {
+		return fmt.Errorf("failed set checksum type, %d isn't supported", checksumType)
+	}
+
+	wc.writer.checksumType = checksumType
+
+	return nil
+}
+
+// SetMetadataCompression sets the compression of the metadata blocks. By default is COMPRESSION_NONE.
+func (wc *writerConfigurator) SetMetadataCompression(compression Compression) error {
+
+	if !compression.isValid() {
+		return fmt.Errorf("failed set metadata compression, %d isn't supported", compression)
+	}
+
+	wc.writer.metadataCompression = compression
+
+	return nil
+}
+
+// SetGcodeCompression sets the compression of the gcode blocks. By default is COMPRESSION_HEATSHRINK_12_4.
+func (wc *writerConfigurator) SetGcodeCompression(compression Compression) error {
+
+	if !compression.isValid() {
+		return fmt.Errorf("failed set gcode compression, %d isn't supported", compression)
+	}
+
+	wc.writer.gcodeCompression = compression
+
+	return nil
+}
+
+// SetGcodeEncoding sets the encoding of the gcode text. By default is ENCODING_NONE.
+//
+// The MeatPack encodings aren't lossless, the empty lines and the spaces between the words are removed,
+// and ENCODING_MEATPACK removes the comments too.
+func (wc *writerConfigurator) SetGcodeEncoding(encoding Encoding) error {
+
+	if encoding > ENCODING_MEATPACK_COMMENTS {
+		return fmt.Errorf("failed set gcode encoding, %d isn't supported", encoding)
+	}
+
+	wc.writer.gcodeEncoding = encoding
+
+	return nil
+}
+
+// SetBlockFormat sets the format used to export each block as a line. It doesn't accept an empty string.
+// By default is BLOCK_FORMAT.
+func (wc *writerConfigurator) SetBlockFormat(format string) error {
+
+	if format == "" {
+		return fmt.Errorf("failed set block format, it mustn't be empty")
+	}
+
+	wc.writer.blockFormat = format
+
+	return nil
+}
+
+// isValid indicates if the compression is one of the supported
+func (c Compression) isValid() bool {
+	return c <= COMPRESSION_HEATSHRINK_12_4
+}
@@ -0,0 +1,303 @@
+// This file defines the Reader that decodes a bgcode file.
+package bgcode
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/mauroalderete/gcode-core/bgcode/internal/heatshrink"
+	"github.com/mauroalderete/gcode-core/meatpack"
+)
+
+//#region reader struct
+
+// Reader decodes a bgcode file.
+//
+// When it is constructed, it reads all blocks until the first gcode block and stores his content in the Header.
+// Then, the Read method returns the gcode text decoded from the successive gcode blocks.
+// The non gcode blocks found after the first gcode block are added to the Header too.
+type Reader struct {
+	// Header stores the metadata and thumbnails of the file
+	Header
+
+	// source is the bgcode stream
+	source io.Reader
+
+	// checksumType defines if each block is followed by a checksum
+	checksumType ChecksumType
+
+	// pending stores the gcode decoded that wasn't read yet
+	pending []byte
+
+	// err stores the first error found, including io.EOF
+	err error
+}
+
+// Read reads up to len(p) bytes of gcode text into p.
+//
+// It returns io.EOF when all gcode blocks were read.
+func (r *Reader) Read(p []byte) (int, error) {
+
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.next()
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+
+	return n, nil
+}
+
+// next reads blocks until it finds a gcode block or the end of the file.
+func (r *Reader) next() error {
+
+	for {
+		blockType, params, data, err := r.readBlock()
+		if err != nil {
+			return err
+		}
+
+		if blockType != BLOCK_GCODE {
+			err = r.Header.add(blockType, params, data)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		gcode, err := decodeGcode(Encoding(binary.LittleEndian.Uint16(params)), data)
+		if err != nil {
+			return err
+		}
+
+		r.pending = gcode
+
+		return nil
+	}
+}
+
+// readBlock reads a single block, verifies his checksum and returns his parameters and data uncompressed.
+//
+// It returns io.EOF if there aren't more blocks.
+func (r *Reader) readBlock() (BlockType, []byte, []byte, error) {
+
+	raw := &bytes.Buffer{}
+	source := io.TeeReader(r.source, raw)
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(source, header); err != nil {
+		if err == io.EOF {
+			return 0, nil, nil, io.EOF
+		}
+		return 0, nil, nil, fmt.Errorf("failed to read block header: %w", err)
+	}
+
+	blockType := BlockType(binary.LittleEndian.Uint16(header[0:]))
+	compression := Compression(binary.LittleEndian.Uint16(header[2:]))
+	uncompressedSize := binary.LittleEndian.Uint32(header[4:])
+	size := uncompressedSize
+
+	if compression != COMPRESSION_NONE {
+		compressedSize := make([]byte, 4)
+		if _, err := io.ReadFull(source, compressedSize); err != nil {
+			return 0, nil, nil, fmt.Errorf("failed to read compressed size of block type %d: %w", blockType, err)
+		}
+		size = binary.LittleEndian.Uint32(compressedSize)
+	}
+
+	if uncompressedSize > MAX_BLOCK_SIZE || size > MAX_BLOCK_SIZE {
+		return 0, nil, nil, fmt.Errorf("block type %d is too large, %d bytes uncompressed and %d bytes stored, the maximum is %d", blockType, uncompressedSize, size, MAX_BLOCK_SIZE)
+	}
+
+	paramsSize, err := blockType.paramsSize()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	params := make([]byte, paramsSize)
+	if _, err := io.ReadFull(source, params); err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to read parameters of block type %d: %w", blockType, err)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(source, int64(size)))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to read data of block type %d: %w", blockType, err)
+	}
+	if len(data) != int(size) {
+		return 0, nil, nil, fmt.Errorf("failed to read data of block type %d: %w", blockType, io.ErrUnexpectedEOF)
+	}
+
+	if r.checksumType == CHECKSUM_CRC32 {
+		checksum := make([]byte, 4)
+		if _, err := io.ReadFull(r.source, checksum); err != nil {
+			return 0, nil, nil, fmt.Errorf("failed to read checksum of block type %d: %w", blockType, err)
+		}
+
+		if want, got := binary.LittleEndian.Uint32(checksum), crc32.ChecksumIEEE(raw.Bytes()); want != got {
+			return 0, nil, nil, fmt.Errorf("block type %d is corrupted, checksum is %08x, want %08x", blockType, got, want)
+		}
+	}
+
+	data, err = decompress(compression, data, int(uncompressedSize))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to decompress block type %d: %w", blockType, err)
+	}
+
+	if len(data) != int(uncompressedSize) {
+		return 0, nil, nil, fmt.Errorf("block type %d has %d bytes uncompressed, want %d", blockType, len(data), uncompressedSize)
+	}
+
+	return blockType, params, data, nil
+}
+
+//#endregion
+//#region constructor
+
+// NewReader returns a new Reader that decodes the bgcode file from r.
+//
+// It reads the file header and all blocks that precede the first gcode block.
+// It returns an error if r isn't a bgcode file or if some of these blocks are invalid.
+func NewReader(r io.Reader) (*Reader, error) {
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read file header: %w", err)
+	}
+
+	if string(header[0:4]) != MAGIC {
+		return nil, fmt.Errorf("file hasn't the bgcode magic number, got %q", header[0:4])
+	}
+
+	if version := binary.LittleEndian.Uint32(header[4:]); version != VERSION {
+		return nil, fmt.Errorf("bgcode version %d isn't supported", version)
+	}
+
+	checksumType := ChecksumType(binary.LittleEndian.Uint16(header[8:]))
+	if checksumType != CHECKSUM_NONE && checksumType != CHECKSUM_CRC32 {
+		return nil, fmt.Errorf("bgcode checksum type %d isn't supported", checksumType)
+	}
+
+	reader := &Reader{
+		source:       r,
+		checksumType: checksumType,
+	}
+
+	reader.err = reader.next()
+	if reader.err != nil && reader.err != io.EOF {
+		return nil, reader.err
+	}
+
+	return reader, nil
+}
+
+//#endregion
+//#region private functions
+
+// paramsSize returns the size in bytes of the parameters of each type of block.
+func (t BlockType) paramsSize() (int, error) {
+	switch t {
+	case BLOCK_FILE_METADATA, BLOCK_GCODE, BLOCK_SLICER_METADATA, BLOCK_PRINTER_METADATA, BLOCK_PRINT_METADATA:
+		return 2, nil
+	case BLOCK_THUMBNAIL:
+		return 6, nil
+	}
+	return 0, fmt.Errorf("block type %d is unknown", t)
+}
+
+// add stores the content of a non gcode block in the header.
+func (h *Header) add(blockType BlockType, params []byte, data []byte) error {
+
+	if blockType == BLOCK_THUMBNAIL {
+		h.Thumbnails = append(h.Thumbnails, Thumbnail{
+			Format: ThumbnailFormat(binary.LittleEndian.Uint16(params[0:])),
+			Width:  binary.LittleEndian.Uint16(params[2:]),
+			Height: binary.LittleEndian.Uint16(params[4:]),
+			Data:   data,
+		})
+		return nil
+	}
+
+	if encoding := binary.LittleEndian.Uint16(params); encoding != 0 {
+		return fmt.Errorf("metadata encoding %d of block type %d isn't supported", encoding, blockType)
+	}
+
+	metadata, err := decodeMetadata(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode metadata of block type %d: %w", blockType, err)
+	}
+
+	switch blockType {
+	case BLOCK_FILE_METADATA:
+		h.FileMetadata = append(h.FileMetadata, metadata...)
+	case BLOCK_PRINTER_METADATA:
+		h.PrinterMetadata = append(h.PrinterMetadata, metadata...)
+	case BLOCK_PRINT_METADATA:
+		h.PrintMetadata = append(h.PrintMetadata, metadata...)
+	case BLOCK_SLICER_METADATA:
+		h.SlicerMetadata = append(h.SlicerMetadata, metadata...)
+	}
+
+	return nil
+}
+
+// decompress returns the data of a block uncompressed, size is the uncompressed size declared by the block.
+//
+// The output never grows far beyond size, so a block that lies about his size can't exhaust the memory.
+func decompress(compression Compression, data []byte, size int) ([]byte, error) {
+
+	switch compression {
+	case COMPRESSION_NONE:
+		return data, nil
+	case COMPRESSION_DEFLATE:
+		return inflate(data, size)
+	case COMPRESSION_HEATSHRINK_11_4:
+		return heatshrink.Decode(data, 11, 4, expansion(data, size))
+	case COMPRESSION_HEATSHRINK_12_4:
+		return heatshrink.Decode(data, 12, 4, expansion(data, size))
+	}
+
+	return nil, fmt.Errorf("compression %d isn't supported", compression)
+}
+
+// inflate decompresses data stored in the zlib format, reading up to a byte more than size to detect the longer data.
+func inflate(data []byte, size int) ([]byte, error) {
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return io.ReadAll(io.LimitReader(zr, int64(size)+1))
+}
+
+// expansion returns the size to preallocate for the heatshrink output, the declared size limited to the largest output of the data.
+// Each back reference of at least 16 bits copies up to 16 bytes, so the output isn't longer than 8 times the data.
+func expansion(data []byte, size int) int {
+	if limit := 8 * len(data); size > limit {
+		return limit
+	}
+	return size
+}
+
+// decodeGcode returns the gcode text of a gcode block.
+func decodeGcode(encoding Encoding, data []byte) ([]byte, error) {
+
+	switch encoding {
+	case ENCODING_NONE:
+		return data, nil
+	case ENCODING_MEATPACK, ENCODING_MEATPACK_COMMENTS:
+		return io.ReadAll(meatpack.NewDecoder(bytes.NewReader(data)))
+	}
+
+	return nil, fmt.Errorf("gcode encoding %d isn't supported", encoding)
+}
+
+//#endregion
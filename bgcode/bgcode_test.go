@@ -0,0 +1,381 @@
+package bgcode
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+)
+
+func mockHeader() *Header {
+	return &Header{
+		FileMetadata:    Metadata{{Key: "Producer", Value: "gcode-core"}},
+		PrinterMetadata: Metadata{{Key: "printer_model", Value: "MK4"}, {Key: "nozzle_diameter", Value: "0.4"}},
+		PrintMetadata:   Metadata{{Key: "estimated printing time (normal mode)", Value: "1h 2m 3s"}},
+		SlicerMetadata:  Metadata{{Key: "layer_height", Value: "0.2"}},
+		Thumbnails: []Thumbnail{
+			{Format: THUMBNAIL_PNG, Width: 16, Height: 16, Data: []byte("not really a png")},
+			{Format: THUMBNAIL_QOI, Width: 300, Height: 300, Data: []byte("not really a qoi")},
+		},
+	}
+}
+
+func TestFormat(t *testing.T) {
+
+	out := &bytes.Buffer{}
+
+	w, err := NewWriter(out, &Header{}, func(config WriterConfigurer) error {
+		return config.SetChecksumType(CHECKSUM_NONE)
+	}, func(config WriterConfigurer) error {
+		return config.SetGcodeCompression(COMPRESSION_NONE)
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if err := w.WriteLine("G28"); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	want := []byte{
+		'G', 'C', 'D', 'E', 1, 0, 0, 0, 0, 0, // file header without checksum
+		3, 0, 0, 0, 0, 0, 0, 0, 0, 0, // empty printer metadata
+		4, 0, 0, 0, 0, 0, 0, 0, 0, 0, // empty print metadata
+		2, 0, 0, 0, 0, 0, 0, 0, 0, 0, // empty slicer metadata
+		1, 0, 0, 0, 4, 0, 0, 0, 0, 0, 'G', '2', '8', '\n', // gcode
+	}
+
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("got %v, want %v", out.Bytes(), want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	var lines []string
+	for i := 0; i < 5000; i++ {
+		lines = append(lines, fmt.Sprintf(";LAYER:%d", i), fmt.Sprintf("G1 X%d.5 Y20.000 E0.4000", i%200), "")
+	}
+
+	compressions := []Compression{COMPRESSION_NONE, COMPRESSION_DEFLATE, COMPRESSION_HEATSHRINK_11_4, COMPRESSION_HEATSHRINK_12_4}
+
+	for _, checksumType := range []ChecksumType{CHECKSUM_NONE, CHECKSUM_CRC32} {
+		for _, compression := range compressions {
+			t.Run(fmt.Sprintf("checksum %d compression %d", checksumType, compression), func(t *testing.T) {
+
+				out := &bytes.Buffer{}
+
+				w, err := NewWriter(out, mockHeader(), func(config WriterConfigurer) error {
+					if err := config.SetChecksumType(checksumType); err != nil {
+						return err
+					}
+					if err := config.SetMetadataCompression(compression); err != nil {
+						return err
+					}
+					return config.SetGcodeCompression(compression)
+				})
+				if err != nil {
+					t.Fatalf("got error %v, want nil error", err)
+				}
+
+				for _, line := range lines {
+					if err := w.WriteLine(line); err != nil {
+						t.Fatalf("got error %v, want nil error", err)
+					}
+				}
+
+				if err := w.Close(); err != nil {
+					t.Fatalf("got error %v, want nil error", err)
+				}
+
+				r, err := NewReader(out)
+				if err != nil {
+					t.Fatalf("got error %v, want nil error", err)
+				}
+
+				if !reflect.DeepEqual(r.Header, *mockHeader()) {
+					t.Errorf("got header %v, want %v", r.Header, *mockHeader())
+				}
+
+				gcode, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatalf("got error %v, want nil error", err)
+				}
+
+				if want := strings.Join(lines, "\n") + "\n"; string(gcode) != want {
+					t.Errorf("got %d bytes of gcode, want %d bytes", len(gcode), len(want))
+				}
+			})
+		}
+	}
+}
+
+func TestMeatPackEncoding(t *testing.T) {
+
+	const source = "; header\nG28\n\nG1 X10.5 Y20 E0.4 ; extrude\nM104 S200\n"
+
+	var cases = map[Encoding]string{
+		ENCODING_MEATPACK:          "G28\nG1 X10.5 Y20 E0.4\nM104 S200\n",
+		ENCODING_MEATPACK_COMMENTS: "; header\nG28\nG1 X10.5 Y20 E0.4; extrude\nM104 S200\n",
+	}
+
+	for encoding, want := range cases {
+		t.Run(fmt.Sprintf("encoding %d", encoding), func(t *testing.T) {
+
+			out := &bytes.Buffer{}
+
+			w, err := NewWriter(out, nil, func(config WriterConfigurer) error {
+				return config.SetGcodeEncoding(encoding)
+			})
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			for _, line := range strings.Split(strings.TrimSuffix(source, "\n"), "\n") {
+				if err := w.WriteLine(line); err != nil {
+					t.Fatalf("got error %v, want nil error", err)
+				}
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			r, err := NewReader(out)
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			gcode, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			if string(gcode) != want {
+				t.Errorf("got %q, want %q", gcode, want)
+			}
+		})
+	}
+}
+
+func TestWriteBlock(t *testing.T) {
+
+	out := &bytes.Buffer{}
+
+	w, err := NewWriter(out, nil)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	for _, source := range []string{"N7 G1 X2.0 Y2.0 F3000.0*85", "N1 G28*18 ; home", "G28 ;home"} {
+		b, err := gcodeblock.Parse(source)
+		if err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+		if err := w.WriteBlock(b); err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+	}
+
+	if err := w.WriteBlock(nil); err == nil {
+		t.Errorf("got nil error writing a nil block, want error")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if err := w.WriteLine("G28"); err == nil {
+		t.Errorf("got nil error writing after close, want error")
+	}
+
+	r, err := NewReader(out)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	gcode, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	// the block without parameters has no space before the checksum
+	got := strings.Split(strings.TrimSuffix(string(gcode), "\n"), "\n")
+	want := []string{"N7 G1 X2.000 Y2.000 F3000.000*85", "N1 G28*18 ; home", "G28  ;home"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+
+	valid := &bytes.Buffer{}
+	w, err := NewWriter(valid, mockHeader())
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+	w.WriteLine("G28")
+	w.Close()
+
+	corrupted := append([]byte{}, valid.Bytes()...)
+	corrupted[20] ^= 0xff
+
+	unknownVersion := append([]byte{}, valid.Bytes()...)
+	unknownVersion[4] = 2
+
+	// a heatshrink block of 4 bytes that declares 4 GiB uncompressed
+	oversized := rawBlock(BLOCK_GCODE, COMPRESSION_HEATSHRINK_12_4, 0xffffffff, []byte{0, 0}, []byte{0xff, 0xff, 0xff, 0xff})
+
+	// a deflate block that inflates to 1 MiB but declares 16 bytes
+	bomb := &bytes.Buffer{}
+	zw := zlib.NewWriter(bomb)
+	zw.Write(make([]byte, 1<<20))
+	zw.Close()
+	inflated := rawBlock(BLOCK_GCODE, COMPRESSION_DEFLATE, 16, []byte{0, 0}, bomb.Bytes())
+
+	var cases = map[string][]byte{
+		"empty":           {},
+		"magic":           []byte("GCODE G28\n"),
+		"version":         unknownVersion,
+		"corrupted block": corrupted,
+		"truncated":       valid.Bytes()[:len(valid.Bytes())-30],
+		"oversized block": oversized,
+		"inflated block":  inflated,
+	}
+
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(data))
+			if err == nil {
+				_, err = io.ReadAll(r)
+			}
+			if err == nil {
+				t.Errorf("got nil error, want error")
+			}
+		})
+	}
+}
+
+// rawBlock returns a file without checksums that contains a single block, with any declared uncompressed size
+func rawBlock(blockType BlockType, compression Compression, uncompressedSize uint32, params []byte, data []byte) []byte {
+
+	file := &bytes.Buffer{}
+	file.WriteString(MAGIC)
+	binary.Write(file, binary.LittleEndian, uint32(VERSION))
+	binary.Write(file, binary.LittleEndian, CHECKSUM_NONE)
+
+	binary.Write(file, binary.LittleEndian, blockType)
+	binary.Write(file, binary.LittleEndian, compression)
+	binary.Write(file, binary.LittleEndian, uncompressedSize)
+	if compression != COMPRESSION_NONE {
+		binary.Write(file, binary.LittleEndian, uint32(len(data)))
+	}
+	file.Write(params)
+	file.Write(data)
+
+	return file.Bytes()
+}
+
+func TestWriterConfigurationErrors(t *testing.T) {
+
+	var cases = map[string]WriterConfigurationCallbackable{
+		"checksum type": func(config WriterConfigurer) error {
+			return config.SetChecksumType(7)
+		},
+		"metadata compression": func(config WriterConfigurer) error {
+			return config.SetMetadataCompression(7)
+		},
+		"gcode compression": func(config WriterConfigurer) error {
+			return config.SetGcodeCompression(7)
+		},
+		"gcode encoding": func(config WriterConfigurer) error {
+			return config.SetGcodeEncoding(7)
+		},
+		"block format": func(config WriterConfigurer) error {
+			return config.SetBlockFormat("")
+		},
+	}
+
+	for name, option := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewWriter(&bytes.Buffer{}, nil, option); err == nil {
+				t.Errorf("got nil error, want error")
+			}
+		})
+	}
+}
+
+func TestMetadata_Value(t *testing.T) {
+
+	m := mockHeader().PrinterMetadata
+
+	if v, ok := m.Value("nozzle_diameter"); !ok || v != "0.4" {
+		t.Errorf("got %s %v, want 0.4 true", v, ok)
+	}
+
+	if v, ok := m.Value("unknown"); ok || v != "" {
+		t.Errorf("got %s %v, want empty false", v, ok)
+	}
+}
+
+// TestReaderFixtures decodes each file of testdata and compares his gcode with the file of the same name and the .gcode extension.
+//
+// spec_deflate.bgcode wasn't written by the Writer, it was assembled byte by byte from the specification of libbgcode,
+// with the blocks in the order of PrusaSlicer, deflate and uncompressed blocks, a PNG thumbnail and CRC32 checksums.
+// The files produced by the slicers can be added the same way.
+func TestReaderFixtures(t *testing.T) {
+
+	files, err := filepath.Glob(filepath.Join("testdata", "*.bgcode"))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("got no fixtures, want at least one")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+			want, err := os.ReadFile(strings.TrimSuffix(file, ".bgcode") + ".gcode")
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			r, err := NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			if _, ok := r.PrinterMetadata.Value("printer_model"); !ok {
+				t.Errorf("got printer metadata %v, want a printer_model", r.PrinterMetadata)
+			}
+			if len(r.Thumbnails) == 0 {
+				t.Errorf("got no thumbnails, want at least one")
+			}
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("got gcode %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+// This file defines the Writer that encodes a bgcode file.
+package bgcode
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/bgcode/internal/heatshrink"
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/meatpack"
+)
+
+const (
+	// BLOCK_FORMAT is the format used by default to export each block as a line.
+	BLOCK_FORMAT = "%l %c %p%k%m"
+)
+
+//#region writer struct
+
+// Writer encodes a bgcode file.
+//
+// The metadata and thumbnails are written when the Writer is constructed.
+// Then, the gcode is received line by line or block by block, and it is written in gcode blocks of MAX_GCODE_BLOCK_SIZE bytes at most.
+// The Close method must be called to write the last gcode block.
+type Writer struct {
+	// destination is the bgcode stream
+	destination io.Writer
+
+	// checksumType defines if each block is followed by a checksum
+	checksumType ChecksumType
+
+	// metadataCompression is the compression of the metadata blocks
+	metadataCompression Compression
+
+	// gcodeCompression is the compression of the gcode blocks
+	gcodeCompression Compression
+
+	// gcodeEncoding is the encoding of the gcode text
+	gcodeEncoding Encoding
+
+	// blockFormat is the format used to export each block as a line
+	blockFormat string
+
+	// pending stores the gcode text that wasn't written yet
+	pending bytes.Buffer
+
+	// closed indicates if the Close method was called
+	closed bool
+}
+
+// WriteBlock writes the block as a single line of gcode, using the block format configured.
+// The spaces before the checksum are removed, the firmware would include them in the checksum.
+func (w *Writer) WriteBlock(b block.Blocker) error {
+
+	if b == nil {
+		return fmt.Errorf("failed to write block, it mustn't be nil")
+	}
+
+	return w.WriteLine(line(b, w.blockFormat))
+}
+
+// WriteLine writes a single line of gcode. It can be an empty line or a comment-only line.
+//
+// The line mustn't contain the end of line character, it is added by the Writer.
+func (w *Writer) WriteLine(line string) error {
+
+	if w.closed {
+		return fmt.Errorf("failed to write line, the writer is closed")
+	}
+
+	if w.pending.Len() > 0 && w.pending.Len()+len(line)+1 > MAX_GCODE_BLOCK_SIZE {
+		err := w.flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	w.pending.WriteString(line)
+	w.pending.WriteByte('\n')
+
+	return nil
+}
+
+// Close writes the gcode pending. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+
+	if w.closed {
+		return nil
+	}
+
+	err := w.flush()
+	if err != nil {
+		return err
+	}
+
+	w.closed = true
+
+	return nil
+}
+
+// flush writes the gcode pending as a new gcode block
+func (w *Writer) flush() error {
+
+	if w.pending.Len() == 0 {
+		return nil
+	}
+
+	data, err := encodeGcode(w.gcodeEncoding, w.pending.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to encode gcode block: %w", err)
+	}
+
+	err = w.writeBlock(BLOCK_GCODE, w.gcodeCompression, params(uint16(w.gcodeEncoding)), data)
+	if err != nil {
+		return err
+	}
+
+	w.pending.Reset()
+
+	return nil
+}
+
+// writeHeader writes the metadata and thumbnails blocks in the order defined by the specification.
+//
+// The printer, print and slicer metadata blocks are always written, even if they are empty, because they are mandatory.
+func (w *Writer) writeHeader(header *Header) error {
+
+	if len(header.FileMetadata) > 0 {
+		err := w.writeBlock(BLOCK_FILE_METADATA, w.metadataCompression, params(0), header.FileMetadata.encode())
+		if err != nil {
+			return err
+		}
+	}
+
+	err := w.writeBlock(BLOCK_PRINTER_METADATA, w.metadataCompression, params(0), header.PrinterMetadata.encode())
+	if err != nil {
+		return err
+	}
+
+	for _, t := range header.Thumbnails {
+		err := w.writeBlock(BLOCK_THUMBNAIL, COMPRESSION_NONE, params(uint16(t.Format), t.Width, t.Height), t.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = w.writeBlock(BLOCK_PRINT_METADATA, w.metadataCompression, params(0), header.PrintMetadata.encode())
+	if err != nil {
+		return err
+	}
+
+	err = w.writeBlock(BLOCK_SLICER_METADATA, w.metadataCompression, params(0), header.SlicerMetadata.encode())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeBlock compresses the data and writes a complete block, including his checksum if it is required.
+func (w *Writer) writeBlock(blockType BlockType, compression Compression, params []byte, data []byte) error {
+
+	compressed, err := compress(compression, data)
+	if err != nil {
+		return fmt.Errorf("failed to compress block type %d: %w", blockType, err)
+	}
+
+	raw := &bytes.Buffer{}
+	binary.Write(raw, binary.LittleEndian, uint16(blockType))
+	binary.Write(raw, binary.LittleEndian, uint16(compression))
+	binary.Write(raw, binary.LittleEndian, uint32(len(data)))
+	if compression != COMPRESSION_NONE {
+		binary.Write(raw, binary.LittleEndian, uint32(len(compressed)))
+	}
+	raw.Write(params)
+	raw.Write(compressed)
+
+	if w.checksumType == CHECKSUM_CRC32 {
+		binary.Write(raw, binary.LittleEndian, crc32.ChecksumIEEE(raw.Bytes()))
+	}
+
+	_, err = w.destination.Write(raw.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to write block type %d: %w", blockType, err)
+	}
+
+	return nil
+}
+
+//#endregion
+//#region constructor
+
+// NewWriter returns a new Writer that encodes a bgcode file into w.
+//
+// It writes immediately the file header and the blocks with the content of header, that can be nil.
+// options are a series of configuration callbacks to allow set different aspects of the encoding.
+func NewWriter(w io.Writer, header *Header, options ...WriterConfigurationCallbackable) (*Writer, error) {
+
+	if w == nil {
+		return nil, fmt.Errorf("failed to create a bgcode writer, destination mustn't be nil")
+	}
+
+	if header == nil {
+		header = &Header{}
+	}
+
+	writer := &Writer{
+		destination:         w,
+		checksumType:        CHECKSUM_CRC32,
+		metadataCompression: COMPRESSION_NONE,
+		gcodeCompression:    COMPRESSION_HEATSHRINK_12_4,
+		gcodeEncoding:       ENCODING_NONE,
+		blockFormat:         BLOCK_FORMAT,
+	}
+
+	configurator := &writerConfigurator{writer: writer}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	fileHeader := &bytes.Buffer{}
+	fileHeader.WriteString(MAGIC)
+	binary.Write(fileHeader, binary.LittleEndian, uint32(VERSION))
+	binary.Write(fileHeader, binary.LittleEndian, uint16(writer.checksumType))
+
+	_, err := w.Write(fileHeader.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to write file header: %w", err)
+	}
+
+	err = writer.writeHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+//#endregion
+//#region private functions
+
+// line exports the block with the format, the checksum follows the previous part of the line without spaces
+func line(b block.Blocker, format string) string {
+
+	before, after, found := strings.Cut(format, "%k")
+	if !found || b.Checksum() == nil {
+		return b.ToLine(format)
+	}
+
+	return b.ToLine(before) + b.ToLine("%k"+after)
+}
+
+// params returns the values encoded as the parameters of a block
+func params(values ...uint16) []byte {
+	out := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint16(out[2*i:], v)
+	}
+	return out
+}
+
+// compress returns the data of a block compressed.
+func compress(compression Compression, data []byte) ([]byte, error) {
+
+	switch compression {
+	case COMPRESSION_NONE:
+		return data, nil
+	case COMPRESSION_DEFLATE:
+		return deflate(data)
+	case COMPRESSION_HEATSHRINK_11_4:
+		return heatshrink.Encode(data, 11, 4)
+	case COMPRESSION_HEATSHRINK_12_4:
+		return heatshrink.Encode(data, 12, 4)
+	}
+
+	return nil, fmt.Errorf("compression %d isn't supported", compression)
+}
+
+// deflate compresses data in the zlib format.
+func deflate(data []byte) ([]byte, error) {
+
+	out := &bytes.Buffer{}
+
+	zw := zlib.NewWriter(out)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// encodeGcode returns the gcode text encoded to be stored in a gcode block.
+//
+// Both MeatPack encodings enable the no-spaces mode, ENCODING_MEATPACK removes the comments too.
+func encodeGcode(encoding Encoding, gcode []byte) ([]byte, error) {
+
+	switch encoding {
+	case ENCODING_NONE:
+		return gcode, nil
+	case ENCODING_MEATPACK, ENCODING_MEATPACK_COMMENTS:
+		out := &bytes.Buffer{}
+
+		e, err := meatpack.NewEncoder(out, func(config meatpack.EncoderConfigurer) error {
+			if err := config.SetNoSpaces(true); err != nil {
+				return err
+			}
+			return config.SetKeepComments(encoding == ENCODING_MEATPACK_COMMENTS)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := e.Write(gcode); err != nil {
+			return nil, err
+		}
+
+		if err := e.Close(); err != nil {
+			return nil, err
+		}
+
+		return out.Bytes(), nil
+	}
+
+	return nil, fmt.Errorf("gcode encoding %d isn't supported", encoding)
+}
+
+//#endregion
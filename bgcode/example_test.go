@@ -0,0 +1,67 @@
+package bgcode_test
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mauroalderete/gcode-core/bgcode"
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+)
+
+func Example() {
+
+	file := &bytes.Buffer{}
+
+	header := &bgcode.Header{
+		PrinterMetadata: bgcode.Metadata{{Key: "printer_model", Value: "MK4"}},
+	}
+
+	// encode a bgcode file with his gcode compressed using heatshrink
+	w, err := bgcode.NewWriter(file, header, func(config bgcode.WriterConfigurer) error {
+		return config.SetGcodeCompression(bgcode.COMPRESSION_HEATSHRINK_11_4)
+	})
+	if err != nil {
+		fmt.Printf("failed to create the writer: %v", err)
+		return
+	}
+
+	w.WriteLine(";LAYER_CHANGE")
+	for _, source := range []string{"G28", "G1 X2.0 Y2.0 F3000.0"} {
+		b, err := gcodeblock.Parse(source)
+		if err != nil {
+			fmt.Printf("failed to parse %s: %v", source, err)
+			return
+		}
+		w.WriteBlock(b)
+	}
+
+	if err := w.Close(); err != nil {
+		fmt.Printf("failed to close the writer: %v", err)
+		return
+	}
+
+	// decode the bgcode file and scan his gcode
+	r, err := bgcode.NewReader(file)
+	if err != nil {
+		fmt.Printf("failed to create the reader: %v", err)
+		return
+	}
+
+	model, _ := r.PrinterMetadata.Value("printer_model")
+	fmt.Printf("printer model: %s\n", model)
+
+	s := gcodeblock.NewScanner(r)
+	for s.Scan() {
+		if s.Block() == nil {
+			fmt.Printf("line %d: %s\n", s.Line(), s.Text())
+			continue
+		}
+		fmt.Printf("line %d: block %s\n", s.Line(), s.Block())
+	}
+
+	// Output:
+	// printer model: MK4
+	// line 1: ;LAYER_CHANGE
+	// line 2: block G28
+	// line 3: block G1 X2.000 Y2.000 F3000.000
+}
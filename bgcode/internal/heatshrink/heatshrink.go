@@ -0,0 +1,218 @@
+// heatshrink package implements the LZSS based compression used by the [heatshrink] library.
+//
+// This package is only to internal use by bgcode package.
+//
+// The compressed stream is a sequence of bits written most significant bit first.
+// Each item starts with a tag bit, 1 means a literal byte that follows in the next 8 bits,
+// 0 means a back reference composed by an index of window bits and a count of lookahead bits.
+// The back reference copies count+1 bytes starting index+1 bytes behind the current output position.
+//
+// [heatshrink]: https://github.com/atomicobject/heatshrink
+package heatshrink
+
+import (
+	"fmt"
+)
+
+const (
+	// MIN_WINDOW and MAX_WINDOW define the range of window sizes, expressed in bits, supported.
+	MIN_WINDOW = 4
+	MAX_WINDOW = 15
+
+	// maxChainLength limits the number of candidates evaluated by the encoder at each position
+	maxChainLength = 128
+)
+
+//#region package functions
+
+// Encode compresses src using a window of 2^window bytes and a lookahead of 2^lookahead bytes.
+func Encode(src []byte, window uint8, lookahead uint8) ([]byte, error) {
+
+	if err := validateParameters(window, lookahead); err != nil {
+		return nil, err
+	}
+
+	maxOffset := 1 << window
+	maxLength := 1 << lookahead
+
+	// a back reference is only emitted when it costs fewer bits than the literals it replaces
+	breakEven := (1 + int(window) + int(lookahead)) / 9
+
+	w := &bitWriter{}
+
+	// head and prev are hash chains indexed by the first two bytes of each position
+	head := make(map[uint16]int)
+	prev := make([]int, len(src))
+
+	insert := func(i int) {
+		if i+1 >= len(src) {
+			return
+		}
+		key := uint16(src[i])<<8 | uint16(src[i+1])
+		if p, ok := head[key]; ok {
+			prev[i] = p
+		} else {
+			prev[i] = -1
+		}
+		head[key] = i
+	}
+
+	for i := 0; i < len(src); {
+
+		bestLength, bestOffset := 0, 0
+
+		if i+1 < len(src) {
+			key := uint16(src[i])<<8 | uint16(src[i+1])
+			candidate, ok := head[key]
+			for chain := 0; ok && candidate >= 0 && i-candidate <= maxOffset && chain < maxChainLength; chain++ {
+				length := 0
+				for length < maxLength && i+length < len(src) && src[candidate+length] == src[i+length] {
+					length++
+				}
+				if length > bestLength {
+					bestLength, bestOffset = length, i-candidate
+					if length == maxLength {
+						break
+					}
+				}
+				candidate = prev[candidate]
+			}
+		}
+
+		if bestLength > breakEven {
+			w.write(0, 1)
+			w.write(uint32(bestOffset-1), window)
+			w.write(uint32(bestLength-1), lookahead)
+		} else {
+			bestLength = 1
+			w.write(1, 1)
+			w.write(uint32(src[i]), 8)
+		}
+
+		for j := 0; j < bestLength; j++ {
+			insert(i + j)
+		}
+		i += bestLength
+	}
+
+	return w.bytes(), nil
+}
+
+// Decode decompresses src using a window of 2^window bytes and a lookahead of 2^lookahead bytes.
+//
+// sizeHint is used to preallocate the output, it can be zero if the size isn't known.
+func Decode(src []byte, window uint8, lookahead uint8, sizeHint int) ([]byte, error) {
+
+	if err := validateParameters(window, lookahead); err != nil {
+		return nil, err
+	}
+
+	r := &bitReader{data: src}
+	out := make([]byte, 0, sizeHint)
+
+	for {
+		tag, ok := r.read(1)
+		if !ok {
+			break
+		}
+
+		if tag == 1 {
+			literal, ok := r.read(8)
+			if !ok {
+				break
+			}
+			out = append(out, byte(literal))
+			continue
+		}
+
+		index, ok := r.read(window)
+		if !ok {
+			break
+		}
+		count, ok := r.read(lookahead)
+		if !ok {
+			break
+		}
+
+		offset := int(index) + 1
+		if offset > len(out) {
+			return nil, fmt.Errorf("heatshrink back reference %d is out of the %d bytes decoded", offset, len(out))
+		}
+
+		start := len(out) - offset
+		for j := 0; j <= int(count); j++ {
+			out = append(out, out[start+j])
+		}
+	}
+
+	return out, nil
+}
+
+//#endregion
+//#region private functions
+
+// validateParameters returns an error if the window or lookahead sizes aren't supported
+func validateParameters(window uint8, lookahead uint8) error {
+
+	if window < MIN_WINDOW || window > MAX_WINDOW {
+		return fmt.Errorf("heatshrink window %d is out of range [%d, %d]", window, MIN_WINDOW, MAX_WINDOW)
+	}
+
+	if lookahead < 3 || lookahead >= window {
+		return fmt.Errorf("heatshrink lookahead %d is out of range [3, %d)", lookahead, window)
+	}
+
+	return nil
+}
+
+// bitWriter accumulates bits most significant bit first
+type bitWriter struct {
+	buffer  []byte
+	current byte
+	used    uint8
+}
+
+// write appends the count least significant bits of value
+func (w *bitWriter) write(value uint32, count uint8) {
+	for i := int(count) - 1; i >= 0; i-- {
+		w.current = w.current<<1 | byte(value>>uint(i)&1)
+		w.used++
+		if w.used == 8 {
+			w.buffer = append(w.buffer, w.current)
+			w.current, w.used = 0, 0
+		}
+	}
+}
+
+// bytes returns the bits written, the last byte is padded with zeros
+func (w *bitWriter) bytes() []byte {
+	if w.used > 0 {
+		return append(w.buffer, w.current<<(8-w.used))
+	}
+	return w.buffer
+}
+
+// bitReader consumes bits most significant bit first
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+// read returns the next count bits, or false if there aren't enough bits available
+func (r *bitReader) read(count uint8) (uint32, bool) {
+
+	if r.pos+int(count) > len(r.data)*8 {
+		return 0, false
+	}
+
+	var value uint32
+	for i := uint8(0); i < count; i++ {
+		bit := r.data[r.pos/8] >> (7 - uint(r.pos%8)) & 1
+		value = value<<1 | uint32(bit)
+		r.pos++
+	}
+
+	return value, true
+}
+
+//#endregion
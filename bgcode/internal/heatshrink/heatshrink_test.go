@@ -0,0 +1,101 @@
+package heatshrink
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// bits converts a string of '0' and '1' chars into bytes, padding the last byte with zeros
+func bits(s string) []byte {
+	s = strings.ReplaceAll(s, " ", "")
+	out := make([]byte, (len(s)+7)/8)
+	for i, c := range s {
+		if c == '1' {
+			out[i/8] |= 1 << (7 - uint(i%8))
+		}
+	}
+	return out
+}
+
+func TestKnownStream(t *testing.T) {
+
+	// literal 'a', literal 'b', back reference of 2 bytes at offset 2, with window 8 and lookahead 4
+	compressed := bits("1 01100001 1 01100010 0 00000001 0001")
+
+	t.Run("decode", func(t *testing.T) {
+		out, err := Decode(compressed, 8, 4, 0)
+		if err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+		if string(out) != "abab" {
+			t.Errorf("got %q, want %q", out, "abab")
+		}
+	})
+
+	t.Run("encode", func(t *testing.T) {
+		out, err := Encode([]byte("abab"), 8, 4)
+		if err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+		if !bytes.Equal(out, compressed) {
+			t.Errorf("got %08b, want %08b", out, compressed)
+		}
+	})
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	random := make([]byte, 20000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	gcode := strings.Repeat("G1 X10.000 Y20.000 E0.4000\nG1 X12.500 Y20.000 E0.4500\n;TYPE:Perimeter\n", 500)
+
+	var cases = map[string][]byte{
+		"empty":  {},
+		"single": []byte("G"),
+		"run":    bytes.Repeat([]byte{'a'}, 1000),
+		"random": random,
+		"gcode":  []byte(gcode),
+	}
+
+	for name, data := range cases {
+		for _, params := range [][2]uint8{{11, 4}, {12, 4}} {
+			t.Run(fmt.Sprintf("%s_%d_%d", name, params[0], params[1]), func(t *testing.T) {
+				compressed, err := Encode(data, params[0], params[1])
+				if err != nil {
+					t.Fatalf("got error %v, want nil error", err)
+				}
+
+				out, err := Decode(compressed, params[0], params[1], len(data))
+				if err != nil {
+					t.Fatalf("got error %v, want nil error", err)
+				}
+
+				if !bytes.Equal(out, data) {
+					t.Errorf("got %d bytes decoded, want the %d bytes encoded", len(out), len(data))
+				}
+			})
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+
+	t.Run("parameters", func(t *testing.T) {
+		if _, err := Encode([]byte("abc"), 20, 4); err == nil {
+			t.Errorf("got nil error, want error")
+		}
+		if _, err := Decode([]byte("abc"), 11, 11, 0); err == nil {
+			t.Errorf("got nil error, want error")
+		}
+	})
+
+	t.Run("back reference", func(t *testing.T) {
+		if _, err := Decode(bits("0 00000011 0001"), 8, 4, 0); err == nil {
+			t.Errorf("got nil error, want error")
+		}
+	})
+}
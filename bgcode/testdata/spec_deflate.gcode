@@ -0,0 +1,24 @@
+; generated by PrusaSlicer 2.7.0 on 2023-12-01 at 10:00:00 UTC
+M73 P0 R12
+M201 X4000 Y4000 Z200 E2500 ; sets maximum accelerations, mm/sec^2
+M104 S215 ; set extruder temp
+G28 ; home all without mesh bed level
+G90 ; use absolute coordinates
+M83 ; extruder relative mode
+M109 S215 ; wait for extruder temp
+;LAYER_CHANGE
+;Z:0.2
+;HEIGHT:0.2
+G1 Z.2 F720
+G1 X90.171 Y90.171 F10800
+;TYPE:Perimeter
+;WIDTH:0.449999
+G1 F1200
+G1 X109.829 Y90.171 E.6432
+G1 X109.829 Y109.829 E.6432
+G1 X90.171 Y109.829 E.6432
+G1 X90.171 Y90.171 E.6432
+M107
+;TYPE:Custom
+M104 S0 ; turn off temperature
+M84 X Y E ; disable motors
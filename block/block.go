@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"hash"
 
+	"github.com/mauroalderete/gcode-core/checksum"
 	"github.com/mauroalderete/gcode-core/gcode"
 )
 
@@ -46,10 +47,19 @@ type BlockConfigurer interface {
 	// Set a gcode.GcodeFactory instances that the block will use to handle his internal gcode elements
 	SetGcodeFactory(gcodeFactory gcode.GcoderFactory) error
 
-	// Set the hash instance that implement the algorith to execute checksum
+	// Set the hash instance that implement the algorith to execute checksum.
+	// The blocks that share the instance compute their checksums one at a time.
 	SetHash(hash hash.Hash) error
 }
 
+// BlockHashFactoryConfigurer is an optional option of a BlockConfigurer, it's implemented by the configurers that accept a factory of hashes.
+// The callbacks check it with a type assertion, so the implementations of BlockConfigurer that don't have it still work.
+type BlockHashFactoryConfigurer interface {
+	// Set the factory of the hashes that implement the algorithm to execute checksum.
+	// Each computation uses a new hash, so the blocks can compute their checksums concurrently.
+	SetHashFactory(factory checksum.Factory) error
+}
+
 // BlockConstructorConfigurer extends the basic configurable options to add other parameters that define a block when is constructed.
 // Allows loading of the elements that compose a block during the creation.
 type BlockConstructorConfigurer interface {
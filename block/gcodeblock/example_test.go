@@ -1,7 +1,9 @@
 package gcodeblock_test
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"github.com/mauroalderete/gcode-core/block"
 	"github.com/mauroalderete/gcode-core/block/gcodeblock"
@@ -275,3 +277,32 @@ func ExampleGcodeBlock_ToLine_third() {
 
 	// Output: line is:
 }
+
+func ExampleNewParallelScanner() {
+
+	source := "; generated by a slicer\nG28\nG1 X10 Y10 F1800\nM104 S200\n"
+
+	// parse the lines with 4 workers, the blocks are returned in the order of the file
+	s, err := gcodeblock.NewParallelScanner(context.Background(), strings.NewReader(source), func(config gcodeblock.ParallelScannerConfigurer) error {
+		return config.SetWorkers(4)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for s.Scan() {
+		if s.Block() != nil {
+			fmt.Printf("%d: %s\n", s.Line(), s.Block())
+		}
+	}
+
+	if s.Err() != nil {
+		fmt.Println(s.Err())
+	}
+
+	// Output:
+	// 2: G28
+	// 3: G1 X10 Y10 F1800
+	// 4: M104 S200
+}
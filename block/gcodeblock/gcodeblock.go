@@ -47,9 +47,12 @@ type GcodeBlock struct {
 	// gcode factory
 	gcodeFactory gcode.GcoderFactory
 
-	// instance of the hash algorithm to handle the checksum
+	// instance of the hash algorithm to handle the checksum, shared with other blocks. It's nil if the block uses hashFactory
 	hash hash.Hash
 
+	// factory of the hashes of the algorithm to handle the checksum, a new hash is used on each computation
+	hashFactory checksum.Factory
+
 	// line number of the block. It can be null. Always has an int32 type address.
 	lineNumber gcode.AddressableGcoder[uint32]
 
@@ -93,17 +96,27 @@ func (b *GcodeBlock) Checksum() gcode.AddressableGcoder[uint32] {
 }
 
 // CalculateChecksum calculates a checksum from the block and returns a new GcodeAddressable[uint32] with the value computed.
+//
+// The value is the whole result of the hash, up to 4 bytes in big endian, so the multi-byte algorithms like the CRC-16 are supported.
+//
+// It's safe to call from several goroutines: the computation uses a new hash of the factory,
+// or it's serialized with the other blocks if the block shares a hash instance configured with SetHash.
 func (b *GcodeBlock) CalculateChecksum() (gcode.AddressableGcoder[uint32], error) {
 
-	b.hash.Reset()
-	_, err := b.hash.Write([]byte(b.String()))
+	var value uint32
+	var err error
+	if b.hash != nil {
+		value, err = checksum.Compute(b.hash, []byte(b.String()))
+	} else {
+		value, err = b.hashFactory.Compute([]byte(b.String()))
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate hash to block %s: %w", b, err)
 	}
 
-	gc, err := b.gcodeFactory.NewAddressableGcodeUint32('*', uint32(b.hash.Sum(nil)[0]))
+	gc, err := b.gcodeFactory.NewAddressableGcodeUint32('*', value)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create checksum gcode instance with hash %v: %w", uint32(b.hash.Sum(nil)[0]), err)
+		return nil, fmt.Errorf("failed to create checksum gcode instance with hash %v: %w", value, err)
 	}
 
 	return gc, nil
@@ -216,7 +229,7 @@ func New(command gcode.Gcoder, options ...block.BlockConstructorConfigurationCal
 	gcodeBlock := &GcodeBlock{
 		command:      command,
 		gcodeFactory: &gcodefactory.GcodeFactory{},
-		hash:         checksum.New(),
+		hashFactory:  checksum.New,
 	}
 
 	// prepare an instance of the BlockConfigurer interface to store each configuration callback received
@@ -261,11 +274,10 @@ func New(command gcode.Gcoder, options ...block.BlockConstructorConfigurationCal
 func Parse(source string, options ...block.BlockParserConfigurationCallbackable) (*GcodeBlock, error) {
 
 	gcodeFactory := &gcodefactory.GcodeFactory{}
-	hashGenerator := checksum.New()
 
 	gcodeBlock := &GcodeBlock{
 		gcodeFactory: gcodeFactory,
-		hash:         hashGenerator,
+		hashFactory:  checksum.New,
 	}
 
 	// prepare an instance of the BlockConfigurer interface to store each configuration callback received
@@ -299,7 +311,7 @@ func Parse(source string, options ...block.BlockParserConfigurationCallbackable)
 	// recover linenumber value if is exist
 	element = take(parse, `^N\d+`)
 	if element.taken != "" {
-		address, err := strconv.ParseInt(element.taken[1:], 10, 32)
+		address, err := strconv.ParseUint(element.taken[1:], 10, 32)
 		if err != nil {
 			return nil, fmt.Errorf("try parse Linenumber %v: %w", element.taken, err)
 		}
@@ -314,7 +326,7 @@ func Parse(source string, options ...block.BlockParserConfigurationCallbackable)
 	// recover checksum value if is exist
 	element = take(parse, `\b\*\d+$`)
 	if element.taken != "" {
-		address, err := strconv.ParseInt(element.taken[1:], 10, 32)
+		address, err := strconv.ParseUint(element.taken[1:], 10, 32)
 		if err != nil {
 			return nil, fmt.Errorf("try parse checksum %v: %w", element.taken, err)
 		}
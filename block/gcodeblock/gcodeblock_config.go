@@ -8,13 +8,14 @@ import (
 	"fmt"
 	"hash"
 
+	"github.com/mauroalderete/gcode-core/checksum"
 	"github.com/mauroalderete/gcode-core/gcode"
 )
 
 // optionalBlockPropertyCallbackable is a type that define the signature of the callbacks that implement logic to configure a new block instance.
 type optionalBlockPropertyCallbackable func(*GcodeBlock) error
 
-// blockConfigurator satisfy block.BlockConfigurer, contains the logic to create and store each optionalBlockPropertyCallbackable instance.
+// blockConfigurator satisfy block.BlockConfigurer and block.BlockHashFactoryConfigurer, contains the logic to create and store each optionalBlockPropertyCallbackable instance.
 //
 // It defines a slice of callbacks that will recive a new block reference that must be configured.
 type blockConfigurator struct {
@@ -88,7 +89,10 @@ func (bc *blockConfigurator) SetChecksum(checksum gcode.AddressableGcoder[uint32
 
 // SetHash loads the an hash algoritgh instance of the block with the input instanced. Doesn't accept nil.
 // It require that the gcodeFactory is loaded in the block previously.
-// If this method isn't called when a new block is created, by default will store a instance of Hash from the checksum package.
+// If this method isn't called when a new block is created, by default will use a new Hash from the checksum package on each computation.
+// The hash can return up to 4 bytes, like the CRC-16 or the CRC-32 of the checksum package.
+//
+// The instance is shared, so the blocks that use it compute their checksums one at a time. SetHashFactory avoids it.
 func (bc *blockConfigurator) SetHash(hash hash.Hash) error {
 
 	if hash == nil {
@@ -100,6 +104,29 @@ func (bc *blockConfigurator) SetHash(hash hash.Hash) error {
 			return fmt.Errorf("failed to config ChecksumGenerator because depende of the gcodeFactory instanced and currently it is nil")
 		}
 		gb.hash = hash
+		gb.hashFactory = nil
+		return nil
+	})
+
+	return nil
+}
+
+// SetHashFactory loads the factory of the hashes of the block. Doesn't accept nil.
+// It require that the gcodeFactory is loaded in the block previously.
+// Each computation of the checksum uses a new hash, so the blocks can compute them concurrently.
+// It replaces the instance loaded with SetHash.
+func (bc *blockConfigurator) SetHashFactory(factory checksum.Factory) error {
+
+	if factory == nil {
+		return fmt.Errorf("failed set hash factory, it mustn't be nil")
+	}
+
+	bc.configurationCallbacks = append(bc.configurationCallbacks, func(gb *GcodeBlock) error {
+		if gb.gcodeFactory == nil {
+			return fmt.Errorf("failed to config hash factory because depende of the gcodeFactory instanced and currently it is nil")
+		}
+		gb.hash = nil
+		gb.hashFactory = factory
 		return nil
 	})
 
@@ -0,0 +1,306 @@
+// This file defines a ParallelScanner that reads a gcode file like the Scanner, but parses his lines with a pool of workers.
+//
+// The lines are read in chunks that are parsed concurrently, and the blocks are returned in the order of the source.
+// The number of chunks in memory is bounded, so large files are processed with a constant amount of memory.
+package gcodeblock
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block"
+)
+
+const (
+	// PARALLEL_SCANNER_CHUNK_LINES defines the number of lines of each chunk parsed by a worker by default.
+	PARALLEL_SCANNER_CHUNK_LINES = 4096
+)
+
+//#region parallel scanner struct
+
+// chunk is a sequence of consecutive lines of the source that is parsed by a single worker
+type chunk struct {
+	// first is the number of the first line of the chunk, starting at 1
+	first int
+
+	// lines stores the lines without the end of line characters, and blocks the block of each one of them or nil
+	lines  []string
+	blocks []*GcodeBlock
+
+	// err is the error of the line failed with the index errIndex, the lines after it aren't parsed
+	err      error
+	errIndex int
+
+	// done is closed when the chunk is parsed
+	done chan struct{}
+}
+
+// ParallelScanner reads a stream of gcode lines and parses each one of them into a GcodeBlock using several goroutines.
+//
+// It has the same behaviour than the Scanner: successive calls to the Scan method step through the lines of the source in order,
+// and the scanning stops unrecoverably at EOF, the first I/O error, the first line that can't be parsed or the cancellation of the context.
+//
+// The options of the blocks are applied from several goroutines, so a hash injected with SetHash is shared between them.
+// Close must be called if the scanning is abandoned before Scan returns false, to stop the workers.
+type ParallelScanner struct {
+	// ctx is cancelled when the scanning stops
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// scanner reads the lines of the source
+	scanner *bufio.Scanner
+
+	// options are applied to each block parsed
+	options []block.BlockParserConfigurationCallbackable
+
+	// workers is the number of goroutines that parse the chunks, and chunkLines the number of lines of each chunk
+	workers    int
+	chunkLines int
+
+	// pending stores the chunks in the order of the source, until Scan takes them
+	pending chan *chunk
+
+	// current is the chunk that Scan is stepping through, and index the position of the last line returned
+	current *chunk
+	index   int
+
+	// text, block and line describe the last line read
+	text  string
+	block *GcodeBlock
+	line  int
+
+	// err stores the first error found, and eof is true after the last line is returned
+	err error
+	eof bool
+}
+
+// Scan advances the ParallelScanner to the next line, which will then be available through the Text and Block methods.
+//
+// It returns false when the scan stops, either by reaching the end of the input or an error.
+// After Scan returns false, the Err method will return any error that occurred during scanning, except that if it was io.EOF, Err will return nil.
+func (s *ParallelScanner) Scan() bool {
+
+	if s.err != nil || s.eof {
+		return false
+	}
+
+	s.text = ""
+	s.block = nil
+
+	for !s.hasNext() {
+
+		var c *chunk
+		var ok bool
+		select {
+		case c, ok = <-s.pending:
+		case <-s.ctx.Done():
+		}
+
+		if !ok {
+			if s.ctx.Err() != nil {
+				return s.fail(fmt.Errorf("failed to read line %d: %w", s.line+1, s.ctx.Err()))
+			}
+			s.eof = true
+			s.cancel()
+			return false
+		}
+
+		select {
+		case <-c.done:
+		case <-s.ctx.Done():
+			return s.fail(fmt.Errorf("failed to read line %d: %w", s.line+1, s.ctx.Err()))
+		}
+
+		s.current, s.index = c, -1
+	}
+
+	s.index++
+
+	if s.current.err != nil && s.index == s.current.errIndex {
+		// a line that can't be parsed is the last line read, but the error of the reader is after the last line
+		if s.index < len(s.current.lines) {
+			s.line = s.current.first + s.index
+		}
+		return s.fail(s.current.err)
+	}
+
+	s.line = s.current.first + s.index
+	s.text = s.current.lines[s.index]
+	s.block = s.current.blocks[s.index]
+
+	return true
+}
+
+// Text returns the last line read by Scan, without the end of line characters.
+func (s *ParallelScanner) Text() string {
+	return s.text
+}
+
+// Block returns the block parsed from the last line read by Scan.
+//
+// It returns nil if the line doesn't contain any gcode expression.
+func (s *ParallelScanner) Block() *GcodeBlock {
+	return s.block
+}
+
+// Line returns the number of the last line read by Scan. The first line of the source is the number 1.
+func (s *ParallelScanner) Line() int {
+	return s.line
+}
+
+// Err returns the first non-EOF error that was encountered by the ParallelScanner.
+func (s *ParallelScanner) Err() error {
+	return s.err
+}
+
+// Close stops the workers. It's only required if the scanning is abandoned before Scan returns false.
+func (s *ParallelScanner) Close() {
+	s.cancel()
+}
+
+// hasNext returns true if the current chunk has another line or an error to return
+func (s *ParallelScanner) hasNext() bool {
+	return s.current != nil && (s.index+1 < len(s.current.lines) || s.current.err != nil)
+}
+
+// fail stores the error, stops the workers and returns false
+func (s *ParallelScanner) fail(err error) bool {
+	s.err = err
+	s.text = ""
+	s.block = nil
+	s.cancel()
+	return false
+}
+
+// read splits the source in chunks, and sends each one of them to the workers and to the pending queue in order
+func (s *ParallelScanner) read(jobs chan<- *chunk) {
+
+	defer close(s.pending)
+	defer close(jobs)
+
+	line := 0
+
+	for {
+		c := &chunk{
+			first: line + 1,
+			lines: make([]string, 0, s.chunkLines),
+			done:  make(chan struct{}),
+		}
+
+		for len(c.lines) < s.chunkLines && s.scanner.Scan() {
+			c.lines = append(c.lines, strings.TrimSuffix(s.scanner.Text(), "\r"))
+		}
+		line += len(c.lines)
+
+		// an error of the reader is reported after the lines read before it
+		if err := s.scanner.Err(); err != nil {
+			c.err, c.errIndex = fmt.Errorf("failed to read line %d: %w", line+1, err), len(c.lines)
+		}
+
+		if len(c.lines) == 0 && c.err == nil {
+			return
+		}
+
+		// the chunk belongs to the worker after sending it
+		last := c.err != nil || len(c.lines) < s.chunkLines
+
+		select {
+		case jobs <- c:
+		case <-s.ctx.Done():
+			return
+		}
+
+		select {
+		case s.pending <- c:
+		case <-s.ctx.Done():
+			return
+		}
+
+		if last {
+			return
+		}
+	}
+}
+
+// work parses the chunks until there aren't more
+func (s *ParallelScanner) work(jobs <-chan *chunk) {
+	for c := range jobs {
+		s.parse(c)
+		close(c.done)
+	}
+}
+
+// parse parses each line of the chunk, until the first one that fails
+func (s *ParallelScanner) parse(c *chunk) {
+
+	c.blocks = make([]*GcodeBlock, len(c.lines))
+
+	for i, text := range c.lines {
+
+		// the scanning was stopped, the lines that remain aren't returned
+		if err := s.ctx.Err(); err != nil {
+			c.err, c.errIndex = fmt.Errorf("failed to read line %d: %w", c.first+i, err), i
+			c.lines = c.lines[:i]
+			return
+		}
+
+		if !HasGcode(text) {
+			continue
+		}
+
+		b, err := Parse(text, s.options...)
+		if err != nil {
+			c.err, c.errIndex = fmt.Errorf("failed to parse line %d '%s': %w", c.first+i, text, err), i
+			c.lines = c.lines[:i+1]
+			return
+		}
+
+		c.blocks[i] = b
+	}
+}
+
+//#endregion
+//#region constructor
+
+// NewParallelScanner returns a new ParallelScanner to read from r, that stops when ctx is cancelled.
+//
+// options are a series of configuration callbacks to set the number of workers, the size of the chunks
+// and the options of the blocks. By default, it uses a worker for each CPU and chunks of PARALLEL_SCANNER_CHUNK_LINES lines.
+func NewParallelScanner(ctx context.Context, r io.Reader, options ...ParallelScannerConfigurationCallbackable) (*ParallelScanner, error) {
+
+	s := &ParallelScanner{
+		workers:    runtime.GOMAXPROCS(0),
+		chunkLines: PARALLEL_SCANNER_CHUNK_LINES,
+	}
+
+	configurator := &parallelScannerConfigurator{scanner: s}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	s.scanner = bufio.NewScanner(r)
+	s.scanner.Buffer(make([]byte, 0, 64*1024), SCANNER_MAX_LINE_SIZE)
+
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	// the chunks waiting to be parsed or to be scanned are bounded by the number of workers
+	jobs := make(chan *chunk, s.workers)
+	s.pending = make(chan *chunk, s.workers)
+
+	go s.read(jobs)
+	for i := 0; i < s.workers; i++ {
+		go s.work(jobs)
+	}
+
+	return s, nil
+}
+
+//#endregion
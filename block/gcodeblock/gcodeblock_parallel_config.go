@@ -0,0 +1,64 @@
+// This file defines a parallelScannerConfigurator as an object that implement the ParallelScannerConfigurer
+// interface to allow the caller to configure a new ParallelScanner.
+package gcodeblock
+
+import (
+	"fmt"
+
+	"github.com/mauroalderete/gcode-core/block"
+)
+
+// ParallelScannerConfigurer contains the configurable options that define the workers of a ParallelScanner and the blocks that it parses.
+type ParallelScannerConfigurer interface {
+	// Set the number of goroutines that parse the lines
+	SetWorkers(workers int) error
+
+	// Set the number of lines of each chunk parsed by a worker
+	SetChunkLines(lines int) error
+
+	// Set the configuration callbacks applied to each block parsed
+	SetBlockOptions(options ...block.BlockParserConfigurationCallbackable) error
+}
+
+// ParallelScannerConfigurationCallbackable is the signature of the callbacks that the NewParallelScanner constructor waiting receives to configure the new instance.
+type ParallelScannerConfigurationCallbackable func(config ParallelScannerConfigurer) error
+
+// parallelScannerConfigurator satisfy ParallelScannerConfigurer, it applies each option directly over the ParallelScanner in construction.
+type parallelScannerConfigurator struct {
+	scanner *ParallelScanner
+}
+
+// SetWorkers sets the number of goroutines that parse the lines. It must be positive.
+// By default is the number of CPUs that Go uses, runtime.GOMAXPROCS.
+func (pc *parallelScannerConfigurator) SetWorkers(workers int) error {
+
+	if workers <= 0 {
+		return fmt.Errorf("failed set workers, it must be positive but got %d", workers)
+	}
+
+	pc.scanner.workers = workers
+
+	return nil
+}
+
+// SetChunkLines sets the number of lines of each chunk parsed by a worker. It must be positive.
+// By default is PARALLEL_SCANNER_CHUNK_LINES. The bigger chunks reduce the synchronization but use more memory.
+func (pc *parallelScannerConfigurator) SetChunkLines(lines int) error {
+
+	if lines <= 0 {
+		return fmt.Errorf("failed set chunk lines, it must be positive but got %d", lines)
+	}
+
+	pc.scanner.chunkLines = lines
+
+	return nil
+}
+
+// SetBlockOptions sets the configuration callbacks applied to each block parsed, like the options of the Scanner.
+// They are called from several goroutines.
+func (pc *parallelScannerConfigurator) SetBlockOptions(options ...block.BlockParserConfigurationCallbackable) error {
+
+	pc.scanner.options = options
+
+	return nil
+}
@@ -0,0 +1,325 @@
+package gcodeblock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/checksum"
+)
+
+// generate returns a gcode file with the number of lines, mixing moves, comments and empty lines
+func generate(lines int) string {
+
+	var builder strings.Builder
+
+	for i := 0; i < lines; i++ {
+		switch i % 10 {
+		case 0:
+			fmt.Fprintf(&builder, ";LAYER:%d\n", i/10)
+		case 5:
+			builder.WriteString("\r\n")
+		case 7:
+			fmt.Fprintf(&builder, "M104 S%d ; temperature\n", 200+i%20)
+		default:
+			fmt.Fprintf(&builder, "G1 X%d.%03d Y%d.5 E%d.25 F1800\n", i%220, i%1000, (i*7)%220, i)
+		}
+	}
+
+	return builder.String()
+}
+
+// scanned is a line returned by a scanner
+type scanned struct {
+	line  int
+	text  string
+	block string
+}
+
+// scanAll returns the lines returned by the scanner and his error
+func scanAll(s interface {
+	Scan() bool
+	Text() string
+	Block() *GcodeBlock
+	Line() int
+	Err() error
+}) ([]scanned, error) {
+
+	result := []scanned{}
+	for s.Scan() {
+		l := scanned{line: s.Line(), text: s.Text()}
+		if s.Block() != nil {
+			l.block = s.Block().String()
+		}
+		result = append(result, l)
+	}
+
+	return result, s.Err()
+}
+
+func TestParallelScanner(t *testing.T) {
+
+	source := generate(1000)
+
+	want, err := scanAll(NewScanner(strings.NewReader(source)))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	var cases = map[string]struct {
+		workers    int
+		chunkLines int
+	}{
+		"a worker":                {workers: 1, chunkLines: 64},
+		"several workers":         {workers: 4, chunkLines: 64},
+		"chunks of a line":        {workers: 3, chunkLines: 1},
+		"exact chunks":            {workers: 2, chunkLines: 100},
+		"a chunk bigger than all": {workers: 2, chunkLines: 5000},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewParallelScanner(context.Background(), strings.NewReader(source), func(config ParallelScannerConfigurer) error {
+				if err := config.SetWorkers(tc.workers); err != nil {
+					return err
+				}
+				return config.SetChunkLines(tc.chunkLines)
+			})
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			got, err := scanAll(s)
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			if len(got) != len(want) {
+				t.Fatalf("got %d lines, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("got %+v, want %+v", got[i], want[i])
+				}
+			}
+
+			if s.Scan() {
+				t.Errorf("got scan true at end of input, want false")
+			}
+			if s.Err() != nil {
+				t.Errorf("got error %v at end of input, want nil error", s.Err())
+			}
+		})
+	}
+}
+
+func TestParallelScanner_Empty(t *testing.T) {
+
+	s, err := NewParallelScanner(context.Background(), strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if s.Scan() || s.Err() != nil {
+		t.Errorf("got scan true or error %v, want false without error", s.Err())
+	}
+}
+
+func TestParallelScanner_ParseError(t *testing.T) {
+
+	source := generate(300) + "G1 X%1\n" + generate(300)
+
+	s, err := NewParallelScanner(context.Background(), strings.NewReader(source), func(config ParallelScannerConfigurer) error {
+		return config.SetChunkLines(32)
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	got, err := scanAll(s)
+	if err == nil {
+		t.Fatalf("got nil error, want error")
+	}
+
+	if len(got) != 300 || s.Line() != 301 {
+		t.Errorf("got %d lines and line %d, want 300 lines and line 301", len(got), s.Line())
+	}
+
+	if s.Scan() {
+		t.Errorf("got scan true after an error, want false")
+	}
+}
+
+// failingReader returns the source and then an error
+type failingReader struct {
+	source io.Reader
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.source.Read(p)
+	if err == io.EOF {
+		return n, errors.New("disk failure")
+	}
+	return n, err
+}
+
+func TestParallelScanner_ReadError(t *testing.T) {
+
+	s, err := NewParallelScanner(context.Background(), &failingReader{strings.NewReader(generate(100))}, func(config ParallelScannerConfigurer) error {
+		return config.SetChunkLines(30)
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	got, err := scanAll(s)
+	if err == nil || !strings.Contains(err.Error(), "disk failure") {
+		t.Fatalf("got error %v, want the error of the reader", err)
+	}
+
+	if len(got) != 100 || s.Line() != 100 {
+		t.Errorf("got %d lines and line %d, want 100 lines and line 100", len(got), s.Line())
+	}
+}
+
+func TestParallelScanner_Cancel(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s, err := NewParallelScanner(ctx, strings.NewReader(generate(10000)), func(config ParallelScannerConfigurer) error {
+		return config.SetChunkLines(16)
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	lines := 0
+	for s.Scan() {
+		lines++
+		if lines == 100 {
+			cancel()
+		}
+	}
+
+	if !errors.Is(s.Err(), context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", s.Err())
+	}
+
+	if lines >= 10000 {
+		t.Errorf("got %d lines, want the scanning stopped", lines)
+	}
+}
+
+func TestParallelScanner_Close(t *testing.T) {
+
+	s, err := NewParallelScanner(context.Background(), strings.NewReader(generate(10000)), func(config ParallelScannerConfigurer) error {
+		return config.SetChunkLines(16)
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if !s.Scan() {
+		t.Fatalf("got scan false, want true: %v", s.Err())
+	}
+
+	s.Close()
+
+	for s.Scan() {
+	}
+
+	if !errors.Is(s.Err(), context.Canceled) {
+		t.Errorf("got error %v after close, want context.Canceled", s.Err())
+	}
+}
+
+func TestParallelScanner_BlockOptions(t *testing.T) {
+
+	factory, err := checksum.NewFactory(checksum.ALGORITHM_CRC16_CCITT)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	s, err := NewParallelScanner(context.Background(), strings.NewReader("N2 G1 X10*22376\n"), func(config ParallelScannerConfigurer) error {
+		return config.SetBlockOptions(func(config block.BlockParserConfigurer) error {
+			return config.(block.BlockHashFactoryConfigurer).SetHashFactory(factory)
+		})
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if !s.Scan() {
+		t.Fatalf("got scan false, want true: %v", s.Err())
+	}
+
+	if ok, err := s.Block().VerifyChecksum(); !ok || err != nil {
+		t.Errorf("got verified %v error %v, want the checksum of the hash of the options verified", ok, err)
+	}
+}
+
+func TestParallelScanner_InvalidConfiguration(t *testing.T) {
+
+	var cases = map[string]ParallelScannerConfigurationCallbackable{
+		"workers": func(config ParallelScannerConfigurer) error {
+			return config.SetWorkers(0)
+		},
+		"chunk lines": func(config ParallelScannerConfigurer) error {
+			return config.SetChunkLines(-1)
+		},
+	}
+
+	for name, option := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewParallelScanner(context.Background(), strings.NewReader(""), option); err == nil {
+				t.Errorf("got nil error, want error")
+			}
+		})
+	}
+}
+
+func BenchmarkScanner(b *testing.B) {
+
+	source := generate(10000)
+	b.SetBytes(int64(len(source)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		s := NewScanner(strings.NewReader(source))
+		for s.Scan() {
+		}
+		if s.Err() != nil {
+			b.Fatal(s.Err())
+		}
+	}
+}
+
+func BenchmarkParallelScanner(b *testing.B) {
+
+	source := generate(10000)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(int64(len(source)))
+
+			for i := 0; i < b.N; i++ {
+				s, err := NewParallelScanner(context.Background(), strings.NewReader(source), func(config ParallelScannerConfigurer) error {
+					return config.SetWorkers(workers)
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				for s.Scan() {
+				}
+				if s.Err() != nil {
+					b.Fatal(s.Err())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// This file defines a Scanner that reads a gcode file, or any stream of gcode lines, and parses each line into a GcodeBlock.
+//
+// A gcode file contains lines that aren't blocks, like empty lines or lines with only a comment.
+// The Scanner keeps them available through the Text method so that nothing of the source is lost.
+package gcodeblock
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block"
+)
+
+const (
+	// SCANNER_MAX_LINE_SIZE defines the maximum size in bytes of a single line that the Scanner can read.
+	SCANNER_MAX_LINE_SIZE = 1024 * 1024
+)
+
+//#region scanner struct
+
+// Scanner reads a stream of gcode lines and parses each one of them into a GcodeBlock.
+//
+// Successive calls to the Scan method step through the lines of the source.
+// Lines without a gcode expression, like empty lines or comment-only lines, don't produce a block,
+// but they are scanned anyway and can be recovered with the Text method.
+//
+// Scanning stops unrecoverably at EOF, the first I/O error or the first line that can't be parsed.
+type Scanner struct {
+	// scanner reads the source line by line
+	scanner *bufio.Scanner
+
+	// options are applied to each block parsed
+	options []block.BlockParserConfigurationCallbackable
+
+	// text stores the last line read without the end of line characters
+	text string
+
+	// block stores the block parsed from the last line read, it's nil if the line doesn't contain a gcode expression
+	block *GcodeBlock
+
+	// line stores the number of the last line read, starting at 1
+	line int
+
+	// err stores the first error found
+	err error
+}
+
+// Scan advances the Scanner to the next line, which will then be available through the Text and Block methods.
+//
+// It returns false when the scan stops, either by reaching the end of the input or an error.
+// After Scan returns false, the Err method will return any error that occurred during scanning, except that if it was io.EOF, Err will return nil.
+func (s *Scanner) Scan() bool {
+
+	if s.err != nil {
+		return false
+	}
+
+	s.text = ""
+	s.block = nil
+
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			s.err = fmt.Errorf("failed to read line %d: %w", s.line+1, err)
+		}
+		return false
+	}
+
+	s.line++
+	s.text = strings.TrimSuffix(s.scanner.Text(), "\r")
+
+	if !HasGcode(s.text) {
+		return true
+	}
+
+	b, err := Parse(s.text, s.options...)
+	if err != nil {
+		s.err = fmt.Errorf("failed to parse line %d '%s': %w", s.line, s.text, err)
+		return false
+	}
+
+	s.block = b
+
+	return true
+}
+
+// Text returns the last line read by Scan, without the end of line characters.
+func (s *Scanner) Text() string {
+	return s.text
+}
+
+// Block returns the block parsed from the last line read by Scan.
+//
+// It returns nil if the line doesn't contain any gcode expression.
+func (s *Scanner) Block() *GcodeBlock {
+	return s.block
+}
+
+// Line returns the number of the last line read by Scan. The first line of the source is the number 1.
+func (s *Scanner) Line() int {
+	return s.line
+}
+
+// Err returns the first non-EOF error that was encountered by the Scanner.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+//#endregion
+//#region constructor
+
+// NewScanner returns a new Scanner to read from r.
+//
+// options are a series of configuration callbacks that are applied to each block parsed.
+func NewScanner(r io.Reader, options ...block.BlockParserConfigurationCallbackable) *Scanner {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), SCANNER_MAX_LINE_SIZE)
+
+	return &Scanner{
+		scanner: scanner,
+		options: options,
+	}
+}
+
+//#endregion
+//#region package functions
+
+// HasGcode indicates if a line contains almost a gcode expression, is said, if it isn't empty or a comment-only line.
+func HasGcode(line string) bool {
+
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+
+	return strings.TrimSpace(line) != ""
+}
+
+//#endregion
@@ -0,0 +1,110 @@
+package gcodeblock
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+
+	const source = "; generated by a slicer\r\n" +
+		"\n" +
+		"G28\n" +
+		"N7 G1 X2.0 Y2.0 F3000.0*85 ; move\n" +
+		";LAYER:0\n" +
+		"M104 S200"
+
+	var cases = []struct {
+		text  string
+		block string
+	}{
+		{text: "; generated by a slicer"},
+		{text: ""},
+		{text: "G28", block: "G28"},
+		{text: "N7 G1 X2.0 Y2.0 F3000.0*85 ; move", block: "N7 G1 X2.000 Y2.000 F3000.000"},
+		{text: ";LAYER:0"},
+		{text: "M104 S200", block: "M104 S200"},
+	}
+
+	s := NewScanner(strings.NewReader(source))
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("(%v)", i), func(t *testing.T) {
+			if !s.Scan() {
+				t.Fatalf("got scan false, want true: %v", s.Err())
+			}
+
+			if s.Line() != i+1 {
+				t.Errorf("got line %d, want line %d", s.Line(), i+1)
+			}
+
+			if s.Text() != tc.text {
+				t.Errorf("got text '%s', want text '%s'", s.Text(), tc.text)
+			}
+
+			if tc.block == "" {
+				if s.Block() != nil {
+					t.Errorf("got block %s, want nil block", s.Block())
+				}
+				return
+			}
+
+			if s.Block() == nil {
+				t.Fatalf("got nil block, want %s", tc.block)
+			}
+
+			if s.Block().String() != tc.block {
+				t.Errorf("got block %s, want %s", s.Block(), tc.block)
+			}
+		})
+	}
+
+	if s.Scan() {
+		t.Errorf("got scan true at end of input, want false")
+	}
+
+	if s.Err() != nil {
+		t.Errorf("got error %v, want nil error", s.Err())
+	}
+}
+
+func TestScanner_Error(t *testing.T) {
+
+	s := NewScanner(strings.NewReader("G28\nG1 X%1\nG1 X2\n"))
+
+	for s.Scan() {
+	}
+
+	if s.Err() == nil {
+		t.Fatalf("got nil error, want error")
+	}
+
+	if s.Line() != 2 {
+		t.Errorf("got line %d, want line 2", s.Line())
+	}
+
+	if s.Scan() {
+		t.Errorf("got scan true after an error, want false")
+	}
+}
+
+func TestHasGcode(t *testing.T) {
+
+	var cases = map[string]bool{
+		"":             false,
+		"   ":          false,
+		";comment":     false,
+		"  ; comment":  false,
+		"G28":          true,
+		"G1 X1 ;hello": true,
+	}
+
+	for line, want := range cases {
+		t.Run(line, func(t *testing.T) {
+			if got := HasGcode(line); got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
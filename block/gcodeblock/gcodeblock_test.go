@@ -1,8 +1,10 @@
 package gcodeblock
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"hash"
+	"sync"
 	"testing"
 
 	"github.com/mauroalderete/gcode-core/block"
@@ -744,3 +746,148 @@ func TestGcodeblock_ToLineError(t *testing.T) {
 		}
 	})
 }
+
+func TestGcodeblock_MultiByteChecksum(t *testing.T) {
+
+	cases := map[string]struct {
+		hash   hash.Hash
+		source string
+		value  uint32
+	}{
+		"crc16-ccitt":       {checksum.NewCRC16CCITT(), "N2 G1 X10", 22376},
+		"crc32":             {checksum.NewCRC32(), "N2 G1 X10", 1409936749},
+		"crc32 above int32": {checksum.NewCRC32(), "N1 G1 X10", 3666274958},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			option := func(config block.BlockParserConfigurer) error {
+				return config.SetHash(tc.hash)
+			}
+
+			b, err := Parse(tc.source, option)
+			if err != nil {
+				t.Errorf("got %v, want nil error", err)
+				return
+			}
+
+			if err := b.UpdateChecksum(); err != nil {
+				t.Errorf("got %v, want nil error", err)
+				return
+			}
+			if b.Checksum().Address() != tc.value {
+				t.Errorf("got checksum %d, want %d", b.Checksum().Address(), tc.value)
+			}
+
+			line := fmt.Sprintf("%s*%d", tc.source, tc.value)
+			parsed, err := Parse(line, option)
+			if err != nil {
+				t.Errorf("got %v, want nil error parsing %s", err, line)
+				return
+			}
+			if parsed.Checksum().Address() != tc.value {
+				t.Errorf("got parsed checksum %d, want %d", parsed.Checksum().Address(), tc.value)
+			}
+
+			ok, err := parsed.VerifyChecksum()
+			if err != nil || !ok {
+				t.Errorf("got verified %v error %v, want verified true error nil", ok, err)
+			}
+		})
+	}
+}
+
+func TestGcodeblock_ChecksumTooLong(t *testing.T) {
+
+	b, err := Parse("N2 G1 X10", func(config block.BlockParserConfigurer) error {
+		return config.SetHash(sha256.New())
+	})
+	if err != nil {
+		t.Errorf("got %v, want nil error", err)
+		return
+	}
+
+	if _, err := b.CalculateChecksum(); err == nil {
+		t.Errorf("got nil error, want error with a hash of 32 bytes")
+	}
+}
+
+func TestGcodeblock_ConcurrentChecksum(t *testing.T) {
+
+	shared := checksum.NewCRC16CCITT()
+	factory, err := checksum.NewFactory(checksum.ALGORITHM_CRC32)
+	if err != nil {
+		t.Fatalf("got %v, want nil error", err)
+	}
+
+	cases := map[string]block.BlockParserConfigurationCallbackable{
+		"default": func(config block.BlockParserConfigurer) error {
+			return nil
+		},
+		"shared hash": func(config block.BlockParserConfigurer) error {
+			return config.SetHash(shared)
+		},
+		"hash factory": func(config block.BlockParserConfigurer) error {
+			return config.(block.BlockHashFactoryConfigurer).SetHashFactory(factory)
+		},
+	}
+
+	for name, option := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			blocks := make([]*GcodeBlock, 64)
+			want := make([]uint32, len(blocks))
+			for i := range blocks {
+				b, err := Parse(fmt.Sprintf("N%d G1 X%d Y%d", i, i, 2*i), option)
+				if err != nil {
+					t.Fatalf("got %v, want nil error", err)
+				}
+				gc, err := b.CalculateChecksum()
+				if err != nil {
+					t.Fatalf("got %v, want nil error", err)
+				}
+				blocks[i], want[i] = b, gc.Address()
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, len(blocks)*8)
+
+			for worker := 0; worker < 8; worker++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i, b := range blocks {
+						gc, err := b.CalculateChecksum()
+						if err != nil {
+							errs <- err
+							return
+						}
+						if gc.Address() != want[i] {
+							errs <- fmt.Errorf("got checksum %d of %s, want %d", gc.Address(), b, want[i])
+						}
+						if ok, err := b.VerifyChecksum(); err == nil && ok {
+							errs <- fmt.Errorf("got block %s verified without checksum", b)
+						}
+					}
+				}()
+			}
+
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestGcodeblock_SetHashFactoryNil(t *testing.T) {
+
+	_, err := Parse("G28", func(config block.BlockParserConfigurer) error {
+		return config.(block.BlockHashFactoryConfigurer).SetHashFactory(nil)
+	})
+	if err == nil {
+		t.Errorf("got nil error, want error with a nil factory")
+	}
+}
@@ -9,15 +9,25 @@
 // [hash.Hash]: https://pkg.go.dev/hash@go1.18.3
 package checksum
 
-import "hash"
+import (
+	"hash"
+	"sync"
+)
 
 //region hash implementation
 
-// digest represents the partial evaluation of a checksum
+// digest represents the partial evaluation of a checksum.
+// The lock serializes the computations of Compute that share the instance.
 type digest struct {
+	mu       sync.Mutex
 	checksum uint8
 }
 
+// mutex returns the lock of the instance.
+func (d *digest) mutex() *sync.Mutex {
+	return &d.mu
+}
+
 // Sum appends the current hash to b and returns the resulting slice.
 // It does not change the underlying hash state.
 func (d *digest) Sum(in []byte) []byte {
@@ -0,0 +1,154 @@
+// This file defines the CRC algorithms used by some firmwares instead of the XOR checksum,
+// and the selection of the algorithm by his name.
+package checksum
+
+import (
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"sync"
+)
+
+// Algorithm is the name of a checksum algorithm.
+type Algorithm string
+
+const (
+	// ALGORITHM_XOR is the checksum of Marlin and RepRap, a XOR of the bytes of the line
+	ALGORITHM_XOR Algorithm = "xor"
+
+	// ALGORITHM_CRC16_CCITT is the CRC-16 with the polynomial 0x1021 and initial value 0xFFFF, without reflection, accepted by RepRapFirmware.
+	// The binary protocols use other algorithms: Klipper a reflected CRC-16 and the binary file transfer of Marlin a Fletcher-16.
+	ALGORITHM_CRC16_CCITT Algorithm = "crc16-ccitt"
+
+	// ALGORITHM_CRC32 is the CRC-32 IEEE
+	ALGORITHM_CRC32 Algorithm = "crc32"
+)
+
+const (
+	// CRC16_CCITT_POLYNOMIAL and CRC16_CCITT_INITIAL define the CRC-16 CCITT
+	CRC16_CCITT_POLYNOMIAL = 0x1021
+	CRC16_CCITT_INITIAL    = 0xFFFF
+)
+
+//#region crc16 hash implementation
+
+// crc16 represents the partial evaluation of a CRC-16 CCITT.
+// The lock serializes the computations of Compute that share the instance.
+type crc16 struct {
+	mu  sync.Mutex
+	crc uint16
+}
+
+// mutex returns the lock of the instance.
+func (d *crc16) mutex() *sync.Mutex {
+	return &d.mu
+}
+
+// Sum appends the current hash to b, in big endian, and returns the resulting slice.
+// It does not change the underlying hash state.
+func (d *crc16) Sum(in []byte) []byte {
+	return append(in, byte(d.crc>>8), byte(d.crc))
+}
+
+// Sum16 returns the current hash.
+func (d *crc16) Sum16() uint16 {
+	return d.crc
+}
+
+// Sum32 returns the current hash, to satisfy hash.Hash32.
+func (d *crc16) Sum32() uint32 {
+	return uint32(d.crc)
+}
+
+// Reset resets the Hash to its initial state.
+func (d *crc16) Reset() {
+	d.crc = CRC16_CCITT_INITIAL
+}
+
+// Size returns the number of bytes Sum will return.
+func (d *crc16) Size() int {
+	return 2
+}
+
+// BlockSize returns the hash's underlying block size.
+func (d *crc16) BlockSize() int {
+	return 1
+}
+
+// Write (via the embedded io.Writer interface) adds more data to the running hash.
+// It never returns an error.
+func (d *crc16) Write(p []byte) (n int, err error) {
+	for _, v := range p {
+		d.crc ^= uint16(v) << 8
+		for i := 0; i < 8; i++ {
+			if d.crc&0x8000 != 0 {
+				d.crc = d.crc<<1 ^ CRC16_CCITT_POLYNOMIAL
+			} else {
+				d.crc <<= 1
+			}
+		}
+	}
+	return len(p), nil
+}
+
+//#endregion
+//#region crc32 hash implementation
+
+// lockedCRC32 is the CRC-32 IEEE of the standard library with the lock that serializes the computations of Compute that share the instance
+type lockedCRC32 struct {
+	mu sync.Mutex
+	hash.Hash32
+}
+
+// mutex returns the lock of the instance.
+func (d *lockedCRC32) mutex() *sync.Mutex {
+	return &d.mu
+}
+
+//#endregion
+//#region constructors
+
+// NewCRC16CCITT creates a new hash.Hash32 computing the CRC-16 CCITT. Sum returns the 2 bytes of the CRC in big endian.
+func NewCRC16CCITT() hash.Hash32 {
+	return &crc16{crc: CRC16_CCITT_INITIAL}
+}
+
+// NewCRC32 creates a new hash.Hash32 computing the CRC-32 IEEE.
+func NewCRC32() hash.Hash32 {
+	return &lockedCRC32{Hash32: crc32.NewIEEE()}
+}
+
+// NewAlgorithm creates a new hash.Hash computing the checksum algorithm with the name.
+func NewAlgorithm(algorithm Algorithm) (hash.Hash, error) {
+
+	switch algorithm {
+	case ALGORITHM_XOR:
+		return New(), nil
+	case ALGORITHM_CRC16_CCITT:
+		return NewCRC16CCITT(), nil
+	case ALGORITHM_CRC32:
+		return NewCRC32(), nil
+	}
+
+	return nil, fmt.Errorf("failed to create checksum, the algorithm '%s' doesn't exist", algorithm)
+}
+
+//#endregion
+//#region package functions
+
+// Value returns the checksum stored in the result of a hash.Sum, that has up to 4 bytes in big endian.
+func Value(sum []byte) (uint32, error) {
+
+	if len(sum) > 4 {
+		return 0, fmt.Errorf("failed to get checksum value, the hash has %d bytes but the maximum is 4", len(sum))
+	}
+
+	var value uint32
+	for _, b := range sum {
+		value = value<<8 | uint32(b)
+	}
+
+	return value, nil
+}
+
+//#endregion
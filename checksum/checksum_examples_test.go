@@ -47,3 +47,44 @@ func Example_second() {
 	// Output:
 	// Hash is: 67
 }
+
+func ExampleNewAlgorithm() {
+	h, err := checksum.NewAlgorithm(checksum.ALGORITHM_CRC16_CCITT)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	h.Write([]byte("N4 G92 E0"))
+
+	value, err := checksum.Value(h.Sum(nil))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("N4 G92 E0*%d", value)
+
+	// Output:
+	// N4 G92 E0*38517
+}
+
+func ExampleFactory_Compute() {
+	factory, err := checksum.NewFactory(checksum.ALGORITHM_XOR)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// each call uses a new hash, so it can be called from several goroutines
+	value, err := factory.Compute([]byte("N4 G92 E0"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("N4 G92 E0*%d", value)
+
+	// Output:
+	// N4 G92 E0*67
+}
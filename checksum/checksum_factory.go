@@ -0,0 +1,85 @@
+// This file defines the factories of hashes and the computation of checksums that is safe from several goroutines.
+package checksum
+
+import (
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// Factory returns a new hash each time it's called, so each computation has his own state.
+type Factory func() hash.Hash
+
+// lockable is a hash of this package, it carries the lock that serializes the computations that share the instance
+type lockable interface {
+	mutex() *sync.Mutex
+}
+
+//#region constructors
+
+// NewFactory returns a Factory of the checksum algorithm with the name.
+func NewFactory(algorithm Algorithm) (Factory, error) {
+
+	if _, err := NewAlgorithm(algorithm); err != nil {
+		return nil, err
+	}
+
+	return func() hash.Hash {
+		h, _ := NewAlgorithm(algorithm)
+		return h
+	}, nil
+}
+
+//#endregion
+//#region package functions
+
+// Compute returns the checksum of data computed with a new hash of the factory.
+//
+// Each call has his own hash, so it can be called from several goroutines.
+func (f Factory) Compute(data []byte) (uint32, error) {
+	return compute(f(), data)
+}
+
+// Compute returns the checksum of data computed with the hash h.
+//
+// A hash keeps his state between Reset, Write and Sum, so the calls that share the instance are serialized by a lock of the instance,
+// while the calls with different instances run in parallel. The hashes of this package carry their own lock, and any other hash
+// must implement sync.Locker to be shared between goroutines, the hashes without lock aren't serialized.
+// Use a Factory to compute the checksums of the same algorithm in parallel.
+func Compute(h hash.Hash, data []byte) (uint32, error) {
+
+	if h == nil {
+		return 0, fmt.Errorf("failed to compute checksum, the hash is nil")
+	}
+
+	switch l := h.(type) {
+	case lockable:
+		l.mutex().Lock()
+		defer l.mutex().Unlock()
+	case sync.Locker:
+		l.Lock()
+		defer l.Unlock()
+	}
+
+	return compute(h, data)
+}
+
+//#endregion
+//#region private functions
+
+// compute resets the hash, writes data and returns the value of the sum
+func compute(h hash.Hash, data []byte) (uint32, error) {
+
+	if h == nil {
+		return 0, fmt.Errorf("failed to compute checksum, the hash is nil")
+	}
+
+	h.Reset()
+	if _, err := h.Write(data); err != nil {
+		return 0, fmt.Errorf("failed to compute checksum: %w", err)
+	}
+
+	return Value(h.Sum(nil))
+}
+
+//#endregion
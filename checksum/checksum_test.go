@@ -2,6 +2,9 @@ package checksum
 
 import (
 	"fmt"
+	"hash"
+	"hash/crc32"
+	"sync"
 	"testing"
 )
 
@@ -48,3 +51,159 @@ func TestConfiguration(t *testing.T) {
 		}
 	})
 }
+
+func TestCRC(t *testing.T) {
+
+	cases := map[string]struct {
+		algorithm Algorithm
+		line      string
+		sum       []byte
+	}{
+		"xor":         {ALGORITHM_XOR, "N4 G92 E0", []byte{67}},
+		"crc16 check": {ALGORITHM_CRC16_CCITT, "123456789", []byte{0x29, 0xB1}},
+		"crc16":       {ALGORITHM_CRC16_CCITT, "N4 G92 E0", []byte{0x96, 0x75}},
+		"crc32 check": {ALGORITHM_CRC32, "123456789", []byte{0xCB, 0xF4, 0x39, 0x26}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			h, err := NewAlgorithm(tc.algorithm)
+			if err != nil {
+				t.Errorf("got error %v, want nil", err)
+				return
+			}
+
+			h.Reset()
+			h.Write([]byte(tc.line))
+
+			if h.Size() != len(tc.sum) {
+				t.Errorf("got size %d, want %d", h.Size(), len(tc.sum))
+			}
+			if fmt.Sprint(h.Sum(nil)) != fmt.Sprint(tc.sum) {
+				t.Errorf("got %v, want %v", h.Sum(nil), tc.sum)
+			}
+		})
+	}
+
+	t.Run("crc16 sum16", func(t *testing.T) {
+		h := NewCRC16CCITT()
+		h.Write([]byte("123456789"))
+		if h.Sum32() != 0x29B1 {
+			t.Errorf("got %x, want 29b1", h.Sum32())
+		}
+	})
+
+	t.Run("unknown algorithm", func(t *testing.T) {
+		if _, err := NewAlgorithm("md5"); err == nil {
+			t.Errorf("got nil error, want error")
+		}
+	})
+}
+
+func TestValue(t *testing.T) {
+
+	cases := map[string]struct {
+		sum   []byte
+		value uint32
+		err   bool
+	}{
+		"empty":   {[]byte{}, 0, false},
+		"1 byte":  {[]byte{67}, 67, false},
+		"2 bytes": {[]byte{0x29, 0xB1}, 0x29B1, false},
+		"4 bytes": {[]byte{0xCB, 0xF4, 0x39, 0x26}, 0xCBF43926, false},
+		"5 bytes": {[]byte{1, 2, 3, 4, 5}, 0, true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			value, err := Value(tc.sum)
+			if (err != nil) != tc.err {
+				t.Errorf("got error %v, want error %v", err, tc.err)
+			}
+			if value != tc.value {
+				t.Errorf("got %d, want %d", value, tc.value)
+			}
+		})
+	}
+}
+
+// valueHash is a hash without lock, whose instances can't be compared
+type valueHash struct {
+	sums []byte
+}
+
+func (h valueHash) Write(p []byte) (int, error) { return len(p), nil }
+func (h valueHash) Sum(in []byte) []byte        { return append(in, h.sums...) }
+func (h valueHash) Reset()                      {}
+func (h valueHash) Size() int                   { return len(h.sums) }
+func (h valueHash) BlockSize() int              { return 1 }
+
+func TestCompute(t *testing.T) {
+
+	factory, err := NewFactory(ALGORITHM_CRC16_CCITT)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	shared, sharedIEEE := New(), NewCRC32()
+	lines := []string{"N3 T0", "N4 G92 E0", "N5 G28", "N6 G1 F1500.0"}
+	want := map[string][3]uint32{}
+	for _, line := range lines {
+		x, _ := Compute(New(), []byte(line))
+		crc, _ := factory.Compute([]byte(line))
+		want[line] = [3]uint32{x, crc, crc32.ChecksumIEEE([]byte(line))}
+	}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				line := lines[i%len(lines)]
+				x, err := Compute(shared, []byte(line))
+				if err != nil || x != want[line][0] {
+					t.Errorf("got %d error %v with the shared hash, want %d", x, err, want[line][0])
+				}
+				crc, err := factory.Compute([]byte(line))
+				if err != nil || crc != want[line][1] {
+					t.Errorf("got %d error %v with the factory, want %d", crc, err, want[line][1])
+				}
+				ieee, err := Compute(sharedIEEE, []byte(line))
+				if err != nil || ieee != want[line][2] {
+					t.Errorf("got %d error %v with the shared CRC-32, want %d", ieee, err, want[line][2])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	t.Run("nil hash", func(t *testing.T) {
+		if _, err := Compute(nil, []byte("G28")); err == nil {
+			t.Errorf("got nil error, want error")
+		}
+	})
+
+	t.Run("hash without lock", func(t *testing.T) {
+		if value, err := Compute(valueHash{sums: []byte{7}}, []byte("G28")); err != nil || value != 7 {
+			t.Errorf("got %d error %v, want 7", value, err)
+		}
+	})
+
+	t.Run("lock of the hashes of the package", func(t *testing.T) {
+		for _, h := range []hash.Hash{New(), NewCRC16CCITT(), NewCRC32()} {
+			if _, ok := h.(sync.Locker); ok {
+				t.Errorf("got %T implementing sync.Locker, want his lock unexported", h)
+			}
+			if _, ok := h.(lockable); !ok {
+				t.Errorf("got %T without lock, want a lockable hash", h)
+			}
+		}
+	})
+
+	t.Run("unknown algorithm", func(t *testing.T) {
+		if _, err := NewFactory("md5"); err == nil {
+			t.Errorf("got nil error, want error")
+		}
+	})
+}
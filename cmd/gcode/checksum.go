@@ -0,0 +1,94 @@
+// This file defines the checksum command.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/checksum"
+	"github.com/mauroalderete/gcode-core/pipeline"
+)
+
+// runChecksum numbers each line with gcode and appends his checksum, like a host does before sending it to the firmware.
+// The comments and the lines without gcode are removed, because the firmware doesn't receive them.
+// The rest of each line is copied as it is, it's the text that the checksum covers.
+func runChecksum(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+
+	flags := newFlagSet("checksum", stderr)
+	start := flags.Int("start", 1, "number of the first line")
+	reset := flags.Bool("reset", false, "begin with a M110 that resets the line number of the firmware")
+	algorithm := flags.String("algorithm", "", "checksum algorithm: xor, crc16-ccitt or crc32, xor by default")
+	profile := flags.String("profile", "", "built-in profile or JSON file of the machine, it sets the default algorithm")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	h, err := selectHash(*algorithm, *profile)
+	if err != nil {
+		return err
+	}
+
+	r, err := input(flags.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if *start < 0 || (*reset && *start < 1) {
+		return fmt.Errorf("failed to number the lines, the first line must be positive, or greater than 0 with -reset")
+	}
+
+	// the M110 is numbered like the rest, with the number before the first line
+	number := *start
+	if *reset {
+		r = io.NopCloser(io.MultiReader(strings.NewReader("M110\n"), r))
+		number--
+	}
+
+	p, err := pipeline.New(func(config pipeline.PipelineConfigurer) error {
+		if err := config.AddTransform("strip", pipeline.TransformFunc(strip)); err != nil {
+			return err
+		}
+		if err := config.AddTransform("renumber", pipeline.NewRenumber(number)); err != nil {
+			return err
+		}
+		return config.AddTransform("checksum", pipeline.NewChecksum(h))
+	})
+	if err != nil {
+		return err
+	}
+
+	return p.Run(context.Background(), stdout, r)
+}
+
+// strip removes the comments and the lines without gcode, because the firmware doesn't receive them
+func strip(line pipeline.Line, state pipeline.State, emit pipeline.Emit) error {
+
+	code := strings.TrimSpace(stripComment(line.Text))
+	if code == "" {
+		return nil
+	}
+
+	return emit(state.ParseLine(line.Number, code))
+}
+
+// selectHash returns the factory of the hashes of the algorithm, or of the machine of the profile if the algorithm is empty.
+// It's the XOR checksum if both are empty.
+func selectHash(algorithm string, profile string) (checksum.Factory, error) {
+
+	if algorithm != "" {
+		return checksum.NewFactory(checksum.Algorithm(algorithm))
+	}
+
+	if profile != "" {
+		p, err := loadProfile(profile)
+		if err != nil {
+			return nil, err
+		}
+		return checksum.NewFactory(p.ChecksumAlgorithm())
+	}
+
+	return checksum.New, nil
+}
@@ -0,0 +1,201 @@
+// gcode is a command line tool that exposes the operations of the library to check and modify gcode files.
+//
+// Usage:
+//
+//	gcode <command> [flags] [file]
+//
+// The commands are:
+//
+//	validate    parses each line, verifies the checksums and lints the file
+//	checksum    adds or refreshes the line numbers and the checksums
+//	stats       prints the estimated time, the filament used and the bounding boxes
+//	transform   translates, scales or skews the moves
+//	strip       removes the comments and the empty lines
+//	format      rewrites each block in a normalized way
+//
+// Each command reads the file, or the standard input if the file is missing or is "-", and writes to the standard output.
+// The exit code is 0 on success, 1 if the file has errors or violations, and 2 if the command couldn't run.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/machine"
+)
+
+const (
+	EXIT_OK      = 0
+	EXIT_FAILURE = 1
+	EXIT_ERROR   = 2
+)
+
+// errFailure is returned by a command when the file has errors, it's already reported so it only changes the exit code
+var errFailure = errors.New("the file has errors")
+
+// command is a subcommand of the tool
+type command struct {
+	name        string
+	description string
+	run         func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error
+}
+
+// commands stores the subcommands in the order they are listed in the usage
+var commands = []command{
+	{"validate", "parse each line, verify the checksums and lint the file", runValidate},
+	{"checksum", "add or refresh the line numbers and the checksums", runChecksum},
+	{"stats", "print the estimated time, the filament used and the bounding boxes", runStats},
+	{"transform", "translate, scale or skew the moves", runTransform},
+	{"strip", "remove the comments and the empty lines", runStrip},
+	{"format", "rewrite each block in a normalized way", runFormat},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command of the arguments and returns the exit code
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(stderr)
+		if len(args) == 0 {
+			return EXIT_ERROR
+		}
+		return EXIT_OK
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+
+		err := c.run(args[1:], stdin, stdout, stderr)
+		switch {
+		case err == nil:
+			return EXIT_OK
+		case errors.Is(err, errFailure):
+			return EXIT_FAILURE
+		case errors.Is(err, flag.ErrHelp):
+			return EXIT_OK
+		default:
+			fmt.Fprintf(stderr, "gcode %s: %v\n", c.name, err)
+			return EXIT_ERROR
+		}
+	}
+
+	fmt.Fprintf(stderr, "gcode: unknown command '%s'\n", args[0])
+	usage(stderr)
+	return EXIT_ERROR
+}
+
+// usage writes the list of commands
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: gcode <command> [flags] [file]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s  %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(w, "\nRun 'gcode <command> -h' to see the flags of a command.\n")
+}
+
+// newFlagSet returns a flag set that reports his errors to stderr instead of exiting
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("gcode "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	return flags
+}
+
+// input opens the file of the arguments, or returns stdin if there isn't one or it's "-"
+func input(args []string, stdin io.Reader) (io.ReadCloser, error) {
+
+	switch {
+	case len(args) > 1:
+		return nil, fmt.Errorf("failed to open input, expected one file but got %d", len(args))
+	case len(args) == 0 || args[0] == "-":
+		return io.NopCloser(stdin), nil
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input: %w", err)
+	}
+
+	return f, nil
+}
+
+// lines reads each line and calls fn with the line, without the end of line characters, and his block.
+// The block is nil if the line hasn't gcode, and err is the error of the parse if it couldn't be parsed.
+func lines(r io.Reader, fn func(line string, b *gcodeblock.GcodeBlock, err error) error) error {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), gcodeblock.SCANNER_MAX_LINE_SIZE)
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		var b *gcodeblock.GcodeBlock
+		var err error
+		if gcodeblock.HasGcode(line) {
+			b, err = gcodeblock.Parse(line)
+		}
+
+		if err := fn(line, b, err); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read gcode: %w", err)
+	}
+
+	return nil
+}
+
+// blocker returns the block as a block.Blocker, nil if there isn't a block
+func blocker(b *gcodeblock.GcodeBlock) block.Blocker {
+	if b == nil {
+		return nil
+	}
+	return b
+}
+
+// loadProfile returns the built-in profile with the name, or loads it from the JSON file with the name
+func loadProfile(name string) (*machine.MachineProfile, error) {
+
+	if profile, err := machine.Builtin(name); err == nil {
+		return profile, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load profile, '%s' isn't a built-in profile (%s) nor a file: %w", name, strings.Join(machine.Builtins(), ", "), err)
+	}
+	defer f.Close()
+
+	return machine.Load(f)
+}
+
+// stripComment removes the comment of a line, the semicolons between quotes aren't comments
+func stripComment(line string) string {
+
+	quoted := false
+	for i, c := range line {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return strings.TrimRight(line[:i], " \t")
+			}
+		}
+	}
+
+	return strings.TrimRight(line, " \t")
+}
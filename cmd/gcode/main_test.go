@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func execute(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Usage(t *testing.T) {
+
+	var cases = map[string]struct {
+		args []string
+		code int
+	}{
+		"without command": {args: []string{}, code: EXIT_ERROR},
+		"help":            {args: []string{"help"}, code: EXIT_OK},
+		"unknown command": {args: []string{"print"}, code: EXIT_ERROR},
+		"unknown flag":    {args: []string{"strip", "-unknown"}, code: EXIT_ERROR},
+		"command help":    {args: []string{"strip", "-h"}, code: EXIT_OK},
+		"two files":       {args: []string{"strip", "a.gcode", "b.gcode"}, code: EXIT_ERROR},
+		"missing file":    {args: []string{"strip", "missing.gcode"}, code: EXIT_ERROR},
+		"unknown profile": {args: []string{"stats", "-profile", "missing"}, code: EXIT_ERROR},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			code, _, _ := execute(t, "", tc.args...)
+			if code != tc.code {
+				t.Errorf("got exit code %d, want %d", code, tc.code)
+			}
+		})
+	}
+}
+
+func TestRun_File(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "part.gcode")
+	if err := os.WriteFile(path, []byte("G28 ; home\n\nG1 X10\n"), 0644); err != nil {
+		t.Fatalf("failed to write the file: %v", err)
+	}
+
+	code, stdout, _ := execute(t, "", "strip", path)
+	if code != EXIT_OK || stdout != "G28\nG1 X10\n" {
+		t.Errorf("got exit code %d and output %q, want 0 and the file stripped", code, stdout)
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	var cases = map[string]struct {
+		source string
+		args   []string
+		code   int
+		output []string
+	}{
+		"valid": {
+			source: "G28\nM109 S210\nG1 X10 E1 F1200\n",
+			code:   EXIT_OK,
+			output: []string{},
+		},
+		"bad checksum": {
+			source: "N1 G28*19\n",
+			code:   EXIT_FAILURE,
+			output: []string{"<stdin>:1: error: the checksum doesn't match (checksum)"},
+		},
+		"gap": {
+			source: "N1 G28*18\nN3 G28*16\n",
+			args:   []string{"-no-lint"},
+			code:   EXIT_FAILURE,
+			output: []string{"<stdin>:2: error: expected the line number 2 but found 3, some lines are missing (line-number)"},
+		},
+		"unparseable line": {
+			source: "G28\nM117 Hello world\n",
+			code:   EXIT_OK,
+			output: []string{"<stdin>:2: warning:"},
+		},
+		"garbage": {
+			source: "G28\nG1 X10 Y\nhello world\n",
+			args:   []string{"-no-lint"},
+			code:   EXIT_FAILURE,
+			output: []string{"<stdin>:2: error: the line can't be parsed", "<stdin>:3: error: the line can't be parsed"},
+		},
+		"klipper start macro": {
+			source: "PRINT_START EXTRUDER=210\nG28\nG1 X10 E1 F1200\n",
+			args:   []string{"-firmware", "klipper"},
+			code:   EXIT_OK,
+			output: []string{"<stdin>:1: warning: the line can't be parsed", "<stdin>:3: warning:", "(cold-extrusion)"},
+		},
+		"lint": {
+			source: "G28\nG1 X10 E1 F1200\n",
+			code:   EXIT_FAILURE,
+			output: []string{"<stdin>:2: error:", "(cold-extrusion)"},
+		},
+		"without lint": {
+			source: "G28\nG1 X10 E1 F1200\n",
+			args:   []string{"-no-lint"},
+			code:   EXIT_OK,
+			output: []string{},
+		},
+		"dialect of the profile": {
+			source: "G28\nM109 S200\nM355 S1\n",
+			args:   []string{"-profile", "klipper-corexy"},
+			code:   EXIT_OK,
+			output: []string{"(unknown-command)"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			code, stdout, stderr := execute(t, tc.source, append([]string{"validate"}, tc.args...)...)
+
+			if code != tc.code {
+				t.Errorf("got exit code %d, want %d, stderr: %s", code, tc.code, stderr)
+			}
+			if len(tc.output) == 0 && stdout != "" {
+				t.Errorf("got output %q, want empty", stdout)
+			}
+			for _, want := range tc.output {
+				if !strings.Contains(stdout, want) {
+					t.Errorf("got output %q, want it contains %q", stdout, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_JSON(t *testing.T) {
+
+	code, stdout, _ := execute(t, "N1 G28*19\n", "validate", "-json")
+	if code != EXIT_FAILURE {
+		t.Errorf("got exit code %d, want %d", code, EXIT_FAILURE)
+	}
+
+	var diagnostics []map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &diagnostics); err != nil {
+		t.Fatalf("got error %v, want valid json", err)
+	}
+	if len(diagnostics) != 1 || diagnostics[0]["rule"] != RULE_CHECKSUM || diagnostics[0]["line"] != 1.0 {
+		t.Errorf("got %v, want a checksum diagnostic at line 1", diagnostics)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+
+	var cases = map[string]struct {
+		source string
+		args   []string
+		want   string
+	}{
+		"add": {
+			source: "G28 ; home\n\nG1  X10 E12345.67891\n",
+			want:   "N1 G28*18\nN2 G1  X10 E12345.67891*56\n",
+		},
+		"refresh": {
+			source: "N10 G28*99\nN11 G1 X10*99\n",
+			args:   []string{"-start", "5"},
+			want:   "N5 G28*22\nN6 G1 X10*87\n",
+		},
+		"unparseable line": {
+			source: "M117 Hello ; comment\n",
+			want:   "N1 M117 Hello*71\n",
+		},
+		"reset": {
+			source: "G28\n",
+			args:   []string{"-reset"},
+			want:   "N0 M110 N0*125\nN1 G28*18\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			code, stdout, stderr := execute(t, tc.source, append([]string{"checksum"}, tc.args...)...)
+
+			if code != EXIT_OK {
+				t.Fatalf("got exit code %d, want 0, stderr: %s", code, stderr)
+			}
+			if stdout != tc.want {
+				t.Errorf("got %q, want %q", stdout, tc.want)
+			}
+
+			// the output must be valid
+			if code, out, _ := execute(t, stdout, "validate", "-no-lint"); code != EXIT_OK {
+				t.Errorf("got validation exit code %d with %s, want 0", code, out)
+			}
+		})
+	}
+}
+
+func TestChecksum_Algorithm(t *testing.T) {
+
+	var cases = map[string]struct {
+		args []string
+		want string
+	}{
+		"crc16-ccitt": {args: []string{"-algorithm", "crc16-ccitt"}, want: "N2 G1 X10*22376\n"},
+		"crc32":       {args: []string{"-algorithm", "crc32"}, want: "N2 G1 X10*1409936749\n"},
+		"profile":     {args: []string{"-profile", "prusa-mk3"}, want: "N2 G1 X10*83\n"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := append(append([]string{"checksum"}, tc.args...), "-start", "2")
+			code, stdout, stderr := execute(t, "G1 X10\n", args...)
+
+			if code != EXIT_OK {
+				t.Fatalf("got exit code %d, want 0, stderr: %s", code, stderr)
+			}
+			if stdout != tc.want {
+				t.Errorf("got %q, want %q", stdout, tc.want)
+			}
+
+			validate := append(append([]string{"validate", "-no-lint"}, tc.args...), "-")
+			if code, out, _ := execute(t, stdout, validate...); code != EXIT_OK {
+				t.Errorf("got validation exit code %d with %s, want 0", code, out)
+			}
+		})
+	}
+
+	if code, _, _ := execute(t, "G1 X10\n", "checksum", "-algorithm", "md5"); code != EXIT_ERROR {
+		t.Errorf("got exit code %d with an unknown algorithm, want %d", code, EXIT_ERROR)
+	}
+}
+
+func TestStats(t *testing.T) {
+
+	source := `G28
+M83
+G1 Z0.3 F600
+G1 X10 Y10 F6000
+G1 X110 Y10 E5 F1800
+G1 E-0.8
+G1 X110 Y250 F6000
+`
+
+	code, stdout, _ := execute(t, source, "stats")
+	if code != EXIT_OK {
+		t.Errorf("got exit code %d, want 0", code)
+	}
+	for _, want := range []string{"filament:    5.0mm", "retractions: 1, 0.8mm", "extruding:   X 10.000..110.000, Y 10.000..10.000"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("got output %q, want it contains %q", stdout, want)
+		}
+	}
+
+	code, stdout, _ = execute(t, source, "stats", "-profile", "prusa-mk3", "-json")
+	if code != EXIT_FAILURE {
+		t.Errorf("got exit code %d, want %d for a move out of the bed", code, EXIT_FAILURE)
+	}
+
+	var s stats
+	if err := json.Unmarshal([]byte(stdout), &s); err != nil {
+		t.Fatalf("got error %v, want valid json", err)
+	}
+	if s.Violation == nil || s.Violation.Line != 7 {
+		t.Errorf("got violation %v, want the line 7", s.Violation)
+	}
+	if s.Time <= 0 || s.Bounds["travel"] == nil {
+		t.Errorf("got stats %+v, want a time and the travel bounds", s)
+	}
+}
+
+func TestTransform(t *testing.T) {
+
+	var cases = map[string]struct {
+		source string
+		args   []string
+		want   string
+	}{
+		"translate": {
+			source: "G28\nG1 X10 Y20 E1 F1200 ; perimeter\nG1 Z0.2\nM117 Hello world\n",
+			args:   []string{"-translate", "5,-5"},
+			want:   "G28\nG1 X15 Y15 E1 F1200 ; perimeter\nG1 Z0.2\nM117 Hello world\n",
+		},
+		"relative": {
+			source: "G91\nG1 X10 Y20\n",
+			args:   []string{"-translate", "5,5", "-scale", "2"},
+			want:   "G91\nG1 X20 Y40\n",
+		},
+		"scale": {
+			source: "G1 X10 Y20 Z1\n",
+			args:   []string{"-scale", "0.5"},
+			want:   "G1 X5 Y10 Z1\n",
+		},
+		"skew": {
+			source: "G1 X10 Y10\nG1 Y20\n",
+			args:   []string{"-skew", "45"},
+			want:   "G1 X20 Y10\nG1 Y20 X30\n",
+		},
+		"precision": {
+			source: "G1 X123.4567 Y98.7654 E12345.67891 F1800.5 ; wall\n",
+			args:   []string{"-translate", "1,0"},
+			want:   "G1 X124.457 Y98.7654 E12345.67891 F1800.5 ; wall\n",
+		},
+		"offset": {
+			source: "G1 X10\nG92 X0\nG1 X5\n",
+			args:   []string{"-translate", "1"},
+			want:   "G1 X11\nG92 X1\nG1 X6\n",
+		},
+		"arc": {
+			source: "G1 X10 Y0\nG2 X0 Y-10 I-10 J0 E1\n",
+			args:   []string{"-scale", "2"},
+			want:   "G1 X20 Y0\nG2 X0 Y-20 I-20 J0 E1\n",
+		},
+		"mirrored arc": {
+			source: "G1 X10 Y0\nG2 X0 Y-10 I-10 J0\n",
+			args:   []string{"-scale", "-1,1"},
+			want:   "G1 X-10 Y0\nG3 X0 Y-10 I10 J0\n",
+		},
+		"linearized arc": {
+			source: "G1 X1 Y0\nG2 X-1 Y0 R1 E1\n",
+			args:   []string{"-scale", "2,1"},
+			want:   "G1 X2 Y0\nG1 X1 Y-0.866 E0.33333\nG1 X-1 Y-0.866 E0.66667\nG1 X-2 Y0 E1\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			code, stdout, stderr := execute(t, tc.source, append([]string{"transform"}, tc.args...)...)
+
+			if code != EXIT_OK {
+				t.Fatalf("got exit code %d, want 0, stderr: %s", code, stderr)
+			}
+			if stdout != tc.want {
+				t.Errorf("got %q, want %q", stdout, tc.want)
+			}
+		})
+	}
+}
+
+func TestStripFormat(t *testing.T) {
+
+	source := "; header\nG1   X1 Y98.7654 ; move\n\n\n\nM117 Hello \"a;b\" world ; message\nN3 G1  X10*99\n"
+
+	_, stdout, _ := execute(t, source, "strip")
+	want := "G1   X1 Y98.7654\nM117 Hello \"a;b\" world\nN3 G1  X10*99\n"
+	if stdout != want {
+		t.Errorf("got strip %q, want %q", stdout, want)
+	}
+
+	_, stdout, _ = execute(t, source, "format")
+	want = "; header\nG1 X1 Y98.7654 ; move\n\nM117 Hello \"a;b\" world ; message\nN3 G1 X10*99\n"
+	if stdout != want {
+		t.Errorf("got format %q, want %q", stdout, want)
+	}
+}
@@ -0,0 +1,177 @@
+// This file defines the stats command.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/mauroalderete/gcode-core/analysis"
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/estimator"
+)
+
+// stats is the summary of a file written by the stats command
+type stats struct {
+	Time      time.Duration    `json:"time"`
+	Layers    int              `json:"layers"`
+	Filament  analysis.Usage   `json:"filament"`
+	Extruders []analysis.Usage `json:"extruders"`
+	Retract   retractions      `json:"retractions"`
+	Bounds    map[string]*box  `json:"bounds"`
+	Violation *violation       `json:"violation,omitempty"`
+}
+
+// violation is the first move out of the machine, with the number of his line starting at 1
+type violation struct {
+	Line   int    `json:"line"`
+	Text   string `json:"text"`
+	Reason string `json:"reason"`
+}
+
+// retractions counts the retractions and his total length
+type retractions struct {
+	Count  int     `json:"count"`
+	Length float64 `json:"length"`
+}
+
+// box is a bounding box that isn't empty, the empty ones are nil
+type box struct {
+	Min [3]float64 `json:"min"`
+	Max [3]float64 `json:"max"`
+}
+
+// runStats estimates the print time, the filament used and the bounding boxes of a file.
+// With a profile it validates the moves against the machine and fails if any move leaves it.
+func runStats(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+
+	flags := newFlagSet("stats", stderr)
+	profile := flags.String("profile", "", "built-in profile or JSON file of the machine")
+	asJSON := flags.Bool("json", false, "write the stats as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	estimatorOptions := []estimator.EstimatorConfigurationCallbackable{}
+	analyzerOptions := []analysis.AnalyzerConfigurationCallbackable{}
+	toolpathOptions := []analysis.ToolpathConfigurationCallbackable{}
+
+	if *profile != "" {
+		p, err := loadProfile(*profile)
+		if err != nil {
+			return err
+		}
+		estimatorOptions = append(estimatorOptions, p.EstimatorOption())
+		analyzerOptions = append(analyzerOptions, p.AnalyzerOption())
+		toolpathOptions = append(toolpathOptions, p.ToolpathOption())
+	}
+
+	e, err := estimator.New(estimatorOptions...)
+	if err != nil {
+		return err
+	}
+	a, err := analysis.New(analyzerOptions...)
+	if err != nil {
+		return err
+	}
+	t, err := analysis.NewToolpath(toolpathOptions...)
+	if err != nil {
+		return err
+	}
+
+	r, err := input(flags.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	err = lines(r, func(line string, b *gcodeblock.GcodeBlock, _ error) error {
+		e.Add(line, blocker(b))
+		a.Add(line, blocker(b))
+		t.Add(line, blocker(b))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	result := e.Close()
+	statistics := a.Statistics()
+	bounds := t.Bounds()
+
+	s := stats{
+		Time:      result.Total,
+		Layers:    len(result.Layers),
+		Filament:  statistics.Total(),
+		Extruders: statistics.Extruders,
+		Retract:   retractions{Count: statistics.Retractions, Length: statistics.RetractLength},
+		Bounds: map[string]*box{
+			"extruding": newBox(bounds.Extruding),
+			"travel":    newBox(bounds.Travel),
+			"all":       newBox(bounds.All),
+		},
+	}
+
+	if v := t.Violation(); v != nil {
+		s.Violation = &violation{Line: v.Line + 1, Text: v.Text, Reason: v.Reason}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(s)
+	} else {
+		err = writeStats(stdout, s)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write stats: %w", err)
+	}
+
+	if s.Violation != nil {
+		return errFailure
+	}
+
+	return nil
+}
+
+// writeStats writes the stats as text
+func writeStats(w io.Writer, s stats) error {
+
+	text := fmt.Sprintf("time:        %s\n", s.Time.Round(time.Second))
+	text += fmt.Sprintf("layers:      %d\n", s.Layers)
+	text += fmt.Sprintf("filament:    %.1fmm, %.2fcm³, %.2fg\n", s.Filament.Length, s.Filament.Volume/1000, s.Filament.Weight)
+
+	if len(s.Extruders) > 1 {
+		for i, u := range s.Extruders {
+			text += fmt.Sprintf("  T%d:        %.1fmm, %.2fcm³, %.2fg\n", i, u.Length, u.Volume/1000, u.Weight)
+		}
+	}
+
+	text += fmt.Sprintf("retractions: %d, %.1fmm\n", s.Retract.Count, s.Retract.Length)
+
+	for _, name := range []string{"extruding", "travel", "all"} {
+		b := s.Bounds[name]
+		if b == nil {
+			text += fmt.Sprintf("%-12s none\n", name+":")
+			continue
+		}
+		text += fmt.Sprintf("%-12s X %.3f..%.3f, Y %.3f..%.3f, Z %.3f..%.3f\n", name+":", b.Min[0], b.Max[0], b.Min[1], b.Max[1], b.Min[2], b.Max[2])
+	}
+
+	if s.Violation != nil {
+		text += fmt.Sprintf("violation:   %d '%s': %s\n", s.Violation.Line, s.Violation.Text, s.Violation.Reason)
+	}
+
+	_, err := io.WriteString(w, text)
+	return err
+}
+
+// newBox returns the box to write, nil if it's empty
+func newBox(b analysis.Box) *box {
+	if b.Empty() || math.IsInf(b.Min[0], 0) {
+		return nil
+	}
+	return &box{Min: b.Min, Max: b.Max}
+}
@@ -0,0 +1,83 @@
+// This file defines the strip and the format commands.
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+)
+
+// runStrip removes the comments, the empty lines and the spaces at the ends of the lines.
+// The lines are kept as they are otherwise, so their checksums remain valid.
+func runStrip(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+
+	flags := newFlagSet("strip", stderr)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	r, err := input(flags.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return lines(r, func(line string, _ *gcodeblock.GcodeBlock, _ error) error {
+
+		code := stripComment(strings.TrimSpace(line))
+		if code == "" {
+			return nil
+		}
+
+		if _, err := fmt.Fprintln(stdout, code); err != nil {
+			return fmt.Errorf("failed to write gcode: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// runFormat rewrites each block with a single space between his gcodes and the comment at the end.
+// The lines that can't be parsed are only trimmed, and the consecutive empty lines are reduced to one.
+func runFormat(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+
+	flags := newFlagSet("format", stderr)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	r, err := input(flags.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	empty := false
+
+	return lines(r, func(line string, b *gcodeblock.GcodeBlock, _ error) error {
+
+		// the words are copied as they are written, the values keep all their decimals
+		formatted := strings.TrimSpace(line)
+		if b != nil {
+			code := stripComment(formatted)
+			comment := strings.TrimSpace(formatted[len(code):])
+			formatted = strings.Join(strings.Fields(code), " ")
+			if comment != "" {
+				formatted += " " + comment
+			}
+		}
+
+		if formatted == "" && empty {
+			return nil
+		}
+		empty = formatted == ""
+
+		if _, err := fmt.Fprintln(stdout, formatted); err != nil {
+			return fmt.Errorf("failed to write gcode: %w", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,377 @@
+// This file defines the transform command.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/analysis"
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/gcode"
+	"github.com/mauroalderete/gcode-core/interpreter"
+	"github.com/mauroalderete/gcode-core/pipeline"
+)
+
+// runTransform translates, scales and skews the moves of a file.
+//
+// The coordinates are scaled first, then skewed and then translated. The extrusion isn't changed.
+// The arcs are kept when the transformation preserves their shape, otherwise they are split into linear moves.
+// The line numbers and the checksums of the transformed blocks are removed, the checksum command adds them again.
+func runTransform(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+
+	flags := newFlagSet("transform", stderr)
+	translate := flags.String("translate", "", "offset to add, as x,y or x,y,z")
+	scale := flags.String("scale", "", "factor to multiply, as a single factor for X and Y, or x,y or x,y,z")
+	skew := flags.Float64("skew", 0, "angle in degrees to skew X along Y, to correct an XY axes that aren't square")
+	linearize := flags.Bool("linearize-arcs", false, "split the arcs into linear moves always")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	t := &transformer{linearize: *linearize}
+
+	factors, err := vector(*scale, 1)
+	if err != nil {
+		return fmt.Errorf("failed to parse the scale: %w", err)
+	}
+	if *scale != "" && !strings.Contains(*scale, ",") {
+		factors[1] = factors[0]
+	}
+
+	offsets, err := vector(*translate, 0)
+	if err != nil {
+		return fmt.Errorf("failed to parse the translation: %w", err)
+	}
+
+	t.m = [3][3]float64{
+		{factors[0], factors[1] * math.Tan(*skew*math.Pi/180), 0},
+		{0, factors[1], 0},
+		{0, 0, factors[2]},
+	}
+	t.t = offsets
+
+	r, err := input(flags.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	p, err := pipeline.New(func(config pipeline.PipelineConfigurer) error {
+		return config.AddTransform("transform", t)
+	})
+	if err != nil {
+		return err
+	}
+
+	return p.Run(context.Background(), stdout, r)
+}
+
+//#region transformer struct
+
+// transformer applies an affine transformation p' = m p + t to the coordinates of the moves.
+// The words that don't change are copied as they are written.
+type transformer struct {
+	m [3][3]float64
+	t [3]float64
+
+	// linearize splits the arcs always
+	linearize bool
+}
+
+func (t *transformer) Apply(line pipeline.Line, state pipeline.State, emit pipeline.Emit) error {
+
+	b := interpreter.Block(line.Block)
+	if b == nil {
+		return emit(line)
+	}
+
+	for _, l := range t.rewrite(line, b, state.State) {
+		if err := emit(l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *transformer) Flush(state pipeline.State, emit pipeline.Emit) error {
+	return nil
+}
+
+// rewrite returns the lines that replace the line of the block at the state before it
+func (t *transformer) rewrite(line pipeline.Line, b block.Blocker, state interpreter.State) []pipeline.Line {
+
+	command := b.Command()
+	number, ok := gcode.NumericAddress(command)
+	if !ok || command.Word() != 'G' {
+		return []pipeline.Line{line}
+	}
+
+	var values map[byte]float64
+	switch number {
+	case 0, 1:
+		values = t.coordinates(b, state.Relative, state.Position)
+	case 2, 3:
+		return t.arc(line, b, number == 2, state)
+	case 92:
+		values = t.coordinates(b, false, state.Position)
+	}
+
+	if len(values) == 0 {
+		return []pipeline.Line{line}
+	}
+
+	return []pipeline.Line{line.Rewrite(values)}
+}
+
+// coordinates returns the coordinates of the block after the transformation, only those that change and that the block writes
+// or depend on them. The absolute coordinates not written are taken from the position.
+func (t *transformer) coordinates(b block.Blocker, relative bool, position [4]float64) map[byte]float64 {
+
+	values := [3]float64{}
+	given := [3]bool{}
+
+	for axis, word := range []byte{'X', 'Y', 'Z'} {
+		values[axis], given[axis] = interpreter.Parameter(b, word)
+	}
+
+	// the relative coordinates of the axes not written are 0, the absolute ones are the current position
+	point := values
+	for axis := range point {
+		if !given[axis] && !relative {
+			point[axis] = position[axis]
+		}
+	}
+
+	transformed := t.apply(point, !relative)
+
+	written := map[byte]float64{}
+	for axis, word := range []byte{'X', 'Y', 'Z'} {
+		if !t.changes(axis, !relative) {
+			continue
+		}
+		for from := range given {
+			if given[from] && (from == axis || t.m[axis][from] != 0) {
+				written[word] = transformed[axis]
+			}
+		}
+	}
+
+	return written
+}
+
+// changes returns true if the transformation changes the coordinate of the axis
+func (t *transformer) changes(axis int, translate bool) bool {
+
+	for from := range t.m[axis] {
+		if (from == axis && t.m[axis][from] != 1) || (from != axis && t.m[axis][from] != 0) {
+			return true
+		}
+	}
+
+	return translate && t.t[axis] != 0
+}
+
+// apply transforms a point, the relative coordinates aren't translated
+func (t *transformer) apply(p [3]float64, translate bool) [3]float64 {
+
+	transformed := [3]float64{}
+
+	for i := range transformed {
+		for j := range p {
+			transformed[i] += t.m[i][j] * p[j]
+		}
+		if translate {
+			transformed[i] += t.t[i]
+		}
+	}
+
+	return transformed
+}
+
+// similar returns true if the transformation keeps the shape of the arcs in the XY plane, and his scale
+func (t *transformer) similar() (bool, float64) {
+	return t.m[0][1] == 0 && t.m[1][0] == 0 && math.Abs(t.m[0][0]) == math.Abs(t.m[1][1]), t.m[0][0]
+}
+
+// arc transforms an arc, or splits it into linear moves if the transformation doesn't keep his shape
+func (t *transformer) arc(line pipeline.Line, b block.Blocker, clockwise bool, state interpreter.State) []pipeline.Line {
+
+	target := state.Target(b)
+	similar, scale := t.similar()
+
+	if similar && !t.linearize {
+		values := t.coordinates(b, state.Relative, state.Position)
+
+		// a mirror changes the direction of the arc
+		if t.m[0][0]*t.m[1][1] < 0 && clockwise {
+			values['G'] = 3
+		} else if t.m[0][0]*t.m[1][1] < 0 {
+			values['G'] = 2
+		}
+
+		if v, ok := interpreter.Parameter(b, 'I'); ok && t.m[0][0] != 1 {
+			values['I'] = v * t.m[0][0]
+		}
+		if v, ok := interpreter.Parameter(b, 'J'); ok && t.m[1][1] != 1 {
+			values['J'] = v * t.m[1][1]
+		}
+		if v, ok := interpreter.Parameter(b, 'R'); ok && math.Abs(scale) != 1 {
+			values['R'] = v * math.Abs(scale)
+		}
+
+		if len(values) == 0 {
+			return []pipeline.Line{line}
+		}
+		return []pipeline.Line{line.Rewrite(values)}
+	}
+
+	start := state.Position
+	points := arcPoints(start, target, clockwise, b)
+	texts := make([]string, 0, len(points))
+
+	for i, p := range points {
+
+		// the relative segments are written as the difference with the previous point
+		previous := start
+		if i > 0 {
+			previous = points[i-1]
+		}
+
+		point, e := [3]float64{p[0], p[1], p[2]}, p[3]
+		if state.Relative {
+			point = [3]float64{p[0] - previous[0], p[1] - previous[1], p[2] - previous[2]}
+		}
+		if state.RelativeE {
+			e = p[3] - previous[3]
+		}
+
+		transformed := t.apply(point, !state.Relative)
+
+		text := fmt.Sprintf("G1 X%s Y%s", coordinate(transformed[0]), coordinate(transformed[1]))
+		if p[2] != start[2] {
+			text += " Z" + coordinate(transformed[2])
+		}
+		if p[3] != start[3] {
+			text += " E" + strconv.FormatFloat(math.Round(e*1e5)/1e5, 'f', -1, 64)
+		}
+		if f, ok := interpreter.Parameter(b, 'F'); ok && i == 0 {
+			text += " F" + coordinate(f)
+		}
+
+		texts = append(texts, text)
+	}
+	if comment := strings.TrimSpace(b.Comment()); comment != "" {
+		texts[len(texts)-1] += " " + comment
+	}
+
+	lines := make([]pipeline.Line, 0, len(texts))
+	for _, text := range texts {
+		lines = append(lines, pipeline.ParseLine(line.Number, text))
+	}
+
+	return lines
+}
+
+//#endregion
+//#region private functions
+
+// arcPoints returns the points that split an arc into segments of analysis.ARC_SEGMENT_LENGTH, the last one is the target
+func arcPoints(start [4]float64, target [4]float64, clockwise bool, b block.Blocker) [][4]float64 {
+
+	i, _ := interpreter.Parameter(b, 'I')
+	j, _ := interpreter.Parameter(b, 'J')
+
+	if r, ok := interpreter.Parameter(b, 'R'); ok && !interpreter.Has(b, 'I') && !interpreter.Has(b, 'J') {
+		dx, dy := target[0]-start[0], target[1]-start[1]
+		d := math.Hypot(dx, dy)
+		if d == 0 {
+			return [][4]float64{target}
+		}
+
+		sign := 1.0
+		if clockwise != (r < 0) {
+			sign = -1
+		}
+		h := math.Sqrt(math.Max(0, r*r-d*d/4))
+		i = (start[0]+target[0])/2 - sign*h*dy/d - start[0]
+		j = (start[1]+target[1])/2 + sign*h*dx/d - start[1]
+	}
+
+	cx, cy := start[0]+i, start[1]+j
+	radius := math.Hypot(i, j)
+
+	tX, tY := target[0]-cx, target[1]-cy
+	angle := math.Atan2(-i*tY+j*tX, -i*tX-j*tY)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	if clockwise {
+		angle -= 2 * math.Pi
+	}
+	if target[0] == start[0] && target[1] == start[1] && (angle == 0 || angle == -2*math.Pi) {
+		angle = 2 * math.Pi
+		if clockwise {
+			angle = -angle
+		}
+	}
+
+	length := math.Hypot(radius*angle, target[2]-start[2])
+	segments := int(math.Max(1, math.Floor(length/analysis.ARC_SEGMENT_LENGTH)))
+	startAngle := math.Atan2(-j, -i)
+
+	points := make([][4]float64, 0, segments)
+
+	for n := 1; n < segments; n++ {
+		fraction := float64(n) / float64(segments)
+		a := startAngle + angle*fraction
+
+		points = append(points, [4]float64{
+			cx + radius*math.Cos(a),
+			cy + radius*math.Sin(a),
+			start[2] + (target[2]-start[2])*fraction,
+			start[3] + (target[3]-start[3])*fraction,
+		})
+	}
+
+	return append(points, target)
+}
+
+// vector parses a list of up to three numbers separated by commas, the missing ones are the default value
+func vector(s string, value float64) ([3]float64, error) {
+
+	v := [3]float64{value, value, value}
+	if s == "" {
+		return v, nil
+	}
+
+	fields := strings.Split(s, ",")
+	if len(fields) > 3 {
+		return v, fmt.Errorf("expected up to 3 numbers but got %d", len(fields))
+	}
+
+	for i, field := range fields {
+		n, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return v, fmt.Errorf("'%s' isn't a number", field)
+		}
+		v[i] = n
+	}
+
+	return v, nil
+}
+
+// coordinate formats a coordinate with up to 3 decimals
+func coordinate(v float64) string {
+	v = math.Round(v*1000) / 1000
+	if v == 0 {
+		v = 0
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+//#endregion
@@ -0,0 +1,193 @@
+// This file defines the validate command.
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/integrity"
+	"github.com/mauroalderete/gcode-core/lint"
+	"github.com/mauroalderete/gcode-core/machine"
+)
+
+const (
+	// RULE_PARSE reports the lines that can't be parsed, RULE_CHECKSUM the checksums that don't match or are missing
+	// and RULE_LINE_NUMBER the line numbers that aren't consecutive
+	RULE_PARSE       = "parse"
+	RULE_CHECKSUM    = "checksum"
+	RULE_LINE_NUMBER = "line-number"
+)
+
+// textCommands are the commands whose argument is a text, like a message or a file name, that the parser doesn't accept
+var textCommands = map[string]bool{"M23": true, "M28": true, "M30": true, "M32": true, "M117": true, "M118": true, "M928": true}
+
+// extendedCommand matches the name of an extended command, like the macros of Klipper, that isn't a gcode word
+var extendedCommand = regexp.MustCompile(`^[A-Z_][A-Z_][A-Z0-9_]*$`)
+
+// lineNumber matches the line number at the beginning of a line
+var lineNumber = regexp.MustCompile(`^N\d+$`)
+
+// runValidate parses each line, verifies the line numbers and the checksums, and lints the file.
+// It fails if any diagnostic is an error, like a line that can't be parsed.
+func runValidate(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+
+	flags := newFlagSet("validate", stderr)
+	firmware := flags.String("firmware", "", "firmware of the dialect: marlin, klipper, reprapfirmware or grbl")
+	profile := flags.String("profile", "", "built-in profile or JSON file of the machine, it sets the firmware")
+	asJSON := flags.Bool("json", false, "write the diagnostics as JSON")
+	noLint := flags.Bool("no-lint", false, "skip the lint rules")
+	algorithm := flags.String("algorithm", "", "checksum algorithm: xor, crc16-ccitt or crc32, by default the one of the profile or xor")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	options := []lint.LinterConfigurationCallbackable{}
+	if *noLint {
+		options = append(options, func(config lint.LinterConfigurer) error {
+			return config.SetRules()
+		})
+	}
+
+	dialect := machine.Firmware(*firmware)
+	if *profile != "" {
+		p, err := loadProfile(*profile)
+		if err != nil {
+			return err
+		}
+		dialect = p.Firmware
+	}
+	if dialect != "" {
+		options = append(options, func(config lint.LinterConfigurer) error {
+			return config.SetFirmware(dialect)
+		})
+	}
+
+	linter, err := lint.New(options...)
+	if err != nil {
+		return err
+	}
+
+	r, err := input(flags.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	h, err := selectHash(*algorithm, *profile)
+	if err != nil {
+		return err
+	}
+
+	checker, err := integrity.New(func(config integrity.CheckerConfigurer) error {
+		return config.SetHashFactory(h)
+	})
+	if err != nil {
+		return err
+	}
+
+	diagnostics := []lint.Diagnostic{}
+	number := 0
+
+	err = lines(r, func(line string, b *gcodeblock.GcodeBlock, err error) error {
+		number++
+
+		if err != nil {
+			diagnostics = append(diagnostics, lint.Diagnostic{
+				Line:     number,
+				Rule:     RULE_PARSE,
+				Severity: parseSeverity(line),
+				Message:  fmt.Sprintf("the line can't be parsed: %v", err),
+			})
+		}
+
+		checker.Add(line)
+		linter.Add(line, blocker(b))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	diagnostics = append(diagnostics, integrityDiagnostics(checker.Report())...)
+	diagnostics = append(diagnostics, linter.Diagnostics()...)
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Line < diagnostics[j].Line
+	})
+
+	if *asJSON {
+		err = lint.WriteJSON(stdout, diagnostics)
+	} else {
+		err = lint.WriteText(stdout, inputName(flags.Args()), diagnostics)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, d := range diagnostics {
+		if d.Severity == lint.SEVERITY_ERROR {
+			return errFailure
+		}
+	}
+
+	return nil
+}
+
+// integrityDiagnostics returns the problems of the line numbers and the checksums as diagnostics
+func integrityDiagnostics(r *integrity.Report) []lint.Diagnostic {
+
+	diagnostics := []lint.Diagnostic{}
+
+	add := func(line int, rule string, message string) {
+		diagnostics = append(diagnostics, lint.Diagnostic{Line: line, Rule: rule, Severity: lint.SEVERITY_ERROR, Message: message})
+	}
+
+	for _, line := range r.BadChecksums {
+		add(line, RULE_CHECKSUM, "the checksum doesn't match")
+	}
+	for _, line := range r.MissingChecksums {
+		add(line, RULE_CHECKSUM, "the line has a number but not a checksum")
+	}
+	for _, line := range r.Unnumbered {
+		add(line, RULE_LINE_NUMBER, "the line hasn't a number but the previous ones have")
+	}
+	for _, jump := range r.Gaps {
+		add(jump.Line, RULE_LINE_NUMBER, fmt.Sprintf("expected the line number %d but found %d, some lines are missing", jump.Expected, jump.Found))
+	}
+	for _, jump := range r.Duplicates {
+		add(jump.Line, RULE_LINE_NUMBER, fmt.Sprintf("expected the line number %d but found %d, it's duplicated", jump.Expected, jump.Found))
+	}
+
+	return diagnostics
+}
+
+// parseSeverity returns the severity of a line that can't be parsed. It's a warning for the text commands and the extended commands,
+// which the parser doesn't support but the firmware does, and an error for the rest.
+func parseSeverity(line string) lint.Severity {
+
+	fields := strings.Fields(stripComment(strings.TrimSpace(line)))
+	if len(fields) > 0 && lineNumber.MatchString(fields[0]) {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return lint.SEVERITY_ERROR
+	}
+
+	name := strings.SplitN(fields[0], "*", 2)[0]
+	if textCommands[strings.ToUpper(name)] || extendedCommand.MatchString(name) {
+		return lint.SEVERITY_WARNING
+	}
+
+	return lint.SEVERITY_ERROR
+}
+
+// inputName returns the name of the input to prefix the diagnostics
+func inputName(args []string) string {
+	if len(args) == 0 || args[0] == "-" {
+		return "<stdin>"
+	}
+	return args[0]
+}
@@ -0,0 +1,582 @@
+// estimator package estimates the time that a machine takes to print a gcode file.
+//
+// The slicers estimate the time too, but they often differ from the real one because the firmware
+// doesn't move at the requested speeds: it accelerates, decelerates and slows down at each corner.
+// The Estimator emulates it with a lookahead motion planner that executes each move with a trapezoidal speed profile,
+// respecting the maximum feedrates and accelerations of each axis and the speed allowed at each junction.
+//
+// Two firmwares are emulated through the Model of the Limits:
+//
+//   - Marlin, with the classic jerk or the junction deviation, applying the M201, M203, M204 and M205 commands found in the file.
+//   - Klipper, with the square corner velocity, applying the M204 and SET_VELOCITY_LIMIT commands found in the file.
+//
+// The Estimator follows the position, the positioning modes, the units and the feedrate with an interpreter.Interpreter,
+// and tracks the feedrate multiplier (M220) by itself.
+// The arcs (G2 and G3) are split into segments of ARC_SEGMENT_LENGTH like the firmwares do, and the dwells (G4) are added to the time.
+// The time spent homing and heating isn't known, so G28, M109 and M190 only stop the machine.
+package estimator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/gcode"
+	"github.com/mauroalderete/gcode-core/interpreter"
+	"github.com/mauroalderete/gcode-core/layer"
+)
+
+const (
+	// ARC_SEGMENT_LENGTH is the length in mm of the segments in which the arcs are split
+	ARC_SEGMENT_LENGTH = 1.0
+
+	// DEFAULT_FEEDRATE is the feedrate in mm/s used until the file sets one
+	DEFAULT_FEEDRATE = 25.0
+)
+
+// velocityLimitRegex matches the SET_VELOCITY_LIMIT command of Klipper
+var velocityLimitRegex = regexp.MustCompile(`^SET_VELOCITY_LIMIT((?:\s+[A-Z_]+=[\d.]+)*)\s*$`)
+
+//#region result struct
+
+// LayerTime is the time estimated to print a layer.
+type LayerTime struct {
+	layer.Layer
+
+	// Time is the time estimated
+	Time time.Duration
+}
+
+// Result is the time estimated to print a gcode file.
+type Result struct {
+	// Total is the time estimated to print the whole file
+	Total time.Duration
+
+	// Lines stores the time estimated of each line added, in the same order.
+	// The lines that don't move the machine have a zero time, except the dwells.
+	Lines []time.Duration
+
+	// Layers stores the time estimated of each layer found in the file, see the layer package
+	Layers []LayerTime
+}
+
+//#endregion
+//#region estimator struct
+
+// Estimator receives the lines of a gcode file one by one and estimates the time to print them.
+type Estimator struct {
+	// limits are the current limits of the machine
+	limits Limits
+
+	// planner plans the moves
+	planner *planner
+
+	// segmenter finds the layers of the file
+	segmenter *layer.Segmenter
+
+	// layers stores the layers found
+	layers []layer.Layer
+
+	// seconds stores the time of each line added, and elapsed their sum
+	seconds []float64
+	elapsed float64
+
+	// machine tracks the state of the machine, and factor is the feedrate multiplier
+	machine interpreter.Interpreter
+	factor  float64
+}
+
+// Add receives the next line of the gcode file.
+//
+// b is the block parsed from the line, it can be nil if the line doesn't contain gcode or it couldn't be parsed.
+// The lines without gcode must be added too, to keep the count of the lines and to find the layers.
+func (e *Estimator) Add(line string, b block.Blocker) {
+
+	index := len(e.seconds)
+	e.seconds = append(e.seconds, 0)
+
+	if l, ok := e.segmenter.Add(line, b); ok {
+		e.layers = append(e.layers, l)
+	}
+
+	code := line
+	if i := strings.IndexByte(code, ';'); i >= 0 {
+		code = code[:i]
+	}
+	code = strings.TrimSpace(code)
+
+	if match := velocityLimitRegex.FindStringSubmatch(code); match != nil {
+		e.setVelocityLimit(match[1])
+		return
+	}
+
+	if b = interpreter.Block(b); b == nil {
+		return
+	}
+
+	before := e.machine.State()
+	e.machine.Add(line, b)
+
+	command := b.Command()
+	number, ok := gcode.NumericAddress(command)
+	if !ok {
+		return
+	}
+
+	switch command.Word() {
+	case 'G':
+		e.gcommand(index, number, b, before)
+	case 'M':
+		e.mcommand(number, b)
+	}
+}
+
+// Elapsed returns the time estimated of the lines added until now.
+//
+// The moves still queued in the planner aren't included, their speeds depend on the next moves,
+// so the time lags behind the last line by up to the size of the lookahead buffer.
+func (e *Estimator) Elapsed() time.Duration {
+	return seconds(e.elapsed)
+}
+
+// Planned returns the time estimated of the lines added until now, including the moves still queued in the planner.
+//
+// The queued moves are taken with the speeds planned until now, as if the machine stopped after the last line,
+// so it's the time at which the last line ends if no more lines were added.
+func (e *Estimator) Planned() time.Duration {
+	return seconds(e.elapsed + e.planner.pending())
+}
+
+// Close stops the machine, executing the moves still queued in the planner, and returns the time estimated.
+//
+// The Estimator mustn't be used after closing it.
+func (e *Estimator) Close() *Result {
+
+	e.planner.flush()
+
+	if l, ok := e.segmenter.Close(); ok {
+		e.layers = append(e.layers, l)
+	}
+
+	result := &Result{
+		Lines: make([]time.Duration, len(e.seconds)),
+	}
+
+	total := 0.0
+	for i, s := range e.seconds {
+		result.Lines[i] = seconds(s)
+		total += s
+	}
+	result.Total = seconds(total)
+
+	for _, l := range e.layers {
+		layerTotal := 0.0
+		for _, s := range e.seconds[l.Start:l.End] {
+			layerTotal += s
+		}
+		result.Layers = append(result.Layers, LayerTime{Layer: l, Time: seconds(layerTotal)})
+	}
+
+	return result
+}
+
+// gcommand executes a G command, before is the state of the machine before the command
+func (e *Estimator) gcommand(index int, number float64, b block.Blocker, before interpreter.State) {
+
+	switch number {
+	case 0, 1:
+		e.moveTo(index, before.Machine(before.Position), before.Machine(before.Target(b)))
+
+	case 2, 3:
+		e.arc(index, number == 2, b, before)
+
+	case 4:
+		e.planner.flush()
+		if p, ok := interpreter.Parameter(b, 'P'); ok {
+			e.seconds[index] += p / 1000
+			e.elapsed += p / 1000
+		} else if s, ok := interpreter.Parameter(b, 'S'); ok {
+			e.seconds[index] += s
+			e.elapsed += s
+		}
+
+	case 28:
+		e.planner.flush()
+	}
+}
+
+// mcommand executes a M command
+func (e *Estimator) mcommand(number float64, b block.Blocker) {
+
+	marlin := e.limits.Model != MODEL_SQUARE_CORNER_VELOCITY
+
+	switch number {
+	case 109, 190, 400:
+		e.planner.flush()
+
+	case 220:
+		if s, ok := interpreter.Parameter(b, 'S'); ok && s > 0 {
+			e.factor = s / 100
+		}
+
+	case 201:
+		if marlin {
+			setAxes(&e.limits.MaxAcceleration, b)
+		}
+	case 203:
+		if marlin {
+			setAxes(&e.limits.MaxFeedrate, b)
+		}
+	case 205:
+		if marlin {
+			setAxes(&e.limits.Jerk, b)
+			if j, ok := interpreter.Parameter(b, 'J'); ok && j > 0 {
+				e.limits.JunctionDeviation = j
+			}
+			if s, ok := interpreter.Parameter(b, 'S'); ok {
+				e.limits.MinimumFeedrate = s
+			}
+			if t, ok := interpreter.Parameter(b, 'T'); ok {
+				e.limits.MinimumTravelFeedrate = t
+			}
+		}
+
+	case 204:
+		s, hasS := interpreter.Parameter(b, 'S')
+		p, hasP := interpreter.Parameter(b, 'P')
+		r, hasR := interpreter.Parameter(b, 'R')
+		t, hasT := interpreter.Parameter(b, 'T')
+
+		if !marlin {
+			// Klipper takes the lower of P and T when S isn't present
+			if !hasS && hasP && hasT {
+				s, hasS = math.Min(p, t), true
+			}
+			if hasS && s > 0 {
+				e.limits.Acceleration, e.limits.TravelAcceleration = s, s
+			}
+			return
+		}
+
+		if hasS && s > 0 {
+			e.limits.Acceleration, e.limits.TravelAcceleration = s, s
+		}
+		if hasP && p > 0 {
+			e.limits.Acceleration = p
+		}
+		if hasR && r > 0 {
+			e.limits.RetractAcceleration = r
+		}
+		if hasT && t > 0 {
+			e.limits.TravelAcceleration = t
+		}
+	}
+}
+
+// setVelocityLimit applies the arguments of the SET_VELOCITY_LIMIT command of Klipper
+func (e *Estimator) setVelocityLimit(arguments string) {
+
+	for _, argument := range strings.Fields(arguments) {
+		name, raw, _ := strings.Cut(argument, "=")
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value <= 0 {
+			continue
+		}
+
+		switch name {
+		case "VELOCITY":
+			e.limits.MaxFeedrate[AXIS_X], e.limits.MaxFeedrate[AXIS_Y] = value, value
+		case "ACCEL":
+			e.limits.Acceleration, e.limits.TravelAcceleration = value, value
+		case "SQUARE_CORNER_VELOCITY":
+			e.limits.SquareCornerVelocity = value
+		}
+	}
+}
+
+// arc splits an arc into linear segments and moves the machine through them
+func (e *Estimator) arc(index int, clockwise bool, b block.Blocker, before interpreter.State) {
+
+	target := before.Machine(before.Target(b))
+	start := before.Machine(before.Position)
+	scale := before.Scale()
+
+	i, hasI := interpreter.Parameter(b, 'I')
+	j, hasJ := interpreter.Parameter(b, 'J')
+	i, j = i*scale, j*scale
+
+	if r, ok := interpreter.Parameter(b, 'R'); ok && !hasI && !hasJ {
+		r *= scale
+		dx, dy := target[AXIS_X]-start[AXIS_X], target[AXIS_Y]-start[AXIS_Y]
+		d := math.Hypot(dx, dy)
+		if d == 0 {
+			e.moveTo(index, start, target)
+			return
+		}
+
+		sign := 1.0
+		if clockwise != (r < 0) {
+			sign = -1
+		}
+		h := math.Sqrt(math.Max(0, r*r-d*d/4))
+		cx := (start[AXIS_X]+target[AXIS_X])/2 - sign*h*dy/d
+		cy := (start[AXIS_Y]+target[AXIS_Y])/2 + sign*h*dx/d
+		i, j = cx-start[AXIS_X], cy-start[AXIS_Y]
+	}
+
+	cx, cy := start[AXIS_X]+i, start[AXIS_Y]+j
+	radius := math.Hypot(i, j)
+
+	// angle between the vectors from the center to the start and to the target
+	pX, pY := -i, -j
+	tX, tY := target[AXIS_X]-cx, target[AXIS_Y]-cy
+	angle := math.Atan2(pX*tY-pY*tX, pX*tX+pY*tY)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	if clockwise {
+		angle -= 2 * math.Pi
+	}
+	if target[AXIS_X] == start[AXIS_X] && target[AXIS_Y] == start[AXIS_Y] && angle == 0 {
+		// a full circle
+		angle = 2 * math.Pi
+		if clockwise {
+			angle = -angle
+		}
+	}
+
+	length := math.Hypot(radius*angle, target[AXIS_Z]-start[AXIS_Z])
+	segments := int(math.Max(1, math.Floor(length/ARC_SEGMENT_LENGTH)))
+
+	startAngle := math.Atan2(pY, pX)
+
+	from := start
+	for n := 1; n < segments; n++ {
+		fraction := float64(n) / float64(segments)
+		a := startAngle + angle*fraction
+
+		point := [4]float64{
+			cx + radius*math.Cos(a),
+			cy + radius*math.Sin(a),
+			start[AXIS_Z] + (target[AXIS_Z]-start[AXIS_Z])*fraction,
+			start[AXIS_E] + (target[AXIS_E]-start[AXIS_E])*fraction,
+		}
+		e.moveTo(index, from, point)
+		from = point
+	}
+
+	e.moveTo(index, from, target)
+}
+
+// moveTo queues a linear move between two positions in machine coordinates
+func (e *Estimator) moveTo(index int, from [4]float64, to [4]float64) {
+
+	var delta [4]float64
+	for axis := range delta {
+		delta[axis] = to[axis] - from[axis]
+	}
+
+	m := move{line: index}
+
+	xyz := math.Sqrt(delta[AXIS_X]*delta[AXIS_X] + delta[AXIS_Y]*delta[AXIS_Y] + delta[AXIS_Z]*delta[AXIS_Z])
+	if xyz > 1e-9 {
+		m.kinematic = true
+		m.distance = xyz
+	} else {
+		m.distance = math.Abs(delta[AXIS_E])
+	}
+
+	if m.distance < 1e-9 {
+		return
+	}
+
+	extrudes := delta[AXIS_E] != 0
+	speed := e.feedrate() * e.factor
+
+	if m.kinematic && extrudes {
+		speed = math.Max(speed, e.limits.MinimumFeedrate)
+	} else {
+		speed = math.Max(speed, e.limits.MinimumTravelFeedrate)
+	}
+
+	switch {
+	case !m.kinematic:
+		m.accel = e.limits.RetractAcceleration
+	case extrudes:
+		m.accel = e.limits.Acceleration
+	default:
+		m.accel = e.limits.TravelAcceleration
+	}
+
+	if e.limits.Model == MODEL_SQUARE_CORNER_VELOCITY {
+		speed, m.accel = e.klipperLimits(m, delta, speed)
+	} else {
+		speed, m.accel = e.marlinLimits(m, delta, speed)
+	}
+
+	m.nominalV2 = speed * speed
+
+	// the direction of the move
+	norm := m.distance
+	if e.limits.Model != MODEL_SQUARE_CORNER_VELOCITY {
+		norm = math.Sqrt(dot(delta, delta))
+	} else if m.kinematic {
+		delta[AXIS_E] = 0
+	}
+	for axis := range delta {
+		m.unit[axis] = delta[axis] / norm
+	}
+
+	e.planner.add(m)
+}
+
+// feedrate returns the feedrate of the moves in mm/s
+func (e *Estimator) feedrate() float64 {
+
+	s := e.machine.State()
+	if s.Feedrate == 0 {
+		return DEFAULT_FEEDRATE
+	}
+
+	return s.Feedrate * s.Scale() / 60
+}
+
+// marlinLimits limits the speed and the acceleration of a move by the maximum of each axis
+func (e *Estimator) marlinLimits(m move, delta [4]float64, speed float64) (float64, float64) {
+
+	accel := m.accel
+
+	for axis, d := range delta {
+		d = math.Abs(d)
+		if d == 0 {
+			continue
+		}
+
+		if axisSpeed := speed * d / m.distance; axisSpeed > e.limits.MaxFeedrate[axis] {
+			speed *= e.limits.MaxFeedrate[axis] / axisSpeed
+		}
+
+		if axisAccel := accel * d / m.distance; axisAccel > e.limits.MaxAcceleration[axis] {
+			accel *= e.limits.MaxAcceleration[axis] / axisAccel
+		}
+	}
+
+	return speed, accel
+}
+
+// klipperLimits limits the speed and the acceleration of a move like Klipper, that limits the XY speed globally and Z separately
+func (e *Estimator) klipperLimits(m move, delta [4]float64, speed float64) (float64, float64) {
+
+	if !m.kinematic {
+		return math.Min(speed, e.limits.MaxFeedrate[AXIS_E]), math.Min(m.accel, e.limits.MaxAcceleration[AXIS_E])
+	}
+
+	speed = math.Min(speed, e.limits.MaxFeedrate[AXIS_X])
+	accel := m.accel
+
+	if dz := math.Abs(delta[AXIS_Z]); dz > 0 {
+		ratio := m.distance / dz
+		speed = math.Min(speed, e.limits.MaxFeedrate[AXIS_Z]*ratio)
+		accel = math.Min(accel, e.limits.MaxAcceleration[AXIS_Z]*ratio)
+	}
+
+	return speed, accel
+}
+
+//#endregion
+//#region constructor
+
+// New returns an Estimator ready to receive the first line of a gcode file.
+//
+// By default it emulates Marlin with the limits returned by MarlinLimits.
+func New(options ...EstimatorConfigurationCallbackable) (*Estimator, error) {
+
+	estimator := &Estimator{
+		limits:    MarlinLimits(),
+		segmenter: layer.New(),
+		factor:    1,
+	}
+
+	estimator.planner = &planner{
+		limits: &estimator.limits,
+		size:   PLANNER_BUFFER_SIZE,
+		execute: func(line int, s float64) {
+			estimator.seconds[line] += s
+			estimator.elapsed += s
+		},
+	}
+
+	configurator := &estimatorConfigurator{estimator: estimator}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	return estimator, nil
+}
+
+//#endregion
+//#region package functions
+
+// Estimate reads a gcode file and returns the time estimated to print it.
+//
+// Each line is parsed with gcodeblock.Parse. The lines that can't be parsed, like a M117 with an unquoted message,
+// are taken as lines without gcode.
+func Estimate(r io.Reader, options ...EstimatorConfigurationCallbackable) (*Result, error) {
+
+	e, err := New(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), gcodeblock.SCANNER_MAX_LINE_SIZE)
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		var b block.Blocker
+		if gcodeblock.HasGcode(line) {
+			if parsed, err := gcodeblock.Parse(line); err == nil {
+				b = parsed
+			}
+		}
+
+		e.Add(line, b)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read gcode: %w", err)
+	}
+
+	return e.Close(), nil
+}
+
+//#endregion
+//#region private functions
+
+// setAxes sets the value of each axis present in the parameters of the block
+func setAxes(values *[4]float64, b block.Blocker) {
+	for axis, word := range []byte{'X', 'Y', 'Z', 'E'} {
+		if v, ok := interpreter.Parameter(b, word); ok && v > 0 {
+			values[axis] = v
+		}
+	}
+}
+
+// seconds converts seconds to a time.Duration
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
+
+//#endregion
@@ -0,0 +1,58 @@
+// This file defines an estimatorConfigurator as an object that implement the EstimatorConfigurer
+// interface to allow the caller to configure a new Estimator.
+package estimator
+
+import "fmt"
+
+// EstimatorConfigurer contains the configurable options that define the machine emulated by an Estimator.
+type EstimatorConfigurer interface {
+	// Set the limits of the machine at the beginning of the file
+	SetLimits(limits Limits) error
+
+	// Set the number of moves of the lookahead buffer of the planner
+	SetBufferSize(size int) error
+}
+
+// EstimatorConfigurationCallbackable is the signature of the callbacks that the New constructor waiting receives to configure the new Estimator instance.
+type EstimatorConfigurationCallbackable func(config EstimatorConfigurer) error
+
+// estimatorConfigurator satisfy EstimatorConfigurer, it applies each option directly over the Estimator in construction.
+type estimatorConfigurator struct {
+	estimator *Estimator
+}
+
+// SetLimits sets the limits of the machine. By default are the limits returned by MarlinLimits.
+//
+// The speeds and the accelerations must be positive.
+func (ec *estimatorConfigurator) SetLimits(limits Limits) error {
+
+	if limits.Model > MODEL_SQUARE_CORNER_VELOCITY {
+		return fmt.Errorf("failed set limits, model %d isn't supported", limits.Model)
+	}
+
+	for axis := AXIS_X; axis <= AXIS_E; axis++ {
+		if limits.MaxFeedrate[axis] <= 0 || limits.MaxAcceleration[axis] <= 0 {
+			return fmt.Errorf("failed set limits, the maximum feedrate and acceleration of each axis must be positive")
+		}
+	}
+
+	if limits.Acceleration <= 0 || limits.RetractAcceleration <= 0 || limits.TravelAcceleration <= 0 {
+		return fmt.Errorf("failed set limits, the accelerations must be positive")
+	}
+
+	ec.estimator.limits = limits
+
+	return nil
+}
+
+// SetBufferSize sets the number of moves of the lookahead buffer. By default is PLANNER_BUFFER_SIZE.
+func (ec *estimatorConfigurator) SetBufferSize(size int) error {
+
+	if size < 1 {
+		return fmt.Errorf("failed set buffer size, it must be at least 1")
+	}
+
+	ec.estimator.planner.size = size
+
+	return nil
+}
@@ -0,0 +1,93 @@
+// This file defines the Limits of the machine that the planner respects and the profiles with their default values.
+package estimator
+
+// Model is the algorithm used to limit the speed at the junction of two moves.
+type Model int
+
+const (
+	// MODEL_JERK is the classic jerk of Marlin, the maximum instant change of speed of each axis
+	MODEL_JERK Model = iota
+
+	// MODEL_JUNCTION_DEVIATION is the junction deviation of Marlin, the default since Marlin 2.0
+	MODEL_JUNCTION_DEVIATION
+
+	// MODEL_SQUARE_CORNER_VELOCITY is the square corner velocity of Klipper
+	MODEL_SQUARE_CORNER_VELOCITY
+)
+
+const (
+	AXIS_X = iota
+	AXIS_Y
+	AXIS_Z
+	AXIS_E
+)
+
+// Limits are the kinematic limits of the machine.
+//
+// The arrays are indexed by AXIS_X, AXIS_Y, AXIS_Z and AXIS_E. The speeds are in mm/s and the accelerations in mm/s².
+// The gcode file can change them with M201, M203, M204 and M205 on Marlin, and with M204 and SET_VELOCITY_LIMIT on Klipper.
+type Limits struct {
+	// Model is the algorithm used to limit the speed at the junctions
+	Model Model
+
+	// MaxFeedrate is the maximum speed of each axis
+	MaxFeedrate [4]float64
+
+	// MaxAcceleration is the maximum acceleration of each axis
+	MaxAcceleration [4]float64
+
+	// Acceleration is used by the moves that extrude, RetractAcceleration by the moves of the extruder alone
+	// and TravelAcceleration by the moves that don't extrude
+	Acceleration        float64
+	RetractAcceleration float64
+	TravelAcceleration  float64
+
+	// Jerk is the maximum instant change of speed of each axis, used by MODEL_JERK
+	Jerk [4]float64
+
+	// JunctionDeviation is the distance in mm to the virtual arc of a junction, used by MODEL_JUNCTION_DEVIATION
+	JunctionDeviation float64
+
+	// SquareCornerVelocity is the maximum speed at a 90 degrees corner, used by MODEL_SQUARE_CORNER_VELOCITY
+	SquareCornerVelocity float64
+
+	// MinimumFeedrate is the minimum speed of the moves that extrude and MinimumTravelFeedrate of the moves that don't extrude
+	MinimumFeedrate       float64
+	MinimumTravelFeedrate float64
+}
+
+//#region package functions
+
+// MarlinLimits returns the default limits of Marlin 2, that uses the junction deviation.
+//
+// Set the Model to MODEL_JERK to emulate a firmware built with the classic jerk.
+func MarlinLimits() Limits {
+	return Limits{
+		Model:               MODEL_JUNCTION_DEVIATION,
+		MaxFeedrate:         [4]float64{300, 300, 5, 25},
+		MaxAcceleration:     [4]float64{3000, 3000, 100, 10000},
+		Acceleration:        3000,
+		RetractAcceleration: 3000,
+		TravelAcceleration:  3000,
+		Jerk:                [4]float64{10, 10, 0.3, 5},
+		JunctionDeviation:   0.013,
+	}
+}
+
+// KlipperLimits returns the default limits of Klipper, with a max_velocity of 300 mm/s and a max_accel of 3000 mm/s².
+//
+// Klipper doesn't limit the speed and the acceleration of X and Y separately, so they are set to the global ones.
+// The limits of the extruder are those used by the moves of the extruder alone.
+func KlipperLimits() Limits {
+	return Limits{
+		Model:                MODEL_SQUARE_CORNER_VELOCITY,
+		MaxFeedrate:          [4]float64{300, 300, 5, 50},
+		MaxAcceleration:      [4]float64{3000, 3000, 100, 3000},
+		Acceleration:         3000,
+		RetractAcceleration:  3000,
+		TravelAcceleration:   3000,
+		SquareCornerVelocity: 5,
+	}
+}
+
+//#endregion
@@ -0,0 +1,320 @@
+// This file implements the lookahead motion planner that computes the time of each move.
+//
+// Like the planner of the firmwares, it keeps a buffer with the last moves received and computes the maximum speed
+// at each junction that still allows to stop at the end of the buffer.
+// When the buffer is full, the oldest move is executed with a trapezoidal speed profile: it accelerates from his entry speed,
+// cruises at his nominal speed and decelerates to the entry speed of the next move.
+package estimator
+
+import (
+	"math"
+)
+
+const (
+	// PLANNER_BUFFER_SIZE is the default number of moves of the lookahead buffer, like the BLOCK_BUFFER_SIZE of Marlin
+	PLANNER_BUFFER_SIZE = 16
+)
+
+// move is a single linear move queued in the planner
+type move struct {
+	// line is the index of the line that produced the move
+	line int
+
+	// distance is the length of the move in mm
+	distance float64
+
+	// accel is the acceleration of the move
+	accel float64
+
+	// nominalV2 is the square of the speed requested
+	nominalV2 float64
+
+	// maxEntryV2 is the square of the maximum speed allowed at the junction with the previous move
+	maxEntryV2 float64
+
+	// entryV2 is the square of the entry speed planned
+	entryV2 float64
+
+	// unit is the direction of the move, normalized over XYZE or over XYZ depending on the model
+	unit [4]float64
+
+	// kinematic is true if the move moves any of X, Y or Z
+	kinematic bool
+}
+
+// planner plans the moves received and reports the time of each one of them when it is executed
+type planner struct {
+	// limits are the limits of the machine
+	limits *Limits
+
+	// size is the number of moves of the lookahead buffer
+	size int
+
+	// queue stores the moves not executed yet
+	queue []move
+
+	// locked is true when the entry speed of the first move of the queue can't change anymore,
+	// because the previous move was already executed
+	locked bool
+
+	// previous is the last move received, it's nil after the machine stops
+	previous *move
+
+	// execute receives the time of each move executed
+	execute func(line int, seconds float64)
+}
+
+// add queues a move, computing the maximum speed at the junction with the previous one
+func (p *planner) add(m move) {
+
+	m.maxEntryV2 = p.junction(m)
+	p.queue = append(p.queue, m)
+	p.previous = &m
+
+	p.replan()
+
+	for len(p.queue) > p.size {
+		p.pop()
+	}
+}
+
+// flush executes all moves queued until the machine stops
+func (p *planner) flush() {
+
+	p.replan()
+
+	for len(p.queue) > 0 {
+		p.pop()
+	}
+
+	p.locked = false
+	p.previous = nil
+}
+
+// replan computes the entry speed of each move queued.
+//
+// The reverse pass ensures that each move can decelerate to the entry speed of the next one, being the last exit speed zero.
+// The forward pass ensures that each move can accelerate to the entry speed of the next one.
+func (p *planner) replan() {
+
+	first := 0
+	if p.locked {
+		first = 1
+	}
+
+	next := 0.0
+	for i := len(p.queue) - 1; i >= first; i-- {
+		m := &p.queue[i]
+		m.entryV2 = math.Min(m.maxEntryV2, next+2*m.accel*m.distance)
+		next = m.entryV2
+	}
+
+	for i := 0; i < len(p.queue)-1; i++ {
+		m := &p.queue[i]
+		if limit := m.entryV2 + 2*m.accel*m.distance; p.queue[i+1].entryV2 > limit {
+			p.queue[i+1].entryV2 = limit
+		}
+	}
+}
+
+// pop executes the first move of the queue
+func (p *planner) pop() {
+
+	m := p.queue[0]
+
+	exitV2 := 0.0
+	if len(p.queue) > 1 {
+		exitV2 = p.queue[1].entryV2
+	}
+
+	p.execute(m.line, trapezoid(m, exitV2))
+
+	p.queue = p.queue[1:]
+	p.locked = true
+}
+
+// pending returns the time of the moves queued, executed with the speeds planned until now as if the machine stopped after the last one
+func (p *planner) pending() float64 {
+
+	total := 0.0
+	for i, m := range p.queue {
+		exitV2 := 0.0
+		if i+1 < len(p.queue) {
+			exitV2 = p.queue[i+1].entryV2
+		}
+		total += trapezoid(m, exitV2)
+	}
+
+	return total
+}
+
+// junction returns the square of the maximum speed at the junction of the previous move and m
+func (p *planner) junction(m move) float64 {
+
+	switch p.limits.Model {
+	case MODEL_JERK:
+		return p.jerkJunction(m)
+	case MODEL_SQUARE_CORNER_VELOCITY:
+		return p.squareCornerJunction(m)
+	}
+
+	return p.deviationJunction(m)
+}
+
+// jerkJunction implements the classic jerk of Marlin.
+//
+// The speed of each axis can change instantly, at the junction, up to his jerk.
+// A move that begins from a stop can begin at his safe speed, the speed that doesn't exceed the jerk of any axis.
+func (p *planner) jerkJunction(m move) float64 {
+
+	nominal := math.Sqrt(m.nominalV2)
+	safe := safeSpeed(m, nominal, p.limits.Jerk)
+
+	if p.previous == nil || p.previous.nominalV2 == 0 {
+		return safe * safe
+	}
+
+	prev := p.previous
+	prevNominal := math.Sqrt(prev.nominalV2)
+	prevSafe := safeSpeed(*prev, prevNominal, p.limits.Jerk)
+
+	junction := math.Min(nominal, prevNominal)
+	factor := 1.0
+
+	for axis := range m.unit {
+		exit := prev.unit[axis] * junction
+		entry := m.unit[axis] * junction
+
+		// coasting in the same direction or reversing the axis
+		var jerk float64
+		if exit > entry {
+			if entry > 0 || exit < 0 {
+				jerk = exit - entry
+			} else {
+				jerk = math.Max(exit, -entry)
+			}
+		} else {
+			if entry < 0 || exit > 0 {
+				jerk = entry - exit
+			} else {
+				jerk = math.Max(-exit, entry)
+			}
+		}
+
+		if limit := p.limits.Jerk[axis]; jerk > limit {
+			factor = math.Min(factor, limit/jerk)
+		}
+	}
+
+	junction *= factor
+
+	if threshold := junction * 0.99; prevSafe > threshold && safe > threshold {
+		junction = safe
+	}
+
+	return junction * junction
+}
+
+// deviationJunction implements the junction deviation of Marlin.
+//
+// The junction is taken as a virtual arc, tangent to both moves, that deviates from the corner the junction deviation distance.
+// The speed is the one that reaches the acceleration of the move as centripetal acceleration on that arc.
+func (p *planner) deviationJunction(m move) float64 {
+
+	if p.previous == nil {
+		return 0
+	}
+
+	prev := p.previous
+
+	cos := -dot(prev.unit, m.unit)
+	if cos > 0.999999 {
+		// full reversal
+		return 0
+	}
+	cos = math.Max(cos, -0.999999)
+
+	sin := math.Sqrt(0.5 * (1 - cos))
+	v2 := m.accel * p.limits.JunctionDeviation * sin / (1 - sin)
+
+	return math.Min(v2, math.Min(m.nominalV2, prev.nominalV2))
+}
+
+// squareCornerJunction implements the square corner velocity of Klipper.
+//
+// It is similar to the junction deviation, but the deviation is derived from the square corner velocity,
+// and the speed is limited too by the centripetal acceleration needed to round the corner within the half of each move.
+// The moves of the extruder alone always begin from a stop.
+func (p *planner) squareCornerJunction(m move) float64 {
+
+	if p.previous == nil || !p.previous.kinematic || !m.kinematic {
+		return 0
+	}
+
+	prev := p.previous
+
+	cos := -dot(prev.unit, m.unit)
+	if cos > 0.999999 {
+		return 0
+	}
+	cos = math.Max(cos, -0.999999)
+
+	sin := math.Sqrt(0.5 * (1 - cos))
+	r := sin / (1 - sin)
+	tan := sin / math.Sqrt(0.5*(1+cos))
+
+	scv2 := p.limits.SquareCornerVelocity * p.limits.SquareCornerVelocity
+	deviation := r * scv2 * (math.Sqrt2 - 1)
+
+	v2 := math.Min(deviation, math.Min(m.nominalV2, prev.nominalV2))
+	v2 = math.Min(v2, 0.5*m.distance*tan*m.accel)
+	v2 = math.Min(v2, 0.5*prev.distance*tan*prev.accel)
+
+	return v2
+}
+
+// safeSpeed returns the speed from which a move can begin from a stop without exceeding the jerk of any axis
+func safeSpeed(m move, nominal float64, jerk [4]float64) float64 {
+
+	safe := nominal
+
+	for axis, u := range m.unit {
+		if speed := math.Abs(u) * nominal; speed > jerk[axis] {
+			safe = math.Min(safe, jerk[axis]/math.Abs(u))
+		}
+	}
+
+	return safe
+}
+
+// trapezoid returns the time in seconds to execute a move that begins at his entry speed and ends at the exit speed
+func trapezoid(m move, exitV2 float64) float64 {
+
+	nominal := math.Sqrt(m.nominalV2)
+	if nominal == 0 {
+		return 0
+	}
+	if m.accel <= 0 {
+		return m.distance / nominal
+	}
+
+	entry := math.Sqrt(m.entryV2)
+	exit := math.Sqrt(exitV2)
+
+	accelD := (m.nominalV2 - m.entryV2) / (2 * m.accel)
+	decelD := (m.nominalV2 - exitV2) / (2 * m.accel)
+
+	if accelD+decelD <= m.distance {
+		return (nominal-entry)/m.accel + (nominal-exit)/m.accel + (m.distance-accelD-decelD)/nominal
+	}
+
+	// the move doesn't reach his nominal speed
+	peak := math.Sqrt(math.Max((2*m.accel*m.distance+m.entryV2+exitV2)/2, math.Max(m.entryV2, exitV2)))
+
+	return (peak-entry)/m.accel + (peak-exit)/m.accel
+}
+
+// dot returns the dot product of two vectors
+func dot(a [4]float64, b [4]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] + a[3]*b[3]
+}
@@ -0,0 +1,327 @@
+package estimator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+)
+
+// limits returns the Marlin limits with an acceleration of 1000 mm/s² and without per axis limits that interfere
+func limits(model Model) Limits {
+	l := MarlinLimits()
+	l.Model = model
+	l.MaxFeedrate = [4]float64{1000, 1000, 1000, 1000}
+	l.MaxAcceleration = [4]float64{100000, 100000, 100000, 100000}
+	l.Acceleration, l.RetractAcceleration, l.TravelAcceleration = 1000, 1000, 1000
+	l.SquareCornerVelocity = 5
+	return l
+}
+
+func estimate(t *testing.T, source string, options ...EstimatorConfigurationCallbackable) *Result {
+	t.Helper()
+
+	result, err := Estimate(strings.NewReader(source), options...)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	return result
+}
+
+func withLimits(l Limits) EstimatorConfigurationCallbackable {
+	return func(config EstimatorConfigurer) error {
+		return config.SetLimits(l)
+	}
+}
+
+func near(got time.Duration, want float64, tolerance float64) bool {
+	return math.Abs(got.Seconds()-want) <= tolerance
+}
+
+func TestEstimate(t *testing.T) {
+
+	var cases = map[string]struct {
+		source string
+		model  Model
+		want   float64
+	}{
+		"single move": {
+			// 5mm accelerating, 90mm cruising and 5mm decelerating
+			source: "G1 X100 F6000",
+			want:   1.1,
+		},
+		"collinear moves": {
+			// the junction doesn't slow down the machine
+			source: "G1 X50 F6000\nG1 X100",
+			want:   1.1,
+		},
+		"short move": {
+			// it only reaches sqrt(2000) mm/s
+			source: "G1 X2 F6000",
+			want:   2 * math.Sqrt(2000) / 1000,
+		},
+		"reversal": {
+			// it stops at the junction
+			source: "G1 X100 F6000\nG1 X0",
+			want:   2.2,
+		},
+		"relative moves": {
+			source: "G91\nG1 X50 F6000\nG1 X50",
+			want:   1.1,
+		},
+		"inches": {
+			source: "G20\nG1 X3.93700787 F236.220472",
+			want:   1.1,
+		},
+		"dwell": {
+			source: "G1 X100 F6000\nG4 P500\nG4 S2",
+			want:   3.6,
+		},
+		"feedrate multiplier": {
+			source: "M220 S50\nG1 X100 F12000",
+			want:   1.1,
+		},
+		"print acceleration": {
+			// 10mm accelerating, 80mm cruising and 10mm decelerating
+			source: "M204 P500\nG1 X100 E5 F6000",
+			want:   1.2,
+		},
+		"travel acceleration": {
+			source: "M204 P500 T1000\nG1 X100 F6000",
+			want:   1.1,
+		},
+		"max feedrate": {
+			// 1.25mm accelerating, 97.5mm cruising and 1.25mm decelerating
+			source: "M203 X50\nG1 X100 F6000",
+			want:   0.05 + 0.05 + 97.5/50,
+		},
+		"max acceleration": {
+			source: "M201 X500\nG1 X100 F6000",
+			want:   1.2,
+		},
+		"full circle": {
+			source: "G1 X10 F600\nG2 X10 Y0 I-10 J0",
+			want:   1.01 + 2*math.Pi,
+		},
+		"klipper velocity limit": {
+			source: "SET_VELOCITY_LIMIT VELOCITY=50\nG1 X100 F6000",
+			model:  MODEL_SQUARE_CORNER_VELOCITY,
+			want:   0.05 + 0.05 + 97.5/50,
+		},
+		"klipper ignores marlin limits": {
+			source: "M203 X50\nG1 X100 F6000",
+			model:  MODEL_SQUARE_CORNER_VELOCITY,
+			want:   1.1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			model := tc.model
+			if model == 0 {
+				model = MODEL_JUNCTION_DEVIATION
+			}
+
+			result := estimate(t, tc.source, withLimits(limits(model)))
+
+			if !near(result.Total, tc.want, 0.02) {
+				t.Errorf("got %v, want %.3fs", result.Total, tc.want)
+			}
+		})
+	}
+}
+
+func TestEstimate_Corners(t *testing.T) {
+
+	// a square, the corners slow down the machine but it doesn't stop
+	square := "G1 X50 F6000\nG1 Y50\nG1 X0\nG1 Y0"
+
+	stopping := 4 * (0.1 + 0.1 + 40.0/100)
+	straight := 0.1 + 0.1 + 190.0/100
+
+	for _, model := range []Model{MODEL_JERK, MODEL_JUNCTION_DEVIATION, MODEL_SQUARE_CORNER_VELOCITY} {
+		t.Run(fmt.Sprint(model), func(t *testing.T) {
+			result := estimate(t, square, withLimits(limits(model)))
+
+			if got := result.Total.Seconds(); got >= stopping || got <= straight {
+				t.Errorf("got %v, want between %.3fs and %.3fs", result.Total, straight, stopping)
+			}
+		})
+	}
+
+	// a higher jerk allows faster corners
+	low := limits(MODEL_JERK)
+	high := limits(MODEL_JERK)
+	high.Jerk = [4]float64{40, 40, 1, 10}
+
+	if slow, fast := estimate(t, square, withLimits(low)), estimate(t, square, withLimits(high)); fast.Total >= slow.Total {
+		t.Errorf("got %v with high jerk and %v with low jerk, want it faster", fast.Total, slow.Total)
+	}
+}
+
+func TestEstimate_Lookahead(t *testing.T) {
+
+	// many short collinear moves, the machine must be able to stop within the moves buffered
+	var moves strings.Builder
+	moves.WriteString("G1 F6000\n")
+	for x := 1; x <= 100; x++ {
+		fmt.Fprintf(&moves, "G1 X%d\n", x)
+	}
+
+	full := estimate(t, moves.String(), withLimits(limits(MODEL_JUNCTION_DEVIATION)))
+	short := estimate(t, moves.String(), withLimits(limits(MODEL_JUNCTION_DEVIATION)), func(config EstimatorConfigurer) error {
+		return config.SetBufferSize(2)
+	})
+
+	if !near(full.Total, 1.1, 0.02) {
+		t.Errorf("got %v with the default buffer, want 1.1s", full.Total)
+	}
+
+	if short.Total <= full.Total {
+		t.Errorf("got %v with a short buffer, want more than %v", short.Total, full.Total)
+	}
+}
+
+func TestEstimate_Lines(t *testing.T) {
+
+	source := `G28
+;LAYER_CHANGE
+;Z:0.2
+G1 Z0.2 F6000
+G1 X100 E5
+;LAYER_CHANGE
+;Z:0.4
+G1 Z0.4
+G1 X0 E10
+G4 S1`
+
+	result := estimate(t, source, withLimits(limits(MODEL_JUNCTION_DEVIATION)))
+
+	if len(result.Lines) != 10 {
+		t.Fatalf("got %d lines, want 10", len(result.Lines))
+	}
+
+	var sum time.Duration
+	for i, d := range result.Lines {
+		sum += d
+		if (i == 0 || i == 1 || i == 2 || i == 5 || i == 6) && d != 0 {
+			t.Errorf("got line %d with %v, want 0", i, d)
+		}
+	}
+
+	if result.Lines[9] != time.Second {
+		t.Errorf("got dwell of %v, want 1s", result.Lines[9])
+	}
+
+	if d := sum - result.Total; d > time.Microsecond || d < -time.Microsecond {
+		t.Errorf("got lines summing %v, want %v", sum, result.Total)
+	}
+
+	if len(result.Layers) != 2 {
+		t.Fatalf("got %d layers, want 2", len(result.Layers))
+	}
+
+	if want := result.Lines[7] + result.Lines[8] + result.Lines[9]; !near(result.Layers[1].Time, want.Seconds(), 1e-6) {
+		t.Errorf("got layer 1 time %v, want %v", result.Layers[1].Time, want)
+	}
+
+	if !near(result.Layers[0].Time+result.Layers[1].Time, sum.Seconds(), 1e-6) {
+		t.Errorf("got layers %v and %v, want summing %v", result.Layers[0].Time, result.Layers[1].Time, sum)
+	}
+}
+
+func TestEstimator_Elapsed(t *testing.T) {
+
+	e, err := New(withLimits(limits(MODEL_JUNCTION_DEVIATION)))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	add := func(line string) {
+		b, err := gcodeblock.Parse(line)
+		if err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+		e.Add(line, b)
+	}
+
+	add("G1 X100 F6000")
+	if e.Elapsed() != 0 {
+		t.Errorf("got %v elapsed, want 0 while the move is queued", e.Elapsed())
+	}
+
+	// the dwell executes the queued moves
+	add("G4 S1")
+	if !near(e.Elapsed(), 2.1, 0.01) {
+		t.Errorf("got %v elapsed, want 2.1s after the dwell", e.Elapsed())
+	}
+
+	add("G1 X0")
+	elapsed := e.Elapsed()
+
+	if result := e.Close(); result.Total < elapsed || !near(result.Total, 3.2, 0.01) {
+		t.Errorf("got total %v, want 3.2s and not less than %v", result.Total, elapsed)
+	}
+}
+
+func TestEstimator_Planned(t *testing.T) {
+
+	e, err := New(withLimits(limits(MODEL_JUNCTION_DEVIATION)))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	add := func(line string) {
+		b, err := gcodeblock.Parse(line)
+		if err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+		e.Add(line, b)
+	}
+
+	// the queued move counts with the speeds planned, as if the machine stopped after it
+	add("G1 X100 F6000")
+	if !near(e.Planned(), 1.1, 0.01) {
+		t.Errorf("got %v planned, want 1.1s while the move is queued", e.Planned())
+	}
+
+	add("G4 S1")
+	add("G1 X0")
+	planned := e.Planned()
+
+	if result := e.Close(); !near(result.Total, planned.Seconds(), 1e-6) {
+		t.Errorf("got total %v, want the %v planned before closing", result.Total, planned)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+
+	invalid := MarlinLimits()
+	invalid.MaxFeedrate[AXIS_Z] = 0
+
+	var cases = map[string]EstimatorConfigurationCallbackable{
+		"invalid limits": func(config EstimatorConfigurer) error {
+			return config.SetLimits(invalid)
+		},
+		"invalid model": func(config EstimatorConfigurer) error {
+			l := MarlinLimits()
+			l.Model = Model(10)
+			return config.SetLimits(l)
+		},
+		"invalid buffer": func(config EstimatorConfigurer) error {
+			return config.SetBufferSize(0)
+		},
+	}
+
+	for name, option := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(option); err == nil {
+				t.Errorf("got nil error, want error")
+			}
+		})
+	}
+}
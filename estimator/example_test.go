@@ -0,0 +1,69 @@
+package estimator_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/estimator"
+)
+
+func ExampleEstimate() {
+
+	gcode := `M204 P1000 T1000
+G1 X100 F6000
+G1 Y100 E5
+G4 S1
+`
+
+	result, err := estimator.Estimate(strings.NewReader(gcode))
+	if err != nil {
+		fmt.Printf("failed to estimate: %v", err)
+		return
+	}
+
+	fmt.Printf("total %.1fs\n", result.Total.Seconds())
+	for i, d := range result.Lines {
+		fmt.Printf("line %d: %.2fs\n", i, d.Seconds())
+	}
+
+	// Output:
+	// total 3.2s
+	// line 0: 0.00s
+	// line 1: 1.09s
+	// line 2: 1.09s
+	// line 3: 1.00s
+}
+
+func ExampleNew() {
+
+	// emulate Klipper with a square corner velocity of 8 mm/s
+	limits := estimator.KlipperLimits()
+	limits.SquareCornerVelocity = 8
+
+	e, err := estimator.New(func(config estimator.EstimatorConfigurer) error {
+		return config.SetLimits(limits)
+	})
+	if err != nil {
+		fmt.Printf("failed to create the estimator: %v", err)
+		return
+	}
+
+	// the lines that can't be parsed into a block are added without it
+	e.Add("SET_VELOCITY_LIMIT ACCEL=1000", nil)
+
+	for _, line := range []string{"G1 X100 F6000", "G1 X200"} {
+		b, err := gcodeblock.Parse(line)
+		if err != nil {
+			fmt.Printf("failed to parse %s: %v", line, err)
+			return
+		}
+		e.Add(line, b)
+	}
+
+	result := e.Close()
+	fmt.Printf("%d lines, %v\n", len(result.Lines), result.Total)
+
+	// Output:
+	// 3 lines, 2.1s
+}
@@ -0,0 +1,35 @@
+package feature_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/feature"
+)
+
+func Example() {
+
+	source := `;TYPE:WALL-OUTER
+G1 X10 Y10 E1
+G0 X20 Y20
+;TYPE:FILL
+G1 X30 Y30 E2
+`
+
+	tagger := feature.New()
+
+	// print only the moves of the outer walls
+	scanner := gcodeblock.NewScanner(strings.NewReader(source))
+	for scanner.Scan() {
+		if tagger.Tag(scanner.Text(), scanner.Block()) == feature.FEATURE_OUTER_WALL && scanner.Block() != nil {
+			fmt.Println(scanner.Block().ToLine("%c %p"))
+		}
+	}
+	if scanner.Err() != nil {
+		fmt.Printf("failed to scan the gcode: %v", scanner.Err())
+	}
+
+	// Output:
+	// G1 X10 Y10 E1
+}
@@ -0,0 +1,235 @@
+// feature package tags each line of a gcode file with the type of feature that it prints, like an outer wall or the infill.
+//
+// The slicers annotate the beginning of each section with a comment that names the feature, each one with his own names:
+//
+//	;TYPE:WALL-OUTER                 Cura
+//	;TYPE:External perimeter         PrusaSlicer and SuperSlicer
+//	; FEATURE: Outer wall            Orca Slicer and Bambu Studio, besides ;TYPE:Outer wall
+//	; feature outer perimeter        Simplify3D
+//
+// The Tagger normalizes these names into a FeatureType, so that the transforms and the statistics can target a feature regardless of the slicer.
+// The moves without extrusion are tagged as travels, and the moves between the ;WIPE_START and ;WIPE_END comments of PrusaSlicer as wipes.
+package feature
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/gcode"
+	"github.com/mauroalderete/gcode-core/interpreter"
+)
+
+// FeatureType is the normalized type of a feature.
+type FeatureType string
+
+const (
+	FEATURE_UNKNOWN    FeatureType = "unknown"
+	FEATURE_OUTER_WALL FeatureType = "outer wall"
+	FEATURE_INNER_WALL FeatureType = "inner wall"
+	FEATURE_INFILL     FeatureType = "infill"
+	FEATURE_SUPPORT    FeatureType = "support"
+	FEATURE_SKIRT      FeatureType = "skirt"
+	FEATURE_BRIDGE     FeatureType = "bridge"
+	FEATURE_TRAVEL     FeatureType = "travel"
+	FEATURE_WIPE       FeatureType = "wipe"
+	FEATURE_CUSTOM     FeatureType = "custom"
+)
+
+// typeRegex matches the comments that name a feature and captures the name
+var typeRegex = regexp.MustCompile(`^(?:(?:TYPE|FEATURE)\s*:|feature\s)\s*(.+?)\s*$`)
+
+// names maps the names used by the slicers, in lower case and with spaces instead of hyphens and underscores, to his FeatureType
+var names = map[string]FeatureType{
+	// outer walls
+	"wall outer":         FEATURE_OUTER_WALL,
+	"external perimeter": FEATURE_OUTER_WALL,
+	"overhang perimeter": FEATURE_OUTER_WALL,
+	"outer wall":         FEATURE_OUTER_WALL,
+	"overhang wall":      FEATURE_OUTER_WALL,
+	"outer perimeter":    FEATURE_OUTER_WALL,
+
+	// inner walls
+	"wall inner":      FEATURE_INNER_WALL,
+	"perimeter":       FEATURE_INNER_WALL,
+	"inner wall":      FEATURE_INNER_WALL,
+	"inner perimeter": FEATURE_INNER_WALL,
+
+	// infills
+	"fill":                  FEATURE_INFILL,
+	"skin":                  FEATURE_INFILL,
+	"internal infill":       FEATURE_INFILL,
+	"solid infill":          FEATURE_INFILL,
+	"top solid infill":      FEATURE_INFILL,
+	"gap fill":              FEATURE_INFILL,
+	"ironing":               FEATURE_INFILL,
+	"sparse infill":         FEATURE_INFILL,
+	"internal solid infill": FEATURE_INFILL,
+	"top surface":           FEATURE_INFILL,
+	"bottom surface":        FEATURE_INFILL,
+	"gap infill":            FEATURE_INFILL,
+	"infill":                FEATURE_INFILL,
+	"solid layer":           FEATURE_INFILL,
+
+	// supports
+	"support":                    FEATURE_SUPPORT,
+	"support interface":          FEATURE_SUPPORT,
+	"support infill":             FEATURE_SUPPORT,
+	"support material":           FEATURE_SUPPORT,
+	"support material interface": FEATURE_SUPPORT,
+	"support transition":         FEATURE_SUPPORT,
+	"dense support":              FEATURE_SUPPORT,
+
+	// skirts and brims
+	"skirt":      FEATURE_SKIRT,
+	"skirt/brim": FEATURE_SKIRT,
+	"brim":       FEATURE_SKIRT,
+
+	// bridges
+	"bridge infill":   FEATURE_BRIDGE,
+	"bridge":          FEATURE_BRIDGE,
+	"internal bridge": FEATURE_BRIDGE,
+
+	// wipe and prime towers
+	"prime tower":  FEATURE_WIPE,
+	"wipe tower":   FEATURE_WIPE,
+	"prime pillar": FEATURE_WIPE,
+
+	"custom": FEATURE_CUSTOM,
+}
+
+//#region tagger struct
+
+// Tagger receives the lines of a gcode file one by one and returns the feature of each one of them.
+type Tagger struct {
+	// section is the feature named by the last type comment
+	section FeatureType
+
+	// name is the name used by the slicer in the last type comment
+	name string
+
+	// wiping is true between the wipe start and end comments
+	wiping bool
+}
+
+// Tag receives the next line of the gcode file and returns his feature.
+//
+// b is the block parsed from the line, it can be nil if the line doesn't contain gcode or it couldn't be parsed.
+// The type comments are read from the line, so comment-only lines must be tagged too. They get the feature that they name.
+// The lines before the first type comment are FEATURE_UNKNOWN, except the travels.
+func (t *Tagger) Tag(line string, b block.Blocker) FeatureType {
+
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		comment := strings.TrimSpace(line[i+1:])
+
+		switch {
+		case comment == "WIPE_START":
+			t.wiping = true
+			return FEATURE_WIPE
+		case comment == "WIPE_END":
+			t.wiping = false
+			return FEATURE_WIPE
+		}
+
+		if match := typeRegex.FindStringSubmatch(comment); match != nil {
+			t.name = match[1]
+			t.section = Normalize(match[1])
+		}
+	}
+
+	if t.wiping {
+		return FEATURE_WIPE
+	}
+
+	if b = interpreter.Block(b); b != nil && isTravel(b) {
+		return FEATURE_TRAVEL
+	}
+
+	return t.section
+}
+
+// Name returns the name of the feature as the slicer wrote it in the last type comment, or an empty string if there isn't any yet.
+func (t *Tagger) Name() string {
+	return t.name
+}
+
+//#endregion
+//#region constructor
+
+// New returns a Tagger ready to receive the first line of a gcode file.
+func New() *Tagger {
+	return &Tagger{
+		section: FEATURE_UNKNOWN,
+	}
+}
+
+//#endregion
+//#region package functions
+
+// Normalize returns the FeatureType of a feature name written by a slicer, or FEATURE_UNKNOWN if it isn't known.
+func Normalize(name string) FeatureType {
+
+	key := strings.ToLower(strings.TrimSpace(name))
+	key = strings.NewReplacer("-", " ", "_", " ").Replace(key)
+
+	if feature, ok := names[key]; ok {
+		return feature
+	}
+
+	return FEATURE_UNKNOWN
+}
+
+// TagLines returns the feature of each line of a program in memory.
+//
+// Each line is parsed with gcodeblock.Parse. The lines that can't be parsed are taken as lines without gcode.
+func TagLines(lines []string, options ...block.BlockParserConfigurationCallbackable) []FeatureType {
+
+	features := make([]FeatureType, len(lines))
+
+	t := New()
+
+	for i, line := range lines {
+		var b block.Blocker
+		if gcodeblock.HasGcode(line) {
+			if parsed, err := gcodeblock.Parse(line, options...); err == nil {
+				b = parsed
+			}
+		}
+
+		features[i] = t.Tag(line, b)
+	}
+
+	return features
+}
+
+//#endregion
+//#region private functions
+
+// isTravel returns true if the block is a linear move in the XY plane without extrusion
+func isTravel(b block.Blocker) bool {
+
+	command := b.Command()
+	if command.Word() != 'G' {
+		return false
+	}
+
+	number, ok := gcode.NumericAddress(command)
+	if !ok || (number != 0 && number != 1) {
+		return false
+	}
+
+	moves := false
+	for _, p := range b.Parameters() {
+		switch p.Word() {
+		case 'E':
+			return false
+		case 'X', 'Y':
+			moves = true
+		}
+	}
+
+	return moves
+}
+
+//#endregion
@@ -0,0 +1,130 @@
+package feature
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+)
+
+func TestNormalize(t *testing.T) {
+
+	var cases = map[string]FeatureType{
+		"WALL-OUTER":                 FEATURE_OUTER_WALL,
+		"External perimeter":         FEATURE_OUTER_WALL,
+		"Outer wall":                 FEATURE_OUTER_WALL,
+		"outer perimeter":            FEATURE_OUTER_WALL,
+		"WALL-INNER":                 FEATURE_INNER_WALL,
+		"Perimeter":                  FEATURE_INNER_WALL,
+		"FILL":                       FEATURE_INFILL,
+		"SKIN":                       FEATURE_INFILL,
+		"Top solid infill":           FEATURE_INFILL,
+		"Sparse infill":              FEATURE_INFILL,
+		"SUPPORT-INTERFACE":          FEATURE_SUPPORT,
+		"Support material interface": FEATURE_SUPPORT,
+		"SKIRT":                      FEATURE_SKIRT,
+		"Skirt/Brim":                 FEATURE_SKIRT,
+		"Bridge infill":              FEATURE_BRIDGE,
+		"Internal Bridge":            FEATURE_BRIDGE,
+		"PRIME-TOWER":                FEATURE_WIPE,
+		"Wipe tower":                 FEATURE_WIPE,
+		"Custom":                     FEATURE_CUSTOM,
+		"Something new":              FEATURE_UNKNOWN,
+	}
+
+	for name, want := range cases {
+		if got := Normalize(name); got != want {
+			t.Errorf("got %s normalizing %s, want %s", got, name, want)
+		}
+	}
+}
+
+func TestTagLines(t *testing.T) {
+
+	var cases = map[string]struct {
+		source string
+		want   []FeatureType
+	}{
+		"cura": {
+			source: `G28
+;TYPE:WALL-OUTER
+G1 X10 Y10 E1
+G0 X20 Y20
+G1 E-0.8
+;TYPE:FILL
+G1 X30 Y30 E2`,
+			want: []FeatureType{
+				FEATURE_UNKNOWN,
+				FEATURE_OUTER_WALL, FEATURE_OUTER_WALL, FEATURE_TRAVEL, FEATURE_OUTER_WALL,
+				FEATURE_INFILL, FEATURE_INFILL,
+			},
+		},
+		"prusaslicer": {
+			source: `;TYPE:External perimeter
+G1 X10 Y10 E1
+;WIPE_START
+G1 X12 Y10 E-0.1
+G1 X14 Y10
+;WIPE_END
+G1 X20 Y20
+;TYPE:Bridge infill
+G1 X30 Y30 E2`,
+			want: []FeatureType{
+				FEATURE_OUTER_WALL, FEATURE_OUTER_WALL,
+				FEATURE_WIPE, FEATURE_WIPE, FEATURE_WIPE, FEATURE_WIPE,
+				FEATURE_TRAVEL,
+				FEATURE_BRIDGE, FEATURE_BRIDGE,
+			},
+		},
+		"orcaslicer": {
+			source: `; FEATURE: Inner wall
+G1 X10 Y10 E1
+; FEATURE: Support
+G1 X20 Y20 E1`,
+			want: []FeatureType{FEATURE_INNER_WALL, FEATURE_INNER_WALL, FEATURE_SUPPORT, FEATURE_SUPPORT},
+		},
+		"simplify3d": {
+			source: `; feature skirt
+G1 X10 Y10 E1
+; feature infill
+M117 "printing infill"
+G1 X20 Y20 E1`,
+			want: []FeatureType{FEATURE_SKIRT, FEATURE_SKIRT, FEATURE_INFILL, FEATURE_INFILL, FEATURE_INFILL},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := TagLines(strings.Split(tc.source, "\n"))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTagger_Scanner(t *testing.T) {
+
+	tagger := New()
+
+	scanner := gcodeblock.NewScanner(strings.NewReader(";TYPE:WALL-OUTER\n\nG0 X1 Y1\nG1 X2 Y2 E1\n"))
+
+	var got []FeatureType
+	for scanner.Scan() {
+		// the Scanner returns a nil block for the comment-only and empty lines
+		got = append(got, tagger.Tag(scanner.Text(), scanner.Block()))
+	}
+	if scanner.Err() != nil {
+		t.Fatalf("got error %v, want nil error", scanner.Err())
+	}
+
+	want := []FeatureType{FEATURE_OUTER_WALL, FEATURE_OUTER_WALL, FEATURE_TRAVEL, FEATURE_OUTER_WALL}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if tagger.Name() != "WALL-OUTER" {
+		t.Errorf("got name %s, want WALL-OUTER", tagger.Name())
+	}
+}
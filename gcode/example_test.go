@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"github.com/mauroalderete/gcode-core/gcode"
+	"github.com/mauroalderete/gcode-core/gcode/addressablegcode"
 )
 
 func ExampleIsValidWord() {
@@ -35,3 +36,17 @@ func ExampleIsValidWord_second() {
 
 	// Output: word ; is invalid: gcode's word has invalid value: 59
 }
+
+func ExampleNumericAddress() {
+
+	g, err := addressablegcode.New[float32]('Z', 0.2)
+	if err != nil {
+		log.Fatalf("failed to create the gcode: %v", err)
+	}
+
+	z, ok := gcode.NumericAddress(g)
+
+	fmt.Println(z, ok)
+
+	// Output: 0.2 true
+}
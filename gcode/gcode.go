@@ -44,7 +44,10 @@
 // gcode package provides two packages that implement all interfaces ready to use.
 package gcode
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+)
 
 //#region interfaces
 
@@ -116,4 +119,23 @@ func IsValidWord(word byte) error {
 	return fmt.Errorf("gcode's word has invalid value: %v", word)
 }
 
+// NumericAddress returns the address of a gcode as a float64 if it is a numeric address.
+//
+// It returns false if the gcode doesn't have an address or if it is a string address.
+// A float32 address is converted through his shortest decimal representation, so Z0.2 returns 0.2 instead of 0.20000000298023224.
+func NumericAddress(g Gcoder) (float64, bool) {
+
+	switch a := g.(type) {
+	case AddressableGcoder[float32]:
+		value, _ := strconv.ParseFloat(strconv.FormatFloat(float64(a.Address()), 'g', -1, 32), 64)
+		return value, true
+	case AddressableGcoder[int32]:
+		return float64(a.Address()), true
+	case AddressableGcoder[uint32]:
+		return float64(a.Address()), true
+	}
+
+	return 0, false
+}
+
 //#endregion
@@ -0,0 +1,70 @@
+package gcodearchive_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/gcodearchive"
+)
+
+func Example() {
+
+	// a minimal container with a single gcode part
+	container := &bytes.Buffer{}
+	zw := zip.NewWriter(container)
+	w, _ := zw.Create("3D/model.gcode")
+	w.Write([]byte(";FLAVOR:Marlin\nG28\nG1 X10.0 Y10.0\n"))
+	zw.Close()
+
+	a, err := gcodearchive.New(bytes.NewReader(container.Bytes()), int64(container.Len()))
+	if err != nil {
+		fmt.Printf("failed to read the container: %v", err)
+		return
+	}
+
+	name := a.GcodeParts()[0]
+
+	rc, err := a.Open(name)
+	if err != nil {
+		fmt.Printf("failed to open %s: %v", name, err)
+		return
+	}
+	defer rc.Close()
+
+	// scan the gcode part and write each line, with the blocks in a normalized format, into a new content
+	gcode := &bytes.Buffer{}
+	s := gcodeblock.NewScanner(rc)
+	for s.Scan() {
+		if s.Block() == nil {
+			fmt.Fprintln(gcode, s.Text())
+			continue
+		}
+		fmt.Fprintln(gcode, s.Block().ToLine("%c %p"))
+	}
+	if s.Err() != nil {
+		fmt.Printf("failed to scan %s: %v", name, s.Err())
+		return
+	}
+
+	repackaged := &bytes.Buffer{}
+	err = a.Repackage(repackaged, name, gcode)
+	if err != nil {
+		fmt.Printf("failed to repackage: %v", err)
+		return
+	}
+
+	r, _ := gcodearchive.New(bytes.NewReader(repackaged.Bytes()), int64(repackaged.Len()))
+	rc, _ = r.Open(name)
+	content, _ := io.ReadAll(rc)
+
+	fmt.Printf("%s:\n%s", name, content)
+
+	// Output:
+	// 3D/model.gcode:
+	// ;FLAVOR:Marlin
+	// G28
+	// G1 X10.000 Y10.000
+}
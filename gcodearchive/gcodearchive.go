@@ -0,0 +1,282 @@
+// gcodearchive package reads and repackages zip containers that hold gcode parts,
+// like the Ultimaker Format Package (.ufp) files made by Cura or the .gcode.3mf projects made by Bambu Studio and Orca Slicer.
+//
+// These containers follow the Open Packaging Conventions. Besides the gcode, they store thumbnails, metadata,
+// a [Content_Types].xml part and relationship parts that link all of them.
+//
+// The gcode parts are located using the relationships of the package and, if there aren't any, using the .gcode extension.
+// Each gcode part can be opened as an io.Reader to be read with a gcodeblock.Scanner.
+//
+// The Repackage method writes a new container where a gcode part is replaced by a new content.
+// The rest of the parts are copied without being decompressed, so the thumbnails, the metadata and the relationships are preserved as they are.
+// If a gcode part has a companion part with his MD5 checksum, like Bambu Studio does, it is updated too.
+package gcodearchive
+
+import (
+	"archive/zip"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// RELATIONSHIPS_PART is the name of the part that contains the relationships of the package
+	RELATIONSHIPS_PART = "_rels/.rels"
+
+	// MD5_SUFFIX is the suffix of the companion part that stores the MD5 checksum of a gcode part
+	MD5_SUFFIX = ".md5"
+)
+
+//#region archive struct
+
+// Archive is a zip container with one or more gcode parts.
+type Archive struct {
+	// reader gives access to the parts of the container
+	reader *zip.Reader
+
+	// gcodeParts stores the names of the gcode parts located
+	gcodeParts []string
+}
+
+// GcodeParts returns the names of the gcode parts found in the container.
+//
+// The names are the paths of the parts into the zip file, without the leading slash.
+func (a *Archive) GcodeParts() []string {
+	return append([]string{}, a.gcodeParts...)
+}
+
+// Open returns the content of a part. The caller must close it.
+//
+// It is typically used with the names returned by GcodeParts and a gcodeblock.Scanner to read the blocks of the part.
+func (a *Archive) Open(name string) (io.ReadCloser, error) {
+
+	f := a.find(name)
+	if f == nil {
+		return nil, fmt.Errorf("failed to open part %s, it doesn't exist", name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open part %s: %w", name, err)
+	}
+
+	return rc, nil
+}
+
+// Repackage writes into w a copy of the container where the content of the gcode part with the given name is replaced by gcode.
+//
+// The other parts are copied as they are. If the part has a companion MD5 part, it is updated with the checksum of the new content.
+func (a *Archive) Repackage(w io.Writer, name string, gcode io.Reader) error {
+
+	if a.find(name) == nil {
+		return fmt.Errorf("failed to repackage, part %s doesn't exist", name)
+	}
+
+	if gcode == nil {
+		return fmt.Errorf("failed to repackage, gcode mustn't be nil")
+	}
+
+	zw := zip.NewWriter(w)
+
+	var companion *zip.File
+	var checksum []byte
+
+	for _, f := range a.reader.File {
+
+		switch f.Name {
+		case name:
+			header := f.FileHeader
+			header.Method = zip.Deflate
+
+			pw, err := zw.CreateHeader(&header)
+			if err != nil {
+				return fmt.Errorf("failed to create part %s: %w", name, err)
+			}
+
+			h := md5.New()
+			if _, err := io.Copy(io.MultiWriter(pw, h), gcode); err != nil {
+				return fmt.Errorf("failed to write part %s: %w", name, err)
+			}
+			checksum = h.Sum(nil)
+
+		case name + MD5_SUFFIX:
+			// the checksum is known after writing the gcode part
+			companion = f
+
+		default:
+			err := copyRaw(zw, f)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if companion != nil {
+		err := writeChecksum(zw, companion, checksum)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := zw.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close the container: %w", err)
+	}
+
+	return nil
+}
+
+// find returns the zip entry of a part or nil if it doesn't exist
+func (a *Archive) find(name string) *zip.File {
+	for _, f := range a.reader.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+//#endregion
+//#region constructor
+
+// New returns an Archive that reads the zip container from r, which has the given size.
+//
+// It returns an error if r isn't a zip file or if it doesn't contain any gcode part.
+func New(r io.ReaderAt, size int64) (*Archive, error) {
+
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the container: %w", err)
+	}
+
+	archive := &Archive{
+		reader: reader,
+	}
+
+	archive.gcodeParts, err = locateGcodeParts(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(archive.gcodeParts) == 0 {
+		return nil, fmt.Errorf("the container doesn't contain any gcode part")
+	}
+
+	return archive, nil
+}
+
+//#endregion
+//#region private functions
+
+// relationships models the content of a relationships part
+type relationships struct {
+	Relationships []struct {
+		Target string `xml:"Target,attr"`
+		Type   string `xml:"Type,attr"`
+	} `xml:"Relationship"`
+}
+
+// locateGcodeParts returns the gcode parts referenced by the package relationships,
+// or, if there isn't any, the parts with the .gcode extension.
+func locateGcodeParts(a *Archive) ([]string, error) {
+
+	var parts []string
+
+	if f := a.find(RELATIONSHIPS_PART); f != nil {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", RELATIONSHIPS_PART, err)
+		}
+		defer rc.Close()
+
+		var rels relationships
+		err = xml.NewDecoder(rc).Decode(&rels)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", RELATIONSHIPS_PART, err)
+		}
+
+		for _, rel := range rels.Relationships {
+			name := strings.TrimPrefix(rel.Target, "/")
+			if strings.HasSuffix(strings.ToLower(rel.Type), "gcode") && a.find(name) != nil {
+				parts = append(parts, name)
+			}
+		}
+	}
+
+	if len(parts) > 0 {
+		return parts, nil
+	}
+
+	for _, f := range a.reader.File {
+		if strings.EqualFold(path.Ext(f.Name), ".gcode") {
+			parts = append(parts, f.Name)
+		}
+	}
+
+	sort.Strings(parts)
+
+	return parts, nil
+}
+
+// copyRaw copies a part into the new container without decompressing it
+func copyRaw(zw *zip.Writer, f *zip.File) error {
+
+	header := f.FileHeader
+
+	pw, err := zw.CreateRaw(&header)
+	if err != nil {
+		return fmt.Errorf("failed to create part %s: %w", f.Name, err)
+	}
+
+	rc, err := f.OpenRaw()
+	if err != nil {
+		return fmt.Errorf("failed to open part %s: %w", f.Name, err)
+	}
+
+	_, err = io.Copy(pw, rc)
+	if err != nil {
+		return fmt.Errorf("failed to copy part %s: %w", f.Name, err)
+	}
+
+	return nil
+}
+
+// writeChecksum writes the companion MD5 part, keeping the letter case used by the original
+func writeChecksum(zw *zip.Writer, f *zip.File, checksum []byte) error {
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open part %s: %w", f.Name, err)
+	}
+	original, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read part %s: %w", f.Name, err)
+	}
+
+	value := hex.EncodeToString(checksum)
+	if strings.ToUpper(string(original)) == string(original) {
+		value = strings.ToUpper(value)
+	}
+
+	header := f.FileHeader
+
+	pw, err := zw.CreateHeader(&header)
+	if err != nil {
+		return fmt.Errorf("failed to create part %s: %w", f.Name, err)
+	}
+
+	_, err = pw.Write([]byte(value))
+	if err != nil {
+		return fmt.Errorf("failed to write part %s: %w", f.Name, err)
+	}
+
+	return nil
+}
+
+//#endregion
@@ -0,0 +1,241 @@
+package gcodearchive
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const ufpRelationships = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Target="/3D/model.gcode" Id="rel0" Type="http://schemas.ultimaker.org/package/2018/relationships/gcode"/>
+<Relationship Target="/Metadata/thumbnail.png" Id="rel1" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/thumbnail"/>
+</Relationships>`
+
+const bambuRelationships = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Target="/3D/3dmodel.model" Id="rel-1" Type="http://schemas.microsoft.com/3dmanufacturing/2013/01/3dmodel"/>
+</Relationships>`
+
+type part struct {
+	name    string
+	content string
+	method  uint16
+}
+
+func mockArchive(t *testing.T, parts []part) []byte {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+	zw := zip.NewWriter(out)
+
+	for _, p := range parts {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: p.name, Method: p.method})
+		if err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+		w.Write([]byte(p.content))
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	return out.Bytes()
+}
+
+func mockUFP(t *testing.T) []byte {
+	return mockArchive(t, []part{
+		{name: "[Content_Types].xml", content: "<Types/>", method: zip.Deflate},
+		{name: "_rels/.rels", content: ufpRelationships, method: zip.Deflate},
+		{name: "3D/model.gcode", content: ";FLAVOR:Marlin\nG28\nG1 X10 Y10\n", method: zip.Deflate},
+		{name: "Metadata/thumbnail.png", content: "\x89PNG not really", method: zip.Store},
+	})
+}
+
+func mockBambu(t *testing.T) []byte {
+	gcode := "; HEADER_BLOCK_START\nG28\n"
+	sum := md5.Sum([]byte(gcode))
+	return mockArchive(t, []part{
+		{name: "[Content_Types].xml", content: "<Types/>", method: zip.Deflate},
+		{name: "_rels/.rels", content: bambuRelationships, method: zip.Deflate},
+		{name: "Metadata/plate_2.gcode.md5", content: strings.ToUpper(hex.EncodeToString(sum[:])), method: zip.Deflate},
+		{name: "Metadata/plate_2.gcode", content: gcode, method: zip.Deflate},
+		{name: "Metadata/plate_1.gcode", content: gcode, method: zip.Deflate},
+		{name: "Metadata/plate_1.png", content: "png", method: zip.Store},
+	})
+}
+
+func read(t *testing.T, a *Archive, name string) string {
+	t.Helper()
+
+	rc, err := a.Open(name)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	return string(content)
+}
+
+func TestNew(t *testing.T) {
+
+	var cases = map[string]struct {
+		data  []byte
+		parts []string
+	}{
+		"ufp": {
+			data:  mockUFP(t),
+			parts: []string{"3D/model.gcode"},
+		},
+		"bambu 3mf": {
+			data:  mockBambu(t),
+			parts: []string{"Metadata/plate_1.gcode", "Metadata/plate_2.gcode"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			a, err := New(bytes.NewReader(tc.data), int64(len(tc.data)))
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			if !reflect.DeepEqual(a.GcodeParts(), tc.parts) {
+				t.Errorf("got parts %v, want %v", a.GcodeParts(), tc.parts)
+			}
+		})
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+
+	withoutGcode := mockArchive(t, []part{{name: "3D/3dmodel.model", content: "<model/>"}})
+
+	var cases = map[string][]byte{
+		"not a zip":     []byte("G28\n"),
+		"without gcode": withoutGcode,
+	}
+
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(bytes.NewReader(data), int64(len(data))); err == nil {
+				t.Errorf("got nil error, want error")
+			}
+		})
+	}
+}
+
+func TestRepackage(t *testing.T) {
+
+	var cases = map[string]struct {
+		data      []byte
+		part      string
+		unchanged []string
+	}{
+		"ufp": {
+			data:      mockUFP(t),
+			part:      "3D/model.gcode",
+			unchanged: []string{"[Content_Types].xml", "_rels/.rels", "Metadata/thumbnail.png"},
+		},
+		"bambu 3mf": {
+			data:      mockBambu(t),
+			part:      "Metadata/plate_2.gcode",
+			unchanged: []string{"[Content_Types].xml", "_rels/.rels", "Metadata/plate_1.gcode", "Metadata/plate_1.png"},
+		},
+	}
+
+	const gcode = "G28\nG1 X20 Y20\nM600\n"
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			a, err := New(bytes.NewReader(tc.data), int64(len(tc.data)))
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			out := &bytes.Buffer{}
+			if err := a.Repackage(out, tc.part, strings.NewReader(gcode)); err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			r, err := New(bytes.NewReader(out.Bytes()), int64(out.Len()))
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			if !reflect.DeepEqual(r.GcodeParts(), a.GcodeParts()) {
+				t.Errorf("got parts %v, want %v", r.GcodeParts(), a.GcodeParts())
+			}
+
+			if got := read(t, r, tc.part); got != gcode {
+				t.Errorf("got gcode %q, want %q", got, gcode)
+			}
+
+			for _, name := range tc.unchanged {
+				if got, want := read(t, r, name), read(t, a, name); got != want {
+					t.Errorf("got part %s %q, want %q", name, got, want)
+				}
+			}
+
+			if r.find(tc.part+MD5_SUFFIX) != nil {
+				sum := md5.Sum([]byte(gcode))
+				want := strings.ToUpper(hex.EncodeToString(sum[:]))
+				if got := read(t, r, tc.part+MD5_SUFFIX); got != want {
+					t.Errorf("got checksum %s, want %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRepackage_Errors(t *testing.T) {
+
+	data := mockUFP(t)
+	a, err := New(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	t.Run("unknown part", func(t *testing.T) {
+		if err := a.Repackage(&bytes.Buffer{}, "model.gcode", strings.NewReader("G28")); err == nil {
+			t.Errorf("got nil error, want error")
+		}
+	})
+
+	t.Run("nil gcode", func(t *testing.T) {
+		if err := a.Repackage(&bytes.Buffer{}, "3D/model.gcode", nil); err == nil {
+			t.Errorf("got nil error, want error")
+		}
+	})
+
+	t.Run("open unknown part", func(t *testing.T) {
+		if _, err := a.Open("model.gcode"); err == nil {
+			t.Errorf("got nil error, want error")
+		}
+	})
+}
+
+func TestOpen(t *testing.T) {
+
+	data := mockUFP(t)
+	a, err := New(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	got := read(t, a, a.GcodeParts()[0])
+	if want := ";FLAVOR:Marlin\nG28\nG1 X10 Y10\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package generator_test
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mauroalderete/gcode-core/generator"
+)
+
+func ExampleNew() {
+
+	b, err := generator.New(func(config generator.BuilderConfigurer) error {
+		return config.SetRelativeExtrusion(true)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	b.MoveZ(0.2).MoveTo(10, 10).LineTo(20, 10).ArcTo(30, 10, 5, 0, true).Retract()
+
+	_, err = b.WriteTo(os.Stdout)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	// Output:
+	// G90
+	// M83
+	// G0 Z0.200 F9000
+	// G0 X10.000 Y10.000
+	// G1 X20.000 Y10.000 E0.3742 F1800
+	// G2 X30.000 Y10.000 I5.000 J0.000 E0.5878
+	// G1 E-0.8000 F2100
+}
+
+func ExampleBuilder_PressureAdvance() {
+
+	b, err := generator.New()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	blocks, err := b.MoveZ(0.2).PressureAdvance(10, 10, 80, 5, generator.PRESSURE_ADVANCE_REPRAPFIRMWARE, 0, 0.02, 0.04).Blocks()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, block := range blocks {
+		if block.Command().String() == "M572" {
+			fmt.Println(block)
+		}
+	}
+
+	// Output:
+	// M572 D0 S0
+	// M572 D0 S0.020
+	// M572 D0 S0.040
+}
+
+func ExampleBuilder_Raw() {
+
+	b, err := generator.New(func(config generator.BuilderConfigurer) error {
+		return config.SetRelativeExtrusion(true)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// the linear advance of Marlin can't be parsed as a block, K isn't a word of gcode
+	b.Raw("M900 K0.05").MoveZ(0.2)
+
+	_, err = b.WriteTo(os.Stdout)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	// Output:
+	// G90
+	// M83
+	// M900 K0.05
+	// G0 Z0.200 F9000
+}
@@ -0,0 +1,503 @@
+// generator package generates gcode directly, without a slicer, like the calibration patterns that test a single setting.
+//
+// A Builder draws a toolpath with a fluent interface and produces a line of gcode for each move:
+//
+//	b, err := generator.New()
+//	b.MoveZ(0.2).MoveTo(10, 10).LineTo(60, 10).LineTo(60, 60).Retract()
+//	_, err = b.WriteTo(os.Stdout)
+//
+// The extrusion of each line and arc is computed from the length of the path, the width and the height of the line
+// and the diameter of the filament, so the toolpath only describes the geometry. The first error of a fluent call
+// is stored, the next calls do nothing and the error is returned by Err, Blocks and WriteTo.
+//
+// The position is tracked in float64 and each line is formatted from it, so the absolute E keeps his precision as it grows.
+// Each line has his gcodeblock.GcodeBlock created from the same values, returned by Blocks. The blocks store the numbers in float32,
+// so a value with more digits than a float32 holds, like the absolute E of a long toolpath, is rounded in his block and not in the line.
+package generator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/gcode"
+	"github.com/mauroalderete/gcode-core/gcode/addressablegcode"
+)
+
+const (
+	// GENERATOR_LINE_WIDTH is the width in mm of the lines by default
+	GENERATOR_LINE_WIDTH = 0.45
+
+	// GENERATOR_LAYER_HEIGHT is the height in mm of the lines by default
+	GENERATOR_LAYER_HEIGHT = 0.2
+
+	// GENERATOR_FILAMENT_DIAMETER is the diameter in mm of the filament by default
+	GENERATOR_FILAMENT_DIAMETER = 1.75
+
+	// GENERATOR_PRINT_FEEDRATE is the feedrate in mm/min of the lines and the arcs by default
+	GENERATOR_PRINT_FEEDRATE = 1800
+
+	// GENERATOR_TRAVEL_FEEDRATE is the feedrate in mm/min of the travels by default
+	GENERATOR_TRAVEL_FEEDRATE = 9000
+
+	// GENERATOR_RETRACT_LENGTH is the length in mm of the retractions by default
+	GENERATOR_RETRACT_LENGTH = 0.8
+
+	// GENERATOR_RETRACT_FEEDRATE is the feedrate in mm/min of the retractions and the primes by default
+	GENERATOR_RETRACT_FEEDRATE = 2100
+)
+
+//#region builder struct
+
+// Builder draws a toolpath and stores the lines that print it.
+type Builder struct {
+	// filament, line and feedrates of the new moves
+	diameter        float64
+	width           float64
+	height          float64
+	flow            float64
+	printFeedrate   float64
+	travelFeedrate  float64
+	retractLength   float64
+	retractFeedrate float64
+	relativeE       bool
+
+	// position of X, Y, Z and E. positioned is false until the first MoveTo, the position of X and Y is unknown
+	position   [4]float64
+	positioned bool
+
+	// feedrate is the last F written, it's written again only when it changes
+	feedrate float64
+
+	retracted bool
+
+	lines []line
+
+	// err is the first error of the fluent calls
+	err error
+}
+
+// MoveTo travels to X and Y without extruding.
+func (b *Builder) MoveTo(x float64, y float64) *Builder {
+
+	b.add('G', 0, b.travelFeedrate, word{'X', x}, word{'Y', y})
+	b.position[0], b.position[1] = x, y
+	b.positioned = true
+
+	return b
+}
+
+// MoveZ travels to the height Z without extruding, usually to start a layer.
+func (b *Builder) MoveZ(z float64) *Builder {
+
+	b.add('G', 0, b.travelFeedrate, word{'Z', z})
+	b.position[2] = z
+
+	return b
+}
+
+// LineTo prints a straight line to X and Y. The retraction in progress is primed first.
+func (b *Builder) LineTo(x float64, y float64) *Builder {
+
+	if !b.ready("line") {
+		return b
+	}
+
+	e := b.extrusion(math.Hypot(x-b.position[0], y-b.position[1]))
+	b.add('G', 1, b.printFeedrate, word{'X', x}, word{'Y', y}, word{'E', b.advance(e)})
+	b.position[0], b.position[1] = x, y
+
+	return b
+}
+
+// ArcTo prints an arc to X and Y around the center at the offsets I and J from the current position, clockwise with G2 or counter-clockwise with G3.
+// An arc that ends where it starts is a full circle. The retraction in progress is primed first.
+func (b *Builder) ArcTo(x float64, y float64, i float64, j float64, clockwise bool) *Builder {
+
+	if !b.ready("arc") {
+		return b
+	}
+
+	if i == 0 && j == 0 {
+		b.err = fmt.Errorf("failed to draw arc to %g,%g, the center mustn't be the current position", x, y)
+		return b
+	}
+
+	cx, cy := b.position[0]+i, b.position[1]+j
+	radius := math.Hypot(i, j)
+	if math.Abs(math.Hypot(x-cx, y-cy)-radius) > 0.01 {
+		b.err = fmt.Errorf("failed to draw arc to %g,%g, the end isn't at the radius %g of the center %g,%g", x, y, radius, cx, cy)
+		return b
+	}
+
+	start := math.Atan2(-j, -i)
+	end := math.Atan2(y-cy, x-cx)
+	angle := end - start
+	if clockwise {
+		angle = -angle
+	}
+	for angle <= 1e-9 {
+		angle += 2 * math.Pi
+	}
+
+	number := int32(3)
+	if clockwise {
+		number = 2
+	}
+
+	e := b.extrusion(radius * angle)
+	b.add('G', number, b.printFeedrate, word{'X', x}, word{'Y', y}, word{'I', i}, word{'J', j}, word{'E', b.advance(e)})
+	b.position[0], b.position[1] = x, y
+
+	return b
+}
+
+// Retract retracts the filament, before a travel over the print. It does nothing if the filament is already retracted.
+func (b *Builder) Retract() *Builder {
+
+	if b.err != nil || b.retracted || b.retractLength == 0 {
+		return b
+	}
+
+	b.add('G', 1, b.retractFeedrate, word{'E', b.advance(-b.retractLength)})
+	b.retracted = true
+
+	return b
+}
+
+// Prime pushes back the filament retracted. It does nothing if the filament isn't retracted.
+func (b *Builder) Prime() *Builder {
+
+	if b.err != nil || !b.retracted {
+		return b
+	}
+
+	b.retracted = false
+	b.add('G', 1, b.retractFeedrate, word{'E', b.advance(b.retractLength)})
+
+	return b
+}
+
+// Width sets the width of the next lines and arcs. It must be positive.
+func (b *Builder) Width(width float64) *Builder {
+
+	if width <= 0 {
+		b.fail(fmt.Errorf("failed to set width, it must be positive but got %g", width))
+		return b
+	}
+
+	b.width = width
+
+	return b
+}
+
+// Height sets the height of the next lines and arcs, usually the height of the layer. It must be positive.
+func (b *Builder) Height(height float64) *Builder {
+
+	if height <= 0 {
+		b.fail(fmt.Errorf("failed to set height, it must be positive but got %g", height))
+		return b
+	}
+
+	b.height = height
+
+	return b
+}
+
+// Flow sets the multiplier of the extrusion of the next lines and arcs, 1 is the extrusion computed. It must be positive.
+func (b *Builder) Flow(flow float64) *Builder {
+
+	if flow <= 0 {
+		b.fail(fmt.Errorf("failed to set flow, it must be positive but got %g", flow))
+		return b
+	}
+
+	b.flow = flow
+
+	return b
+}
+
+// Feedrate sets the feedrate in mm/min of the next lines and arcs. It must be positive.
+func (b *Builder) Feedrate(feedrate float64) *Builder {
+
+	if feedrate <= 0 {
+		b.fail(fmt.Errorf("failed to set feedrate, it must be positive but got %g", feedrate))
+		return b
+	}
+
+	b.printFeedrate = feedrate
+
+	return b
+}
+
+// Command adds a block parsed from the source, like M104 S200 or M572 D0 S0.05. The moves added this way don't update the position.
+// WriteTo writes the source as it is.
+func (b *Builder) Command(source string) *Builder {
+
+	if b.err != nil {
+		return b
+	}
+
+	block, err := gcodeblock.Parse(source)
+	if err != nil {
+		b.err = fmt.Errorf("failed to add command '%s': %w", source, err)
+		return b
+	}
+
+	b.lines = append(b.lines, line{text: strings.TrimSpace(source), block: block})
+
+	// the command can set the feedrate, the next move writes his own one
+	b.feedrate = 0
+
+	return b
+}
+
+// Raw adds a line as it is, without parsing it, for the commands that a block can't represent,
+// like the M900 K0.05 of Marlin or the SET_PRESSURE_ADVANCE ADVANCE=0.05 of Klipper.
+// The line is written by WriteTo but it isn't returned by Blocks. It must be a single line that isn't empty.
+func (b *Builder) Raw(source string) *Builder {
+
+	if b.err != nil {
+		return b
+	}
+
+	if strings.TrimSpace(source) == "" || strings.ContainsAny(source, "\r\n") {
+		b.err = fmt.Errorf("failed to add raw line '%s', it must be a single line that isn't empty", source)
+		return b
+	}
+
+	b.lines = append(b.lines, line{text: source})
+
+	// the line can set the feedrate, the next move writes his own one
+	b.feedrate = 0
+
+	return b
+}
+
+// Position returns the position of X, Y, Z and E after the last block.
+func (b *Builder) Position() [4]float64 {
+	return b.position
+}
+
+// Err returns the first error of the fluent calls, nil if all of them succeeded.
+func (b *Builder) Err() error {
+	return b.err
+}
+
+// Blocks returns the blocks of the toolpath, or the first error of the fluent calls.
+// The lines added with Raw aren't blocks, they are skipped.
+func (b *Builder) Blocks() ([]*gcodeblock.GcodeBlock, error) {
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	blocks := make([]*gcodeblock.GcodeBlock, 0, len(b.lines))
+	for _, l := range b.lines {
+		if l.block != nil {
+			blocks = append(blocks, l.block)
+		}
+	}
+
+	return blocks, nil
+}
+
+// WriteTo writes each line of the toolpath to w, and returns the number of bytes written.
+// Nothing is written if a fluent call failed.
+func (b *Builder) WriteTo(w io.Writer) (int64, error) {
+
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	writer := bufio.NewWriter(w)
+
+	var written int64
+	for _, l := range b.lines {
+		n, err := fmt.Fprintln(writer, l.text)
+		written += int64(n)
+		if err != nil {
+			return written, fmt.Errorf("failed to write gcode: %w", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return written, fmt.Errorf("failed to write gcode: %w", err)
+	}
+
+	return written, nil
+}
+
+// ready returns true if a path can be drawn from the current position, and primes the retraction in progress
+func (b *Builder) ready(path string) bool {
+
+	if b.err != nil {
+		return false
+	}
+
+	if !b.positioned {
+		b.err = fmt.Errorf("failed to draw %s, the position is unknown until the first MoveTo", path)
+		return false
+	}
+
+	b.Prime()
+
+	return b.err == nil
+}
+
+// extrusion returns the length of filament that fills a path of the length with the width and the height of the line
+func (b *Builder) extrusion(length float64) float64 {
+	area := math.Pi * b.diameter * b.diameter / 4
+	return length * b.width * b.height * b.flow / area
+}
+
+// advance moves the extruder and returns the E to write, relative or absolute
+func (b *Builder) advance(e float64) float64 {
+
+	b.position[3] += e
+
+	if b.relativeE {
+		return e
+	}
+
+	return b.position[3]
+}
+
+// add adds a line with the command, the parameters and the feedrate if it changes.
+// The line is formatted from the float64 values, and his block is created with New from the same values, in float32.
+func (b *Builder) add(letter byte, number int32, feedrate float64, words ...word) {
+
+	if b.err != nil {
+		return
+	}
+
+	command, err := addressablegcode.New(letter, number)
+	if err != nil {
+		b.err = fmt.Errorf("failed to create command %c%d: %w", letter, number, err)
+		return
+	}
+
+	text := command.String()
+	parameters := make([]gcode.Gcoder, 0, len(words)+1)
+
+	for _, w := range words {
+		p, err := addressablegcode.New(w.letter, float32(w.value))
+		if err != nil {
+			b.err = fmt.Errorf("failed to create parameter %s: %w", w, err)
+			return
+		}
+		text += " " + w.String()
+		parameters = append(parameters, p)
+	}
+
+	if feedrate != b.feedrate {
+		p, err := addressablegcode.New('F', int32(math.Round(feedrate)))
+		if err != nil {
+			b.err = fmt.Errorf("failed to create feedrate: %w", err)
+			return
+		}
+		text += " " + p.String()
+		parameters = append(parameters, p)
+		b.feedrate = feedrate
+	}
+
+	gcodeBlock, err := gcodeblock.New(command, func(config block.BlockConstructorConfigurer) error {
+		return config.SetParameters(parameters)
+	})
+	if err != nil {
+		b.err = fmt.Errorf("failed to create block '%s': %w", text, err)
+		return
+	}
+
+	b.lines = append(b.lines, line{text: text, block: gcodeBlock})
+}
+
+// fail stores the error if it's the first one
+func (b *Builder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+//#endregion
+//#region constructor
+
+// New returns a Builder with the settings of the options.
+//
+// The toolpath starts with the absolute positioning and the extrusion mode of the settings, G90 and M82 with G92 E0 or M83,
+// so the extrusion written matches the extrusion computed.
+func New(options ...BuilderConfigurationCallbackable) (*Builder, error) {
+
+	b := &Builder{
+		diameter:        GENERATOR_FILAMENT_DIAMETER,
+		width:           GENERATOR_LINE_WIDTH,
+		height:          GENERATOR_LAYER_HEIGHT,
+		flow:            1,
+		printFeedrate:   GENERATOR_PRINT_FEEDRATE,
+		travelFeedrate:  GENERATOR_TRAVEL_FEEDRATE,
+		retractLength:   GENERATOR_RETRACT_LENGTH,
+		retractFeedrate: GENERATOR_RETRACT_FEEDRATE,
+	}
+
+	configurator := &builderConfigurator{builder: b}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	b.add('G', 90, b.feedrate)
+	if b.relativeE {
+		b.add('M', 83, b.feedrate)
+	} else {
+		b.add('M', 82, b.feedrate)
+		b.add('G', 92, b.feedrate, word{'E', 0})
+	}
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	return b, nil
+}
+
+//#endregion
+//#region private types
+
+// line is a line of the toolpath, block is nil for the raw lines
+type line struct {
+	text  string
+	block *gcodeblock.GcodeBlock
+}
+
+// word is a parameter of a block
+type word struct {
+	letter byte
+	value  float64
+}
+
+// String returns the parameter with 4 decimals for E and 3 for the others, like the blocks write them.
+// A value rounded to zero is written without sign.
+func (w word) String() string {
+
+	decimals := 3
+	if w.letter == 'E' {
+		decimals = 4
+	}
+
+	address := strconv.FormatFloat(w.value, 'f', decimals, 64)
+	if strings.Trim(address, "-0.") == "" {
+		address = strings.TrimPrefix(address, "-")
+	}
+
+	return string(w.letter) + address
+}
+
+//#endregion
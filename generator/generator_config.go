@@ -0,0 +1,88 @@
+// This file defines a builderConfigurator as an object that implement the BuilderConfigurer
+// interface to allow the caller to configure a new Builder.
+package generator
+
+import "fmt"
+
+// BuilderConfigurer contains the configurable options that define the filament, the lines and the moves of a Builder.
+type BuilderConfigurer interface {
+	// Set the diameter of the filament
+	SetFilamentDiameter(diameter float64) error
+
+	// Set the width and the height of the lines
+	SetLine(width float64, height float64) error
+
+	// Set the feedrates of the lines and of the travels
+	SetFeedrates(print float64, travel float64) error
+
+	// Set the length and the feedrate of the retractions
+	SetRetraction(length float64, feedrate float64) error
+
+	// Set if the extrusion is written relative
+	SetRelativeExtrusion(relative bool) error
+}
+
+// BuilderConfigurationCallbackable is the signature of the callbacks that the New constructor waiting receives to configure the new Builder instance.
+type BuilderConfigurationCallbackable func(config BuilderConfigurer) error
+
+// builderConfigurator satisfy BuilderConfigurer, it applies each option directly over the Builder in construction.
+type builderConfigurator struct {
+	builder *Builder
+}
+
+// SetFilamentDiameter sets the diameter of the filament in mm. It must be positive, by default is GENERATOR_FILAMENT_DIAMETER.
+func (bc *builderConfigurator) SetFilamentDiameter(diameter float64) error {
+
+	if diameter <= 0 {
+		return fmt.Errorf("failed set filament diameter, it must be positive but got %g", diameter)
+	}
+
+	bc.builder.diameter = diameter
+
+	return nil
+}
+
+// SetLine sets the width and the height of the lines in mm, the Width and Height methods change them later.
+// They must be positive, by default are GENERATOR_LINE_WIDTH and GENERATOR_LAYER_HEIGHT.
+func (bc *builderConfigurator) SetLine(width float64, height float64) error {
+
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("failed set line, the width and the height must be positive but got %g and %g", width, height)
+	}
+
+	bc.builder.width, bc.builder.height = width, height
+
+	return nil
+}
+
+// SetFeedrates sets the feedrates in mm/min of the lines and of the travels, the Feedrate method changes the first one later.
+// They must be positive, by default are GENERATOR_PRINT_FEEDRATE and GENERATOR_TRAVEL_FEEDRATE.
+func (bc *builderConfigurator) SetFeedrates(print float64, travel float64) error {
+
+	if print <= 0 || travel <= 0 {
+		return fmt.Errorf("failed set feedrates, they must be positive but got %g and %g", print, travel)
+	}
+
+	bc.builder.printFeedrate, bc.builder.travelFeedrate = print, travel
+
+	return nil
+}
+
+// SetRetraction sets the length in mm and the feedrate in mm/min of the retractions. The length mustn't be negative, 0 disables them,
+// and the feedrate must be positive. By default are GENERATOR_RETRACT_LENGTH and GENERATOR_RETRACT_FEEDRATE.
+func (bc *builderConfigurator) SetRetraction(length float64, feedrate float64) error {
+
+	if length < 0 || feedrate <= 0 {
+		return fmt.Errorf("failed set retraction, the length mustn't be negative and the feedrate must be positive but got %g and %g", length, feedrate)
+	}
+
+	bc.builder.retractLength, bc.builder.retractFeedrate = length, feedrate
+
+	return nil
+}
+
+// SetRelativeExtrusion sets if the extrusion is written relative, with M83. By default is absolute, with M82.
+func (bc *builderConfigurator) SetRelativeExtrusion(relative bool) error {
+	bc.builder.relativeE = relative
+	return nil
+}
@@ -0,0 +1,120 @@
+// This file defines the patterns of the Builder, the shapes that the calibration prints are made of.
+package generator
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+)
+
+const (
+	// PRESSURE_ADVANCE_REPRAPFIRMWARE is the format of the command that sets the pressure advance of RepRapFirmware
+	PRESSURE_ADVANCE_REPRAPFIRMWARE = "M572 D0 S%g"
+
+	// PRESSURE_ADVANCE_MARLIN is the format of the command that sets the linear advance of Marlin
+	PRESSURE_ADVANCE_MARLIN = "M900 K%g"
+
+	// PRESSURE_ADVANCE_KLIPPER is the format of the command that sets the pressure advance of Klipper
+	PRESSURE_ADVANCE_KLIPPER = "SET_PRESSURE_ADVANCE ADVANCE=%g"
+)
+
+// Rectangle prints the perimeter of a rectangle with a corner at X and Y, traveling to it with a retraction.
+func (b *Builder) Rectangle(x float64, y float64, width float64, depth float64) *Builder {
+
+	if width <= 0 || depth <= 0 {
+		b.fail(fmt.Errorf("failed to draw rectangle, the width and the depth must be positive but got %g and %g", width, depth))
+		return b
+	}
+
+	return b.Retract().MoveTo(x, y).
+		LineTo(x+width, y).
+		LineTo(x+width, y+depth).
+		LineTo(x, y+depth).
+		LineTo(x, y)
+}
+
+// Fill prints a rectangle with a corner at X and Y filled with lines parallel to X, in zigzag, traveling to it with a retraction.
+// The lines are separated by the width of the line, the last one is at the opposite edge.
+func (b *Builder) Fill(x float64, y float64, width float64, depth float64) *Builder {
+
+	if width <= 0 || depth <= 0 {
+		b.fail(fmt.Errorf("failed to fill rectangle, the width and the depth must be positive but got %g and %g", width, depth))
+		return b
+	}
+
+	lines := int(math.Ceil(depth/b.width-1e-6)) + 1
+
+	b.Retract().MoveTo(x, y)
+	for i := 0; i < lines; i++ {
+		ly := math.Min(y+float64(i)*b.width, y+depth)
+		if i > 0 {
+			b.LineTo(b.position[0], ly)
+		}
+		if i%2 == 0 {
+			b.LineTo(x+width, ly)
+		} else {
+			b.LineTo(x, ly)
+		}
+	}
+
+	return b
+}
+
+// Grid prints squares of the size filled, in columns and rows separated by the spacing, with the first one at X and Y.
+// It tests the first layer and the level of the bed in several places.
+func (b *Builder) Grid(x float64, y float64, columns int, rows int, size float64, spacing float64) *Builder {
+
+	if columns < 1 || rows < 1 || spacing < 0 {
+		b.fail(fmt.Errorf("failed to draw grid, the columns and the rows must be positive and the spacing mustn't be negative but got %d, %d and %g", columns, rows, spacing))
+		return b
+	}
+
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			sx, sy := x+float64(column)*(size+spacing), y+float64(row)*(size+spacing)
+			b.Rectangle(sx, sy, size, size).Fill(sx+b.width, sy+b.width, size-2*b.width, size-2*b.width)
+		}
+	}
+
+	return b
+}
+
+// PressureAdvance prints a pattern of lines to calibrate the pressure advance, one for each value and separated by the spacing
+// along Y from X and Y. Each line has a slow section, a fast section and a slow section of the quarter, the half and the quarter of
+// the length, the fast one at the feedrate of the lines and the slow ones at the quarter of it. The best value is the line with
+// the most even width.
+//
+// The command is the format that sets each value, like PRESSURE_ADVANCE_REPRAPFIRMWARE, PRESSURE_ADVANCE_MARLIN or PRESSURE_ADVANCE_KLIPPER.
+// It's added with Command if gcodeblock can parse it, else with Raw, like the M900 K of Marlin and the SET_PRESSURE_ADVANCE of Klipper.
+// With an empty command only the lines are printed.
+func (b *Builder) PressureAdvance(x float64, y float64, length float64, spacing float64, command string, values ...float64) *Builder {
+
+	if length <= 0 || spacing <= 0 {
+		b.fail(fmt.Errorf("failed to draw pressure advance, the length and the spacing must be positive but got %g and %g", length, spacing))
+		return b
+	}
+
+	fast := b.printFeedrate
+	slow := fast / 4
+
+	for i, v := range values {
+		ly := y + float64(i)*spacing
+
+		if command != "" {
+			source := fmt.Sprintf(command, v)
+			if _, err := gcodeblock.Parse(source); err == nil {
+				b.Command(source)
+			} else {
+				b.Raw(source)
+			}
+		}
+
+		b.Retract().MoveTo(x, ly).
+			Feedrate(slow).LineTo(x+length/4, ly).
+			Feedrate(fast).LineTo(x+length*3/4, ly).
+			Feedrate(slow).LineTo(x+length, ly)
+	}
+
+	return b.Feedrate(fast)
+}
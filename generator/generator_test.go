@@ -0,0 +1,490 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/gcode"
+)
+
+// lines returns the lines written by the builder
+func lines(t *testing.T, b *Builder) []string {
+	t.Helper()
+
+	var output bytes.Buffer
+	n, err := b.WriteTo(&output)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if n != int64(output.Len()) {
+		t.Errorf("got %d bytes written, want %d", n, output.Len())
+	}
+
+	return strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+}
+
+// parameter returns the numeric address of a parameter of the block
+func parameter(t *testing.T, b *gcodeblock.GcodeBlock, word byte) float64 {
+	t.Helper()
+
+	for _, p := range b.Parameters() {
+		if p.Word() == word {
+			if v, ok := gcode.NumericAddress(p); ok {
+				return v
+			}
+		}
+	}
+
+	t.Fatalf("got block %s without %c, want it", b, word)
+	return 0
+}
+
+func TestBuilder(t *testing.T) {
+
+	b, err := New()
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	b.MoveZ(0.2).MoveTo(10, 10).LineTo(20, 10).Retract().Retract().MoveTo(0, 0).LineTo(0, 10).Prime()
+
+	want := []string{
+		"G90",
+		"M82",
+		"G92 E0.0000",
+		"G0 Z0.200 F9000",
+		"G0 X10.000 Y10.000",
+		"G1 X20.000 Y10.000 E0.3742 F1800",
+		"G1 E-0.4258 F2100",
+		"G0 X0.000 Y0.000 F9000",
+		"G1 E0.3742 F2100",
+		"G1 X0.000 Y10.000 E0.7484 F1800",
+	}
+
+	if got := lines(t, b); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if p := b.Position(); math.Abs(p[3]-0.7484) > 1e-4 || p[0] != 0 || p[1] != 10 || p[2] != 0.2 {
+		t.Errorf("got position %v, want 0,10,0.2,0.7484", p)
+	}
+}
+
+func TestBuilder_RelativeExtrusion(t *testing.T) {
+
+	b, err := New(func(config BuilderConfigurer) error {
+		if err := config.SetRelativeExtrusion(true); err != nil {
+			return err
+		}
+		if err := config.SetFilamentDiameter(2.85); err != nil {
+			return err
+		}
+		if err := config.SetLine(0.5, 0.3); err != nil {
+			return err
+		}
+		if err := config.SetFeedrates(1200, 6000); err != nil {
+			return err
+		}
+		return config.SetRetraction(1, 1800)
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	b.MoveTo(0, 0).LineTo(100, 0).Retract().MoveTo(0, 10).LineTo(100, 10)
+
+	// 100 mm of a line of 0.5 x 0.3 are 15 mm³, 2.3513 mm of filament of 2.85 mm
+	want := []string{
+		"G90",
+		"M83",
+		"G0 X0.000 Y0.000 F6000",
+		"G1 X100.000 Y0.000 E2.3513 F1200",
+		"G1 E-1.0000 F1800",
+		"G0 X0.000 Y10.000 F6000",
+		"G1 E1.0000 F1800",
+		"G1 X100.000 Y10.000 E2.3513 F1200",
+	}
+
+	if got := lines(t, b); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBuilder_ArcTo(t *testing.T) {
+
+	var cases = map[string]struct {
+		x, y, i, j float64
+		clockwise  bool
+		command    string
+		length     float64
+	}{
+		"counter-clockwise quarter": {x: 0, y: 10, i: -10, j: 0, clockwise: false, command: "G3", length: math.Pi * 5},
+		"clockwise three quarters":  {x: 0, y: 10, i: -10, j: 0, clockwise: true, command: "G2", length: math.Pi * 15},
+		"full circle":               {x: 10, y: 0, i: -10, j: 0, clockwise: true, command: "G2", length: math.Pi * 20},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			b, err := New(func(config BuilderConfigurer) error {
+				return config.SetRelativeExtrusion(true)
+			})
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			blocks, err := b.MoveTo(10, 0).ArcTo(tc.x, tc.y, tc.i, tc.j, tc.clockwise).Blocks()
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			arc := blocks[len(blocks)-1]
+			if arc.Command().String() != tc.command {
+				t.Errorf("got command %s, want %s", arc.Command(), tc.command)
+			}
+
+			if want := b.extrusion(tc.length); math.Abs(parameter(t, arc, 'E')-want) > 1e-4 {
+				t.Errorf("got extrusion %v, want %v", parameter(t, arc, 'E'), want)
+			}
+
+			if parameter(t, arc, 'I') != tc.i || parameter(t, arc, 'J') != tc.j {
+				t.Errorf("got arc %s, want the center at I%g J%g", arc, tc.i, tc.j)
+			}
+		})
+	}
+}
+
+func TestBuilder_Changes(t *testing.T) {
+
+	b, err := New(func(config BuilderConfigurer) error {
+		return config.SetRelativeExtrusion(true)
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	reference := b.extrusion(10)
+
+	blocks, err := b.MoveTo(0, 0).Width(0.9).LineTo(10, 0).Height(0.4).LineTo(20, 0).Flow(0.5).Feedrate(600).LineTo(30, 0).Blocks()
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	for i, factor := range []float64{2, 4, 2} {
+		if got := parameter(t, blocks[3+i], 'E'); math.Abs(got-reference*factor) > 1e-4 {
+			t.Errorf("got extrusion %v in the line %d, want %v", got, i, reference*factor)
+		}
+	}
+
+	if f := parameter(t, blocks[5], 'F'); f != 600 {
+		t.Errorf("got feedrate %v, want 600", f)
+	}
+}
+
+func TestBuilder_Blocks(t *testing.T) {
+
+	b, err := New()
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	b.MoveZ(0.3).Grid(10, 10, 2, 2, 10, 5).PressureAdvance(50, 10, 40, 5, PRESSURE_ADVANCE_REPRAPFIRMWARE, 0, 0.05, 0.1).Command("M104 S0")
+
+	blocks, err := b.Blocks()
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	written := lines(t, b)
+	if len(written) != len(blocks) {
+		t.Fatalf("got %d lines, want a line for each of the %d blocks", len(written), len(blocks))
+	}
+
+	// the lines written are parsed again as the same blocks
+	for i, text := range written {
+		parsed, err := gcodeblock.Parse(text)
+		if err != nil {
+			t.Fatalf("got error %v parsing %q, want nil error", err, text)
+		}
+		if parsed.String() != blocks[i].String() {
+			t.Errorf("got block %s, want %s", parsed, blocks[i])
+		}
+	}
+
+	counts := map[string]int{}
+	for _, text := range written {
+		counts[strings.Fields(text)[0]]++
+	}
+
+	if counts["M572"] != 3 || counts["M104"] != 1 {
+		t.Errorf("got %v commands, want 3 M572 and a M104", counts)
+	}
+}
+
+func TestBuilder_PressureAdvanceCommands(t *testing.T) {
+
+	var cases = map[string]struct {
+		command string
+		want    []string
+		blocks  int
+	}{
+		"reprapfirmware": {command: PRESSURE_ADVANCE_REPRAPFIRMWARE, want: []string{"M572 D0 S0", "M572 D0 S0.04"}, blocks: 2},
+		"marlin":         {command: PRESSURE_ADVANCE_MARLIN, want: []string{"M900 K0", "M900 K0.04"}},
+		"klipper":        {command: PRESSURE_ADVANCE_KLIPPER, want: []string{"SET_PRESSURE_ADVANCE ADVANCE=0", "SET_PRESSURE_ADVANCE ADVANCE=0.04"}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			b, err := New()
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			b.MoveZ(0.2).PressureAdvance(10, 10, 40, 5, tc.command, 0, 0.04)
+
+			got := []string{}
+			for _, text := range lines(t, b) {
+				if !strings.HasPrefix(text, "G") && text != "M82" {
+					got = append(got, text)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+
+			blocks, err := b.Blocks()
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			commands := 0
+			for _, block := range blocks {
+				if block.Command().String() == "M572" {
+					commands++
+				}
+			}
+			if commands != tc.blocks {
+				t.Errorf("got %d blocks of the commands, want %d, the raw lines aren't blocks", commands, tc.blocks)
+			}
+		})
+	}
+}
+
+func TestBuilder_Precision(t *testing.T) {
+
+	b, err := New(func(config BuilderConfigurer) error {
+		return config.SetRetraction(0, 1800)
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	// the absolute E grows to 4619.46283mm, a float32 writes it as 4619.4629
+	b.MoveTo(0, 0)
+	for i := 0; i < 100; i++ {
+		b.LineTo(1234.5678*float64(1-i%2), 0)
+	}
+
+	written := lines(t, b)
+	if last := written[len(written)-1]; !strings.HasSuffix(last, "E4619.4628") {
+		t.Errorf("got last line %q, want it ending with E4619.4628", last)
+	}
+
+	// the block of the line has the same value, rounded to float32
+	blocks, err := b.Blocks()
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+	if len(blocks) != len(written) {
+		t.Fatalf("got %d blocks, want one for each of the %d lines", len(blocks), len(written))
+	}
+
+	parameters := blocks[len(blocks)-1].Parameters()
+	e, ok := gcode.NumericAddress(parameters[len(parameters)-1])
+	if !ok || float32(e) != float32(b.Position()[3]) {
+		t.Errorf("got E %v in the block, want %v", e, float32(b.Position()[3]))
+	}
+}
+
+func TestBuilder_Patterns(t *testing.T) {
+
+	b, err := New(func(config BuilderConfigurer) error {
+		if err := config.SetRetraction(0, 1800); err != nil {
+			return err
+		}
+		return config.SetLine(1, 0.2)
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	blocks, err := b.Fill(0, 0, 10, 2.5).Blocks()
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	got := []string{}
+	for _, block := range blocks[3:] {
+		got = append(got, block.ToLine("%c %p"))
+	}
+
+	// the lines at 0, 1 and 2, and the last one at the edge
+	want := []string{
+		"G0 X0.000 Y0.000 F9000",
+		"G1 X10.000 Y0.000 E0.8315 F1800",
+		"G1 X10.000 Y1.000 E0.9147",
+		"G1 X0.000 Y1.000 E1.7462",
+		"G1 X0.000 Y2.000 E1.8293",
+		"G1 X10.000 Y2.000 E2.6608",
+		"G1 X10.000 Y2.500 E2.7024",
+		"G1 X0.000 Y2.500 E3.5339",
+	}
+
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBuilder_Errors(t *testing.T) {
+
+	var cases = map[string]struct {
+		draw    func(b *Builder) *Builder
+		message string
+	}{
+		"line without position": {
+			draw:    func(b *Builder) *Builder { return b.MoveZ(0.2).LineTo(10, 10) },
+			message: "the position is unknown until the first MoveTo",
+		},
+		"arc without center": {
+			draw:    func(b *Builder) *Builder { return b.MoveTo(0, 0).ArcTo(10, 0, 0, 0, true) },
+			message: "the center mustn't be the current position",
+		},
+		"arc outside the radius": {
+			draw:    func(b *Builder) *Builder { return b.MoveTo(0, 0).ArcTo(20, 0, 5, 0, true) },
+			message: "the end isn't at the radius 5",
+		},
+		"width": {
+			draw:    func(b *Builder) *Builder { return b.Width(0) },
+			message: "failed to set width",
+		},
+		"height": {
+			draw:    func(b *Builder) *Builder { return b.Height(-1) },
+			message: "failed to set height",
+		},
+		"flow": {
+			draw:    func(b *Builder) *Builder { return b.Flow(0) },
+			message: "failed to set flow",
+		},
+		"feedrate": {
+			draw:    func(b *Builder) *Builder { return b.Feedrate(0) },
+			message: "failed to set feedrate",
+		},
+		"command": {
+			draw:    func(b *Builder) *Builder { return b.Command("M117 Hello") },
+			message: "failed to add command 'M117 Hello'",
+		},
+		"empty raw line": {
+			draw:    func(b *Builder) *Builder { return b.Raw(" ") },
+			message: "failed to add raw line",
+		},
+		"several raw lines": {
+			draw:    func(b *Builder) *Builder { return b.Raw("M900 K0\nM900 K1") },
+			message: "failed to add raw line",
+		},
+		"rectangle": {
+			draw:    func(b *Builder) *Builder { return b.Rectangle(0, 0, 0, 10) },
+			message: "failed to draw rectangle",
+		},
+		"fill": {
+			draw:    func(b *Builder) *Builder { return b.Fill(0, 0, 10, -1) },
+			message: "failed to fill rectangle",
+		},
+		"grid": {
+			draw:    func(b *Builder) *Builder { return b.Grid(0, 0, 0, 2, 10, 5) },
+			message: "failed to draw grid",
+		},
+		"pressure advance": {
+			draw:    func(b *Builder) *Builder { return b.PressureAdvance(0, 0, 40, 0, "", 0.1) },
+			message: "failed to draw pressure advance",
+		},
+		"the first error": {
+			draw:    func(b *Builder) *Builder { return b.Width(0).Height(0).MoveTo(0, 0).LineTo(10, 0) },
+			message: "failed to set width",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			b, err := New()
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			before := len(b.lines)
+			b = tc.draw(b)
+
+			if b.Err() == nil || !strings.Contains(b.Err().Error(), tc.message) {
+				t.Fatalf("got error %v, want it containing '%s'", b.Err(), tc.message)
+			}
+
+			if _, err := b.Blocks(); !errors.Is(err, b.Err()) {
+				t.Errorf("got error %v from Blocks, want the error of the builder", err)
+			}
+
+			var output bytes.Buffer
+			if n, err := b.WriteTo(&output); err == nil || n != 0 || output.Len() != 0 {
+				t.Errorf("got %d bytes and error %v, want nothing written and the error", n, err)
+			}
+
+			// the calls after the error don't add lines
+			if n := len(b.MoveTo(1, 1).LineTo(2, 2).Retract().lines); n > before+2 {
+				t.Errorf("got %d lines after the error, want at most %d", n, before+2)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidConfiguration(t *testing.T) {
+
+	var cases = map[string]BuilderConfigurationCallbackable{
+		"filament diameter": func(config BuilderConfigurer) error { return config.SetFilamentDiameter(0) },
+		"line":              func(config BuilderConfigurer) error { return config.SetLine(0.4, 0) },
+		"feedrates":         func(config BuilderConfigurer) error { return config.SetFeedrates(-1, 6000) },
+		"retraction":        func(config BuilderConfigurer) error { return config.SetRetraction(-1, 1800) },
+	}
+
+	for name, option := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(option); err == nil {
+				t.Errorf("got nil error, want error")
+			}
+		})
+	}
+}
+
+// failingWriter fails on each write
+type failingWriter struct{}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestBuilder_WriteError(t *testing.T) {
+
+	b, err := New()
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if _, err := b.WriteTo(failingWriter{}); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("got error %v, want the error of the writer", err)
+	}
+}
@@ -0,0 +1,54 @@
+package integrity_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/integrity"
+)
+
+func ExampleCheck() {
+
+	gcode := `N0 M110 N0*125
+N1 G28*18
+N3 G1 X10*81
+N4 G1 X20
+`
+
+	report, err := integrity.Check(strings.NewReader(gcode))
+	if err != nil {
+		fmt.Printf("failed to check: %v", err)
+		return
+	}
+
+	fmt.Printf("valid: %v, first bad line: %d\n", report.Valid(), report.FirstBad)
+	fmt.Printf("gaps: %+v\n", report.Gaps)
+	fmt.Printf("missing checksums: %v\n", report.MissingChecksums)
+
+	// Output:
+	// valid: false, first bad line: 3
+	// gaps: [{Line:3 Expected:2 Found:3}]
+	// missing checksums: [4]
+}
+
+func ExampleRepair() {
+
+	gcode := `; purge
+N1 G28*18
+N3 G1 X10*81 ; move
+G1 X20
+`
+
+	err := integrity.Repair(os.Stdout, strings.NewReader(gcode))
+	if err != nil {
+		fmt.Printf("failed to repair: %v", err)
+		return
+	}
+
+	// Output:
+	// ; purge
+	// N1 G28*18
+	// N2 G1 X10*83 ; move
+	// N3 G1 X20*81
+}
@@ -0,0 +1,409 @@
+// integrity package verifies the line numbers and the checksums of a whole gcode file, and repairs them.
+//
+// The firmware expects that each line sent by the host carries the number of the previous line plus one,
+// and a checksum of the line. M110 sets the number of the current line:
+//
+//	N0 M110 N0*125
+//	N1 G28*18
+//	N2 G1 X10*83
+//
+// A Checker receives the lines one by one and reports the lines numbered without a checksum, the checksums that don't match,
+// the numbered lines between lines without number, the gaps and the duplicated numbers.
+// Repair writes the file again, numbering each line and computing his checksum.
+package integrity
+
+import (
+	"bufio"
+	"fmt"
+	"hash"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/checksum"
+	"github.com/mauroalderete/gcode-core/gcode"
+)
+
+// numbered matches the line number, the code, the checksum and the comment of a line, it's used with the lines that can't be parsed
+var numbered = regexp.MustCompile(`^\s*(?:N(\d+)\s*)?(.*?)\s*(?:\*(\d+))?\s*(;.*)?$`)
+
+//#region report struct
+
+// Jump is a line whose number isn't the next one.
+type Jump struct {
+	// Line is the number of the line in the file, starting at 1
+	Line int
+
+	// Expected is the number that the line should have, and Found the number that it has
+	Expected uint32
+	Found    uint32
+}
+
+// Report is the result of the verification of a file.
+//
+// The lines are the numbers of the lines in the file, starting at 1.
+type Report struct {
+	// Numbered counts the lines with a line number
+	Numbered int
+
+	// FirstBad is the first line with a problem, 0 if there isn't any
+	FirstBad int
+
+	// MissingChecksums stores the numbered lines without a checksum
+	MissingChecksums []int
+
+	// BadChecksums stores the lines whose checksum doesn't match
+	BadChecksums []int
+
+	// Unnumbered stores the lines with gcode but without number, after the first numbered line
+	Unnumbered []int
+
+	// Gaps stores the lines whose number skips some numbers
+	Gaps []Jump
+
+	// Duplicates stores the lines whose number was already used, without a M110 between them
+	Duplicates []Jump
+}
+
+// Valid returns true if the report hasn't problems.
+func (r *Report) Valid() bool {
+	return r.FirstBad == 0
+}
+
+// bad records a problem in a line
+func (r *Report) bad(line int) {
+	if r.FirstBad == 0 || line < r.FirstBad {
+		r.FirstBad = line
+	}
+}
+
+//#endregion
+//#region checker struct
+
+// Checker receives the lines of a gcode file one by one and verifies their numbers and checksums.
+type Checker struct {
+	// hash computes the checksums when it's shared, else hashFactory creates a hash for each checksum
+	hash        hash.Hash
+	hashFactory checksum.Factory
+
+	// start is the number of the first line written by Repair
+	start uint32
+
+	report Report
+
+	// lines counts the lines added
+	lines int
+
+	// last is the number of the last numbered line, valid if started is true, or the number set by a M110 without number if reset is true
+	last    uint32
+	started bool
+	reset   bool
+}
+
+// Add receives the next line of the gcode file.
+func (c *Checker) Add(line string) {
+
+	c.lines++
+
+	if !gcodeblock.HasGcode(line) {
+		return
+	}
+
+	l := c.inspect(line)
+
+	// like the firmware, a M110 without number sets the number of the last line too, the hosts send it before numbering the lines
+	if !l.hasNumber && l.reset {
+		c.last, c.reset = l.resetTo, true
+		return
+	}
+
+	if !l.hasNumber {
+		if c.started {
+			c.report.Unnumbered = append(c.report.Unnumbered, c.lines)
+			c.report.bad(c.lines)
+		}
+		return
+	}
+
+	c.report.Numbered++
+
+	if !l.hasChecksum {
+		c.report.MissingChecksums = append(c.report.MissingChecksums, c.lines)
+		c.report.bad(c.lines)
+	} else if !l.valid {
+		c.report.BadChecksums = append(c.report.BadChecksums, c.lines)
+		c.report.bad(c.lines)
+	}
+
+	// like the firmware, the number of a M110 isn't verified
+	if (c.started || c.reset) && !l.reset && l.number != c.last+1 {
+		jump := Jump{Line: c.lines, Expected: c.last + 1, Found: l.number}
+		if l.number > c.last+1 {
+			c.report.Gaps = append(c.report.Gaps, jump)
+		} else {
+			c.report.Duplicates = append(c.report.Duplicates, jump)
+		}
+		c.report.bad(c.lines)
+	}
+
+	c.started = true
+	c.last = l.number
+	if l.reset {
+		c.last = l.resetTo
+	}
+}
+
+// Report returns the report of the lines added until now.
+func (c *Checker) Report() *Report {
+
+	report := c.report
+	report.MissingChecksums = append([]int{}, c.report.MissingChecksums...)
+	report.BadChecksums = append([]int{}, c.report.BadChecksums...)
+	report.Unnumbered = append([]int{}, c.report.Unnumbered...)
+	report.Gaps = append([]Jump{}, c.report.Gaps...)
+	report.Duplicates = append([]Jump{}, c.report.Duplicates...)
+
+	return &report
+}
+
+// inspection describes the sections of a line that are verified
+type inspection struct {
+	// block is nil if the line can't be parsed
+	block *gcodeblock.GcodeBlock
+
+	// code is the line without the number, the checksum and the comment, and comment is the comment
+	code    string
+	comment string
+
+	number    uint32
+	hasNumber bool
+
+	hasChecksum bool
+	valid       bool
+
+	// reset is true if the line is a M110 that sets a number, and resetTo is the number that it sets
+	reset   bool
+	resetTo uint32
+}
+
+// inspect splits a line in his sections and verifies his checksum
+func (c *Checker) inspect(source string) inspection {
+
+	l := inspection{}
+
+	b, err := gcodeblock.Parse(source, c.blockOptions()...)
+	if err == nil {
+		l.block = b
+		l.code, l.comment = b.ToLine("%c %p"), strings.TrimSpace(b.Comment())
+
+		if b.LineNumber() != nil {
+			l.number, l.hasNumber = b.LineNumber().Address(), true
+		}
+		if b.Checksum() != nil {
+			l.hasChecksum = true
+			l.valid = c.sum(checksummed(source)) == b.Checksum().Address()
+		}
+
+		// a M110 without number nor parameter doesn't set any number
+		if n, ok := gcode.NumericAddress(b.Command()); ok && n == 110 && b.Command().Word() == 'M' {
+			l.reset, l.resetTo = l.hasNumber, l.number
+			for _, p := range b.Parameters() {
+				if n, ok := gcode.NumericAddress(p); ok && p.Word() == 'N' {
+					l.reset, l.resetTo = true, uint32(n)
+				}
+			}
+		}
+
+		return l
+	}
+
+	// the lines that can't be parsed, like a M117 with an unquoted message, are verified as they are
+	match := numbered.FindStringSubmatch(source)
+	if match == nil {
+		return l
+	}
+
+	l.code, l.comment = match[2], match[4]
+	if n, err := strconv.ParseUint(match[1], 10, 32); err == nil {
+		l.number, l.hasNumber = uint32(n), true
+	}
+	if n, err := strconv.ParseUint(match[3], 10, 32); err == nil {
+		l.hasChecksum = true
+		l.valid = c.sum(checksummed(source)) == uint32(n)
+	}
+
+	return l
+}
+
+// blockOptions returns the options to parse the blocks with the hash of the Checker.
+// A block parsed by a configurer without a factory of hashes gets a new hash of the factory.
+func (c *Checker) blockOptions() []block.BlockParserConfigurationCallbackable {
+	return []block.BlockParserConfigurationCallbackable{
+		func(config block.BlockParserConfigurer) error {
+			if c.hash != nil {
+				return config.SetHash(c.hash)
+			}
+			if factoryConfig, ok := config.(block.BlockHashFactoryConfigurer); ok {
+				return factoryConfig.SetHashFactory(c.hashFactory)
+			}
+			return config.SetHash(c.hashFactory())
+		},
+	}
+}
+
+// checksummed returns the text of a line covered by his checksum, as it was written: the text before the last '*' outside the comment.
+// The checksum is verified over the original text and not over the block, whose String normalizes the numbers.
+func checksummed(source string) string {
+
+	if i := strings.IndexByte(source, ';'); i >= 0 {
+		source = source[:i]
+	}
+	if i := strings.LastIndexByte(source, '*'); i >= 0 {
+		source = source[:i]
+	}
+
+	return source
+}
+
+// sum returns the checksum of a text, with his spaces, the firmware computes it over each byte before the '*'
+func (c *Checker) sum(text string) uint32 {
+
+	data := []byte(text)
+
+	var value uint32
+	if c.hash != nil {
+		value, _ = checksum.Compute(c.hash, data)
+	} else {
+		value, _ = c.hashFactory.Compute(data)
+	}
+
+	return value
+}
+
+//#endregion
+//#region constructor
+
+// New returns a Checker ready to receive the first line of a gcode file.
+func New(options ...CheckerConfigurationCallbackable) (*Checker, error) {
+
+	checker := &Checker{
+		hashFactory: checksum.New,
+		start:       1,
+	}
+
+	configurator := &checkerConfigurator{checker: checker}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	return checker, nil
+}
+
+//#endregion
+//#region package functions
+
+// Check reads a gcode file and returns the report of his line numbers and checksums.
+func Check(r io.Reader, options ...CheckerConfigurationCallbackable) (*Report, error) {
+
+	c, err := New(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), gcodeblock.SCANNER_MAX_LINE_SIZE)
+
+	for scanner.Scan() {
+		c.Add(strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read gcode: %w", err)
+	}
+
+	return c.Report(), nil
+}
+
+// Repair reads a gcode file and writes it again with each line with gcode numbered and with his checksum.
+//
+// The lines are numbered consecutively from the start configured, replacing their old numbers and checksums.
+// The M110 keep the numbering consecutive, they set the number of their own line.
+// The comments and the lines without gcode are kept as they are.
+func Repair(w io.Writer, r io.Reader, options ...CheckerConfigurationCallbackable) error {
+
+	c, err := New(options...)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), gcodeblock.SCANNER_MAX_LINE_SIZE)
+
+	number := c.start
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if gcodeblock.HasGcode(line) {
+			repaired, err := c.repair(line, number)
+			if err != nil {
+				return fmt.Errorf("failed to repair line '%s': %w", line, err)
+			}
+			line = repaired
+			number++
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return fmt.Errorf("failed to write gcode: %w", err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read gcode: %w", err)
+	}
+
+	return nil
+}
+
+//#endregion
+//#region private functions
+
+// repair returns the line with the number and his checksum
+func (c *Checker) repair(source string, number uint32) (string, error) {
+
+	l := c.inspect(source)
+
+	code := fmt.Sprintf("N%d %s", number, l.code)
+	if l.reset {
+		code = fmt.Sprintf("N%d M110 N%d", number, number)
+	}
+
+	// the checksum of the blocks is computed by the block itself, so it's the same that VerifyChecksum expects
+	if l.block != nil {
+		b, err := gcodeblock.Parse(code, c.blockOptions()...)
+		if err != nil {
+			return "", err
+		}
+		if err := b.UpdateChecksum(); err != nil {
+			return "", err
+		}
+		code += b.Checksum().String()
+	} else {
+		code += fmt.Sprintf("*%d", c.sum(code))
+	}
+
+	if l.comment != "" {
+		code += " " + l.comment
+	}
+
+	return code, nil
+}
+
+//#endregion
@@ -0,0 +1,75 @@
+// This file defines a checkerConfigurator as an object that implement the CheckerConfigurer
+// interface to allow the caller to configure a new Checker or a repair.
+package integrity
+
+import (
+	"fmt"
+	"hash"
+
+	"github.com/mauroalderete/gcode-core/checksum"
+)
+
+// CheckerConfigurer contains the configurable options that define the checksum algorithm and the numbering of a Checker.
+type CheckerConfigurer interface {
+	// Set the hash instance that implement the algorithm of the checksums
+	SetHash(hash hash.Hash) error
+
+	// Set the factory of the hashes that implement the algorithm of the checksums, to use a new hash on each checksum
+	SetHashFactory(factory checksum.Factory) error
+
+	// Set the number of the first line written by Repair
+	SetStart(start uint32) error
+}
+
+// CheckerConfigurationCallbackable is the signature of the callbacks that the New constructor and Repair waiting receive to configure the checks.
+type CheckerConfigurationCallbackable func(config CheckerConfigurer) error
+
+// checkerConfigurator satisfy CheckerConfigurer, it applies each option directly over the Checker in construction.
+type checkerConfigurator struct {
+	checker *Checker
+}
+
+// SetHash sets the hash used to compute the checksums. Doesn't accept nil nor a hash of more than 4 bytes.
+// By default is the checksum of Marlin and RepRap from the checksum package.
+//
+// The instance can be shared with other Checkers and blocks if it carries a lock, like the hashes of the checksum package,
+// their checksums are computed one at a time. See checksum.Compute.
+func (cc *checkerConfigurator) SetHash(hash hash.Hash) error {
+
+	if hash == nil {
+		return fmt.Errorf("failed set hash, it mustn't be nil")
+	}
+
+	if hash.Size() > 4 {
+		return fmt.Errorf("failed set hash, it returns %d bytes but a checksum can store up to 4", hash.Size())
+	}
+
+	cc.checker.hash = hash
+	cc.checker.hashFactory = nil
+
+	return nil
+}
+
+// SetHashFactory sets the factory of the hashes used to compute the checksums. Doesn't accept nil nor a hash of more than 4 bytes.
+// Each checksum uses a new hash, so several Checkers can work concurrently. It replaces the hash set with SetHash.
+func (cc *checkerConfigurator) SetHashFactory(factory checksum.Factory) error {
+
+	if factory == nil {
+		return fmt.Errorf("failed set hash factory, it mustn't be nil")
+	}
+
+	if h := factory(); h == nil || h.Size() > 4 {
+		return fmt.Errorf("failed set hash factory, it must return hashes of up to 4 bytes")
+	}
+
+	cc.checker.hash = nil
+	cc.checker.hashFactory = factory
+
+	return nil
+}
+
+// SetStart sets the number of the first line written by Repair. By default is 1.
+func (cc *checkerConfigurator) SetStart(start uint32) error {
+	cc.checker.start = start
+	return nil
+}
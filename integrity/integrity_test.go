@@ -0,0 +1,248 @@
+package integrity
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"hash/crc32"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mauroalderete/gcode-core/checksum"
+)
+
+func check(t *testing.T, source string, options ...CheckerConfigurationCallbackable) *Report {
+	t.Helper()
+
+	r, err := Check(strings.NewReader(source), options...)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	return r
+}
+
+func TestCheck(t *testing.T) {
+
+	var cases = map[string]struct {
+		source string
+		want   Report
+	}{
+		"valid": {
+			source: "; header\nN0 M110 N0*125\nN1 G28*18\n\nN2 G1 X10*83 ; move\n",
+			want:   Report{Numbered: 3},
+		},
+		"unparseable line": {
+			source: "N1 G28*18\nN2 M117 Hello*68\n",
+			want:   Report{Numbered: 2},
+		},
+		"without numbers": {
+			source: "G28\nG1 X10\n",
+			want:   Report{},
+		},
+		"checksum of the written numbers": {
+			source: "N1 G1 X10.50*123\nN2 G1 X10 Y0.100 E0.0500*123\n",
+			want:   Report{Numbered: 2},
+		},
+		"asterisk in the comment": {
+			source: "N1 G28*18 ; 2*3\nN2 M117 Hello*68 ; a*b\n",
+			want:   Report{Numbered: 2},
+		},
+		"bad checksum": {
+			source: "N1 G28*18\nN2 G1 X10*84\nN3 M117 Hello*70\n",
+			want:   Report{Numbered: 3, FirstBad: 2, BadChecksums: []int{2, 3}},
+		},
+		"missing checksum": {
+			source: "N1 G28*18\nN2 G1 X10\n",
+			want:   Report{Numbered: 2, FirstBad: 2, MissingChecksums: []int{2}},
+		},
+		"unnumbered": {
+			source: "G90\nN1 G28*18\nG1 X10\n",
+			want:   Report{Numbered: 1, FirstBad: 3, Unnumbered: []int{3}},
+		},
+		"gap": {
+			source: "N1 G28*18\nN3 G28*16\n",
+			want:   Report{Numbered: 2, FirstBad: 2, Gaps: []Jump{{Line: 2, Expected: 2, Found: 3}}},
+		},
+		"duplicate": {
+			source: "N1 G28*18\nN1 G28*18\n",
+			want:   Report{Numbered: 2, FirstBad: 2, Duplicates: []Jump{{Line: 2, Expected: 2, Found: 1}}},
+		},
+		"reset": {
+			source: "N7 G28*20\nN9 M110 N0*13\nN1 G28*18\n",
+			want:   Report{Numbered: 3, FirstBad: 2, BadChecksums: []int{2}},
+		},
+		"reset without number": {
+			source: "N1 G28*18\nM110 N10\nN11 G1 X1*81\n",
+			want:   Report{Numbered: 2},
+		},
+		"gap after a reset without number": {
+			source: "M110 N10\nN12 G28*32\n",
+			want:   Report{Numbered: 1, FirstBad: 2, Gaps: []Jump{{Line: 2, Expected: 11, Found: 12}}},
+		},
+		"space before the checksum": {
+			source: "N1 G28 *18\nN2 G28 *49\n",
+			want:   Report{Numbered: 2, FirstBad: 1, BadChecksums: []int{1}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := check(t, tc.source)
+
+			// the empty slices are compared as nil
+			for _, s := range []*[]int{&got.MissingChecksums, &got.BadChecksums, &got.Unnumbered} {
+				if len(*s) == 0 {
+					*s = nil
+				}
+			}
+			if len(got.Gaps) == 0 {
+				got.Gaps = nil
+			}
+			if len(got.Duplicates) == 0 {
+				got.Duplicates = nil
+			}
+
+			if !reflect.DeepEqual(*got, tc.want) {
+				t.Errorf("got %+v, want %+v", *got, tc.want)
+			}
+			if got.Valid() != (tc.want.FirstBad == 0) {
+				t.Errorf("got valid %v, want %v", got.Valid(), tc.want.FirstBad == 0)
+			}
+		})
+	}
+}
+
+func TestRepair(t *testing.T) {
+
+	var cases = map[string]struct {
+		source  string
+		options []CheckerConfigurationCallbackable
+		want    string
+	}{
+		"numbers": {
+			source: "; header\nG28 ; home\n\nG1  X10\nM117 Hello ; message\n",
+			want:   "; header\nN1 G28*18 ; home\n\nN2 G1 X10*83\nN3 M117 Hello*69 ; message\n",
+		},
+		"renumber": {
+			source: "N5 G28*99\nN9 M110 N3*99\nN1 G1 X10*99\n",
+			want:   "N1 G28*18\nN2 M110 N2*125\nN3 G1 X10*82\n",
+		},
+		"start": {
+			source:  "G28\n",
+			options: []CheckerConfigurationCallbackable{func(config CheckerConfigurer) error { return config.SetStart(10) }},
+			want:    "N10 G28*34\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := Repair(&buffer, strings.NewReader(tc.source), tc.options...); err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			if buffer.String() != tc.want {
+				t.Errorf("got %q, want %q", buffer.String(), tc.want)
+			}
+
+			if r := check(t, buffer.String(), tc.options...); !r.Valid() {
+				t.Errorf("got report %+v of the repaired file, want a valid report", r)
+			}
+		})
+	}
+}
+
+func TestRepair_Hash(t *testing.T) {
+
+	withCRC := func(config CheckerConfigurer) error {
+		return config.SetHash(crc32.NewIEEE())
+	}
+
+	var buffer bytes.Buffer
+	if err := Repair(&buffer, strings.NewReader("G28\nM117 Hello\n"), withCRC); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if r := check(t, buffer.String(), withCRC); !r.Valid() {
+		t.Errorf("got report %+v, want a valid report with the same hash", r)
+	}
+
+	if r := check(t, buffer.String()); r.Valid() {
+		t.Errorf("got a valid report with the default hash, want an invalid one")
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+
+	_, err := New(func(config CheckerConfigurer) error {
+		var h hash.Hash
+		return config.SetHash(h)
+	})
+	if err == nil {
+		t.Errorf("got nil error, want an error for a nil hash")
+	}
+
+	_, err = New(func(config CheckerConfigurer) error {
+		return config.SetHash(sha256.New())
+	})
+	if err == nil {
+		t.Errorf("got nil error, want an error for a hash of more than 4 bytes")
+	}
+}
+
+func TestCheck_Concurrent(t *testing.T) {
+
+	var buffer bytes.Buffer
+	if err := Repair(&buffer, strings.NewReader(strings.Repeat("G28\nG1 X10 Y20\nM117 Hello\n", 20))); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+	source := buffer.String()
+
+	shared := checksum.NewCRC32()
+	options := map[string]CheckerConfigurationCallbackable{
+		"default": func(config CheckerConfigurer) error {
+			return nil
+		},
+		"shared hash": func(config CheckerConfigurer) error {
+			return config.SetHash(shared)
+		},
+		"hash factory": func(config CheckerConfigurer) error {
+			return config.SetHashFactory(func() hash.Hash { return crc32.NewIEEE() })
+		},
+	}
+
+	for name, option := range options {
+		t.Run(name, func(t *testing.T) {
+
+			var repaired bytes.Buffer
+			if err := Repair(&repaired, strings.NewReader(source), option); err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			var wg sync.WaitGroup
+			for worker := 0; worker < 8; worker++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					r, err := Check(strings.NewReader(repaired.String()), option)
+					if err != nil || !r.Valid() {
+						t.Errorf("got report %+v error %v, want a valid report", r, err)
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+
+	t.Run("invalid factory", func(t *testing.T) {
+		_, err := New(func(config CheckerConfigurer) error {
+			return config.SetHashFactory(sha256.New)
+		})
+		if err == nil {
+			t.Errorf("got nil error, want an error for a hash of more than 4 bytes")
+		}
+	})
+}
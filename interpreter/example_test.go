@@ -0,0 +1,33 @@
+package interpreter_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/interpreter"
+)
+
+func ExampleInterpreter() {
+
+	source := "G28\nG1 Z0.2 F600\nG1 X10 Y10 E1\nG92 E0\nG91\nG1 X5 E0.5\n"
+
+	var i interpreter.Interpreter
+
+	scanner := gcodeblock.NewScanner(strings.NewReader(source))
+	for scanner.Scan() {
+		i.Add(scanner.Text(), scanner.Block())
+	}
+	if scanner.Err() != nil {
+		fmt.Printf("failed to scan the gcode: %v", scanner.Err())
+		return
+	}
+
+	s := i.State()
+	fmt.Printf("position %v, relative %v, feedrate %g\n", s.Position, s.Relative, s.Feedrate)
+	fmt.Printf("machine %v\n", s.Machine(s.Position))
+
+	// Output:
+	// position [15 10 0.2 0.5], relative true, feedrate 600
+	// machine [15 10 0.2 1.5]
+}
@@ -0,0 +1,295 @@
+// interpreter package tracks the state of a machine through the lines of a gcode file.
+//
+// Each package that follows the moves of a file needs to know where the tool is and how the next coordinates are interpreted.
+// The Interpreter is that single modal state machine, shared by the layers, the estimator, the analysis, the lint and the pipeline.
+// It tracks:
+//
+//   - the absolute and relative positioning modes of the axes and the extruder (G90, G91, M82 and M83)
+//   - the units (G20 and G21), converting the position and the feedrate when they change, like the firmwares do
+//   - the position set by the moves (G0, G1, G2 and G3), the resets of G92 and the homing of G28
+//   - the feedrate of the moves and the active tool (T0, T1...)
+//
+// The positions are in the coordinates of the file, the State converts them to machine coordinates in millimeters with Machine.
+package interpreter
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/gcode"
+)
+
+const (
+	AXIS_X = iota
+	AXIS_Y
+	AXIS_Z
+	AXIS_E
+)
+
+const (
+	// INCH is the length of an inch in millimeters
+	INCH = 25.4
+)
+
+// axes stores the word of each axis, indexed by his AXIS_ constant
+var axes = []byte{'X', 'Y', 'Z', 'E'}
+
+//#region state struct
+
+// State is the state of the machine between two lines.
+type State struct {
+	// Position of X, Y, Z and E in the coordinates and the units of the file
+	Position [4]float64
+
+	// Offset is the difference between the machine coordinates and the coordinates of the file, set by G92 and cleared by G28
+	Offset [4]float64
+
+	// Relative and RelativeE are the positioning modes of the axes and of the extruder
+	Relative  bool
+	RelativeE bool
+
+	// Inches is true after a G20, the coordinates are in inches
+	Inches bool
+
+	// Feedrate is the last F of a move in units per minute, 0 if the file hasn't set it yet
+	Feedrate float64
+
+	// Tool is the active extruder
+	Tool int
+
+	// Homed stores which of X, Y and Z have been homed
+	Homed [3]bool
+}
+
+// Target returns the position, in the coordinates of the file, where a move ends.
+func (s State) Target(b block.Blocker) [4]float64 {
+
+	target := s.Position
+
+	for axis, word := range axes {
+		v, ok := Parameter(b, word)
+		if !ok {
+			continue
+		}
+
+		if (axis == AXIS_E && s.RelativeE) || (axis != AXIS_E && s.Relative) {
+			target[axis] += v
+		} else {
+			target[axis] = v
+		}
+	}
+
+	return target
+}
+
+// Extrudes returns true if the block is a move that pushes filament.
+func (s State) Extrudes(b block.Blocker) bool {
+	return IsMove(b) && s.Target(b)[AXIS_E] > s.Position[AXIS_E]
+}
+
+// Scale returns the length in millimeters of a unit of the file.
+func (s State) Scale() float64 {
+	if s.Inches {
+		return INCH
+	}
+	return 1
+}
+
+// Machine converts a position in the coordinates of the file, like Position or the result of Target,
+// to machine coordinates in millimeters.
+func (s State) Machine(position [4]float64) [4]float64 {
+
+	scale := s.Scale()
+
+	for axis := range position {
+		position[axis] = (position[axis] + s.Offset[axis]) * scale
+	}
+
+	return position
+}
+
+//#endregion
+//#region interpreter struct
+
+// Interpreter receives the lines of a gcode file one by one and updates the state of the machine.
+//
+// The zero value is an Interpreter ready to receive the first line, with the tool at the origin
+// and the home position at the origin. New configures a different home position.
+type Interpreter struct {
+	state State
+
+	// home is the position of the tool after homing, in millimeters
+	home [3]float64
+}
+
+// State returns the state after the lines added until now.
+func (i *Interpreter) State() State {
+	return i.state
+}
+
+// Add receives the next line of the gcode file and updates the state with it.
+//
+// b is the block parsed from the line, it can be nil if the line doesn't contain gcode or it couldn't be parsed.
+func (i *Interpreter) Add(line string, b block.Blocker) {
+
+	if b = Block(b); b == nil {
+		return
+	}
+
+	s := &i.state
+
+	command := b.Command()
+	number, ok := gcode.NumericAddress(command)
+	if !ok {
+		return
+	}
+
+	switch command.Word() {
+	case 'G':
+		switch number {
+		case 0, 1, 2, 3:
+			if f, ok := Parameter(b, 'F'); ok && f > 0 {
+				s.Feedrate = f
+			}
+			s.Position = s.Target(b)
+
+		case 20:
+			i.units(true)
+		case 21:
+			i.units(false)
+
+		case 28:
+			all := !Has(b, 'X') && !Has(b, 'Y') && !Has(b, 'Z')
+			for axis, word := range axes[:AXIS_E] {
+				if all || Has(b, word) {
+					s.Position[axis] = i.home[axis] / s.Scale()
+					s.Offset[axis] = 0
+					s.Homed[axis] = true
+				}
+			}
+
+		case 90:
+			s.Relative, s.RelativeE = false, false
+		case 91:
+			s.Relative, s.RelativeE = true, true
+
+		case 92:
+			// the machine doesn't move, the coordinates of the file are shifted
+			for axis, word := range axes {
+				if v, ok := Parameter(b, word); ok {
+					s.Offset[axis] += s.Position[axis] - v
+					s.Position[axis] = v
+				}
+			}
+		}
+
+	case 'M':
+		switch number {
+		case 82:
+			s.RelativeE = false
+		case 83:
+			s.RelativeE = true
+		}
+
+	case 'T':
+		s.Tool = int(number)
+	}
+}
+
+// units changes the units of the file, converting the position and the feedrate so the machine stays where it is
+func (i *Interpreter) units(inches bool) {
+
+	s := &i.state
+	if s.Inches == inches {
+		return
+	}
+
+	factor := INCH
+	if inches {
+		factor = 1 / INCH
+	}
+
+	for axis := range s.Position {
+		s.Position[axis] *= factor
+		s.Offset[axis] *= factor
+	}
+	s.Feedrate *= factor
+	s.Inches = inches
+}
+
+//#endregion
+//#region constructor
+
+// New returns an Interpreter ready to receive the first line of a gcode file, with the tool at the home position.
+func New(options ...InterpreterConfigurationCallbackable) (*Interpreter, error) {
+
+	interpreter := &Interpreter{}
+
+	configurator := &interpreterConfigurator{interpreter: interpreter}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	for axis, v := range interpreter.home {
+		interpreter.state.Position[axis] = v
+	}
+
+	return interpreter, nil
+}
+
+//#endregion
+//#region package functions
+
+// Block returns the block to execute, or a nil block.Blocker if there isn't any.
+//
+// The Scanner returns a nil *gcodeblock.GcodeBlock for the lines without gcode, and stored in a block.Blocker it isn't nil,
+// so each consumer of the blocks converts them with Block once, before reading the command. The blocks without command are nil too.
+func Block(b block.Blocker) block.Blocker {
+
+	if b == nil {
+		return nil
+	}
+
+	if v := reflect.ValueOf(b); v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+
+	if b.Command() == nil {
+		return nil
+	}
+
+	return b
+}
+
+// Parameter returns the numeric address of the first parameter of a block with the given word.
+func Parameter(b block.Blocker, word byte) (float64, bool) {
+	for _, p := range b.Parameters() {
+		if p.Word() == word {
+			return gcode.NumericAddress(p)
+		}
+	}
+	return 0, false
+}
+
+// Has returns true if the block has a parameter with the given word.
+func Has(b block.Blocker, word byte) bool {
+	for _, p := range b.Parameters() {
+		if p.Word() == word {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMove returns true if the block is a linear move or an arc, G0, G1, G2 or G3.
+func IsMove(b block.Blocker) bool {
+	number, ok := gcode.NumericAddress(b.Command())
+	return ok && b.Command().Word() == 'G' && (number == 0 || number == 1 || number == 2 || number == 3)
+}
+
+//#endregion
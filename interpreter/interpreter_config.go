@@ -0,0 +1,37 @@
+// This file defines an interpreterConfigurator as an object that implement the InterpreterConfigurer
+// interface to allow the caller to configure a new Interpreter.
+package interpreter
+
+import (
+	"fmt"
+	"math"
+)
+
+// InterpreterConfigurer contains the configurable options that define the machine of an Interpreter.
+type InterpreterConfigurer interface {
+	// Set the position of the tool after homing
+	SetHome(x float64, y float64, z float64) error
+}
+
+// InterpreterConfigurationCallbackable is the signature of the callbacks that the New constructor waiting receives to configure the new Interpreter instance.
+type InterpreterConfigurationCallbackable func(config InterpreterConfigurer) error
+
+// interpreterConfigurator satisfy InterpreterConfigurer, it applies each option directly over the Interpreter in construction.
+type interpreterConfigurator struct {
+	interpreter *Interpreter
+}
+
+// SetHome sets the position of the tool after homing, in millimeters. The tool is at the home position before the first G28 too.
+// By default it's the origin.
+func (ic *interpreterConfigurator) SetHome(x float64, y float64, z float64) error {
+
+	for _, v := range []float64{x, y, z} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("failed set home, the position must be finite but got %g,%g,%g", x, y, z)
+		}
+	}
+
+	ic.interpreter.home = [3]float64{x, y, z}
+
+	return nil
+}
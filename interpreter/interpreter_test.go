@@ -0,0 +1,234 @@
+package interpreter
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+)
+
+// run adds each line of the source to the interpreter and returns it
+func run(t *testing.T, i *Interpreter, source string) *Interpreter {
+	t.Helper()
+
+	for _, line := range strings.Split(source, "\n") {
+		var b block.Blocker
+		if gcodeblock.HasGcode(line) {
+			parsed, err := gcodeblock.Parse(line)
+			if err != nil {
+				t.Fatalf("got error %v parsing '%s', want nil error", err, line)
+			}
+			b = parsed
+		}
+		i.Add(line, b)
+	}
+
+	return i
+}
+
+// near returns true if both positions are the same, except rounding errors
+func near(a [4]float64, b [4]float64) bool {
+	for axis := range a {
+		if math.Abs(a[axis]-b[axis]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestInterpreter_Add(t *testing.T) {
+
+	var cases = map[string]struct {
+		source   string
+		position [4]float64
+		machine  [4]float64
+		want     func(s State) bool
+	}{
+		"absolute": {
+			source:   "G90\nM82\nG1 X10 Y20 Z0.2 E1 F1800\nG0 X5",
+			position: [4]float64{5, 20, 0.2, 1},
+			machine:  [4]float64{5, 20, 0.2, 1},
+			want:     func(s State) bool { return s.Feedrate == 1800 && !s.Relative && !s.RelativeE },
+		},
+		"relative": {
+			source:   "G91\nG1 X10 Y20 E1\nG1 X10 E1",
+			position: [4]float64{20, 20, 0, 2},
+			machine:  [4]float64{20, 20, 0, 2},
+			want:     func(s State) bool { return s.Relative && s.RelativeE },
+		},
+		"relative extrusion": {
+			source:   "G90\nM83\nG1 X10 E1\nG1 X20 E1",
+			position: [4]float64{20, 0, 0, 2},
+			machine:  [4]float64{20, 0, 0, 2},
+			want:     func(s State) bool { return !s.Relative && s.RelativeE },
+		},
+		"reset": {
+			source:   "G1 X10 E5\nG92 X0 E0\nG1 X5 E1",
+			position: [4]float64{5, 0, 0, 1},
+			machine:  [4]float64{15, 0, 0, 6},
+			want:     func(s State) bool { return s.Offset == [4]float64{10, 0, 0, 5} },
+		},
+		"homing clears the offsets": {
+			source:   "G1 X10 Y10 Z5\nG92 X0 Y0 Z0\nG28 X0 Z0",
+			position: [4]float64{0, 0, 0, 0},
+			machine:  [4]float64{0, 10, 0, 0},
+			want:     func(s State) bool { return s.Homed == [3]bool{true, false, true} },
+		},
+		"homing all": {
+			source:   "G1 X10 Y10 Z5\nG28",
+			position: [4]float64{0, 0, 0, 0},
+			machine:  [4]float64{0, 0, 0, 0},
+			want:     func(s State) bool { return s.Homed == [3]bool{true, true, true} },
+		},
+		"inches": {
+			source:   "G20\nG1 X1 F10",
+			position: [4]float64{1, 0, 0, 0},
+			machine:  [4]float64{25.4, 0, 0, 0},
+			want:     func(s State) bool { return s.Inches && s.Feedrate == 10 && s.Scale() == INCH },
+		},
+		"units change": {
+			source:   "G21\nG1 X25.4 F254\nG20\nG91\nG1 X1",
+			position: [4]float64{2, 0, 0, 0},
+			machine:  [4]float64{50.8, 0, 0, 0},
+			want:     func(s State) bool { return math.Abs(s.Feedrate-10) < 1e-9 },
+		},
+		"feedrate of moves only": {
+			source:   "G1 F1200\nG1 X10 F0\nG4 S1",
+			position: [4]float64{10, 0, 0, 0},
+			machine:  [4]float64{10, 0, 0, 0},
+			want:     func(s State) bool { return s.Feedrate == 1200 },
+		},
+		"tool": {
+			source:   "T1\nG1 X10",
+			position: [4]float64{10, 0, 0, 0},
+			machine:  [4]float64{10, 0, 0, 0},
+			want:     func(s State) bool { return s.Tool == 1 },
+		},
+		"arcs": {
+			source:   "G1 X10 Y0\nG2 X20 Y0 I5 J0 E1",
+			position: [4]float64{20, 0, 0, 1},
+			machine:  [4]float64{20, 0, 0, 1},
+			want:     func(s State) bool { return true },
+		},
+		"comments and unknown commands": {
+			source:   "; G1 X10\nM117\nG1 X5 ; G1 X20",
+			position: [4]float64{5, 0, 0, 0},
+			machine:  [4]float64{5, 0, 0, 0},
+			want:     func(s State) bool { return true },
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			s := run(t, &Interpreter{}, tc.source).State()
+
+			if !near(s.Position, tc.position) {
+				t.Errorf("got position %v, want %v", s.Position, tc.position)
+			}
+
+			if got := s.Machine(s.Position); !near(got, tc.machine) {
+				t.Errorf("got machine position %v, want %v", got, tc.machine)
+			}
+
+			if !tc.want(s) {
+				t.Errorf("got state %+v, want it to match the case", s)
+			}
+		})
+	}
+}
+
+func TestInterpreter_NilBlock(t *testing.T) {
+
+	var i Interpreter
+
+	// the Scanner returns a nil *gcodeblock.GcodeBlock for the lines without gcode,
+	// a caller can pass it with any text, even one with gcode
+	var b *gcodeblock.GcodeBlock
+	i.Add("; G1 X10", b)
+	i.Add("G1 X10", b)
+	i.Add("", nil)
+
+	if i.State() != (State{}) {
+		t.Errorf("got state %+v, want the initial state", i.State())
+	}
+}
+
+func TestBlock(t *testing.T) {
+
+	var typed *gcodeblock.GcodeBlock
+
+	if Block(nil) != nil || Block(typed) != nil {
+		t.Errorf("got a block from a nil block, want a nil block.Blocker")
+	}
+
+	b, err := gcodeblock.Parse("G1 X10")
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+	if Block(b) == nil {
+		t.Errorf("got nil, want the block")
+	}
+}
+
+func TestState_Extrudes(t *testing.T) {
+
+	var cases = map[string]struct {
+		source string
+		line   string
+		want   bool
+	}{
+		"extrusion":            {source: "M82\nG1 E1", line: "G1 X10 E2", want: true},
+		"retraction":           {source: "M82\nG1 E1", line: "G1 E0", want: false},
+		"relative extrusion":   {source: "M83\nG1 E1", line: "G1 X10 E0.5", want: true},
+		"relative retraction":  {source: "M83\nG1 E1", line: "G1 E-0.5", want: false},
+		"travel":               {source: "G1 E1", line: "G0 X10", want: false},
+		"not a move":           {source: "G1 E1", line: "G92 E5", want: false},
+		"absolute same length": {source: "G1 E1", line: "G1 X10 E1", want: false},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			s := run(t, &Interpreter{}, tc.source).State()
+
+			b, err := gcodeblock.Parse(tc.line)
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			if got := s.Extrudes(b); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+
+	i, err := New(func(config InterpreterConfigurer) error {
+		return config.SetHome(0, 210, 5)
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if i.State().Position != [4]float64{0, 210, 5, 0} {
+		t.Errorf("got position %v before homing, want the home position", i.State().Position)
+	}
+
+	s := run(t, i, "G1 X10 Y10 Z10\nG20\nG28 Y0").State()
+
+	if want := [4]float64{10 / INCH, 210 / INCH, 10 / INCH, 0}; !near(s.Position, want) {
+		t.Errorf("got position %v, want %v", s.Position, want)
+	}
+
+	_, err = New(func(config InterpreterConfigurer) error {
+		return config.SetHome(math.NaN(), 0, 0)
+	})
+	if err == nil {
+		t.Errorf("got nil error, want error")
+	}
+}
@@ -0,0 +1,57 @@
+package layer_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/layer"
+)
+
+func ExampleSplit() {
+
+	program := strings.Split(`G28
+;LAYER_CHANGE
+;Z:0.2
+G1 X10 Y10 E1
+;LAYER_CHANGE
+;Z:0.4
+G1 Z0.4
+G1 X20 Y10 E1
+M84`, "\n")
+
+	for _, l := range layer.Split(program) {
+		fmt.Printf("layer %d at Z%.1f (+%.1f): lines %d to %d\n", l.Index, l.Z, l.Height, l.Start, l.End-1)
+	}
+
+	// Output:
+	// layer 0 at Z0.2 (+0.2): lines 1 to 3
+	// layer 1 at Z0.4 (+0.2): lines 4 to 8
+}
+
+func ExampleSegmenter() {
+
+	// a file without markers, the layers are detected from the moves
+	source := "G28\nG1 Z0.2\nG1 X10 Y10 E1\n\nG1 Z0.4\nG1 X20 Y10 E2\nG1 Z10\n"
+
+	s := layer.New()
+
+	scanner := gcodeblock.NewScanner(strings.NewReader(source))
+	for scanner.Scan() {
+		if l, ok := s.Add(scanner.Text(), scanner.Block()); ok {
+			fmt.Printf("layer %d at Z%.1f: lines %d to %d\n", l.Index, l.Z, l.Start, l.End-1)
+		}
+	}
+	if scanner.Err() != nil {
+		fmt.Printf("failed to scan the gcode: %v", scanner.Err())
+		return
+	}
+
+	if l, ok := s.Close(); ok {
+		fmt.Printf("layer %d at Z%.1f: lines %d to %d\n", l.Index, l.Z, l.Start, l.End-1)
+	}
+
+	// Output:
+	// layer 0 at Z0.2: lines 1 to 3
+	// layer 1 at Z0.4: lines 4 to 6
+}
@@ -0,0 +1,304 @@
+// layer package groups the lines of a gcode file into the layers of the print.
+//
+// The slicers mark the beginning of each layer with a comment, and the Segmenter uses them when they are present:
+//
+//	;LAYER:5                 Cura and ideaMaker
+//	;LAYER_CHANGE            PrusaSlicer, SuperSlicer, Orca Slicer and Bambu Studio, followed by ;Z:0.4
+//	; layer 5, Z = 1.200     Simplify3D
+//
+// If the file doesn't have any marker, the layers are detected from the moves: a new layer begins when the tool extrudes while moving at a new Z height.
+// The Segmenter follows the state of the machine with an interpreter.Interpreter to know the Z height and if a move extrudes.
+//
+// The layer in progress when the first marker is found is discarded if it was detected from the moves,
+// because it is usually the purge line of the start gcode. The layers already returned aren't affected.
+//
+// The Segmenter works in streaming mode, receiving one line at a time, and the Split function works with a program already in memory.
+package layer
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/interpreter"
+)
+
+const (
+	// Z_TOLERANCE is the maximum difference between two Z heights to consider them the same
+	Z_TOLERANCE = 1e-6
+)
+
+// layerRegex matches the markers that begin a layer, the second form captures the Z height too
+var layerRegex = regexp.MustCompile(`^(?:LAYER:-?\d+|LAYER_CHANGE|(?i:layer)\s+\d+,\s*Z\s*=\s*(-?[\d.]+))$`)
+
+// zRegex matches the marker that declares the Z height of the layer
+var zRegex = regexp.MustCompile(`^Z:(-?[\d.]+)$`)
+
+//#region layer struct
+
+// Layer is a group of consecutive lines printed at the same Z height.
+type Layer struct {
+	// Index is the position of the layer in the print, starting at 0
+	Index int
+
+	// Z is the height of the layer
+	Z float64
+
+	// Height is the difference between the Z of the layer and the Z of the previous one, or the Z itself for the first layer
+	Height float64
+
+	// Start is the index of the first line of the layer and End is the index of the line after the last one.
+	// The indexes start at 0 and count every line added, including empty and comment-only lines.
+	Start int
+	End   int
+}
+
+//#endregion
+//#region segmenter struct
+
+// Segmenter receives the lines of a gcode file one by one and returns each layer as soon as it ends.
+//
+// The lines before the first layer, like the start gcode, don't belong to any layer.
+// The lines after the last layer, like the end gcode, belong to the last one.
+type Segmenter struct {
+	// lines counts the lines added
+	lines int
+
+	// markers is true once a layer marker is found, from then on the moves don't begin layers
+	markers bool
+
+	// current is the layer in progress, it's nil before the first layer
+	current *Layer
+
+	// next is the index of the next layer to begin
+	next int
+
+	// zKnown is true when the Z of the current layer is already known
+	zKnown bool
+
+	// previousZ is the Z of the last layer returned
+	previousZ float64
+
+	// machine tracks the state of the machine
+	machine interpreter.Interpreter
+
+	// zLine is the index of the line of the last move that changed the Z height
+	zLine int
+}
+
+// Add receives the next line of the gcode file and returns the previous layer if this line begins a new one.
+//
+// b is the block parsed from the line, it can be nil if the line doesn't contain gcode or it couldn't be parsed.
+// The markers are read from the comment of the line, so comment-only lines must be added too.
+func (s *Segmenter) Add(line string, b block.Blocker) (Layer, bool) {
+
+	index := s.lines
+	s.lines++
+
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		comment := strings.TrimSpace(line[i+1:])
+
+		if match := layerRegex.FindStringSubmatch(comment); match != nil {
+			s.firstMarker()
+			done, ok := s.begin(index)
+			if match[1] != "" {
+				s.current.Z, _ = strconv.ParseFloat(match[1], 64)
+				s.zKnown = true
+			}
+			return done, ok
+		}
+
+		if match := zRegex.FindStringSubmatch(comment); match != nil {
+			z, _ := strconv.ParseFloat(match[1], 64)
+
+			// a Z marker alone begins a layer too
+			if !s.markers || s.current == nil || s.zKnown {
+				s.firstMarker()
+				done, ok := s.begin(index)
+				s.current.Z = z
+				s.zKnown = true
+				return done, ok
+			}
+
+			s.current.Z = z
+			s.zKnown = true
+			return Layer{}, false
+		}
+	}
+
+	if b = interpreter.Block(b); b == nil {
+		return Layer{}, false
+	}
+
+	if !s.move(index, line, b) {
+		return Layer{}, false
+	}
+
+	// the block extrudes
+	if s.markers {
+		if s.current != nil && !s.zKnown {
+			s.current.Z = s.z()
+			s.zKnown = true
+		}
+		return Layer{}, false
+	}
+
+	z := s.z()
+	if s.current != nil && math.Abs(s.current.Z-z) <= Z_TOLERANCE {
+		return Layer{}, false
+	}
+
+	start := s.zLine
+	if s.current != nil && start <= s.current.Start {
+		start = index
+	}
+
+	done, ok := s.begin(start)
+	s.current.Z = z
+	s.zKnown = true
+	return done, ok
+}
+
+// Close ends the last layer and returns it, if there is any.
+func (s *Segmenter) Close() (Layer, bool) {
+
+	if s.current == nil {
+		return Layer{}, false
+	}
+
+	return s.end(s.lines), true
+}
+
+// Current returns the layer in progress, ended at the last line added. It returns false before the first layer.
+func (s *Segmenter) Current() (Layer, bool) {
+
+	if s.current == nil {
+		return Layer{}, false
+	}
+
+	current := *s.current
+	current.End = s.lines
+	if !s.zKnown {
+		current.Z = s.z()
+	}
+	current.Height = current.Z - s.previousZ
+
+	return current, true
+}
+
+// begin ends the current layer at the line index and begins a new one there
+func (s *Segmenter) begin(index int) (Layer, bool) {
+
+	var done Layer
+	ok := s.current != nil
+
+	if ok {
+		done = s.end(index)
+	}
+
+	s.current = &Layer{Index: s.next, Start: index}
+	s.next++
+	s.zKnown = false
+
+	return done, ok
+}
+
+// firstMarker switches to the markers mode when the first marker is found.
+// The layer in progress detected from the moves is discarded, it is usually the purge line of the start gcode.
+func (s *Segmenter) firstMarker() {
+
+	if s.markers {
+		return
+	}
+
+	s.markers = true
+
+	if s.current != nil {
+		s.current = nil
+		s.next--
+	}
+}
+
+// end returns the current layer ended at the line index
+func (s *Segmenter) end(index int) Layer {
+
+	done := *s.current
+	done.End = index
+
+	if !s.zKnown {
+		done.Z = s.z()
+	}
+	done.Height = done.Z - s.previousZ
+	s.previousZ = done.Z
+
+	return done
+}
+
+// move updates the state of the machine with a block and returns true if the block extrudes
+func (s *Segmenter) move(index int, line string, b block.Blocker) bool {
+
+	before := s.machine.State()
+	s.machine.Add(line, b)
+
+	if !interpreter.IsMove(b) {
+		return false
+	}
+
+	if math.Abs(s.z()-before.Position[interpreter.AXIS_Z]) > Z_TOLERANCE {
+		s.zLine = index
+	}
+
+	// an unretraction doesn't move the tool, it isn't printing yet
+	return before.Extrudes(b) && (interpreter.Has(b, 'X') || interpreter.Has(b, 'Y'))
+}
+
+// z returns the Z height of the tool
+func (s *Segmenter) z() float64 {
+	return s.machine.State().Position[interpreter.AXIS_Z]
+}
+
+//#endregion
+//#region constructor
+
+// New returns a Segmenter ready to receive the first line of a gcode file.
+func New() *Segmenter {
+	return &Segmenter{}
+}
+
+//#endregion
+//#region package functions
+
+// Split returns the layers of a program in memory, where each element of lines is a line of the gcode file.
+//
+// Each line is parsed with gcodeblock.Parse. The lines that can't be parsed, like a M117 with an unquoted message,
+// are taken as lines without gcode.
+func Split(lines []string, options ...block.BlockParserConfigurationCallbackable) []Layer {
+
+	var layers []Layer
+
+	s := New()
+
+	for _, line := range lines {
+		var b block.Blocker
+		if gcodeblock.HasGcode(line) {
+			if parsed, err := gcodeblock.Parse(line, options...); err == nil {
+				b = parsed
+			}
+		}
+
+		if layer, ok := s.Add(line, b); ok {
+			layers = append(layers, layer)
+		}
+	}
+
+	if layer, ok := s.Close(); ok {
+		layers = append(layers, layer)
+	}
+
+	return layers
+}
+
+//#endregion
@@ -0,0 +1,225 @@
+package layer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+)
+
+func lines(source string) []string {
+	return strings.Split(strings.TrimSuffix(source, "\n"), "\n")
+}
+
+func TestSplit(t *testing.T) {
+
+	var cases = map[string]struct {
+		source string
+		want   []Layer
+	}{
+		"prusaslicer": {
+			source: `G28
+G1 Z0.2 F720
+G1 X60 E9 F1000
+;LAYER_CHANGE
+;Z:0.2
+;HEIGHT:0.2
+G1 X10 Y10 E1
+;LAYER_CHANGE
+;Z:0.4
+;HEIGHT:0.2
+G1 Z0.4
+G1 X20 Y10 E2
+M104 S0`,
+			want: []Layer{
+				{Index: 0, Z: 0.2, Height: 0.2, Start: 3, End: 7},
+				{Index: 1, Z: 0.4, Height: 0.2, Start: 7, End: 13},
+			},
+		},
+		"cura": {
+			source: `;FLAVOR:Marlin
+;LAYER_COUNT:2
+;LAYER:0
+G0 X10 Y10 Z0.3
+G1 X20 Y10 E1
+;LAYER:1
+G0 X10 Y10 Z0.5
+M117 Layer 2
+G1 X20 Y10 E2
+;TIME_ELAPSED:60`,
+			want: []Layer{
+				{Index: 0, Z: 0.3, Height: 0.3, Start: 2, End: 5},
+				{Index: 1, Z: 0.5, Height: 0.2, Start: 5, End: 10},
+			},
+		},
+		"simplify3d": {
+			source: `G28
+; layer 1, Z = 0.250
+G1 X10 Y10 Z0.25 E1
+; layer 2, Z = 0.500
+G1 X10 Y10 Z0.5 E2`,
+			want: []Layer{
+				{Index: 0, Z: 0.25, Height: 0.25, Start: 1, End: 3},
+				{Index: 1, Z: 0.5, Height: 0.25, Start: 3, End: 5},
+			},
+		},
+		"z moves": {
+			source: `G28
+G90
+M83
+G1 Z5
+G1 Z0.2
+G1 X10 Y10 E1
+G1 E-0.8
+G1 Z0.6
+G1 X50 Y50
+G1 E0.8
+G1 Z0.2
+G1 X60 Y50 E1
+G1 Z0.4
+G1 X10 Y10 E1
+G91
+G1 Z0.2
+G1 X10 E1
+G90
+G1 Z10`,
+			want: []Layer{
+				{Index: 0, Z: 0.2, Height: 0.2, Start: 4, End: 12},
+				{Index: 1, Z: 0.4, Height: 0.2, Start: 12, End: 15},
+				{Index: 2, Z: 0.6, Height: 0.2, Start: 15, End: 19},
+			},
+		},
+		"absolute extrusion": {
+			source: `M82
+G92 E0
+G1 Z0.2
+G1 X10 E1
+G1 Z0.4
+G1 X10 E0.5
+G1 X20 E2`,
+			want: []Layer{
+				{Index: 0, Z: 0.2, Height: 0.2, Start: 2, End: 4},
+				{Index: 1, Z: 0.4, Height: 0.2, Start: 4, End: 7},
+			},
+		},
+		"without layers": {
+			source: "G28\nG1 X10 Y10\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Split(lines(tc.source))
+
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d layers %+v, want %d layers", len(got), got, len(tc.want))
+			}
+
+			for i := range got {
+				// the heights are computed with floats
+				if d := got[i].Height - tc.want[i].Height; d > Z_TOLERANCE || d < -Z_TOLERANCE {
+					t.Errorf("got layer %d height %v, want %v", i, got[i].Height, tc.want[i].Height)
+				}
+				got[i].Height = tc.want[i].Height
+
+				if d := got[i].Z - tc.want[i].Z; d > Z_TOLERANCE || d < -Z_TOLERANCE {
+					t.Errorf("got layer %d Z %v, want %v", i, got[i].Z, tc.want[i].Z)
+				}
+				got[i].Z = tc.want[i].Z
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSegmenter_Scanner(t *testing.T) {
+
+	source := ";LAYER:0\nG1 X10 Y10 Z0.2 E1\n\n;LAYER:1\nG1 X10 Y10 Z0.4 E1\n"
+
+	s := New()
+	var got []Layer
+
+	scanner := gcodeblock.NewScanner(strings.NewReader(source))
+	for scanner.Scan() {
+		// the Scanner returns a nil block for the comment-only and empty lines
+		if layer, ok := s.Add(scanner.Text(), scanner.Block()); ok {
+			got = append(got, layer)
+		}
+	}
+	if scanner.Err() != nil {
+		t.Fatalf("got error %v, want nil error", scanner.Err())
+	}
+
+	if layer, ok := s.Close(); ok {
+		got = append(got, layer)
+	}
+
+	want := []Layer{
+		{Index: 0, Z: 0.2, Height: 0.2, Start: 0, End: 3},
+		{Index: 1, Z: 0.4, Height: 0.2, Start: 3, End: 5},
+	}
+
+	if len(got) != 2 || got[0] != want[0] || got[1].Start != want[1].Start || got[1].End != want[1].End || got[1].Z != want[1].Z {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, ok := New().Close(); ok {
+		t.Errorf("got a layer closing an empty segmenter, want none")
+	}
+}
+
+func TestSegmenter_Current(t *testing.T) {
+
+	s := New()
+
+	if _, ok := s.Current(); ok {
+		t.Errorf("got a current layer before the first one, want none")
+	}
+
+	for i, line := range lines(";LAYER:0\n;Z:0.2\nG1 X10 Y10 E1\n;LAYER:1\n;Z:0.4") {
+		b, _ := gcodeblock.Parse(line)
+		if !gcodeblock.HasGcode(line) {
+			b = nil
+		}
+		s.Add(line, b)
+
+		current, ok := s.Current()
+		if !ok {
+			t.Fatalf("got no current layer after the line %d, want one", i)
+		}
+
+		want := Layer{Index: 0, Z: 0.2, Height: 0.2, Start: 0, End: i + 1}
+		if i >= 3 {
+			want = Layer{Index: 1, Z: 0.4, Height: 0.2, Start: 3, End: i + 1}
+		}
+		// the Z isn't known until the marker ;Z, it's the Z of the moves
+		switch i {
+		case 0:
+			want.Z, want.Height = 0, 0
+		case 3:
+			want.Z, want.Height = 0, -0.2
+		}
+
+		if current != want {
+			t.Errorf("got %+v after the line %d, want %+v", current, i, want)
+		}
+	}
+}
+
+func TestSegmenter_NilBlock(t *testing.T) {
+
+	s := New()
+
+	// a nil *gcodeblock.GcodeBlock passed with a line of gcode is a line without block
+	var b *gcodeblock.GcodeBlock
+	if _, ok := s.Add("G1 X10 E1", b); ok {
+		t.Errorf("got a layer, want none")
+	}
+	if _, ok := s.Current(); ok {
+		t.Errorf("got a layer in progress, want none")
+	}
+}
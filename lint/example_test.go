@@ -0,0 +1,35 @@
+package lint_test
+
+import (
+	"os"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/lint"
+	"github.com/mauroalderete/gcode-core/machine"
+)
+
+func ExampleLint() {
+
+	gcode := `G1 X10 Y10 F3000
+M104 S210
+G28
+G1 X20 E1
+M109 S210
+G1 X30 E2
+M9999
+`
+
+	diagnostics, err := lint.Lint(strings.NewReader(gcode), func(config lint.LinterConfigurer) error {
+		return config.SetFirmware(machine.FIRMWARE_MARLIN)
+	})
+	if err != nil {
+		return
+	}
+
+	lint.WriteText(os.Stdout, "part.gcode", diagnostics)
+
+	// Output:
+	// part.gcode:1: warning: move of X, Y before homing with G28 (move-before-homing)
+	// part.gcode:4: error: extrusion before the hotend reaches 210°C, wait it with M109 (cold-extrusion)
+	// part.gcode:7: warning: M9999 isn't a command of marlin (unknown-command)
+}
@@ -0,0 +1,309 @@
+// lint package checks a gcode file with a set of rules and reports the problems found as diagnostics.
+//
+// A Linter tracks the state of the machine line by line: the position, the positioning modes, the homed axes,
+// the feedrate, the temperature of the hotend and the spindle or laser. Each Rule receives each block
+// together with the state before the block runs, and returns the diagnostics that it finds:
+//
+//	G1 X10 Y10 F3000     move-before-homing: the move uses X and Y before homing them with G28
+//	M104 S210
+//	G1 X20 E1            cold-extrusion: the extrusion doesn't wait the hotend to reach 210°C
+//
+// The diagnostics are written as text, one per line, or as JSON.
+package lint
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/gcode"
+	"github.com/mauroalderete/gcode-core/interpreter"
+	"github.com/mauroalderete/gcode-core/machine"
+)
+
+// Severity is the importance of a diagnostic.
+type Severity int
+
+const (
+	SEVERITY_INFO Severity = iota
+	SEVERITY_WARNING
+	SEVERITY_ERROR
+)
+
+// severities stores the names of the severities
+var severities = map[Severity]string{
+	SEVERITY_INFO:    "info",
+	SEVERITY_WARNING: "warning",
+	SEVERITY_ERROR:   "error",
+}
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	if name, ok := severities[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// MarshalText encodes the severity with his name, so it's a string in JSON.
+func (s Severity) MarshalText() ([]byte, error) {
+	if _, ok := severities[s]; !ok {
+		return nil, fmt.Errorf("failed to marshal severity %d, it's unknown", int(s))
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes the severity from his name.
+func (s *Severity) UnmarshalText(text []byte) error {
+	for severity, name := range severities {
+		if name == string(text) {
+			*s = severity
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to unmarshal severity '%s', it's unknown", text)
+}
+
+//#region diagnostic struct
+
+// Diagnostic is a problem found by a rule.
+type Diagnostic struct {
+	// Line is the number of the line in the file, starting at 1
+	Line int `json:"line"`
+
+	// Rule is the name of the rule that found the problem
+	Rule string `json:"rule"`
+
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// String returns the diagnostic as a line of text.
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d: %s: %s (%s)", d.Line, d.Severity, d.Message, d.Rule)
+}
+
+//#endregion
+//#region state struct
+
+// State is the state of the machine before a block runs.
+//
+// The position, the positioning modes, the homed axes, the feedrate and the tool are those of the interpreter.State,
+// in the coordinates of the file.
+type State struct {
+	interpreter.State
+
+	// Line is the number of the line of the block, starting at 1, and Text is the line
+	Line int
+	Text string
+
+	// Firmware is the dialect of the file, it's empty if the Linter hasn't a firmware
+	Firmware machine.Firmware
+
+	// HotendTarget is the temperature set to the active hotend and HotendReached is true if the file waited for it
+	HotendTarget  float64
+	HotendReached bool
+
+	// Spindle is true while the spindle or the laser is on
+	Spindle bool
+}
+
+//#endregion
+//#region rule interface
+
+// Rule checks each block of a file.
+//
+// Check receives each block with gcode and the state before the block runs. It returns the diagnostics found,
+// the Linter fills their Line and Rule. A rule can keep his own state between the blocks, so a rule belongs to a single Linter.
+type Rule interface {
+	// Name identifies the rule in the diagnostics
+	Name() string
+
+	// Check returns the problems found in a block
+	Check(b block.Blocker, state State) []Diagnostic
+}
+
+//#endregion
+//#region linter struct
+
+// Linter receives the lines of a gcode file one by one and applies his rules to each one.
+type Linter struct {
+	rules       []Rule
+	state       State
+	diagnostics []Diagnostic
+
+	// machine tracks the state of the machine
+	machine interpreter.Interpreter
+}
+
+// Add receives the next line of the gcode file.
+//
+// b is the block parsed from the line, it can be nil if the line doesn't contain gcode or it couldn't be parsed.
+// The lines without gcode must be added too, to keep the count of the lines.
+func (l *Linter) Add(line string, b block.Blocker) {
+
+	l.state.Line++
+	l.state.Text = line
+
+	if b = interpreter.Block(b); b == nil {
+		return
+	}
+
+	for _, rule := range l.rules {
+		for _, d := range rule.Check(b, l.state) {
+			d.Line = l.state.Line
+			d.Rule = rule.Name()
+			l.diagnostics = append(l.diagnostics, d)
+		}
+	}
+
+	l.apply(line, b)
+}
+
+// Diagnostics returns the diagnostics found until now, sorted by line.
+func (l *Linter) Diagnostics() []Diagnostic {
+	return append([]Diagnostic{}, l.diagnostics...)
+}
+
+// apply updates the state with the effects of a block
+func (l *Linter) apply(line string, b block.Blocker) {
+
+	s := &l.state
+
+	l.machine.Add(line, b)
+	tool := s.Tool
+	s.State = l.machine.State()
+
+	command := b.Command()
+	number, ok := gcode.NumericAddress(command)
+	if !ok {
+		return
+	}
+
+	switch command.Word() {
+	case 'M':
+		switch number {
+		case 3, 4:
+			s.Spindle = true
+		case 5:
+			s.Spindle = false
+		case 104, 109:
+			if tool, ok := interpreter.Parameter(b, 'T'); ok && int(tool) != s.Tool {
+				return
+			}
+			target, ok := interpreter.Parameter(b, 'S')
+			if !ok {
+				target, ok = interpreter.Parameter(b, 'R')
+			}
+			if !ok {
+				return
+			}
+			if number == 109 {
+				s.HotendTarget, s.HotendReached = target, true
+			} else if target != s.HotendTarget {
+				s.HotendTarget, s.HotendReached = target, target == 0
+			}
+		case 116:
+			s.HotendReached = true
+		}
+
+	case 'T':
+		if s.Tool != tool {
+			s.HotendTarget, s.HotendReached = 0, false
+		}
+	}
+}
+
+//#endregion
+//#region constructor
+
+// New returns a Linter ready to receive the first line of a gcode file.
+func New(options ...LinterConfigurationCallbackable) (*Linter, error) {
+
+	linter := &Linter{
+		rules: Rules(),
+	}
+
+	configurator := &linterConfigurator{linter: linter}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	return linter, nil
+}
+
+//#endregion
+//#region package functions
+
+// Lint reads a gcode file and returns the diagnostics found by the rules.
+//
+// Each line is parsed with gcodeblock.Parse. The lines that can't be parsed, like a M117 with an unquoted message,
+// are taken as lines without gcode.
+func Lint(r io.Reader, options ...LinterConfigurationCallbackable) ([]Diagnostic, error) {
+
+	l, err := New(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), gcodeblock.SCANNER_MAX_LINE_SIZE)
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		var b block.Blocker
+		if gcodeblock.HasGcode(line) {
+			if parsed, err := gcodeblock.Parse(line); err == nil {
+				b = parsed
+			}
+		}
+
+		l.Add(line, b)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read gcode: %w", err)
+	}
+
+	return l.Diagnostics(), nil
+}
+
+// WriteText writes the diagnostics as text, one per line, prefixed by the name of the file.
+func WriteText(w io.Writer, name string, diagnostics []Diagnostic) error {
+
+	for _, d := range diagnostics {
+		if _, err := fmt.Fprintf(w, "%s:%s\n", name, d); err != nil {
+			return fmt.Errorf("failed to write diagnostic: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// WriteJSON writes the diagnostics as an indented JSON array.
+func WriteJSON(w io.Writer, diagnostics []Diagnostic) error {
+
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(diagnostics); err != nil {
+		return fmt.Errorf("failed to encode diagnostics: %w", err)
+	}
+
+	return nil
+}
+
+//#endregion
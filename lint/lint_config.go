@@ -0,0 +1,52 @@
+// This file defines a linterConfigurator as an object that implement the LinterConfigurer
+// interface to allow the caller to configure a new Linter.
+package lint
+
+import (
+	"fmt"
+
+	"github.com/mauroalderete/gcode-core/machine"
+)
+
+// LinterConfigurer contains the configurable options that define the rules and the dialect of a Linter.
+type LinterConfigurer interface {
+	// Set the rules applied to each block, replacing the built-in ones
+	SetRules(rules ...Rule) error
+
+	// Set the firmware that defines the dialect of the file
+	SetFirmware(firmware machine.Firmware) error
+}
+
+// LinterConfigurationCallbackable is the signature of the callbacks that the New constructor waiting receives to configure the new Linter instance.
+type LinterConfigurationCallbackable func(config LinterConfigurer) error
+
+// linterConfigurator satisfy LinterConfigurer, it applies each option directly over the Linter in construction.
+type linterConfigurator struct {
+	linter *Linter
+}
+
+// SetRules sets the rules of the Linter. By default are the rules returned by Rules.
+func (lc *linterConfigurator) SetRules(rules ...Rule) error {
+
+	for i, rule := range rules {
+		if rule == nil {
+			return fmt.Errorf("failed set rules, the rule %d is nil", i)
+		}
+	}
+
+	lc.linter.rules = append([]Rule{}, rules...)
+
+	return nil
+}
+
+// SetFirmware sets the firmware of the file. By default is empty and the rules that depend on the dialect are skipped.
+func (lc *linterConfigurator) SetFirmware(firmware machine.Firmware) error {
+
+	if _, ok := dialects[firmware]; !ok {
+		return fmt.Errorf("failed set firmware, '%s' isn't supported", firmware)
+	}
+
+	lc.linter.state.Firmware = firmware
+
+	return nil
+}
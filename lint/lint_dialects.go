@@ -0,0 +1,62 @@
+// This file defines the G and M commands supported by each firmware, used by the unknown command rule.
+package lint
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/machine"
+)
+
+// dialects stores the commands supported by each firmware
+var dialects = map[machine.Firmware]map[string]bool{
+	machine.FIRMWARE_MARLIN: commands(`
+		G0 G1 G2 G3 G4 G5 G6 G10 G11 G12 G17 G18 G19 G20 G21 G26 G27 G28 G29 G30 G31 G32 G33 G34 G35
+		G38.2 G38.3 G38.4 G38.5 G42 G53 G54 G55 G56 G57 G58 G59 G59.1 G59.2 G59.3 G60 G61 G76 G80 G90 G91 G92 G425
+		M0 M1 M3 M4 M5 M7 M8 M9 M10 M11 M16 M17 M18 M20-M34 M42 M43 M48 M73 M75 M76 M77 M78 M80 M81 M82 M83 M84 M85 M86 M87 M92
+		M100 M102 M104 M105 M106 M107 M108 M109 M110 M111 M112 M113 M114 M115 M117 M118 M119 M120 M121 M122 M123 M125 M126 M127 M128 M129
+		M140 M141 M143 M145 M149 M150 M154 M155 M163 M164 M165 M166 M190 M191 M192 M193
+		M200 M201 M203 M204 M205 M206 M207 M208 M209 M211 M217 M218 M220 M221 M226 M240 M250 M255 M256 M260 M261 M280 M281 M282 M290
+		M300 M301 M302 M303 M304 M305 M350 M351 M355 M360-M364 M380 M381 M400-M407 M410 M412 M413 M420 M421 M422 M423 M425 M428 M430 M486 M493
+		M500-M504 M510 M511 M512 M524 M540 M569 M575 M592 M593 M600 M603 M605 M665 M666 M672 M701 M702 M710 M808 M810-M819 M851 M852 M860-M869 M871 M876
+		M900 M906 M907 M908 M909 M910-M919 M928 M951 M993 M994 M995 M997 M999 M7219`),
+
+	machine.FIRMWARE_KLIPPER: commands(`
+		G0 G1 G2 G3 G4 G10 G11 G17 G18 G19 G20 G21 G28 G90 G91 G92
+		M18 M73 M80 M81 M82 M83 M84 M104 M105 M106 M107 M109 M110 M112 M114 M115 M117 M118 M119 M140 M190 M204 M220 M221 M400 M600`),
+
+	machine.FIRMWARE_REPRAPFIRMWARE: commands(`
+		G0 G1 G2 G3 G4 G10 G11 G17 G18 G19 G20 G21 G22 G23 G28 G29 G30 G31 G32 G53 G54 G55 G56 G57 G58 G59 G59.1 G59.2 G59.3 G60 G68 G69 G90 G91 G92 G93 G94
+		M0 M1 M2 M3 M4 M5 M7 M8 M9 M17 M18 M20-M39 M42 M73 M80 M81 M82 M83 M84 M92 M98 M99
+		M101-M112 M114-M122 M140 M141 M143 M144 M150 M190 M191 M200 M201 M203 M204 M205 M206 M207 M208 M220 M221 M226 M232 M260 M261 M280 M290 M291 M292
+		M300-M305 M307 M308 M309 M350 M374 M375 M400 M401 M402 M403 M404 M408 M409 M450 M451 M452 M453 M470 M471 M486
+		M500 M501 M502 M503 M505 M540 M550-M587 M591-M600 M650 M651 M665 M666 M667 M669 M671-M675 M701 M702 M703 M851
+		M900 M905-M918 M929 M950-M957 M997 M998 M999`),
+
+	machine.FIRMWARE_GRBL: commands(`
+		G0 G1 G2 G3 G4 G10 G17 G18 G19 G20 G21 G28 G28.1 G30 G30.1 G38.2 G38.3 G38.4 G38.5 G40 G43.1 G49
+		G53 G54 G55 G56 G57 G58 G59 G61 G80 G90 G91 G91.1 G92 G92.1 G93 G94
+		M0 M1 M2 M3 M4 M5 M7 M8 M9 M30 M56`),
+}
+
+// commands returns the set of the commands of a list separated by spaces, where M20-M34 is a range of commands
+func commands(list string) map[string]bool {
+
+	set := map[string]bool{}
+
+	for _, name := range strings.Fields(list) {
+		from, to, isRange := strings.Cut(name, "-")
+		if !isRange {
+			set[name] = true
+			continue
+		}
+
+		first, _ := strconv.Atoi(from[1:])
+		last, _ := strconv.Atoi(to[1:])
+		for n := first; n <= last; n++ {
+			set[from[:1]+strconv.Itoa(n)] = true
+		}
+	}
+
+	return set
+}
@@ -0,0 +1,221 @@
+// This file defines the built-in rules.
+package lint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/gcode"
+	"github.com/mauroalderete/gcode-core/interpreter"
+	"github.com/mauroalderete/gcode-core/machine"
+)
+
+const (
+	RULE_COLD_EXTRUSION      = "cold-extrusion"
+	RULE_MOVE_BEFORE_HOMING  = "move-before-homing"
+	RULE_SPINDLE_ON_RAPID    = "spindle-on-rapid"
+	RULE_EXTRUSION_IN_TRAVEL = "extrusion-in-travel"
+	RULE_UNKNOWN_COMMAND     = "unknown-command"
+	RULE_FEEDRATE_NOT_SET    = "feedrate-not-set"
+)
+
+//#region package functions
+
+// Rules returns a new instance of each built-in rule.
+func Rules() []Rule {
+	return []Rule{
+		&coldExtrusion{},
+		&moveBeforeHoming{},
+		&spindleOnRapid{},
+		&extrusionInTravel{},
+		&unknownCommand{},
+		&feedrateNotSet{},
+	}
+}
+
+//#endregion
+//#region cold extrusion rule
+
+// coldExtrusion reports the first extrusion before the file waits the hotend to reach his temperature, with M109 or M116.
+// It's reported again after each change of temperature. It's an error, except in Klipper, whose start macros usually heat the hotend
+// out of the file.
+type coldExtrusion struct {
+	reported bool
+}
+
+func (r *coldExtrusion) Name() string {
+	return RULE_COLD_EXTRUSION
+}
+
+func (r *coldExtrusion) Check(b block.Blocker, state State) []Diagnostic {
+
+	if state.HotendReached {
+		r.reported = false
+		return nil
+	}
+
+	if r.reported || !state.Extrudes(b) {
+		return nil
+	}
+	r.reported = true
+
+	message := "extrusion before setting the temperature of the hotend"
+	if state.HotendTarget > 0 {
+		message = fmt.Sprintf("extrusion before the hotend reaches %g°C, wait it with M109", state.HotendTarget)
+	}
+
+	severity := SEVERITY_ERROR
+	if state.Firmware == machine.FIRMWARE_KLIPPER {
+		severity = SEVERITY_WARNING
+	}
+
+	return []Diagnostic{{Severity: severity, Message: message}}
+}
+
+//#endregion
+//#region move before homing rule
+
+// moveBeforeHoming reports the first move of each axis before homing it with G28. Grbl homes with $H, so it's skipped.
+type moveBeforeHoming struct {
+	reported [3]bool
+}
+
+func (r *moveBeforeHoming) Name() string {
+	return RULE_MOVE_BEFORE_HOMING
+}
+
+func (r *moveBeforeHoming) Check(b block.Blocker, state State) []Diagnostic {
+
+	if state.Firmware == machine.FIRMWARE_GRBL || !interpreter.IsMove(b) {
+		return nil
+	}
+
+	axes := []string{}
+	for axis, word := range []byte{'X', 'Y', 'Z'} {
+		if interpreter.Has(b, word) && !state.Homed[axis] && !r.reported[axis] {
+			r.reported[axis] = true
+			axes = append(axes, string(word))
+		}
+	}
+
+	if len(axes) == 0 {
+		return nil
+	}
+
+	return []Diagnostic{{
+		Severity: SEVERITY_WARNING,
+		Message:  fmt.Sprintf("move of %s before homing with G28", strings.Join(axes, ", ")),
+	}}
+}
+
+//#endregion
+//#region spindle on rapid rule
+
+// spindleOnRapid reports each rapid move, G0, while the spindle or the laser is on.
+type spindleOnRapid struct{}
+
+func (r *spindleOnRapid) Name() string {
+	return RULE_SPINDLE_ON_RAPID
+}
+
+func (r *spindleOnRapid) Check(b block.Blocker, state State) []Diagnostic {
+
+	if !state.Spindle || !commandIs(b, 'G', 0) {
+		return nil
+	}
+
+	return []Diagnostic{{Severity: SEVERITY_WARNING, Message: "rapid move with the spindle or the laser on"}}
+}
+
+//#endregion
+//#region extrusion in travel rule
+
+// extrusionInTravel reports each G0 that moves the extruder, G0 is meant for travel moves.
+type extrusionInTravel struct{}
+
+func (r *extrusionInTravel) Name() string {
+	return RULE_EXTRUSION_IN_TRAVEL
+}
+
+func (r *extrusionInTravel) Check(b block.Blocker, state State) []Diagnostic {
+
+	if !commandIs(b, 'G', 0) || state.Target(b)[3] == state.Position[3] {
+		return nil
+	}
+
+	return []Diagnostic{{Severity: SEVERITY_WARNING, Message: "E motion in a travel move G0, use G1"}}
+}
+
+//#endregion
+//#region unknown command rule
+
+// unknownCommand reports each G or M command that the firmware doesn't support. It's skipped if the Linter hasn't a firmware.
+type unknownCommand struct{}
+
+func (r *unknownCommand) Name() string {
+	return RULE_UNKNOWN_COMMAND
+}
+
+func (r *unknownCommand) Check(b block.Blocker, state State) []Diagnostic {
+
+	commands, ok := dialects[state.Firmware]
+	if !ok {
+		return nil
+	}
+
+	word := b.Command().Word()
+	number, ok := gcode.NumericAddress(b.Command())
+	if !ok || (word != 'G' && word != 'M') {
+		return nil
+	}
+
+	name := string(word) + strconv.FormatFloat(number, 'f', -1, 64)
+	if commands[name] {
+		return nil
+	}
+
+	return []Diagnostic{{
+		Severity: SEVERITY_WARNING,
+		Message:  fmt.Sprintf("%s isn't a command of %s", name, state.Firmware),
+	}}
+}
+
+//#endregion
+//#region feedrate not set rule
+
+// feedrateNotSet reports the first move without a feedrate, when the file hasn't set one yet.
+// The rapid moves of Grbl don't use the feedrate, so they are skipped.
+type feedrateNotSet struct {
+	reported bool
+}
+
+func (r *feedrateNotSet) Name() string {
+	return RULE_FEEDRATE_NOT_SET
+}
+
+func (r *feedrateNotSet) Check(b block.Blocker, state State) []Diagnostic {
+
+	if r.reported || state.Feedrate != 0 || !interpreter.IsMove(b) || interpreter.Has(b, 'F') {
+		return nil
+	}
+
+	if state.Firmware == machine.FIRMWARE_GRBL && commandIs(b, 'G', 0) {
+		return nil
+	}
+	r.reported = true
+
+	return []Diagnostic{{Severity: SEVERITY_WARNING, Message: "move without a feedrate, the firmware uses his default"}}
+}
+
+//#endregion
+//#region private functions
+
+// commandIs returns true if the command of the block has the given word and number
+func commandIs(b block.Blocker, word byte, number float64) bool {
+	n, ok := gcode.NumericAddress(b.Command())
+	return ok && b.Command().Word() == word && n == number
+}
+
+//#endregion
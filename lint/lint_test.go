@@ -0,0 +1,281 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/machine"
+)
+
+type finding struct {
+	line int
+	rule string
+}
+
+func lint(t *testing.T, source string, options ...LinterConfigurationCallbackable) []finding {
+	t.Helper()
+
+	diagnostics, err := Lint(strings.NewReader(source), options...)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	findings := []finding{}
+	for _, d := range diagnostics {
+		findings = append(findings, finding{line: d.Line, rule: d.Rule})
+	}
+
+	return findings
+}
+
+func withFirmware(firmware machine.Firmware) LinterConfigurationCallbackable {
+	return func(config LinterConfigurer) error {
+		return config.SetFirmware(firmware)
+	}
+}
+
+func TestLint_Rules(t *testing.T) {
+
+	var cases = map[string]struct {
+		source   string
+		firmware machine.Firmware
+		want     []finding
+	}{
+		"clean": {
+			source: `G28
+M104 S210
+M109 S210
+G1 Z0.3 F600
+G1 X10 E1
+M104 S0
+`,
+			want: []finding{},
+		},
+		"cold extrusion": {
+			source: `G28
+G1 X10 E1 F1200
+G1 X20 E2
+M104 S210
+G1 X30 E3
+M109 S210
+G1 X40 E4
+M104 S220
+G1 X50 E5
+M116
+G1 X60 E6
+`,
+			want: []finding{{2, RULE_COLD_EXTRUSION}, {9, RULE_COLD_EXTRUSION}},
+		},
+		"retraction isn't an extrusion": {
+			source: `G28
+G1 E-1 F1200
+`,
+			want: []finding{},
+		},
+		"relative extrusion": {
+			source: `G28
+M83
+G1 X10 E1 F1200
+`,
+			want: []finding{{3, RULE_COLD_EXTRUSION}},
+		},
+		"tool change": {
+			source: `G28
+M109 S210
+T1
+G1 X10 E1 F1200
+`,
+			want: []finding{{4, RULE_COLD_EXTRUSION}},
+		},
+		"move before homing": {
+			source: `G1 X10 Y10 F3000
+G1 X20
+G28 X Y
+G1 Z5
+G28
+G1 X1 Y1 Z1
+`,
+			want: []finding{{1, RULE_MOVE_BEFORE_HOMING}, {4, RULE_MOVE_BEFORE_HOMING}},
+		},
+		"grbl doesn't home with G28": {
+			source: `G0 X10 Y10
+`,
+			firmware: machine.FIRMWARE_GRBL,
+			want:     []finding{},
+		},
+		"spindle on rapid": {
+			source: `M3 S1000
+G1 X10 F300
+G0 X0
+M5
+G0 X20
+`,
+			firmware: machine.FIRMWARE_GRBL,
+			want:     []finding{{3, RULE_SPINDLE_ON_RAPID}},
+		},
+		"extrusion in travel": {
+			source: `G28
+M109 S210
+G0 X10 E-1 F9000
+G0 X20
+`,
+			want: []finding{{3, RULE_EXTRUSION_IN_TRAVEL}},
+		},
+		"unknown command": {
+			source: `G28
+M104 S200
+M117 Printing
+M9999
+G38.2 Z-5 F100
+G38.9 Z-5
+`,
+			firmware: machine.FIRMWARE_MARLIN,
+			want:     []finding{{4, RULE_UNKNOWN_COMMAND}, {6, RULE_UNKNOWN_COMMAND}},
+		},
+		"unknown command of klipper": {
+			source: `G28
+M600
+M355 S1
+`,
+			firmware: machine.FIRMWARE_KLIPPER,
+			want:     []finding{{3, RULE_UNKNOWN_COMMAND}},
+		},
+		"feedrate not set": {
+			source: `G28
+G1 X10
+G1 X20
+`,
+			want: []finding{{2, RULE_FEEDRATE_NOT_SET}},
+		},
+		"feedrate in the first move": {
+			source: `G28
+G1 X10 F3000
+G1 X20
+`,
+			want: []finding{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			options := []LinterConfigurationCallbackable{}
+			if tc.firmware != "" {
+				options = append(options, withFirmware(tc.firmware))
+			}
+
+			got := lint(t, tc.source, options...)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// m600Rule reports each M600 as an info
+type m600Rule struct{}
+
+func (r *m600Rule) Name() string {
+	return "filament-change"
+}
+
+func (r *m600Rule) Check(b block.Blocker, state State) []Diagnostic {
+	if !commandIs(b, 'M', 600) {
+		return nil
+	}
+	return []Diagnostic{{Severity: SEVERITY_INFO, Message: "filament change"}}
+}
+
+func TestLint_ColdExtrusionSeverity(t *testing.T) {
+
+	var cases = map[machine.Firmware]Severity{
+		"":                       SEVERITY_ERROR,
+		machine.FIRMWARE_MARLIN:  SEVERITY_ERROR,
+		machine.FIRMWARE_KLIPPER: SEVERITY_WARNING,
+	}
+
+	for firmware, want := range cases {
+		t.Run(string(firmware), func(t *testing.T) {
+
+			diagnostics, err := Lint(strings.NewReader("G28\nG1 X10 E1 F1200\n"), func(config LinterConfigurer) error {
+				if firmware == "" {
+					return nil
+				}
+				return config.SetFirmware(firmware)
+			})
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			if len(diagnostics) != 1 || diagnostics[0].Rule != RULE_COLD_EXTRUSION || diagnostics[0].Severity != want {
+				t.Errorf("got %v, want a cold extrusion %s", diagnostics, want)
+			}
+		})
+	}
+}
+
+func TestLint_CustomRules(t *testing.T) {
+
+	got := lint(t, "G1 X10\nM600\n", func(config LinterConfigurer) error {
+		return config.SetRules(&m600Rule{})
+	})
+
+	want := []finding{{2, "filament-change"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+
+	if _, err := New(withFirmware("sailfish")); err == nil {
+		t.Errorf("got nil error, want an error for an unknown firmware")
+	}
+
+	if _, err := New(func(config LinterConfigurer) error { return config.SetRules(nil) }); err == nil {
+		t.Errorf("got nil error, want an error for a nil rule")
+	}
+}
+
+func TestWrite(t *testing.T) {
+
+	diagnostics := []Diagnostic{
+		{Line: 2, Rule: RULE_COLD_EXTRUSION, Severity: SEVERITY_ERROR, Message: "cold"},
+		{Line: 5, Rule: RULE_FEEDRATE_NOT_SET, Severity: SEVERITY_WARNING, Message: "feedrate"},
+	}
+
+	var text bytes.Buffer
+	if err := WriteText(&text, "part.gcode", diagnostics); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	want := "part.gcode:2: error: cold (cold-extrusion)\npart.gcode:5: warning: feedrate (feedrate-not-set)\n"
+	if text.String() != want {
+		t.Errorf("got text %q, want %q", text.String(), want)
+	}
+
+	var encoded bytes.Buffer
+	if err := WriteJSON(&encoded, diagnostics); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if !strings.Contains(encoded.String(), `"severity": "error"`) {
+		t.Errorf("got json %s, want the severity as a string", encoded.String())
+	}
+
+	decoded := []Diagnostic{}
+	if err := json.Unmarshal(encoded.Bytes(), &decoded); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+	if !reflect.DeepEqual(decoded, diagnostics) {
+		t.Errorf("got %v, want %v", decoded, diagnostics)
+	}
+
+	encoded.Reset()
+	if err := WriteJSON(&encoded, nil); err != nil || strings.TrimSpace(encoded.String()) != "[]" {
+		t.Errorf("got json %q and error %v, want an empty array", encoded.String(), err)
+	}
+}
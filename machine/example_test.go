@@ -0,0 +1,59 @@
+package machine_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/analysis"
+	"github.com/mauroalderete/gcode-core/machine"
+)
+
+func ExampleLoad() {
+
+	source := `{
+  "name": "ender",
+  "firmware": "marlin",
+  "kinematics": "cartesian",
+  "volume": {"min": [0, 0, 0], "max": [220, 220, 250]},
+  "home": [0, 0, 0],
+  "max_feedrate": [500, 500, 5, 25],
+  "max_acceleration": [500, 500, 100, 5000],
+  "acceleration": 500,
+  "jerk": [8, 8, 0.4, 5],
+  "extruders": 1,
+  "filament_diameter": 1.75
+}`
+
+	profile, err := machine.Load(strings.NewReader(source))
+	if err != nil {
+		fmt.Printf("failed to load: %v", err)
+		return
+	}
+
+	_, violation, err := analysis.Measure(strings.NewReader("G28\nG1 X230 Y10\n"), profile.ToolpathOption())
+	if err != nil {
+		fmt.Printf("failed to measure: %v", err)
+		return
+	}
+
+	fmt.Println(violation)
+
+	// Output:
+	// line 1 'G1 X230 Y10': position X230.000 Y10.000 Z0.000 is out of the volume of the machine
+}
+
+func ExampleBuiltin() {
+
+	profile, err := machine.Builtin(machine.PROFILE_KLIPPER_COREXY)
+	if err != nil {
+		fmt.Printf("failed to get profile: %v", err)
+		return
+	}
+
+	fmt.Println(machine.Builtins())
+	fmt.Printf("%s %s, home at %v\n", profile.Firmware, profile.Kinematics, profile.Home)
+
+	// Output:
+	// [grbl-router klipper-corexy marlin-cartesian prusa-mk3]
+	// klipper corexy, home at [300 300 0]
+}
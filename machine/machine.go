@@ -0,0 +1,352 @@
+// machine package defines the MachineProfile that describes a machine: his volume, his kinematic limits,
+// his firmware and his extruders.
+//
+// The profiles are stored as JSON:
+//
+//	{
+//	  "name": "my printer",
+//	  "firmware": "marlin",
+//	  "kinematics": "cartesian",
+//	  "volume": {"min": [0, 0, 0], "max": [220, 220, 250]},
+//	  ...
+//	}
+//
+// A MachineProfile converts itself into the options that the other packages receive, like the envelope of analysis.Toolpath
+// or the limits of estimator.Estimator, so a single profile configures all of them.
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/mauroalderete/gcode-core/analysis"
+	"github.com/mauroalderete/gcode-core/checksum"
+	"github.com/mauroalderete/gcode-core/estimator"
+)
+
+// Firmware is the firmware that runs in the machine, it defines the dialect of gcode that it accepts.
+type Firmware string
+
+const (
+	FIRMWARE_MARLIN         Firmware = "marlin"
+	FIRMWARE_KLIPPER        Firmware = "klipper"
+	FIRMWARE_REPRAPFIRMWARE Firmware = "reprapfirmware"
+	FIRMWARE_GRBL           Firmware = "grbl"
+)
+
+// Kinematics is the mechanical arrangement of the axes of the machine.
+type Kinematics string
+
+const (
+	KINEMATICS_CARTESIAN Kinematics = "cartesian"
+	KINEMATICS_COREXY    Kinematics = "corexy"
+	KINEMATICS_DELTA     Kinematics = "delta"
+)
+
+//#region profile struct
+
+// Volume is the reachable volume of the machine in machine coordinates, in mm.
+type Volume struct {
+	Min [3]float64 `json:"min"`
+	Max [3]float64 `json:"max"`
+}
+
+// Zone is a named region where the tool mustn't enter, like the clips that hold the bed.
+type Zone struct {
+	Name string     `json:"name"`
+	Min  [3]float64 `json:"min"`
+	Max  [3]float64 `json:"max"`
+}
+
+// MachineProfile describes a machine.
+//
+// The arrays of the limits are indexed by estimator.AXIS_X, AXIS_Y, AXIS_Z and AXIS_E.
+// The speeds are in mm/s and the accelerations in mm/s². The limits of the extruder are ignored by the machines without extruders.
+type MachineProfile struct {
+	// Name identifies the profile
+	Name string `json:"name"`
+
+	// Firmware and Kinematics of the machine
+	Firmware   Firmware   `json:"firmware"`
+	Kinematics Kinematics `json:"kinematics"`
+
+	// Checksum is the algorithm of the checksums of the lines, the default of the firmware if it's empty
+	Checksum checksum.Algorithm `json:"checksum,omitempty"`
+
+	// Volume is the reachable volume, Home is the position after homing and ForbiddenZones are the regions where the tool mustn't enter
+	Volume         Volume     `json:"volume"`
+	Home           [3]float64 `json:"home"`
+	ForbiddenZones []Zone     `json:"forbidden_zones,omitempty"`
+
+	// MaxFeedrate and MaxAcceleration are the limits of each axis
+	MaxFeedrate     [4]float64 `json:"max_feedrate"`
+	MaxAcceleration [4]float64 `json:"max_acceleration"`
+
+	// Acceleration is used by the moves that work, RetractAcceleration by the moves of the extruder alone
+	// and TravelAcceleration by the other moves
+	Acceleration        float64 `json:"acceleration"`
+	RetractAcceleration float64 `json:"retract_acceleration,omitempty"`
+	TravelAcceleration  float64 `json:"travel_acceleration,omitempty"`
+
+	// Jerk is used by the firmwares with classic jerk, JunctionDeviation by Marlin and Grbl when it's positive
+	// and SquareCornerVelocity by Klipper
+	Jerk                 [4]float64 `json:"jerk,omitempty"`
+	JunctionDeviation    float64    `json:"junction_deviation,omitempty"`
+	SquareCornerVelocity float64    `json:"square_corner_velocity,omitempty"`
+
+	// Extruders is the number of extruders and FilamentDiameter the diameter of the filament that they use, in mm
+	Extruders        int     `json:"extruders"`
+	FilamentDiameter float64 `json:"filament_diameter,omitempty"`
+
+	// MaxHotendTemperature and MaxBedTemperature are the maximum temperatures of the heaters, in °C. 0 means that there isn't a heater
+	MaxHotendTemperature float64 `json:"max_hotend_temperature,omitempty"`
+	MaxBedTemperature    float64 `json:"max_bed_temperature,omitempty"`
+}
+
+// Validate returns an error if the profile is inconsistent, like a home position out of the volume or a limit that isn't positive.
+// The values of the junction model that Limits selects must be positive too.
+func (p *MachineProfile) Validate() error {
+
+	if p.Name == "" {
+		return fmt.Errorf("profile without name")
+	}
+
+	switch p.Firmware {
+	case FIRMWARE_MARLIN, FIRMWARE_KLIPPER, FIRMWARE_REPRAPFIRMWARE, FIRMWARE_GRBL:
+	default:
+		return fmt.Errorf("firmware '%s' isn't supported", p.Firmware)
+	}
+
+	switch p.Kinematics {
+	case KINEMATICS_CARTESIAN, KINEMATICS_COREXY, KINEMATICS_DELTA:
+	default:
+		return fmt.Errorf("kinematics '%s' isn't supported", p.Kinematics)
+	}
+
+	if p.Checksum != "" {
+		if _, err := checksum.NewAlgorithm(p.Checksum); err != nil {
+			return err
+		}
+	}
+
+	envelope := p.Envelope()
+	if envelope.Volume.Empty() {
+		return fmt.Errorf("the volume is empty")
+	}
+	if !envelope.Volume.Contains(p.Home) {
+		return fmt.Errorf("the home position is out of the volume")
+	}
+	for _, zone := range envelope.Forbidden {
+		if zone.Empty() {
+			return fmt.Errorf("the forbidden zone '%s' is empty", zone.Name)
+		}
+	}
+
+	axes := estimator.AXIS_Z
+	if p.Extruders > 0 {
+		axes = estimator.AXIS_E
+	}
+	for axis := estimator.AXIS_X; axis <= axes; axis++ {
+		if p.MaxFeedrate[axis] <= 0 || p.MaxAcceleration[axis] <= 0 {
+			return fmt.Errorf("the maximum feedrate and acceleration of each axis must be positive")
+		}
+	}
+
+	if p.Acceleration <= 0 || p.RetractAcceleration < 0 || p.TravelAcceleration < 0 {
+		return fmt.Errorf("the acceleration must be positive")
+	}
+
+	if p.JunctionDeviation < 0 {
+		return fmt.Errorf("the junction deviation mustn't be negative")
+	}
+
+	// the junction model that Limits selects must have his values
+	switch p.Limits().Model {
+	case estimator.MODEL_SQUARE_CORNER_VELOCITY:
+		if p.SquareCornerVelocity <= 0 {
+			return fmt.Errorf("the square corner velocity must be positive on %s", p.Firmware)
+		}
+	case estimator.MODEL_JERK:
+		for axis := estimator.AXIS_X; axis <= axes; axis++ {
+			if p.Jerk[axis] <= 0 {
+				return fmt.Errorf("the jerk of each axis must be positive on %s without junction deviation", p.Firmware)
+			}
+		}
+	}
+
+	if p.Extruders < 0 {
+		return fmt.Errorf("the number of extruders mustn't be negative")
+	}
+	if p.Extruders > 0 && p.FilamentDiameter <= 0 {
+		return fmt.Errorf("the filament diameter must be positive")
+	}
+
+	if p.MaxHotendTemperature < 0 || p.MaxBedTemperature < 0 {
+		return fmt.Errorf("the maximum temperatures mustn't be negative")
+	}
+
+	return nil
+}
+
+// Envelope returns the envelope of the machine to validate the moves with an analysis.Toolpath.
+func (p *MachineProfile) Envelope() analysis.Envelope {
+
+	envelope := analysis.Envelope{
+		Volume: analysis.Box{Min: p.Volume.Min, Max: p.Volume.Max},
+		Home:   p.Home,
+	}
+
+	for _, zone := range p.ForbiddenZones {
+		envelope.Forbidden = append(envelope.Forbidden, analysis.Zone{
+			Name: zone.Name,
+			Box:  analysis.Box{Min: zone.Min, Max: zone.Max},
+		})
+	}
+
+	return envelope
+}
+
+// Limits returns the kinematic limits of the machine for an estimator.Estimator.
+//
+// The junction model is the square corner velocity on Klipper, the junction deviation on Marlin and Grbl when it's positive,
+// and the classic jerk in the other cases. The accelerations that aren't set use Acceleration,
+// and the machines without extruders use the limits of X for the extruder.
+func (p *MachineProfile) Limits() estimator.Limits {
+
+	limits := estimator.Limits{
+		Model:                estimator.MODEL_JERK,
+		MaxFeedrate:          p.MaxFeedrate,
+		MaxAcceleration:      p.MaxAcceleration,
+		Acceleration:         p.Acceleration,
+		RetractAcceleration:  p.RetractAcceleration,
+		TravelAcceleration:   p.TravelAcceleration,
+		Jerk:                 p.Jerk,
+		JunctionDeviation:    p.JunctionDeviation,
+		SquareCornerVelocity: p.SquareCornerVelocity,
+	}
+
+	switch {
+	case p.Firmware == FIRMWARE_KLIPPER:
+		limits.Model = estimator.MODEL_SQUARE_CORNER_VELOCITY
+	case (p.Firmware == FIRMWARE_MARLIN || p.Firmware == FIRMWARE_GRBL) && p.JunctionDeviation > 0:
+		limits.Model = estimator.MODEL_JUNCTION_DEVIATION
+	}
+
+	if limits.RetractAcceleration == 0 {
+		limits.RetractAcceleration = p.Acceleration
+	}
+	if limits.TravelAcceleration == 0 {
+		limits.TravelAcceleration = p.Acceleration
+	}
+
+	if p.Extruders == 0 {
+		limits.MaxFeedrate[estimator.AXIS_E] = p.MaxFeedrate[estimator.AXIS_X]
+		limits.MaxAcceleration[estimator.AXIS_E] = p.MaxAcceleration[estimator.AXIS_X]
+	}
+
+	return limits
+}
+
+// ChecksumAlgorithm returns the checksum algorithm of the machine.
+//
+// If the profile doesn't set an algorithm, it's the CRC-16 CCITT on RepRapFirmware and the XOR checksum on the other firmwares.
+func (p *MachineProfile) ChecksumAlgorithm() checksum.Algorithm {
+
+	if p.Checksum != "" {
+		return p.Checksum
+	}
+
+	if p.Firmware == FIRMWARE_REPRAPFIRMWARE {
+		return checksum.ALGORITHM_CRC16_CCITT
+	}
+
+	return checksum.ALGORITHM_XOR
+}
+
+// Hash returns a new hash of the checksum algorithm of the machine, to compute the checksums of the lines sent to it.
+//
+// It returns an error if the algorithm isn't supported, Validate and Load reject those profiles too.
+func (p *MachineProfile) Hash() (hash.Hash, error) {
+
+	h, err := checksum.NewAlgorithm(p.ChecksumAlgorithm())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the hash of the profile '%s': %w", p.Name, err)
+	}
+
+	return h, nil
+}
+
+// Filament returns the filament used by the extruders for an analysis.Analyzer, with the density of DEFAULT_FILAMENT.
+func (p *MachineProfile) Filament() analysis.Filament {
+
+	filament := analysis.DEFAULT_FILAMENT
+	if p.FilamentDiameter > 0 {
+		filament.Diameter = p.FilamentDiameter
+	}
+
+	return filament
+}
+
+// EstimatorOption returns the option that configures an estimator.Estimator with the limits of the machine.
+func (p *MachineProfile) EstimatorOption() estimator.EstimatorConfigurationCallbackable {
+	limits := p.Limits()
+	return func(config estimator.EstimatorConfigurer) error {
+		return config.SetLimits(limits)
+	}
+}
+
+// AnalyzerOption returns the option that configures an analysis.Analyzer with the filament of the machine.
+func (p *MachineProfile) AnalyzerOption() analysis.AnalyzerConfigurationCallbackable {
+	filament := p.Filament()
+	return func(config analysis.AnalyzerConfigurer) error {
+		return config.SetFilaments(filament)
+	}
+}
+
+// ToolpathOption returns the option that configures an analysis.Toolpath with the envelope of the machine.
+func (p *MachineProfile) ToolpathOption() analysis.ToolpathConfigurationCallbackable {
+	envelope := p.Envelope()
+	return func(config analysis.ToolpathConfigurer) error {
+		return config.SetEnvelope(envelope)
+	}
+}
+
+// Save writes the profile as indented JSON.
+func (p *MachineProfile) Save(w io.Writer) error {
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(p); err != nil {
+		return fmt.Errorf("failed to encode profile: %w", err)
+	}
+
+	return nil
+}
+
+//#endregion
+//#region package functions
+
+// Load reads a profile from JSON and validates it.
+//
+// The unknown fields are rejected, to detect the misspelled ones.
+func Load(r io.Reader) (*MachineProfile, error) {
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	profile := &MachineProfile{}
+	if err := decoder.Decode(profile); err != nil {
+		return nil, fmt.Errorf("failed to decode profile: %w", err)
+	}
+
+	if err := profile.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to validate profile: %w", err)
+	}
+
+	return profile, nil
+}
+
+//#endregion
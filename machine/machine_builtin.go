@@ -0,0 +1,127 @@
+// This file defines the built-in profiles of some common machines.
+package machine
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	PROFILE_MARLIN_CARTESIAN = "marlin-cartesian"
+	PROFILE_PRUSA_MK3        = "prusa-mk3"
+	PROFILE_KLIPPER_COREXY   = "klipper-corexy"
+	PROFILE_GRBL_ROUTER      = "grbl-router"
+)
+
+// builtins stores the constructors of the built-in profiles by name
+var builtins = map[string]func() *MachineProfile{
+	PROFILE_MARLIN_CARTESIAN: MarlinCartesian,
+	PROFILE_PRUSA_MK3:        PrusaMK3,
+	PROFILE_KLIPPER_COREXY:   KlipperCoreXY,
+	PROFILE_GRBL_ROUTER:      GrblRouter,
+}
+
+//#region package functions
+
+// Builtin returns a new copy of the built-in profile with the name, so the caller can modify it.
+func Builtin(name string) (*MachineProfile, error) {
+
+	constructor, ok := builtins[name]
+	if !ok {
+		return nil, fmt.Errorf("failed to get builtin profile, '%s' doesn't exist", name)
+	}
+
+	return constructor(), nil
+}
+
+// Builtins returns the names of the built-in profiles, sorted.
+func Builtins() []string {
+
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// MarlinCartesian returns a generic cartesian printer of 220x220x250 mm with Marlin 2 and his default limits.
+func MarlinCartesian() *MachineProfile {
+	return &MachineProfile{
+		Name:                 PROFILE_MARLIN_CARTESIAN,
+		Firmware:             FIRMWARE_MARLIN,
+		Kinematics:           KINEMATICS_CARTESIAN,
+		Volume:               Volume{Max: [3]float64{220, 220, 250}},
+		MaxFeedrate:          [4]float64{300, 300, 5, 25},
+		MaxAcceleration:      [4]float64{3000, 3000, 100, 10000},
+		Acceleration:         3000,
+		RetractAcceleration:  3000,
+		TravelAcceleration:   3000,
+		Jerk:                 [4]float64{10, 10, 0.3, 5},
+		JunctionDeviation:    0.013,
+		Extruders:            1,
+		FilamentDiameter:     1.75,
+		MaxHotendTemperature: 275,
+		MaxBedTemperature:    125,
+	}
+}
+
+// PrusaMK3 returns a printer like the Original Prusa i3 MK3, with the classic jerk and the Y axis that homes 4 mm out of the bed.
+func PrusaMK3() *MachineProfile {
+	return &MachineProfile{
+		Name:                 PROFILE_PRUSA_MK3,
+		Firmware:             FIRMWARE_MARLIN,
+		Kinematics:           KINEMATICS_CARTESIAN,
+		Volume:               Volume{Min: [3]float64{0, -4, 0}, Max: [3]float64{250, 210, 210}},
+		Home:                 [3]float64{0, -4, 0},
+		MaxFeedrate:          [4]float64{200, 200, 12, 120},
+		MaxAcceleration:      [4]float64{1000, 1000, 200, 5000},
+		Acceleration:         1250,
+		RetractAcceleration:  1250,
+		TravelAcceleration:   1250,
+		Jerk:                 [4]float64{8, 8, 0.4, 1.5},
+		Extruders:            1,
+		FilamentDiameter:     1.75,
+		MaxHotendTemperature: 300,
+		MaxBedTemperature:    120,
+	}
+}
+
+// KlipperCoreXY returns a CoreXY printer of 300x300x300 mm with Klipper, that homes X and Y at the maximum.
+func KlipperCoreXY() *MachineProfile {
+	return &MachineProfile{
+		Name:                 PROFILE_KLIPPER_COREXY,
+		Firmware:             FIRMWARE_KLIPPER,
+		Kinematics:           KINEMATICS_COREXY,
+		Volume:               Volume{Max: [3]float64{300, 300, 300}},
+		Home:                 [3]float64{300, 300, 0},
+		MaxFeedrate:          [4]float64{500, 500, 15, 50},
+		MaxAcceleration:      [4]float64{5000, 5000, 350, 5000},
+		Acceleration:         5000,
+		RetractAcceleration:  5000,
+		TravelAcceleration:   5000,
+		SquareCornerVelocity: 5,
+		Extruders:            1,
+		FilamentDiameter:     1.75,
+		MaxHotendTemperature: 300,
+		MaxBedTemperature:    120,
+	}
+}
+
+// GrblRouter returns a router of 800x800x100 mm with Grbl, that homes at the maximum of each axis
+// and so works with negative machine coordinates. It hasn't extruders nor heaters.
+func GrblRouter() *MachineProfile {
+	return &MachineProfile{
+		Name:              PROFILE_GRBL_ROUTER,
+		Firmware:          FIRMWARE_GRBL,
+		Kinematics:        KINEMATICS_CARTESIAN,
+		Volume:            Volume{Min: [3]float64{-800, -800, -100}},
+		MaxFeedrate:       [4]float64{83.333, 83.333, 16.667, 0},
+		MaxAcceleration:   [4]float64{500, 500, 200, 0},
+		Acceleration:      500,
+		JunctionDeviation: 0.01,
+	}
+}
+
+//#endregion
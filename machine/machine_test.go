@@ -0,0 +1,226 @@
+package machine
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mauroalderete/gcode-core/analysis"
+	"github.com/mauroalderete/gcode-core/checksum"
+	"github.com/mauroalderete/gcode-core/estimator"
+)
+
+func TestBuiltins(t *testing.T) {
+
+	names := Builtins()
+	if len(names) != 4 {
+		t.Fatalf("got %d builtins, want 4", len(names))
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			p, err := Builtin(name)
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+			if p.Name != name {
+				t.Errorf("got name %s, want %s", p.Name, name)
+			}
+			if err := p.Validate(); err != nil {
+				t.Errorf("got validation error %v, want nil error", err)
+			}
+
+			if _, err := estimator.New(p.EstimatorOption()); err != nil {
+				t.Errorf("got estimator error %v, want nil error", err)
+			}
+			if _, err := analysis.NewToolpath(p.ToolpathOption()); err != nil {
+				t.Errorf("got toolpath error %v, want nil error", err)
+			}
+			if _, err := analysis.New(p.AnalyzerOption()); err != nil {
+				t.Errorf("got analyzer error %v, want nil error", err)
+			}
+		})
+	}
+}
+
+func TestBuiltin_Copy(t *testing.T) {
+
+	p, _ := Builtin(PROFILE_PRUSA_MK3)
+	p.Volume.Max[0] = 0
+
+	q, _ := Builtin(PROFILE_PRUSA_MK3)
+	if q.Volume.Max[0] != 250 {
+		t.Errorf("got a builtin modified by the caller, want a new copy")
+	}
+
+	if _, err := Builtin("unknown"); err == nil {
+		t.Errorf("got nil error, want an error for an unknown profile")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+
+	for _, name := range Builtins() {
+		t.Run(name, func(t *testing.T) {
+			p, _ := Builtin(name)
+			p.ForbiddenZones = []Zone{{Name: "clip", Min: [3]float64{-10, -10, -10}, Max: [3]float64{-5, -5, -5}}}
+			if name != PROFILE_GRBL_ROUTER {
+				p.ForbiddenZones[0].Min, p.ForbiddenZones[0].Max = [3]float64{10, 10, 0}, [3]float64{20, 20, 5}
+			}
+
+			var buffer bytes.Buffer
+			if err := p.Save(&buffer); err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			q, err := Load(&buffer)
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+			if !reflect.DeepEqual(p, q) {
+				t.Errorf("got %+v, want %+v", q, p)
+			}
+		})
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+
+	var cases = map[string]string{
+		"malformed":         `{"name": "x"`,
+		"unknown field":     `{"name": "x", "bed": 220}`,
+		"without name":      `{"firmware": "marlin"}`,
+		"unknown firmware":  `{"name": "x", "firmware": "sailfish", "kinematics": "cartesian"}`,
+		"empty volume":      `{"name": "x", "firmware": "marlin", "kinematics": "cartesian", "volume": {"min": [10, 0, 0], "max": [0, 10, 10]}}`,
+		"home out":          `{"name": "x", "firmware": "marlin", "kinematics": "cartesian", "volume": {"max": [10, 10, 10]}, "home": [20, 0, 0]}`,
+		"without feedrates": `{"name": "x", "firmware": "marlin", "kinematics": "cartesian", "volume": {"max": [10, 10, 10]}, "acceleration": 1000}`,
+		"without filament": `{"name": "x", "firmware": "marlin", "kinematics": "cartesian", "volume": {"max": [10, 10, 10]},
+			"max_feedrate": [1, 1, 1, 1], "max_acceleration": [1, 1, 1, 1], "acceleration": 1, "extruders": 1}`,
+	}
+
+	for name, source := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(strings.NewReader(source)); err == nil {
+				t.Errorf("got nil error, want an error")
+			}
+		})
+	}
+}
+
+func TestValidate_JunctionModel(t *testing.T) {
+
+	var cases = map[string]struct {
+		profile func() *MachineProfile
+		change  func(p *MachineProfile)
+		valid   bool
+	}{
+		"jerk without jerk":                    {profile: PrusaMK3, change: func(p *MachineProfile) { p.Jerk[estimator.AXIS_E] = 0 }, valid: false},
+		"square corner velocity without value": {profile: KlipperCoreXY, change: func(p *MachineProfile) { p.SquareCornerVelocity = 0 }, valid: false},
+		"negative junction deviation":          {profile: MarlinCartesian, change: func(p *MachineProfile) { p.JunctionDeviation = -0.01 }, valid: false},
+		"junction deviation without jerk":      {profile: MarlinCartesian, change: func(p *MachineProfile) { p.Jerk = [4]float64{} }, valid: true},
+		"klipper without jerk":                 {profile: KlipperCoreXY, change: func(p *MachineProfile) { p.Jerk = [4]float64{} }, valid: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := tc.profile()
+			tc.change(p)
+
+			if err := p.Validate(); (err == nil) != tc.valid {
+				t.Errorf("got error %v, want valid %v", err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+
+	var cases = map[string]struct {
+		profile func() *MachineProfile
+		model   estimator.Model
+	}{
+		"marlin with junction deviation": {profile: MarlinCartesian, model: estimator.MODEL_JUNCTION_DEVIATION},
+		"marlin with classic jerk":       {profile: PrusaMK3, model: estimator.MODEL_JERK},
+		"klipper":                        {profile: KlipperCoreXY, model: estimator.MODEL_SQUARE_CORNER_VELOCITY},
+		"grbl":                           {profile: GrblRouter, model: estimator.MODEL_JUNCTION_DEVIATION},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			limits := tc.profile().Limits()
+
+			if limits.Model != tc.model {
+				t.Errorf("got model %d, want %d", limits.Model, tc.model)
+			}
+			if limits.MaxFeedrate[estimator.AXIS_E] <= 0 || limits.RetractAcceleration <= 0 || limits.TravelAcceleration <= 0 {
+				t.Errorf("got limits %+v, want all of them positive", limits)
+			}
+		})
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+
+	p := PrusaMK3()
+	p.ForbiddenZones = []Zone{{Name: "clip", Min: [3]float64{100, -4, 0}, Max: [3]float64{110, 5, 2}}}
+
+	_, violation, err := analysis.Measure(strings.NewReader("G28\nG1 X20 Y0 Z1\nG1 X200\n"), p.ToolpathOption())
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+	if violation == nil || violation.Line != 2 {
+		t.Errorf("got violation %v, want a violation at line 2", violation)
+	}
+}
+
+func TestHash(t *testing.T) {
+
+	cases := map[string]struct {
+		firmware  Firmware
+		algorithm checksum.Algorithm
+		size      int
+	}{
+		"marlin":                   {FIRMWARE_MARLIN, "", 1},
+		"reprapfirmware":           {FIRMWARE_REPRAPFIRMWARE, "", 2},
+		"algorithm of the profile": {FIRMWARE_MARLIN, checksum.ALGORITHM_CRC32, 4},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			profile := MarlinCartesian()
+			profile.Firmware = tc.firmware
+			profile.Checksum = tc.algorithm
+
+			if err := profile.Validate(); err != nil {
+				t.Fatalf("got error %v, want nil", err)
+			}
+			h, err := profile.Hash()
+			if err != nil {
+				t.Fatalf("got error %v, want nil", err)
+			}
+			if h.Size() != tc.size {
+				t.Errorf("got a hash of %d bytes, want %d", h.Size(), tc.size)
+			}
+		})
+	}
+
+	t.Run("unknown algorithm", func(t *testing.T) {
+		profile := MarlinCartesian()
+		profile.Checksum = "md5"
+		if err := profile.Validate(); err == nil {
+			t.Errorf("got nil error, want an error for an unknown algorithm")
+		}
+		if h, err := profile.Hash(); err == nil {
+			t.Errorf("got hash %T and nil error, want an error for an unknown algorithm", h)
+		}
+
+		var profileJSON bytes.Buffer
+		if err := profile.Save(&profileJSON); err != nil {
+			t.Fatalf("got error %v, want nil", err)
+		}
+		if _, err := Load(&profileJSON); err == nil {
+			t.Errorf("got nil error, want an error loading an unknown algorithm")
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package meatpack_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/meatpack"
+)
+
+func Example() {
+
+	packed := &bytes.Buffer{}
+
+	e, err := meatpack.NewEncoder(packed, func(config meatpack.EncoderConfigurer) error {
+		return config.SetNoSpaces(true)
+	})
+	if err != nil {
+		fmt.Printf("failed to create the encoder: %v", err)
+		return
+	}
+
+	b, err := gcodeblock.Parse("G1 X2.0 Y2.0 F3000.0")
+	if err != nil {
+		fmt.Printf("failed to parse the block: %v", err)
+		return
+	}
+
+	// the encoder receives the lines exported by the blocks
+	fmt.Fprintln(e, b.ToLine("%c %p"))
+	e.Close()
+
+	size := packed.Len()
+
+	unpacked, err := io.ReadAll(meatpack.NewDecoder(packed))
+	if err != nil {
+		fmt.Printf("failed to decode: %v", err)
+		return
+	}
+
+	fmt.Printf("%d bytes packed\n", size)
+	fmt.Printf("%d bytes unpacked: %s", len(unpacked), unpacked)
+
+	// Output:
+	// 23 bytes packed
+	// 27 bytes unpacked: G1 X2.000 Y2.000 F3000.000
+}
@@ -0,0 +1,73 @@
+// meatpack package implements the MeatPack encoding used by Marlin and Prusa firmwares to compress the gcode streamed by a serial port.
+//
+// MeatPack packs the fifteen most common characters of the gcode into 4-bit nibbles, so two of them are sent in a single byte.
+// Any other character is marked with the special nibble 0b1111 and it is sent as a full byte after the packed byte.
+//
+// The packing is enabled or disabled by a signal sequence of two 0xFF bytes followed by a command byte.
+// When the no-spaces mode is enabled, the space character is replaced by the 'E' character in the lookup table,
+// because the firmware doesn't need the spaces between the words of a gcode line.
+//
+// The Encoder implements io.Writer, it receives gcode lines, for example the output of the block.Blocker ToLine method, and writes them packed.
+// The Decoder implements io.Reader, it reads a packed stream and returns the gcode lines unpacked.
+//
+// For more information visit [MeatPack]
+//
+// [MeatPack]: https://github.com/scottmudge/OctoPrint-MeatPack
+package meatpack
+
+const (
+	// SIGNAL is the byte that, repeated twice, indicates that the next byte is a command
+	SIGNAL byte = 0xFF
+
+	// COMMAND_* are the commands that can follow a signal sequence
+	COMMAND_ENABLE_PACKING    byte = 0xFB
+	COMMAND_DISABLE_PACKING   byte = 0xFA
+	COMMAND_RESET_ALL         byte = 0xF9
+	COMMAND_QUERY_CONFIG      byte = 0xF8
+	COMMAND_ENABLE_NO_SPACES  byte = 0xF7
+	COMMAND_DISABLE_NO_SPACES byte = 0xF6
+
+	// notPacked is the nibble that indicates that the character is sent as a full byte
+	notPacked byte = 0x0F
+
+	// spaceIndex is the index of the lookup table that changes when the no-spaces mode is enabled
+	spaceIndex byte = 11
+)
+
+// lookup is the table of the characters that can be packed, the index of each one is his nibble
+var lookup = [15]byte{'0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '.', ' ', '\n', 'G', 'X'}
+
+//#region private functions
+
+// pack returns the nibble of a character or notPacked if it isn't in the lookup table.
+func pack(c byte, noSpaces bool) byte {
+
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c == '.':
+		return 10
+	case c == ' ' && !noSpaces, c == 'E' && noSpaces:
+		return spaceIndex
+	case c == '\n':
+		return 12
+	case c == 'G':
+		return 13
+	case c == 'X':
+		return 14
+	}
+
+	return notPacked
+}
+
+// unpack returns the character of a nibble. The nibble mustn't be notPacked.
+func unpack(nibble byte, noSpaces bool) byte {
+
+	if nibble == spaceIndex && noSpaces {
+		return 'E'
+	}
+
+	return lookup[nibble]
+}
+
+//#endregion
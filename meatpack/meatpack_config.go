@@ -0,0 +1,34 @@
+// This file defines an encoderConfigurator as an object that implement the EncoderConfigurer
+// interface to allow the caller to configure a new Encoder.
+package meatpack
+
+// EncoderConfigurer contains the configurable options that define how an Encoder packs the gcode.
+type EncoderConfigurer interface {
+	// Set if the no-spaces mode is enabled
+	SetNoSpaces(noSpaces bool) error
+
+	// Set if the comments are kept or removed
+	SetKeepComments(keepComments bool) error
+}
+
+// EncoderConfigurationCallbackable is the signature of the callbacks that the NewEncoder constructor waiting receives to configure the new Encoder instance.
+type EncoderConfigurationCallbackable func(config EncoderConfigurer) error
+
+// encoderConfigurator satisfy EncoderConfigurer, it applies each option directly over the Encoder in construction.
+type encoderConfigurator struct {
+	encoder *Encoder
+}
+
+// SetNoSpaces enables or disables the no-spaces mode. By default is disabled.
+//
+// When it is enabled, the spaces of the lines that begin with a G command are removed, and the 'E' character is packed instead of the space.
+func (ec *encoderConfigurator) SetNoSpaces(noSpaces bool) error {
+	ec.encoder.noSpaces = noSpaces
+	return nil
+}
+
+// SetKeepComments defines if the comments are kept or removed from each line. By default they are removed.
+func (ec *encoderConfigurator) SetKeepComments(keepComments bool) error {
+	ec.encoder.keepComments = keepComments
+	return nil
+}
@@ -0,0 +1,211 @@
+// This file defines the Decoder that unpacks a MeatPack stream.
+package meatpack
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+//#region decoder struct
+
+// Decoder reads a MeatPack stream and returns the gcode unpacked.
+//
+// It follows the same state machine as the Marlin firmware. The signal sequences are interpreted and never returned.
+// While the packing is disabled, the bytes are returned as they are read.
+//
+// When the no-spaces mode is enabled, the Decoder restores a space before each word of the lines that begin with a G command,
+// so the gcode returned can be parsed by the gcodeblock package again.
+type Decoder struct {
+	// source is the packed stream
+	source *bufio.Reader
+
+	// packing indicates if the packing is enabled
+	packing bool
+
+	// noSpaces indicates if the no-spaces mode is enabled
+	noSpaces bool
+
+	// signals counts the consecutive signal bytes read
+	signals int
+
+	// commandNext indicates if the next byte is a command
+	commandNext bool
+
+	// fullChars counts the characters sent as full bytes that are waiting to be read
+	fullChars int
+
+	// buffered stores a character unpacked that must be returned after the next full character
+	buffered byte
+
+	// lineStart indicates if the next character begins a new line
+	lineStart bool
+
+	// gline indicates if the current line begins with a G command
+	gline bool
+
+	// comment indicates if the current line reached a comment
+	comment bool
+
+	// previous is the last character returned
+	previous byte
+
+	// pending stores the characters unpacked that weren't read yet
+	pending []byte
+
+	// err stores the first error found, including io.EOF
+	err error
+}
+
+// Read reads up to len(p) bytes of gcode unpacked into p.
+func (d *Decoder) Read(p []byte) (int, error) {
+
+	for len(d.pending) < len(p) && d.err == nil {
+		c, err := d.source.ReadByte()
+		if err != nil {
+			d.err = err
+			if d.fullChars > 0 || d.signals > 0 || d.commandNext {
+				d.err = fmt.Errorf("meatpack stream ends in the middle of a sequence: %w", io.ErrUnexpectedEOF)
+			}
+			break
+		}
+
+		d.receive(c)
+	}
+
+	if len(d.pending) == 0 {
+		return 0, d.err
+	}
+
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+
+	return n, nil
+}
+
+// receive processes a single byte of the stream.
+func (d *Decoder) receive(c byte) {
+
+	if c == SIGNAL {
+		if d.signals == 1 {
+			d.signals = 0
+			d.commandNext = true
+		} else {
+			d.signals = 1
+		}
+		return
+	}
+
+	if d.commandNext {
+		d.commandNext = false
+		d.command(c)
+		return
+	}
+
+	// a single signal byte is a packed byte with two full characters
+	if d.signals == 1 {
+		d.signals = 0
+		d.unpack(SIGNAL)
+	}
+
+	d.unpack(c)
+}
+
+// command applies a command received after a signal sequence.
+func (d *Decoder) command(c byte) {
+
+	switch c {
+	case COMMAND_ENABLE_PACKING:
+		d.packing = true
+	case COMMAND_DISABLE_PACKING:
+		d.packing = false
+	case COMMAND_ENABLE_NO_SPACES:
+		d.noSpaces = true
+	case COMMAND_DISABLE_NO_SPACES:
+		d.noSpaces = false
+	case COMMAND_RESET_ALL:
+		d.packing = false
+		d.noSpaces = false
+	}
+}
+
+// unpack processes a single byte that isn't part of a signal sequence.
+func (d *Decoder) unpack(c byte) {
+
+	if !d.packing {
+		d.output(c)
+		return
+	}
+
+	if d.fullChars > 0 {
+		d.output(c)
+		if d.buffered != 0 {
+			d.output(d.buffered)
+			d.buffered = 0
+		}
+		d.fullChars--
+		return
+	}
+
+	first, second := c&0x0F, c>>4
+
+	if first == notPacked {
+		d.fullChars++
+		if second == notPacked {
+			d.fullChars++
+		} else {
+			d.buffered = unpack(second, d.noSpaces)
+		}
+		return
+	}
+
+	d.output(unpack(first, d.noSpaces))
+
+	// the character that follows an end of line is a padding
+	if unpack(first, d.noSpaces) == '\n' {
+		return
+	}
+
+	if second == notPacked {
+		d.fullChars++
+		return
+	}
+
+	d.output(unpack(second, d.noSpaces))
+}
+
+// output stores a character unpacked, restoring the spaces removed by the no-spaces mode.
+func (d *Decoder) output(c byte) {
+
+	if d.lineStart {
+		d.gline = c == 'G'
+		d.comment = false
+	}
+
+	if c == ';' {
+		d.comment = true
+	}
+
+	if d.noSpaces && d.gline && !d.comment && !d.lineStart && c >= 'A' && c <= 'Z' && d.previous != ' ' {
+		d.pending = append(d.pending, ' ')
+	}
+
+	d.pending = append(d.pending, c)
+	d.previous = c
+	d.lineStart = c == '\n'
+}
+
+//#endregion
+//#region constructor
+
+// NewDecoder returns a new Decoder that reads the packed stream from r.
+//
+// The packing is disabled until the signal sequence to enable it is read.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		source:    bufio.NewReader(r),
+		lineStart: true,
+	}
+}
+
+//#endregion
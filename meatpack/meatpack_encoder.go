@@ -0,0 +1,201 @@
+// This file defines the Encoder that packs a stream of gcode lines.
+package meatpack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+//#region encoder struct
+
+// Encoder packs the gcode lines written on it and writes the result in the underlying writer.
+//
+// Before the first line, it writes the signal sequence that enables the packing and, if it is configured, the no-spaces mode.
+// The Close method writes the last line, even if it hasn't the end of line character, and the signal sequence that disables the packing.
+//
+// Each line is cleaned before being packed: the carriage returns and the trailing whitespaces are removed,
+// the comments are removed unless they are kept, and the lines that are empty after that are skipped.
+type Encoder struct {
+	// destination is the packed stream
+	destination io.Writer
+
+	// noSpaces indicates if the no-spaces mode is enabled
+	noSpaces bool
+
+	// keepComments indicates if the comments are kept
+	keepComments bool
+
+	// started indicates if the signal sequence that enables the packing was written
+	started bool
+
+	// pending stores the last line received that is incomplete
+	pending bytes.Buffer
+
+	// closed indicates if the Close method was called
+	closed bool
+}
+
+// Write receives gcode lines, packs each complete line and writes it in the underlying writer.
+//
+// The characters after the last end of line are kept until the next call to Write or Close.
+func (e *Encoder) Write(p []byte) (int, error) {
+
+	if e.closed {
+		return 0, fmt.Errorf("failed to write, the encoder is closed")
+	}
+
+	e.pending.Write(p)
+
+	for {
+		i := bytes.IndexByte(e.pending.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+
+		line := string(e.pending.Next(i + 1))
+
+		err := e.writeLine(line[:i])
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close packs the incomplete line pending and writes the signal sequence to disable the packing.
+// It doesn't close the underlying writer.
+func (e *Encoder) Close() error {
+
+	if e.closed {
+		return nil
+	}
+
+	if e.pending.Len() > 0 {
+		err := e.writeLine(e.pending.String())
+		if err != nil {
+			return err
+		}
+		e.pending.Reset()
+	}
+
+	if e.started {
+		_, err := e.destination.Write([]byte{SIGNAL, SIGNAL, COMMAND_DISABLE_PACKING})
+		if err != nil {
+			return fmt.Errorf("failed to write the signal to disable packing: %w", err)
+		}
+	}
+
+	e.closed = true
+
+	return nil
+}
+
+// writeLine cleans a single line, without the end of line character, packs it and writes it.
+func (e *Encoder) writeLine(line string) error {
+
+	line = e.clean(line)
+	if line == "" {
+		return nil
+	}
+
+	// a full byte 0xFF could be confused with a signal sequence
+	if strings.IndexByte(line, SIGNAL) >= 0 {
+		return fmt.Errorf("failed to pack the line '%s', it contains the signal byte %#x", line, SIGNAL)
+	}
+
+	out := make([]byte, 0, len(line)+8)
+
+	if !e.started {
+		out = append(out, SIGNAL, SIGNAL, COMMAND_ENABLE_PACKING)
+		if e.noSpaces {
+			out = append(out, SIGNAL, SIGNAL, COMMAND_ENABLE_NO_SPACES)
+		}
+		e.started = true
+	}
+
+	// an odd line is padded with a packable character, the firmware ignores the character that follows the end of line
+	line += "\n"
+	if len(line)%2 != 0 {
+		line += string(unpack(spaceIndex, e.noSpaces))
+	}
+
+	for i := 0; i < len(line); i += 2 {
+		first := pack(line[i], e.noSpaces)
+		second := pack(line[i+1], e.noSpaces)
+
+		out = append(out, first|second<<4)
+		if first == notPacked {
+			out = append(out, line[i])
+		}
+		if second == notPacked {
+			out = append(out, line[i+1])
+		}
+	}
+
+	_, err := e.destination.Write(out)
+	if err != nil {
+		return fmt.Errorf("failed to write the line '%s' packed: %w", line, err)
+	}
+
+	return nil
+}
+
+// clean removes the parts of the line that aren't required by the firmware.
+func (e *Encoder) clean(line string) string {
+
+	code, comment, found := strings.Cut(strings.TrimRight(line, " \t\r"), ";")
+	if found {
+		comment = ";" + comment
+	}
+
+	code = strings.TrimSpace(code)
+
+	spacesRemoved := false
+	if e.noSpaces && strings.HasPrefix(code, "G") && !strings.Contains(code, "\"") {
+		code = strings.NewReplacer(" ", "", "\t", "").Replace(code)
+		spacesRemoved = true
+	}
+
+	if !e.keepComments || comment == "" {
+		return code
+	}
+
+	if code == "" || spacesRemoved {
+		return code + comment
+	}
+
+	return code + " " + comment
+}
+
+//#endregion
+//#region constructor
+
+// NewEncoder returns a new Encoder that writes the packed stream into w.
+//
+// options are a series of configuration callbacks to allow set different aspects of the packing.
+func NewEncoder(w io.Writer, options ...EncoderConfigurationCallbackable) (*Encoder, error) {
+
+	if w == nil {
+		return nil, fmt.Errorf("failed to create a meatpack encoder, destination mustn't be nil")
+	}
+
+	encoder := &Encoder{
+		destination: w,
+	}
+
+	configurator := &encoderConfigurator{encoder: encoder}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	return encoder, nil
+}
+
+//#endregion
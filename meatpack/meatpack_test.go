@@ -0,0 +1,189 @@
+package meatpack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func encode(t *testing.T, source string, noSpaces bool, keepComments bool) []byte {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+
+	e, err := NewEncoder(out, func(config EncoderConfigurer) error {
+		if err := config.SetNoSpaces(noSpaces); err != nil {
+			return err
+		}
+		return config.SetKeepComments(keepComments)
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if _, err := e.Write([]byte(source)); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	return out.Bytes()
+}
+
+func TestEncoder(t *testing.T) {
+
+	enable := []byte{0xFF, 0xFF, 0xFB}
+	enableNoSpaces := []byte{0xFF, 0xFF, 0xFB, 0xFF, 0xFF, 0xF7}
+	disable := []byte{0xFF, 0xFF, 0xFA}
+
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	var cases = map[string]struct {
+		source   string
+		noSpaces bool
+		packed   []byte
+	}{
+		"both packed": {
+			source: "G1 X10\n",
+			packed: join(enable, []byte{0x1D, 0xEB, 0x01, 0xBC}, disable),
+		},
+		"first full": {
+			source: "M104\n",
+			packed: join(enable, []byte{0x1F, 'M', 0x40, 0xBC}, disable),
+		},
+		"second full": {
+			source: "XM\n",
+			packed: join(enable, []byte{0xFE, 'M', 0xBC}, disable),
+		},
+		"both full": {
+			source: "MM1\n",
+			packed: join(enable, []byte{0xFF, 'M', 'M', 0xC1}, disable),
+		},
+		"no spaces": {
+			source:   "G1 X10 E0.5\n",
+			noSpaces: true,
+			packed:   join(enableNoSpaces, []byte{0x1D, 0x1E, 0xB0, 0xA0, 0xC5}, disable),
+		},
+		"comments and empty lines removed": {
+			source: "; header\n\nT0 ; tool\r\n",
+			packed: join(enable, []byte{0x0F, 'T', 0xBC}, disable),
+		},
+		"line without end of line": {
+			source: "T0",
+			packed: join(enable, []byte{0x0F, 'T', 0xBC}, disable),
+		},
+		"nothing to pack": {
+			source: ";only comments\n",
+			packed: nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := encode(t, tc.source, tc.noSpaces, false)
+			if !bytes.Equal(got, tc.packed) {
+				t.Errorf("got % X, want % X", got, tc.packed)
+			}
+		})
+	}
+}
+
+func TestEncoder_Errors(t *testing.T) {
+
+	t.Run("nil destination", func(t *testing.T) {
+		if _, err := NewEncoder(nil); err == nil {
+			t.Errorf("got nil error, want error")
+		}
+	})
+
+	t.Run("signal byte", func(t *testing.T) {
+		e, _ := NewEncoder(&bytes.Buffer{})
+		if _, err := e.Write([]byte("M117 \xff\n")); err == nil {
+			t.Errorf("got nil error, want error")
+		}
+	})
+
+	t.Run("write after close", func(t *testing.T) {
+		e, _ := NewEncoder(&bytes.Buffer{})
+		e.Close()
+		if _, err := e.Write([]byte("G28\n")); err == nil {
+			t.Errorf("got nil error, want error")
+		}
+	})
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	var cases = []struct {
+		source       string
+		noSpaces     bool
+		keepComments bool
+		want         string
+	}{
+		{
+			source: "G28\nG1 X10.5 Y-3.25 E0.4000 F3000\nM104 S200\n",
+			want:   "G28\nG1 X10.5 Y-3.25 E0.4000 F3000\nM104 S200\n",
+		},
+		{
+			source:   "G28\nG1 X10.5 Y-3.25 E0.4000 F3000\nM104 S200\n",
+			noSpaces: true,
+			want:     "G28\nG1 X10.5 Y-3.25 E0.4000 F3000\nM104 S200\n",
+		},
+		{
+			source:       ";LAYER:1\nG1 X1 Y2 ; move\nM117 \"Hello World\"\n",
+			noSpaces:     true,
+			keepComments: true,
+			want:         ";LAYER:1\nG1 X1 Y2; move\nM117 \"Hello World\"\n",
+		},
+		{
+			source:       ";LAYER:1\nG1 X1 Y2 ; move\n",
+			keepComments: true,
+			want:         ";LAYER:1\nG1 X1 Y2 ; move\n",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("(%v)", i), func(t *testing.T) {
+			packed := encode(t, tc.source, tc.noSpaces, tc.keepComments)
+
+			got, err := io.ReadAll(NewDecoder(bytes.NewReader(packed)))
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			if string(got) != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecoder(t *testing.T) {
+
+	t.Run("packing disabled", func(t *testing.T) {
+		got, err := io.ReadAll(NewDecoder(bytes.NewReader([]byte("G28\n"))))
+		if err != nil || string(got) != "G28\n" {
+			t.Errorf("got %q %v, want \"G28\\n\" nil error", got, err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		_, err := io.ReadAll(NewDecoder(bytes.NewReader([]byte{0xFF, 0xFF, 0xFB, 0xFF, 'M'})))
+		if err == nil {
+			t.Errorf("got nil error, want error")
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		packed := []byte{0xFF, 0xFF, 0xFB, 0x1D, 0xEB, 0x01, 0xBC, 0xFF, 0xFF, 0xF9, 'T', '0', '\n'}
+		got, err := io.ReadAll(NewDecoder(bytes.NewReader(packed)))
+		if err != nil || string(got) != "G1 X10\nT0\n" {
+			t.Errorf("got %q %v, want \"G1 X10\\nT0\\n\" nil error", got, err)
+		}
+	})
+}
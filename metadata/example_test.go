@@ -0,0 +1,33 @@
+package metadata_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/metadata"
+)
+
+func Example() {
+
+	gcode := `;FLAVOR:Marlin
+;TIME:5025
+;Filament used: 2.5m
+;Layer height: 0.2
+;Generated with Cura_SteamEngine 5.4.0
+G28
+G1 X10 Y10 E0.5
+`
+
+	m, err := metadata.Extract(strings.NewReader(gcode))
+	if err != nil {
+		fmt.Printf("failed to extract the metadata: %v", err)
+		return
+	}
+
+	fmt.Printf("%s %s, flavor %s\n", m.Slicer, m.Version, m.Raw["FLAVOR"])
+	fmt.Printf("%v, %vmm of filament, layers of %vmm\n", m.EstimatedTime, m.FilamentLength, m.LayerHeight)
+
+	// Output:
+	// Cura 5.4.0, flavor Marlin
+	// 1h23m45s, [2500]mm of filament, layers of 0.2mm
+}
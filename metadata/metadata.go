@@ -0,0 +1,227 @@
+// metadata package recognizes the slicer that made a gcode file and extracts the metadata that it writes into the comments.
+//
+// Each slicer has his own way to write it:
+//
+//	;FLAVOR:Marlin                                      Cura writes KEY:value comments in the header
+//	; filament used [mm] = 1234.56                      PrusaSlicer and his forks write a key = value config dump at the end
+//	;   layerHeight,0.2                                 Simplify3D writes key,value settings in the header
+//	;   Build time: 1 hours 2 minutes                   and a summary at the end
+//
+// All comments that look like a key and a value are stored in a raw map, and the known keys of the recognized slicer
+// are interpreted into the typed fields of Metadata.
+package metadata
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+)
+
+// Slicer is the name of a slicer.
+type Slicer string
+
+const (
+	SLICER_UNKNOWN     Slicer = ""
+	SLICER_PRUSASLICER Slicer = "PrusaSlicer"
+	SLICER_SUPERSLICER Slicer = "SuperSlicer"
+	SLICER_ORCASLICER  Slicer = "OrcaSlicer"
+	SLICER_BAMBUSTUDIO Slicer = "BambuStudio"
+	SLICER_CURA        Slicer = "Cura"
+	SLICER_SIMPLIFY3D  Slicer = "Simplify3D"
+)
+
+// generatorRegexes matches the comments that identify each slicer and captures his version
+var generatorRegexes = []struct {
+	slicer Slicer
+	regex  *regexp.Regexp
+}{
+	{SLICER_PRUSASLICER, regexp.MustCompile(`^generated by PrusaSlicer ([^\s]+)`)},
+	{SLICER_SUPERSLICER, regexp.MustCompile(`^generated by SuperSlicer ([^\s]+)`)},
+	{SLICER_ORCASLICER, regexp.MustCompile(`^generated by OrcaSlicer ([^\s]+)`)},
+	{SLICER_BAMBUSTUDIO, regexp.MustCompile(`^BambuStudio ([^\s]+)$`)},
+	{SLICER_CURA, regexp.MustCompile(`^Generated with Cura_SteamEngine ([^\s]+)`)},
+	{SLICER_SIMPLIFY3D, regexp.MustCompile(`^G-Code generated by Simplify3D\(R\) Version ([^\s]+)`)},
+}
+
+// keyRegex matches the keys accepted into the raw map
+var keyRegex = regexp.MustCompile(`^[A-Za-z_][\w .\[\]()%/-]*$`)
+
+//#region metadata struct
+
+// Metadata is the information that a slicer writes into the comments of a gcode file.
+//
+// The lists have one value per extruder. The fields that the slicer doesn't write are left with their zero value.
+type Metadata struct {
+	// Slicer and Version identify the slicer that made the file
+	Slicer  Slicer
+	Version string
+
+	// EstimatedTime is the print time estimated by the slicer
+	EstimatedTime time.Duration
+
+	// FilamentLength is the filament used in millimeters
+	FilamentLength []float64
+
+	// FilamentWeight is the filament used in grams
+	FilamentWeight []float64
+
+	// LayerHeight is the layer height in millimeters
+	LayerHeight float64
+
+	// NozzleDiameter is the nozzle diameter in millimeters
+	NozzleDiameter []float64
+
+	// NozzleTemperature is the nozzle temperature in celsius degrees
+	NozzleTemperature []float64
+
+	// BedTemperature is the bed temperature in celsius degrees
+	BedTemperature float64
+
+	// Raw stores all key and value pairs found in the comments. If a key is repeated, the last value is kept.
+	Raw map[string]string
+}
+
+//#endregion
+//#region extractor struct
+
+// Extractor collects the comments of a gcode file to build his Metadata.
+//
+// It is useful when the comments are already available, for example from the Comment method of each GcodeBlock
+// or from the Text method of a gcodeblock.Scanner, so the file doesn't need to be read twice.
+type Extractor struct {
+	// slicer and version are set when the comment that identifies the slicer is found
+	slicer  Slicer
+	version string
+
+	// raw stores the key and value pairs found
+	raw map[string]string
+}
+
+// Add collects a comment. It can be passed with or without the leading semicolon and whitespaces.
+func (e *Extractor) Add(comment string) {
+
+	comment = strings.TrimSpace(comment)
+	comment = strings.TrimSpace(strings.TrimPrefix(comment, ";"))
+
+	if comment == "" {
+		return
+	}
+
+	if e.slicer == SLICER_UNKNOWN {
+		for _, g := range generatorRegexes {
+			if match := g.regex.FindStringSubmatch(comment); match != nil {
+				e.slicer = g.slicer
+				e.version = match[1]
+				return
+			}
+		}
+	}
+
+	// the config dump of PrusaSlicer and his forks has values that contain any character, even semicolons
+	if key, value, ok := split(comment, "="); ok {
+		e.raw[key] = value
+		return
+	}
+
+	// Orca Slicer writes several pairs in a single comment
+	for _, part := range strings.Split(comment, ";") {
+		if key, value, ok := split(part, ":"); ok {
+			e.raw[key] = value
+			continue
+		}
+
+		if e.slicer == SLICER_SIMPLIFY3D {
+			if key, value, ok := split(part, ","); ok {
+				e.raw[key] = value
+			}
+		}
+	}
+}
+
+// Metadata returns the metadata built from the comments collected until now.
+func (e *Extractor) Metadata() *Metadata {
+
+	m := &Metadata{
+		Slicer:  e.slicer,
+		Version: e.version,
+		Raw:     make(map[string]string, len(e.raw)),
+	}
+
+	for key, value := range e.raw {
+		m.Raw[key] = value
+	}
+
+	switch m.Slicer {
+	case SLICER_PRUSASLICER, SLICER_SUPERSLICER, SLICER_ORCASLICER, SLICER_BAMBUSTUDIO:
+		interpretPrusaSlicer(m)
+	case SLICER_CURA:
+		interpretCura(m)
+	case SLICER_SIMPLIFY3D:
+		interpretSimplify3D(m)
+	}
+
+	return m
+}
+
+//#endregion
+//#region constructor
+
+// New returns an Extractor without comments collected.
+func New() *Extractor {
+	return &Extractor{
+		raw: make(map[string]string),
+	}
+}
+
+//#endregion
+//#region package functions
+
+// Extract reads a gcode file and returns the metadata found in his comments.
+//
+// The lines are read as they are, without being parsed into blocks,
+// so the file can contain gcode that the gcodeblock package doesn't accept.
+func Extract(r io.Reader) (*Metadata, error) {
+
+	e := New()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), gcodeblock.SCANNER_MAX_LINE_SIZE)
+
+	for scanner.Scan() {
+		if i := strings.IndexByte(scanner.Text(), ';'); i >= 0 {
+			e.Add(scanner.Text()[i:])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read gcode: %w", err)
+	}
+
+	return e.Metadata(), nil
+}
+
+//#endregion
+//#region private functions
+
+// split returns the key and the value of a comment separated by sep, if the key is valid
+func split(comment string, sep string) (string, string, bool) {
+
+	key, value, found := strings.Cut(comment, sep)
+	if !found {
+		return "", "", false
+	}
+
+	key = strings.TrimSpace(key)
+	if !keyRegex.MatchString(key) {
+		return "", "", false
+	}
+
+	return key, strings.TrimSpace(value), true
+}
+
+//#endregion
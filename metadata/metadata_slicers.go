@@ -0,0 +1,205 @@
+// This file interprets the raw keys written by each slicer into the typed fields of Metadata.
+package metadata
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationRegex matches each component of the durations written by the slicers, like "1d 2h 3m 4s" or "1 hours 2 minutes"
+var durationRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(days?|hours?|minutes?|seconds?|d|h|m|s)\b`)
+
+// durationUnits stores the duration of each unit matched by durationRegex
+var durationUnits = map[string]time.Duration{
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"h": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"m": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"s": time.Second, "second": time.Second, "seconds": time.Second,
+}
+
+//#region slicer interpreters
+
+// interpretPrusaSlicer reads the config dump of PrusaSlicer and his forks, SuperSlicer, Orca Slicer and Bambu Studio
+func interpretPrusaSlicer(m *Metadata) {
+
+	if value, ok := first(m.Raw, "estimated printing time (normal mode)", "total estimated time", "estimated printing time"); ok {
+		m.EstimatedTime, _ = parseDuration(value)
+	}
+
+	if value, ok := first(m.Raw, "filament used [mm]", "total filament length [mm]"); ok {
+		m.FilamentLength, _ = parseList(value, "")
+	}
+
+	if value, ok := first(m.Raw, "filament used [g]", "total filament weight [g]", "total filament used [g]"); ok {
+		m.FilamentWeight, _ = parseList(value, "")
+	}
+
+	if value, ok := first(m.Raw, "layer_height"); ok {
+		m.LayerHeight, _ = strconv.ParseFloat(value, 64)
+	}
+
+	if value, ok := first(m.Raw, "nozzle_diameter"); ok {
+		m.NozzleDiameter, _ = parseList(value, "")
+	}
+
+	if value, ok := first(m.Raw, "temperature", "nozzle_temperature"); ok {
+		m.NozzleTemperature, _ = parseList(value, "")
+	}
+
+	if value, ok := first(m.Raw, "bed_temperature", "hot_plate_temp"); ok {
+		if list, err := parseList(value, ""); err == nil && len(list) > 0 {
+			m.BedTemperature = list[0]
+		}
+	}
+}
+
+// interpretCura reads the header of Cura
+func interpretCura(m *Metadata) {
+
+	if value, ok := first(m.Raw, "TIME"); ok {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			m.EstimatedTime = time.Duration(seconds * float64(time.Second))
+		}
+	}
+
+	// Cura writes the filament used in meters
+	if value, ok := first(m.Raw, "Filament used"); ok {
+		if list, err := parseList(value, "m"); err == nil {
+			for i := range list {
+				list[i] *= 1000
+			}
+			m.FilamentLength = list
+		}
+	}
+
+	if value, ok := first(m.Raw, "Layer height"); ok {
+		m.LayerHeight, _ = strconv.ParseFloat(value, 64)
+	}
+
+	// the Griffin flavor writes a group of keys for each extruder
+	for i := 0; ; i++ {
+		diameter, ok := first(m.Raw, fmt.Sprintf("EXTRUDER_TRAIN.%d.NOZZLE.DIAMETER", i))
+		temperature, ok2 := first(m.Raw, fmt.Sprintf("EXTRUDER_TRAIN.%d.INITIAL_TEMPERATURE", i))
+		if !ok && !ok2 {
+			break
+		}
+
+		d, _ := strconv.ParseFloat(diameter, 64)
+		t, _ := strconv.ParseFloat(temperature, 64)
+		m.NozzleDiameter = append(m.NozzleDiameter, d)
+		m.NozzleTemperature = append(m.NozzleTemperature, t)
+	}
+
+	if value, ok := first(m.Raw, "BUILD_PLATE.INITIAL_TEMPERATURE"); ok {
+		m.BedTemperature, _ = strconv.ParseFloat(value, 64)
+	}
+}
+
+// interpretSimplify3D reads the settings header and the build summary of Simplify3D
+func interpretSimplify3D(m *Metadata) {
+
+	if value, ok := first(m.Raw, "Build time"); ok {
+		m.EstimatedTime, _ = parseDuration(value)
+	}
+
+	// the summary has the values in two units, like "1234.5 mm (1.23 m)"
+	if value, ok := first(m.Raw, "Filament length"); ok {
+		if length, err := strconv.ParseFloat(strings.Fields(value + " ")[0], 64); err == nil {
+			m.FilamentLength = []float64{length}
+		}
+	}
+
+	if value, ok := first(m.Raw, "Plastic weight"); ok {
+		if weight, err := strconv.ParseFloat(strings.Fields(value + " ")[0], 64); err == nil {
+			m.FilamentWeight = []float64{weight}
+		}
+	}
+
+	if value, ok := first(m.Raw, "layerHeight"); ok {
+		m.LayerHeight, _ = strconv.ParseFloat(value, 64)
+	}
+
+	if value, ok := first(m.Raw, "extruderDiameter"); ok {
+		m.NozzleDiameter, _ = parseList(value, "")
+	}
+
+	// each temperature controller has a setpoint and a flag that tells if it's the heated bed
+	setpoints, err := parseList(m.Raw["temperatureSetpointTemperatures"], "")
+	if err != nil {
+		return
+	}
+	beds, err := parseList(m.Raw["temperatureHeatedBed"], "")
+	if err != nil || len(beds) != len(setpoints) {
+		beds = make([]float64, len(setpoints))
+	}
+
+	for i, setpoint := range setpoints {
+		if beds[i] != 0 {
+			m.BedTemperature = setpoint
+			continue
+		}
+		m.NozzleTemperature = append(m.NozzleTemperature, setpoint)
+	}
+}
+
+//#endregion
+//#region private functions
+
+// first returns the value of the first key found in raw
+func first(raw map[string]string, keys ...string) (string, bool) {
+	for _, key := range keys {
+		if value, ok := raw[key]; ok && value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// parseList parses a list of numbers separated by commas, each one of them can have a unit suffix that is ignored
+func parseList(value string, suffix string) ([]float64, error) {
+
+	if strings.TrimSpace(value) == "" {
+		return nil, fmt.Errorf("list is empty")
+	}
+
+	var list []float64
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSuffix(strings.TrimSpace(item), suffix)
+
+		number, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse list '%s': %w", value, err)
+		}
+
+		list = append(list, number)
+	}
+
+	return list, nil
+}
+
+// parseDuration parses the durations written by the slicers, like "1d 2h 3m 4s" or "1 hours 2 minutes"
+func parseDuration(value string) (time.Duration, error) {
+
+	matches := durationRegex.FindAllStringSubmatch(value, -1)
+	if matches == nil {
+		return 0, fmt.Errorf("failed to parse duration '%s'", value)
+	}
+
+	var d time.Duration
+
+	for _, match := range matches {
+		n, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse duration '%s': %w", value, err)
+		}
+		d += time.Duration(n * float64(durationUnits[match[2]]))
+	}
+
+	return d, nil
+}
+
+//#endregion
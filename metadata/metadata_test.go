@@ -0,0 +1,252 @@
+package metadata
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+)
+
+const prusaSlicerGcode = `; generated by PrusaSlicer 2.6.1+win64 on 2023-09-12 at 10:25:11 UTC
+
+; external perimeters extrusion width = 0.45mm
+
+M73 P0 R62
+G28 W ; home all without mesh bed level
+G1 X10 Y10 E0.5 ; extrude
+M73 P100 R0
+
+; filament used [mm] = 1234.56, 10.20
+; filament used [cm3] = 2.97
+; filament used [g] = 3.68, 0.03
+; estimated printing time (normal mode) = 1h 2m 3s
+; estimated printing time (silent mode) = 1h 5m 9s
+
+; prusaslicer_config = begin
+; bed_temperature = 60,60
+; layer_height = 0.2
+; nozzle_diameter = 0.4,0.4
+; start_gcode = M862.3 P "[printer_model]" ; printer model check\nG28 W ; home all
+; temperature = 215,220
+; prusaslicer_config = end
+`
+
+const curaGcode = `;FLAVOR:Griffin
+;TIME:3723
+;Filament used: 1.25m, 0.5m
+;Layer height: 0.15
+;EXTRUDER_TRAIN.0.INITIAL_TEMPERATURE:200
+;EXTRUDER_TRAIN.0.NOZZLE.DIAMETER:0.4
+;EXTRUDER_TRAIN.1.INITIAL_TEMPERATURE:210
+;EXTRUDER_TRAIN.1.NOZZLE.DIAMETER:0.6
+;BUILD_PLATE.INITIAL_TEMPERATURE:60
+;Generated with Cura_SteamEngine 5.4.0
+M82 ;absolute extrusion mode
+;LAYER:0
+G1 X10 Y10 E0.5
+;TIME_ELAPSED:3723.0
+`
+
+const simplify3DGcode = `; G-Code generated by Simplify3D(R) Version 4.1.2
+; Sep 12, 2023 at 10:25:11 AM
+; Settings Summary
+;   processName,Process1
+;   extruderDiameter,0.4
+;   layerHeight,0.25
+;   temperatureName,Extruder 1,Heated Bed
+;   temperatureHeatedBed,0,1
+;   temperatureSetpointTemperatures,215,60
+G90
+G1 X10 Y10 E0.5
+; Build Summary
+;   Build time: 1 hours 2 minutes
+;   Filament length: 1234.5 mm (1.23 m)
+;   Plastic volume: 2969.37 mm^3 (2.97 cc)
+;   Plastic weight: 3.70 g (0.01 lb)
+`
+
+const orcaSlicerGcode = `; HEADER_BLOCK_START
+; generated by OrcaSlicer 1.8.0 on 2023-11-05 at 10:25:11
+; model printing time: 1h 2m 3s; total estimated time: 1h 10m 5s
+; total layer number: 40
+; HEADER_BLOCK_END
+G1 X10 Y10 E0.5
+; filament used [mm] = 1234.56
+; filament used [g] = 3.68
+; hot_plate_temp = 55
+; layer_height = 0.2
+; nozzle_diameter = 0.4
+; nozzle_temperature = 220
+`
+
+func TestExtract(t *testing.T) {
+
+	var cases = map[string]struct {
+		gcode string
+		want  Metadata
+	}{
+		"prusaslicer": {
+			gcode: prusaSlicerGcode,
+			want: Metadata{
+				Slicer:            SLICER_PRUSASLICER,
+				Version:           "2.6.1+win64",
+				EstimatedTime:     time.Hour + 2*time.Minute + 3*time.Second,
+				FilamentLength:    []float64{1234.56, 10.2},
+				FilamentWeight:    []float64{3.68, 0.03},
+				LayerHeight:       0.2,
+				NozzleDiameter:    []float64{0.4, 0.4},
+				NozzleTemperature: []float64{215, 220},
+				BedTemperature:    60,
+			},
+		},
+		"cura": {
+			gcode: curaGcode,
+			want: Metadata{
+				Slicer:            SLICER_CURA,
+				Version:           "5.4.0",
+				EstimatedTime:     time.Hour + 2*time.Minute + 3*time.Second,
+				FilamentLength:    []float64{1250, 500},
+				LayerHeight:       0.15,
+				NozzleDiameter:    []float64{0.4, 0.6},
+				NozzleTemperature: []float64{200, 210},
+				BedTemperature:    60,
+			},
+		},
+		"simplify3d": {
+			gcode: simplify3DGcode,
+			want: Metadata{
+				Slicer:            SLICER_SIMPLIFY3D,
+				Version:           "4.1.2",
+				EstimatedTime:     time.Hour + 2*time.Minute,
+				FilamentLength:    []float64{1234.5},
+				FilamentWeight:    []float64{3.7},
+				LayerHeight:       0.25,
+				NozzleDiameter:    []float64{0.4},
+				NozzleTemperature: []float64{215},
+				BedTemperature:    60,
+			},
+		},
+		"orcaslicer": {
+			gcode: orcaSlicerGcode,
+			want: Metadata{
+				Slicer:            SLICER_ORCASLICER,
+				Version:           "1.8.0",
+				EstimatedTime:     time.Hour + 10*time.Minute + 5*time.Second,
+				FilamentLength:    []float64{1234.56},
+				FilamentWeight:    []float64{3.68},
+				LayerHeight:       0.2,
+				NozzleDiameter:    []float64{0.4},
+				NozzleTemperature: []float64{220},
+				BedTemperature:    55,
+			},
+		},
+		"unknown": {
+			gcode: "; made by hand\nG28\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, err := Extract(strings.NewReader(tc.gcode))
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			raw := m.Raw
+			m.Raw = nil
+
+			if !reflect.DeepEqual(*m, tc.want) {
+				t.Errorf("got %+v, want %+v", *m, tc.want)
+			}
+
+			if raw == nil {
+				t.Errorf("got nil raw map, want a map")
+			}
+		})
+	}
+}
+
+func TestExtract_Raw(t *testing.T) {
+
+	m, err := Extract(strings.NewReader(prusaSlicerGcode))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	var cases = map[string]string{
+		"external perimeters extrusion width":   "0.45mm",
+		"filament used [cm3]":                   "2.97",
+		"estimated printing time (silent mode)": "1h 5m 9s",
+		"start_gcode":                           `M862.3 P "[printer_model]" ; printer model check\nG28 W ; home all`,
+	}
+
+	for key, want := range cases {
+		if got := m.Raw[key]; got != want {
+			t.Errorf("got raw %s %q, want %q", key, got, want)
+		}
+	}
+
+	if _, ok := m.Raw["home all without mesh bed level"]; ok {
+		t.Errorf("got a comment without value in the raw map")
+	}
+}
+
+func TestExtractor_Blocks(t *testing.T) {
+
+	e := New()
+
+	// the comments of the blocks include the leading whitespaces and semicolon
+	s := gcodeblock.NewScanner(strings.NewReader(";FLAVOR:Marlin\n;TIME:60\nG28 ;Generated with Cura_SteamEngine 5.4.0\n"))
+	for s.Scan() {
+		if s.Block() == nil {
+			e.Add(s.Text())
+			continue
+		}
+		e.Add(s.Block().Comment())
+	}
+	if s.Err() != nil {
+		t.Fatalf("got error %v, want nil error", s.Err())
+	}
+
+	m := e.Metadata()
+
+	if m.Slicer != SLICER_CURA || m.EstimatedTime != time.Minute || m.Raw["FLAVOR"] != "Marlin" {
+		t.Errorf("got %+v, want Cura metadata", m)
+	}
+
+	// the metadata returned isn't modified by the comments added later
+	e.Add(";TIME:120")
+	if m.EstimatedTime != time.Minute || m.Raw["TIME"] != "60" {
+		t.Errorf("got %+v, want metadata unchanged", m)
+	}
+	if e.Metadata().EstimatedTime != 2*time.Minute {
+		t.Errorf("got %v, want %v", e.Metadata().EstimatedTime, 2*time.Minute)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+
+	var cases = map[string]time.Duration{
+		"1d 2h 3m 4s":                  26*time.Hour + 3*time.Minute + 4*time.Second,
+		"45s":                          45 * time.Second,
+		"2 hours 1 minute":             2*time.Hour + time.Minute,
+		"1 hour 30 minutes 10 seconds": time.Hour + 30*time.Minute + 10*time.Second,
+	}
+
+	for value, want := range cases {
+		got, err := parseDuration(value)
+		if err != nil {
+			t.Errorf("got error %v parsing %s, want nil error", err, value)
+			continue
+		}
+		if got != want {
+			t.Errorf("got %v parsing %s, want %v", got, value, want)
+		}
+	}
+
+	if _, err := parseDuration("soon"); err == nil {
+		t.Errorf("got nil error, want error")
+	}
+}
@@ -0,0 +1,229 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/pipeline"
+)
+
+func ExamplePipeline_Run() {
+
+	source := "; start\nG28\nG1 Z0.2\nG1 X10 Y10 E1\n"
+
+	// drop the comment-only lines
+	strip := pipeline.TransformFunc(func(line pipeline.Line, state pipeline.State, emit pipeline.Emit) error {
+		if line.Block == nil {
+			return nil
+		}
+		return emit(line)
+	})
+
+	// announce the height of each move that changes it, the state has the position before the move
+	announce := pipeline.TransformFunc(func(line pipeline.Line, state pipeline.State, emit pipeline.Emit) error {
+		if line.Block != nil {
+			if z := state.Target(line.Block)[2]; z != state.Position[2] {
+				if err := emit(pipeline.Line{Text: fmt.Sprintf("M117 Z%g", z)}); err != nil {
+					return err
+				}
+			}
+		}
+		return emit(line)
+	})
+
+	p, err := pipeline.New(func(config pipeline.PipelineConfigurer) error {
+		if err := config.AddTransform("strip", strip); err != nil {
+			return err
+		}
+		return config.AddTransform("announce", announce)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := p.Run(context.Background(), os.Stdout, strings.NewReader(source)); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, m := range p.Metrics() {
+		fmt.Printf("%s: %d lines in, %d lines out\n", m.Name, m.In, m.Out)
+	}
+
+	// Output:
+	// G28
+	// M117 Z0.2
+	// G1 Z0.2
+	// G1 X10 Y10 E1
+	// strip: 4 lines in, 3 lines out
+	// announce: 3 lines in, 4 lines out
+}
+
+func ExampleLoad() {
+
+	config := `[
+		{"translate": {"x": 10}},
+		{"insert_at_layer": {"layer": 1, "gcode": "M600"}},
+		{"renumber": {}},
+		{"checksum": {}}
+	]`
+
+	p, err := pipeline.Load(strings.NewReader(config))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	source := ";LAYER:0\nG1 Z0.2\nG1 X10 Y10 E1\n;LAYER:1\nG1 Z0.4\n"
+
+	if err := p.Run(context.Background(), os.Stdout, strings.NewReader(source)); err != nil {
+		fmt.Println(err)
+		return
+	}
+	// Output:
+	// ;LAYER:0
+	// N1 G1 Z0.2*127
+	// N2 G1 X20 Y10 E1*124
+	// ;LAYER:1
+	// N3 M600*38
+	// N4 G1 Z0.4*124
+}
+
+func ExampleNewInsert() {
+
+	source := ";LAYER:0\nG1 Z0.2\nG1 X10 E1\n;LAYER:1\nG1 Z0.4\nG1 X0 E2\n;LAYER:2\nG1 Z0.6\n"
+
+	// change the fan speed every 2 layers, and tell the height
+	insert, err := pipeline.NewInsert([]string{"M106 S255", "M117 Layer {layer} at Z{z}"}, func(config pipeline.InsertConfigurer) error {
+		return config.SetEveryLayers(2)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	p, err := pipeline.New(func(config pipeline.PipelineConfigurer) error {
+		return config.AddTransform("fan", insert)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := p.Run(context.Background(), os.Stdout, strings.NewReader(source)); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Output:
+	// ;LAYER:0
+	// G1 Z0.2
+	// G1 X10 E1
+	// ;LAYER:1
+	// G1 Z0.4
+	// G1 X0 E2
+	// ;LAYER:2
+	// M106 S255
+	// M117 Layer 2 at Z0.6
+	// G1 Z0.6
+}
+
+func ExampleNewPause() {
+
+	source := ";LAYER:0\nG1 Z0.2 F600\nG1 X10 Y10 E1 F1200\n;LAYER:1\nG1 Z0.4\n"
+
+	// pause at the second layer to insert magnets, parking at the front
+	pause, err := pipeline.NewPause(func(config pipeline.PauseConfigurer) error {
+		if err := config.SetLayer(1); err != nil {
+			return err
+		}
+		if err := config.SetMode(pipeline.PAUSE_MODE_MANUAL); err != nil {
+			return err
+		}
+		return config.SetPark(0, 200)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	p, err := pipeline.New(func(config pipeline.PipelineConfigurer) error {
+		return config.AddTransform("magnets", pause)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := p.Run(context.Background(), os.Stdout, strings.NewReader(source)); err != nil {
+		fmt.Println(err)
+		return
+	}
+	// Output:
+	// ;LAYER:0
+	// G1 Z0.2 F600
+	// G1 X10 Y10 E1 F1200
+	// ;LAYER:1
+	// ; pause at layer 1
+	// M83
+	// G1 E-2 F1800
+	// G90
+	// G1 Z5.2 F3000
+	// G1 X0 Y200
+	// M0
+	// G1 E2 F1800
+	// G1 X10 Y10 F3000
+	// G1 Z0.2
+	// M82
+	// G92 E1
+	// G1 F1200
+	// G1 Z0.4
+}
+
+func ExampleNewSweep() {
+
+	// a tower of 3 bands of 5 mm over a base of 1 mm
+	source := "G1 Z0.5 F600\nG1 X10 E1\nG1 Z1\nG1 X0 E2\nG1 Z6\nG1 X10 E3\nG1 Z11\nG1 X0 E4\nG1 Z16\nG1 X10 E5\n"
+
+	sweep, err := pipeline.NewSweep(pipeline.SWEEP_TEMPERATURE, 230, -5, 5, func(config pipeline.SweepConfigurer) error {
+		if err := config.SetBase(1); err != nil {
+			return err
+		}
+		return config.SetBands(3)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	p, err := pipeline.New(func(config pipeline.PipelineConfigurer) error {
+		return config.AddTransform("temperature tower", sweep)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := p.Run(context.Background(), os.Stdout, strings.NewReader(source)); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Output:
+	// G1 Z0.5 F600
+	// G1 X10 E1
+	// G1 Z1
+	// M104 S230 ; temperature band 0
+	// G1 X0 E2
+	// G1 Z6
+	// M104 S225 ; temperature band 1
+	// G1 X10 E3
+	// G1 Z11
+	// M104 S220 ; temperature band 2
+	// G1 X0 E4
+	// G1 Z16
+	// G1 X10 E5
+}
@@ -0,0 +1,514 @@
+// pipeline package chains transforms that modify a stream of gcode lines, like the post-processing scripts of the slicers.
+//
+// A Transform receives each line of the stream, with his block and the state of the machine before it, and emits
+// zero or more lines in his place: the same line, a modified one, new lines around it or nothing at all.
+// The transforms are chained in the stages of a Pipeline, and each stage interprets the lines that it receives,
+// so the state that a transform sees includes the changes of the previous stages.
+//
+//	p, err := pipeline.New(
+//		func(config pipeline.PipelineConfigurer) error {
+//			return config.AddTransform("strip comments", stripComments)
+//		},
+//	)
+//	err = p.Run(ctx, os.Stdout, file)
+//
+// The stages run one after the other in the goroutine of the caller, or concurrently, each one in his own goroutine
+// connected by channels. In both modes the first error stops the pipeline, the cancellation of the context too,
+// and the metrics of each stage are available after the run.
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+)
+
+const (
+	// PIPELINE_BUFFER_SIZE is the number of lines that the channels between the concurrent stages store by default
+	PIPELINE_BUFFER_SIZE = 256
+)
+
+//#region line struct
+
+// Line is a line of the stream.
+type Line struct {
+	// Number is the number of the line in the source, starting at 1. It's 0 for the lines inserted by a transform
+	Number int
+
+	// Text is the line as it's written
+	Text string
+
+	// Block is the block parsed from Text, it's nil if the line hasn't gcode or it couldn't be parsed
+	Block block.Blocker
+}
+
+// WithBlock returns the line with a new block, and his text written again from the block.
+func (l Line) WithBlock(b block.Blocker) Line {
+	return Line{Number: l.Number, Text: format(b), Block: b}
+}
+
+// Rewrite returns the line with the values of the parameters replaced, without line number and checksum.
+// The new values are written with up to 3 decimals, like they are usually written in the files,
+// the rest of the line is copied as it is. The values of parameters that the line hasn't are appended to the code.
+//
+// The first word of each letter is replaced, so the command can be replaced too, like the G of an arc.
+// The new text is parsed with the options.
+func (l Line) Rewrite(values map[byte]float64, options ...block.BlockParserConfigurationCallbackable) Line {
+
+	code := stripComment(l.Text)
+	comment := strings.TrimSpace(l.Text[len(code):])
+	code = numbered.ReplaceAllString(strings.TrimSpace(code), "")
+
+	replaced := map[byte]bool{}
+	code = word.ReplaceAllStringFunc(code, func(w string) string {
+		letter := w[0] &^ 0x20
+		v, ok := values[letter]
+		if !ok || replaced[letter] {
+			return w
+		}
+		replaced[letter] = true
+		return string(letter) + coordinate(v)
+	})
+
+	missing := []byte{}
+	for letter := range values {
+		if !replaced[letter] {
+			missing = append(missing, letter)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+
+	for _, letter := range missing {
+		code += " " + string(letter) + coordinate(values[letter])
+	}
+
+	if comment != "" {
+		code += " " + comment
+	}
+
+	return ParseLine(l.Number, code, options...)
+}
+
+// NewLine returns a line to insert in the stream, with the text written from the block.
+func NewLine(b block.Blocker) Line {
+	return Line{Text: format(b), Block: b}
+}
+
+// ParseLine returns the line with the number and the text, and his block if the text has gcode that can be parsed.
+func ParseLine(number int, text string, options ...block.BlockParserConfigurationCallbackable) Line {
+
+	line := Line{Number: number, Text: text}
+
+	if gcodeblock.HasGcode(text) {
+		if b, err := gcodeblock.Parse(text, options...); err == nil {
+			line.Block = b
+		}
+	}
+
+	return line
+}
+
+//#endregion
+//#region transform interface
+
+// Emit sends a line to the next stage of the pipeline.
+type Emit func(line Line) error
+
+// Transform modifies the lines of a stream.
+//
+// Apply receives each line with the state of the machine before it, and emits the lines that replace it.
+// Flush receives the state after the last line, and emits the lines that the transform kept until the end.
+// A transform can keep his own state between the lines, so a transform belongs to a single Pipeline.
+type Transform interface {
+	Apply(line Line, state State, emit Emit) error
+	Flush(state State, emit Emit) error
+}
+
+// TransformFunc is a Transform without state, that only implements Apply.
+type TransformFunc func(line Line, state State, emit Emit) error
+
+// Apply calls the function.
+func (f TransformFunc) Apply(line Line, state State, emit Emit) error {
+	return f(line, state, emit)
+}
+
+// Flush doesn't emit lines.
+func (f TransformFunc) Flush(state State, emit Emit) error {
+	return nil
+}
+
+//#endregion
+//#region stage struct
+
+// Metrics describes the work of a stage in the last run.
+type Metrics struct {
+	// Name is the name of the transform
+	Name string
+
+	// In counts the lines received and Out the lines emitted
+	In  int
+	Out int
+
+	// Duration is the time spent in the transform, without the time that the next stages took to receive his lines
+	Duration time.Duration
+}
+
+// stage runs a transform with his own interpreter
+type stage struct {
+	transform   Transform
+	interpreter *Interpreter
+	metrics     Metrics
+
+	// next sends the lines to the next stage
+	next Emit
+
+	// waited is the time spent in next during the current call, and failed the error returned by next
+	waited time.Duration
+	failed error
+}
+
+// process applies the transform to a line
+func (s *stage) process(line Line) error {
+
+	s.metrics.In++
+
+	state := s.interpreter.State()
+	s.interpreter.Update(line)
+
+	s.waited = 0
+	start := time.Now()
+	err := s.transform.Apply(line, state, s.emit)
+	s.metrics.Duration += time.Since(start) - s.waited
+
+	return s.check(err, fmt.Sprintf("the line %d '%s'", line.Number, line.Text))
+}
+
+// flush flushes the transform at the end of the stream
+func (s *stage) flush() error {
+
+	s.waited = 0
+	start := time.Now()
+	err := s.transform.Flush(s.interpreter.State(), s.emit)
+	s.metrics.Duration += time.Since(start) - s.waited
+
+	return s.check(err, "the end of the stream")
+}
+
+// emit sends a line to the next stage
+func (s *stage) emit(line Line) error {
+
+	s.metrics.Out++
+
+	start := time.Now()
+	err := s.next(line)
+	s.waited += time.Since(start)
+
+	if err != nil {
+		s.failed = err
+	}
+
+	return err
+}
+
+// check wraps the error of the transform, the errors of the next stages are returned as they are
+func (s *stage) check(err error, where string) error {
+
+	switch {
+	case err == nil:
+		return nil
+	case s.failed != nil:
+		return s.failed
+	}
+
+	return fmt.Errorf("failed to apply transform '%s' to %s: %w", s.metrics.Name, where, err)
+}
+
+//#endregion
+//#region pipeline struct
+
+// transformEntry is a transform with his name, as it's configured
+type transformEntry struct {
+	name      string
+	transform Transform
+}
+
+// Pipeline chains transforms over a stream of gcode lines.
+type Pipeline struct {
+	transforms []transformEntry
+
+	// concurrent is true if each stage runs in his own goroutine, and bufferSize is the size of the channels between them
+	concurrent bool
+	bufferSize int
+
+	// blockOptions are applied to each block parsed by Run
+	blockOptions []block.BlockParserConfigurationCallbackable
+
+	// metrics of the stages in the last run
+	metrics []Metrics
+}
+
+// Run reads the lines of r, applies the transforms and writes the lines emitted by the last one to w.
+//
+// Each line is parsed with gcodeblock.Parse, the lines that can't be parsed, like a M117 with an unquoted message, pass without a block.
+func (p *Pipeline) Run(ctx context.Context, w io.Writer, r io.Reader) error {
+
+	writer := bufio.NewWriter(w)
+
+	source := func(emit Emit) error {
+
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), gcodeblock.SCANNER_MAX_LINE_SIZE)
+
+		number := 0
+		for scanner.Scan() {
+			number++
+			if err := emit(ParseLine(number, strings.TrimSuffix(scanner.Text(), "\r"), p.blockOptions...)); err != nil {
+				return err
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read gcode: %w", err)
+		}
+
+		return nil
+	}
+
+	sink := func(line Line) error {
+		if _, err := fmt.Fprintln(writer, line.Text); err != nil {
+			return fmt.Errorf("failed to write gcode: %w", err)
+		}
+		return nil
+	}
+
+	if err := p.stream(ctx, source, sink); err != nil {
+		return err
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write gcode: %w", err)
+	}
+
+	return nil
+}
+
+// Apply applies the transforms to lines in memory and returns the lines emitted by the last one.
+func (p *Pipeline) Apply(ctx context.Context, lines []Line) ([]Line, error) {
+
+	source := func(emit Emit) error {
+		for _, line := range lines {
+			if err := emit(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	result := []Line{}
+	sink := func(line Line) error {
+		result = append(result, line)
+		return nil
+	}
+
+	if err := p.stream(ctx, source, sink); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Metrics returns the metrics of each stage in the last run, in the order of the stages.
+func (p *Pipeline) Metrics() []Metrics {
+	return append([]Metrics{}, p.metrics...)
+}
+
+// stream sends the lines of source through the stages to sink
+func (p *Pipeline) stream(ctx context.Context, source func(emit Emit) error, sink Emit) error {
+
+	stages := make([]*stage, len(p.transforms))
+	for i, entry := range p.transforms {
+		stages[i] = &stage{
+			transform:   entry.transform,
+			interpreter: newInterpreter(p.blockOptions),
+			metrics:     Metrics{Name: entry.name},
+		}
+	}
+
+	defer func() {
+		p.metrics = make([]Metrics, len(stages))
+		for i, s := range stages {
+			p.metrics[i] = s.metrics
+		}
+	}()
+
+	if p.concurrent {
+		return p.streamConcurrent(ctx, stages, source, sink)
+	}
+
+	return p.streamSync(ctx, stages, source, sink)
+}
+
+// streamSync runs the stages in the goroutine of the caller, each line goes through all the stages before the next one
+func (p *Pipeline) streamSync(ctx context.Context, stages []*stage, source func(emit Emit) error, sink Emit) error {
+
+	next := sink
+	for i := len(stages) - 1; i >= 0; i-- {
+		stages[i].next = next
+		next = stages[i].process
+	}
+
+	err := source(func(line Line) error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("failed to run pipeline: %w", err)
+		}
+		return next(line)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, s := range stages {
+		if err := s.flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// streamConcurrent runs each stage in his own goroutine, connected by channels
+func (p *Pipeline) streamConcurrent(ctx context.Context, stages []*stage, source func(emit Emit) error, sink Emit) error {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var once sync.Once
+	var failure error
+	fail := func(err error) {
+		once.Do(func() {
+			failure = err
+			cancel()
+		})
+	}
+
+	send := func(channel chan<- Line) Emit {
+		return func(line Line) error {
+			select {
+			case channel <- line:
+				return nil
+			case <-ctx.Done():
+				return fmt.Errorf("failed to run pipeline: %w", ctx.Err())
+			}
+		}
+	}
+
+	channels := make([]chan Line, len(stages)+1)
+	for i := range channels {
+		channels[i] = make(chan Line, p.bufferSize)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(stages) + 1)
+
+	go func() {
+		defer wg.Done()
+		defer close(channels[0])
+		if err := source(send(channels[0])); err != nil {
+			fail(err)
+		}
+	}()
+
+	for i, s := range stages {
+		s.next = send(channels[i+1])
+
+		go func(s *stage, in <-chan Line, out chan<- Line) {
+			defer wg.Done()
+			defer close(out)
+
+			for line := range in {
+				if err := s.process(line); err != nil {
+					fail(err)
+					return
+				}
+			}
+
+			// the input ends early if a stage failed
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err := s.flush(); err != nil {
+				fail(err)
+			}
+		}(s, channels[i], channels[i+1])
+	}
+
+	for line := range channels[len(stages)] {
+		if err := sink(line); err != nil {
+			fail(err)
+			break
+		}
+	}
+
+	wg.Wait()
+
+	if failure == nil && ctx.Err() != nil {
+		failure = fmt.Errorf("failed to run pipeline: %w", ctx.Err())
+	}
+
+	return failure
+}
+
+//#endregion
+//#region constructor
+
+// New returns a Pipeline with the transforms of the options, in the order they are added.
+func New(options ...PipelineConfigurationCallbackable) (*Pipeline, error) {
+
+	pipeline := &Pipeline{
+		bufferSize: PIPELINE_BUFFER_SIZE,
+	}
+
+	configurator := &pipelineConfigurator{pipeline: pipeline}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	return pipeline, nil
+}
+
+//#endregion
+//#region private functions
+
+// format writes a block as a line, with his checksum and his comment
+func format(b block.Blocker) string {
+
+	text := b.ToLine("%l %c %p")
+
+	if b.Checksum() != nil {
+		text += b.Checksum().String()
+	}
+
+	if comment := strings.TrimSpace(b.Comment()); comment != "" {
+		if !strings.HasPrefix(comment, ";") {
+			comment = "; " + comment
+		}
+		text += " " + comment
+	}
+
+	return text
+}
+
+//#endregion
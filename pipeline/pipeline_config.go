@@ -0,0 +1,80 @@
+// This file defines a pipelineConfigurator as an object that implement the PipelineConfigurer
+// interface to allow the caller to configure a new Pipeline.
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/mauroalderete/gcode-core/block"
+)
+
+// PipelineConfigurer contains the configurable options that define the stages of a Pipeline and how they run.
+type PipelineConfigurer interface {
+	// Add a stage with the transform at the end of the pipeline
+	AddTransform(name string, transform Transform) error
+
+	// Set if each stage runs in his own goroutine
+	SetConcurrent(concurrent bool) error
+
+	// Set the number of lines that the channels between the concurrent stages store
+	SetBufferSize(size int) error
+
+	// Set the configuration callbacks applied to each block parsed by Run
+	SetBlockOptions(options ...block.BlockParserConfigurationCallbackable) error
+}
+
+// PipelineConfigurationCallbackable is the signature of the callbacks that the New constructor waiting receives to configure the new Pipeline.
+type PipelineConfigurationCallbackable func(config PipelineConfigurer) error
+
+// pipelineConfigurator satisfy PipelineConfigurer, it applies each option directly over the Pipeline in construction.
+type pipelineConfigurator struct {
+	pipeline *Pipeline
+}
+
+// AddTransform adds a stage with the transform at the end of the pipeline. Doesn't accept a nil transform,
+// nor an empty name or a name used by another stage, the name identifies the stage in the errors and the metrics.
+func (pc *pipelineConfigurator) AddTransform(name string, transform Transform) error {
+
+	if transform == nil {
+		return fmt.Errorf("failed set transform '%s', it mustn't be nil", name)
+	}
+
+	if name == "" {
+		return fmt.Errorf("failed set transform, the name mustn't be empty")
+	}
+
+	for _, entry := range pc.pipeline.transforms {
+		if entry.name == name {
+			return fmt.Errorf("failed set transform '%s', the name is already used", name)
+		}
+	}
+
+	pc.pipeline.transforms = append(pc.pipeline.transforms, transformEntry{name: name, transform: transform})
+
+	return nil
+}
+
+// SetConcurrent sets if each stage runs in his own goroutine. By default the stages run in the goroutine of the caller.
+func (pc *pipelineConfigurator) SetConcurrent(concurrent bool) error {
+	pc.pipeline.concurrent = concurrent
+	return nil
+}
+
+// SetBufferSize sets the number of lines that the channels between the concurrent stages store. It mustn't be negative.
+// By default is PIPELINE_BUFFER_SIZE.
+func (pc *pipelineConfigurator) SetBufferSize(size int) error {
+
+	if size < 0 {
+		return fmt.Errorf("failed set buffer size, it mustn't be negative but got %d", size)
+	}
+
+	pc.pipeline.bufferSize = size
+
+	return nil
+}
+
+// SetBlockOptions sets the configuration callbacks applied to each block parsed by Run.
+func (pc *pipelineConfigurator) SetBlockOptions(options ...block.BlockParserConfigurationCallbackable) error {
+	pc.pipeline.blockOptions = options
+	return nil
+}
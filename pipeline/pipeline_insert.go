@@ -0,0 +1,236 @@
+// This file defines the insert transform, that injects a snippet of gcode at the layers, heights, times or commands of the stream.
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mauroalderete/gcode-core/estimator"
+)
+
+const (
+	// INSERT_VARIABLE_LAYER is replaced in the snippets by the index of the layer, the first layer is 0
+	INSERT_VARIABLE_LAYER = "{layer}"
+
+	// INSERT_VARIABLE_Z is replaced in the snippets by the height where the line of the insertion ends
+	INSERT_VARIABLE_Z = "{z}"
+
+	// INSERT_VARIABLE_TIME is replaced in the snippets by the print time estimated until the insertion, like 1h2m3s
+	INSERT_VARIABLE_TIME = "{time}"
+)
+
+// trigger is the kind of event that inserts the snippet
+type trigger int
+
+const (
+	triggerNone trigger = iota
+	triggerLayer
+	triggerHeight
+	triggerEveryLayers
+	triggerEvery
+	triggerBefore
+	triggerAfter
+)
+
+//#region insert struct
+
+// insert injects a snippet when his trigger happens
+type insert struct {
+	lines []string
+
+	// trigger and his argument, only one of them is used
+	trigger     trigger
+	layer       int
+	height      float64
+	everyLayers int
+	every       time.Duration
+	command     string
+
+	// estimatorOptions configure the estimator of the print time
+	estimatorOptions []estimator.EstimatorConfigurationCallbackable
+
+	// fired is true after the insertion of the triggers that happen once, lastLayer is the last layer with insertion
+	fired     bool
+	lastLayer int
+
+	// estimator estimates the print time if the trigger or the snippet need it, next is the time of the next insertion
+	estimator *estimator.Estimator
+	next      time.Duration
+}
+
+func (t *insert) Apply(line Line, state State, emit Emit) error {
+
+	elapsed := t.elapsed()
+	if t.estimator != nil {
+		t.estimator.Add(line.Text, line.Block)
+	}
+
+	if t.trigger == triggerAfter {
+		if err := emit(line); err != nil {
+			return err
+		}
+		if command(line.Text) != t.command {
+			return nil
+		}
+		return t.insert(line, state, t.elapsed(), emit)
+	}
+
+	if t.fires(line, state, elapsed) {
+		if err := t.insert(line, state, elapsed, emit); err != nil {
+			return err
+		}
+	}
+
+	return emit(line)
+}
+
+func (t *insert) Flush(state State, emit Emit) error {
+	return nil
+}
+
+// fires returns true if the snippet must be inserted before the line
+func (t *insert) fires(line Line, state State, elapsed time.Duration) bool {
+
+	switch t.trigger {
+	case triggerLayer:
+		if !t.fired && state.InLayer && state.Layer.Index == t.layer && hasGcode(line) {
+			t.fired = true
+			return true
+		}
+
+	case triggerEveryLayers:
+		index := state.Layer.Index
+		if state.InLayer && index > 0 && index%t.everyLayers == 0 && index != t.lastLayer && hasGcode(line) {
+			t.lastLayer = index
+			return true
+		}
+
+	case triggerHeight:
+		// the first extrusion at the height, the travels and the z hops before it don't count
+		if !t.fired && line.Block != nil && isMove(line) {
+			target := state.Target(line.Block)
+			if target[2] >= t.height-1e-6 && target[3] > state.Position[3] {
+				t.fired = true
+				return true
+			}
+		}
+
+	case triggerEvery:
+		if elapsed >= t.next && hasGcode(line) {
+			t.next = (elapsed/t.every + 1) * t.every
+			return true
+		}
+
+	case triggerBefore:
+		return command(line.Text) == t.command
+	}
+
+	return false
+}
+
+// insert emits the lines of the snippet with the variables replaced
+func (t *insert) insert(line Line, state State, elapsed time.Duration, emit Emit) error {
+
+	z := state.Position[2]
+	if line.Block != nil && isMove(line) {
+		z = state.Target(line.Block)[2]
+	}
+
+	replacer := strings.NewReplacer(
+		INSERT_VARIABLE_LAYER, strconv.Itoa(state.Layer.Index),
+		INSERT_VARIABLE_Z, coordinate(z),
+		INSERT_VARIABLE_TIME, elapsed.Round(time.Second).String(),
+	)
+
+	for _, text := range t.lines {
+		if err := emit(state.ParseLine(0, replacer.Replace(text))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// elapsed returns the print time estimated until the end of the lines received, 0 if the transform doesn't estimate it.
+// The moves still queued in the planner count, so the time doesn't lag behind the current line.
+func (t *insert) elapsed() time.Duration {
+	if t.estimator == nil {
+		return 0
+	}
+	return t.estimator.Planned()
+}
+
+//#endregion
+//#region constructor
+
+// NewInsert returns a transform that inserts the lines of a snippet each time that his trigger happens.
+//
+// The options must set a single trigger: a layer, a height, every some layers, every some print time,
+// or before or after the lines with a command. The lines of the snippet can use the variables
+// INSERT_VARIABLE_LAYER, INSERT_VARIABLE_Z and INSERT_VARIABLE_TIME, that are replaced at each insertion.
+//
+// The print time is estimated with the estimator package only if the trigger or the snippet need it.
+func NewInsert(lines []string, options ...InsertConfigurationCallbackable) (Transform, error) {
+
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("failed to create insert, the snippet mustn't be empty")
+	}
+
+	t := &insert{lines: lines, lastLayer: -1}
+
+	configurator := &insertConfigurator{insert: t}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	if t.trigger == triggerNone {
+		return nil, fmt.Errorf("failed to create insert, it needs a trigger")
+	}
+
+	if t.trigger == triggerEvery || strings.Contains(strings.Join(lines, "\n"), INSERT_VARIABLE_TIME) {
+		e, err := estimator.New(t.estimatorOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create insert: %w", err)
+		}
+		t.estimator = e
+		t.next = t.every
+	}
+
+	return t, nil
+}
+
+//#endregion
+//#region private functions
+
+// isMove returns true if the block of the line is a G0, G1, G2 or G3
+func isMove(line Line) bool {
+	return isCommand(line, 'G', 0) || isCommand(line, 'G', 1) || isCommand(line, 'G', 2) || isCommand(line, 'G', 3)
+}
+
+// command returns the command of a line in upper case, without the zeros that don't change his number,
+// so G01 and g1 are G1. It returns an empty string if the line hasn't gcode.
+func command(text string) string {
+
+	fields := strings.Fields(numbered.ReplaceAllString(strings.TrimSpace(stripComment(text)), ""))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	c := strings.ToUpper(fields[0])
+	if len(c) > 1 && c[0] >= 'A' && c[0] <= 'Z' {
+		if n, err := strconv.ParseFloat(c[1:], 64); err == nil && !math.IsInf(n, 0) && !math.IsNaN(n) {
+			return c[:1] + strconv.FormatFloat(n, 'f', -1, 64)
+		}
+	}
+
+	return c
+}
+
+//#endregion
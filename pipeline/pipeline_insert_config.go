@@ -0,0 +1,157 @@
+// This file defines an insertConfigurator as an object that implement the InsertConfigurer
+// interface to allow the caller to configure a new insert transform.
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mauroalderete/gcode-core/estimator"
+)
+
+// InsertConfigurer contains the configurable options that define when an insert transform injects his snippet.
+// Only one trigger can be set.
+type InsertConfigurer interface {
+	// Set the trigger that inserts the snippet at the beginning of the layer with the index
+	SetLayer(index int) error
+
+	// Set the trigger that inserts the snippet before the first extrusion at the height or above it
+	SetHeight(z float64) error
+
+	// Set the trigger that inserts the snippet at the beginning of each layer multiple of count
+	SetEveryLayers(count int) error
+
+	// Set the trigger that inserts the snippet each time that the print time estimated passes the interval
+	SetEvery(interval time.Duration) error
+
+	// Set the trigger that inserts the snippet before each line with the command
+	SetBefore(command string) error
+
+	// Set the trigger that inserts the snippet after each line with the command
+	SetAfter(command string) error
+
+	// Set the configuration callbacks of the estimator of the print time
+	SetEstimatorOptions(options ...estimator.EstimatorConfigurationCallbackable) error
+}
+
+// InsertConfigurationCallbackable is the signature of the callbacks that the NewInsert constructor waiting receives to configure the new transform.
+type InsertConfigurationCallbackable func(config InsertConfigurer) error
+
+// insertConfigurator satisfy InsertConfigurer, it applies each option directly over the insert transform in construction.
+type insertConfigurator struct {
+	insert *insert
+}
+
+// SetLayer sets the trigger that inserts the snippet before the first line with gcode of the layer with the index.
+// The first layer is 0, the index mustn't be negative.
+func (ic *insertConfigurator) SetLayer(index int) error {
+
+	if index < 0 {
+		return fmt.Errorf("failed set layer, it mustn't be negative but got %d", index)
+	}
+
+	if err := ic.setTrigger(triggerLayer, "layer"); err != nil {
+		return err
+	}
+
+	ic.insert.layer = index
+
+	return nil
+}
+
+// SetHeight sets the trigger that inserts the snippet before the first move that extrudes at the height or above it,
+// the travels and the z hops don't insert it. The height must be positive.
+func (ic *insertConfigurator) SetHeight(z float64) error {
+
+	if z <= 0 {
+		return fmt.Errorf("failed set height, it must be positive but got %g", z)
+	}
+
+	if err := ic.setTrigger(triggerHeight, "height"); err != nil {
+		return err
+	}
+
+	ic.insert.height = z
+
+	return nil
+}
+
+// SetEveryLayers sets the trigger that inserts the snippet at the beginning of the layers count, 2*count, 3*count and so on.
+// The count must be positive.
+func (ic *insertConfigurator) SetEveryLayers(count int) error {
+
+	if count < 1 {
+		return fmt.Errorf("failed set every layers, it must be positive but got %d", count)
+	}
+
+	if err := ic.setTrigger(triggerEveryLayers, "every layers"); err != nil {
+		return err
+	}
+
+	ic.insert.everyLayers = count
+
+	return nil
+}
+
+// SetEvery sets the trigger that inserts the snippet before the first line with gcode each time that the print time estimated
+// passes a multiple of the interval. The interval must be positive.
+func (ic *insertConfigurator) SetEvery(interval time.Duration) error {
+
+	if interval <= 0 {
+		return fmt.Errorf("failed set every, the interval must be positive but got %v", interval)
+	}
+
+	if err := ic.setTrigger(triggerEvery, "every"); err != nil {
+		return err
+	}
+
+	ic.insert.every = interval
+
+	return nil
+}
+
+// SetBefore sets the trigger that inserts the snippet before each line with the command, like M104 or PAUSE.
+// The command is compared without the case and the zeros that don't change his number, G01 matches G1.
+func (ic *insertConfigurator) SetBefore(c string) error {
+	return ic.setCommand(triggerBefore, "before", c)
+}
+
+// SetAfter sets the trigger that inserts the snippet after each line with the command, like SetBefore.
+func (ic *insertConfigurator) SetAfter(c string) error {
+	return ic.setCommand(triggerAfter, "after", c)
+}
+
+// SetEstimatorOptions sets the configuration callbacks of the estimator of the print time, by default it emulates Marlin.
+func (ic *insertConfigurator) SetEstimatorOptions(options ...estimator.EstimatorConfigurationCallbackable) error {
+	ic.insert.estimatorOptions = options
+	return nil
+}
+
+// setCommand sets a trigger of a command
+func (ic *insertConfigurator) setCommand(kind trigger, name string, c string) error {
+
+	normalized := command(c)
+	if normalized == "" {
+		return fmt.Errorf("failed set %s, the command mustn't be empty", name)
+	}
+
+	if err := ic.setTrigger(kind, name); err != nil {
+		return err
+	}
+
+	ic.insert.command = normalized
+
+	return nil
+}
+
+// setTrigger sets the kind of trigger, it fails if the transform has already one
+func (ic *insertConfigurator) setTrigger(kind trigger, name string) error {
+
+	if ic.insert.trigger != triggerNone {
+		return fmt.Errorf("failed set %s, the insert has already a trigger", name)
+	}
+
+	ic.insert.trigger = kind
+
+	return nil
+}
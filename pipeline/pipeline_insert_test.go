@@ -0,0 +1,210 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/checksum"
+	"github.com/mauroalderete/gcode-core/estimator"
+)
+
+func TestInsert(t *testing.T) {
+
+	var cases = map[string]struct {
+		lines  []string
+		option InsertConfigurationCallbackable
+		source string
+		want   string
+	}{
+		"layer": {
+			lines:  []string{"M117 Layer {layer} at {z}"},
+			option: func(config InsertConfigurer) error { return config.SetLayer(1) },
+			source: ";LAYER:0\nG1 Z0.2\nG1 X10 E1\n;LAYER:1\nG1 Z0.4\nG1 X20 E2\n",
+			want:   ";LAYER:0\nG1 Z0.2\nG1 X10 E1\n;LAYER:1\nM117 Layer 1 at 0.4\nG1 Z0.4\nG1 X20 E2\n",
+		},
+		"height": {
+			lines:  []string{"M600", "; at {z}"},
+			option: func(config InsertConfigurer) error { return config.SetHeight(0.4) },
+			source: "G1 Z0.2\nG1 X10 E1\nG1 Z5\nG1 X0\nG1 Z0.4\nG1 X20 E2\nG1 Z0.6\nG1 X30 E3\n",
+			want:   "G1 Z0.2\nG1 X10 E1\nG1 Z5\nG1 X0\nG1 Z0.4\nM600\n; at 0.4\nG1 X20 E2\nG1 Z0.6\nG1 X30 E3\n",
+		},
+		"every layers": {
+			lines:  []string{"M106 S{layer}"},
+			option: func(config InsertConfigurer) error { return config.SetEveryLayers(2) },
+			source: ";LAYER:0\nG1 X1\n;LAYER:1\nG1 X2\n;LAYER:2\n; comment\nG1 X3\nG1 X4\n;LAYER:3\nG1 X5\n;LAYER:4\nG1 X6\n",
+			want:   ";LAYER:0\nG1 X1\n;LAYER:1\nG1 X2\n;LAYER:2\n; comment\nM106 S2\nG1 X3\nG1 X4\n;LAYER:3\nG1 X5\n;LAYER:4\nM106 S4\nG1 X6\n",
+		},
+		"every": {
+			lines:  []string{"M117 {time}"},
+			option: func(config InsertConfigurer) error { return config.SetEvery(2 * time.Minute) },
+			source: "; start\nG4 S60\nG4 S60\nG4 S60\nG4 S60\nG4 S60\n",
+			want:   "; start\nG4 S60\nG4 S60\nM117 2m0s\nG4 S60\nG4 S60\nM117 4m0s\nG4 S60\n",
+		},
+		"every with queued moves": {
+			lines:  []string{"M117 {time}"},
+			option: func(config InsertConfigurer) error { return config.SetEvery(15 * time.Second) },
+			source: "G1 X10 F60\nG1 X20\nG1 X30\n",
+			want:   "G1 X10 F60\nG1 X20\nM117 20s\nG1 X30\n",
+		},
+		"before": {
+			lines:  []string{"M400"},
+			option: func(config InsertConfigurer) error { return config.SetBefore("g01") },
+			source: "G1 X1\nG0 X2\nN3 G01 X3*5 ; move\n; G1 X4\n",
+			want:   "M400\nG1 X1\nG0 X2\nM400\nN3 G01 X3*5 ; move\n; G1 X4\n",
+		},
+		"after": {
+			lines:  []string{"M117 Resumed at layer {layer}"},
+			option: func(config InsertConfigurer) error { return config.SetAfter("PAUSE") },
+			source: ";LAYER:0\nG1 X1\npause ; user\nG1 X2\n",
+			want:   ";LAYER:0\nG1 X1\npause ; user\nM117 Resumed at layer 0\nG1 X2\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			transform, err := NewInsert(tc.lines, tc.option)
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			if got := run(t, transform, tc.source); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInsert_BlockOptions(t *testing.T) {
+
+	sum, err := checksum.Compute(checksum.NewCRC16CCITT(), []byte("N1 G28"))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	transform, err := NewInsert([]string{fmt.Sprintf("N1 G28*%d", sum)}, func(config InsertConfigurer) error { return config.SetBefore("G1") })
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	p, err := New(transforms("insert", transform), func(config PipelineConfigurer) error {
+		return config.SetBlockOptions(func(config block.BlockParserConfigurer) error {
+			return config.SetHash(checksum.NewCRC16CCITT())
+		})
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	lines, err := p.Apply(context.Background(), []Line{ParseLine(1, "G1 X1")})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+	if len(lines) != 2 || lines[0].Block == nil {
+		t.Fatalf("got lines %v, want the snippet parsed before the move", lines)
+	}
+
+	// the snippet is parsed with the hash of the pipeline
+	if valid, err := lines[0].Block.VerifyChecksum(); err != nil || !valid {
+		t.Errorf("got valid %v and error %v, want the checksum of the snippet valid", valid, err)
+	}
+}
+
+func TestNewInsert_Errors(t *testing.T) {
+
+	var cases = map[string]struct {
+		lines   []string
+		options []InsertConfigurationCallbackable
+		message string
+	}{
+		"empty snippet": {
+			options: []InsertConfigurationCallbackable{func(config InsertConfigurer) error { return config.SetLayer(1) }},
+			message: "the snippet mustn't be empty",
+		},
+		"without trigger": {
+			lines:   []string{"M600"},
+			message: "it needs a trigger",
+		},
+		"two triggers": {
+			lines: []string{"M600"},
+			options: []InsertConfigurationCallbackable{
+				func(config InsertConfigurer) error { return config.SetLayer(1) },
+				func(config InsertConfigurer) error { return config.SetBefore("M104") },
+			},
+			message: "failed set before, the insert has already a trigger",
+		},
+		"negative layer": {
+			lines:   []string{"M600"},
+			options: []InsertConfigurationCallbackable{func(config InsertConfigurer) error { return config.SetLayer(-1) }},
+			message: "failed set layer",
+		},
+		"zero height": {
+			lines:   []string{"M600"},
+			options: []InsertConfigurationCallbackable{func(config InsertConfigurer) error { return config.SetHeight(0) }},
+			message: "failed set height",
+		},
+		"zero layers": {
+			lines:   []string{"M600"},
+			options: []InsertConfigurationCallbackable{func(config InsertConfigurer) error { return config.SetEveryLayers(0) }},
+			message: "failed set every layers",
+		},
+		"zero interval": {
+			lines:   []string{"M600"},
+			options: []InsertConfigurationCallbackable{func(config InsertConfigurer) error { return config.SetEvery(0) }},
+			message: "failed set every",
+		},
+		"empty command": {
+			lines:   []string{"M600"},
+			options: []InsertConfigurationCallbackable{func(config InsertConfigurer) error { return config.SetAfter(" ; comment") }},
+			message: "failed set after, the command mustn't be empty",
+		},
+		"estimator": {
+			lines: []string{"M117 {time}"},
+			options: []InsertConfigurationCallbackable{
+				func(config InsertConfigurer) error { return config.SetLayer(1) },
+				func(config InsertConfigurer) error {
+					return config.SetEstimatorOptions(func(config estimator.EstimatorConfigurer) error {
+						return config.SetBufferSize(0)
+					})
+				},
+			},
+			message: "failed to create insert",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewInsert(tc.lines, tc.options...)
+			if err == nil {
+				t.Fatalf("got nil error, want error")
+			}
+			if !strings.Contains(err.Error(), tc.message) {
+				t.Errorf("got error '%v', want it containing '%s'", err, tc.message)
+			}
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+
+	var cases = map[string]string{
+		"G1 X10":            "G1",
+		"g01 x10":           "G1",
+		"N10 G1.0 X10*33":   "G1",
+		"M600 ; change":     "M600",
+		"PAUSE":             "PAUSE",
+		"set_fan_speed S=1": "SET_FAN_SPEED",
+		"; comment":         "",
+		"":                  "",
+	}
+
+	for text, want := range cases {
+		if got := command(text); got != want {
+			t.Errorf("got command %q of %q, want %q", got, text, want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// This file defines the pause transform, that pauses the print at a layer or a height to change the filament or insert parts.
+package pipeline
+
+import (
+	"fmt"
+)
+
+// PauseMode is how the pause transform pauses the machine.
+type PauseMode string
+
+const (
+	// PAUSE_MODE_FILAMENT_CHANGE pauses with a M600, the firmware parks, waits and returns by itself
+	PAUSE_MODE_FILAMENT_CHANGE PauseMode = "m600"
+
+	// PAUSE_MODE_MANUAL pauses with a sequence of moves around the pause command, for the firmwares without M600
+	PAUSE_MODE_MANUAL PauseMode = "manual"
+)
+
+const (
+	// PAUSE_RETRACT is the length in mm retracted before parking by default
+	PAUSE_RETRACT = 2.0
+
+	// PAUSE_LIFT is the distance in mm that the nozzle is lifted before parking by default
+	PAUSE_LIFT = 5.0
+
+	// PAUSE_TRAVEL_FEEDRATE is the feedrate in mm/min of the moves to the park position and back by default
+	PAUSE_TRAVEL_FEEDRATE = 3000.0
+
+	// PAUSE_RETRACT_FEEDRATE is the feedrate in mm/min of the retraction and the prime by default
+	PAUSE_RETRACT_FEEDRATE = 1800.0
+
+	// PAUSE_COMMAND is the command that pauses the manual sequence by default
+	PAUSE_COMMAND = "M0"
+)
+
+//#region pause struct
+
+// pause inserts a pause sequence when his trigger happens
+type pause struct {
+	// trigger decides where the pause is inserted, his snippet isn't used
+	trigger *insert
+
+	mode PauseMode
+
+	// park position and lift of the nozzle, retract and prime lengths, feedrates and temperature of the manual sequence
+	park            [2]float64
+	lift            float64
+	retract         float64
+	prime           float64
+	travelFeedrate  float64
+	retractFeedrate float64
+	temperature     float64
+	command         string
+}
+
+func (t *pause) Apply(line Line, state State, emit Emit) error {
+
+	if t.trigger.fires(line, state, 0) {
+		for _, text := range t.sequence(state) {
+			if err := emit(state.ParseLine(0, text)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return emit(line)
+}
+
+func (t *pause) Flush(state State, emit Emit) error {
+	return nil
+}
+
+// sequence returns the lines that pause the machine at the state and restore it after the pause
+func (t *pause) sequence(state State) []string {
+
+	lines := []string{fmt.Sprintf("; pause at layer %d", t.trigger.layer)}
+	if t.trigger.trigger == triggerHeight {
+		lines[0] = fmt.Sprintf("; pause at height %s", coordinate(t.trigger.height))
+	}
+
+	if t.mode == PAUSE_MODE_FILAMENT_CHANGE {
+		return append(lines, "M600")
+	}
+
+	// the lengths and the feedrates are in mm, they are written in the units of the file
+	mm := func(v float64) string { return coordinate(v / state.Scale()) }
+
+	lines = append(lines,
+		"M83",
+		fmt.Sprintf("G1 E-%s F%s", mm(t.retract), mm(t.retractFeedrate)),
+		"G90",
+		fmt.Sprintf("G1 Z%s F%s", coordinate(state.Position[2]+t.lift/state.Scale()), mm(t.travelFeedrate)),
+		fmt.Sprintf("G1 X%s Y%s", coordinate(t.park[0]), coordinate(t.park[1])),
+		t.command,
+	)
+
+	if t.temperature > 0 {
+		lines = append(lines, fmt.Sprintf("M109 S%s", coordinate(t.temperature)))
+	}
+
+	lines = append(lines,
+		fmt.Sprintf("G1 E%s F%s", mm(t.prime), mm(t.retractFeedrate)),
+		fmt.Sprintf("G1 X%s Y%s F%s", coordinate(state.Position[0]), coordinate(state.Position[1]), mm(t.travelFeedrate)),
+		fmt.Sprintf("G1 Z%s", coordinate(state.Position[2])),
+	)
+
+	// the sequence ends in the modes of the file, with the position of the extruder that the file expects
+	if state.Relative {
+		lines = append(lines, "G91")
+	}
+
+	if !state.RelativeE {
+		lines = append(lines, "M82", fmt.Sprintf("G92 E%s", coordinate(state.Position[3])))
+	}
+
+	if state.Feedrate > 0 {
+		lines = append(lines, fmt.Sprintf("G1 F%s", coordinate(state.Feedrate)))
+	}
+
+	return lines
+}
+
+//#endregion
+//#region constructor
+
+// NewPause returns a transform that pauses the print at a layer or at a height, to change the filament or to insert parts like magnets.
+//
+// The options must set a single trigger, the layer or the height, they work like the triggers of NewInsert.
+// By default the pause is a M600. The manual mode retracts, lifts the nozzle and parks it, pauses with the pause command,
+// optionally heats the nozzle again, primes and returns. The interpreter of the stage knows the position, the positioning
+// and extrusion modes and the feedrate before the pause, so the sequence restores them at the end.
+// The lengths and the feedrates in mm are written in the units of the file, so the sequence works after a G20 too.
+func NewPause(options ...PauseConfigurationCallbackable) (Transform, error) {
+
+	t := &pause{
+		trigger:         &insert{lastLayer: -1},
+		mode:            PAUSE_MODE_FILAMENT_CHANGE,
+		lift:            PAUSE_LIFT,
+		retract:         PAUSE_RETRACT,
+		prime:           PAUSE_RETRACT,
+		travelFeedrate:  PAUSE_TRAVEL_FEEDRATE,
+		retractFeedrate: PAUSE_RETRACT_FEEDRATE,
+		command:         PAUSE_COMMAND,
+	}
+
+	configurator := &pauseConfigurator{pause: t, trigger: &insertConfigurator{insert: t.trigger}}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	if t.trigger.trigger == triggerNone {
+		return nil, fmt.Errorf("failed to create pause, it needs a layer or a height")
+	}
+
+	return t, nil
+}
+
+//#endregion
@@ -0,0 +1,148 @@
+// This file defines a pauseConfigurator as an object that implement the PauseConfigurer
+// interface to allow the caller to configure a new pause transform.
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PauseConfigurer contains the configurable options that define where a pause transform pauses the print and how.
+type PauseConfigurer interface {
+	// Set the trigger that pauses at the beginning of the layer with the index
+	SetLayer(index int) error
+
+	// Set the trigger that pauses before the first extrusion at the height or above it
+	SetHeight(z float64) error
+
+	// Set how the machine is paused
+	SetMode(mode PauseMode) error
+
+	// Set the position where the nozzle is parked in the manual mode
+	SetPark(x float64, y float64) error
+
+	// Set the distance that the nozzle is lifted in the manual mode
+	SetLift(distance float64) error
+
+	// Set the length retracted before parking and the length primed after the pause in the manual mode
+	SetRetract(retract float64, prime float64) error
+
+	// Set the feedrates of the travels and of the retraction in the manual mode
+	SetFeedrates(travel float64, retract float64) error
+
+	// Set the temperature that the nozzle is heated to after the pause in the manual mode
+	SetTemperature(celsius float64) error
+
+	// Set the command that pauses the machine in the manual mode
+	SetPauseCommand(command string) error
+}
+
+// PauseConfigurationCallbackable is the signature of the callbacks that the NewPause constructor waiting receives to configure the new transform.
+type PauseConfigurationCallbackable func(config PauseConfigurer) error
+
+// pauseConfigurator satisfy PauseConfigurer, it applies each option directly over the pause transform in construction.
+type pauseConfigurator struct {
+	pause *pause
+
+	// trigger configures the trigger of the pause
+	trigger *insertConfigurator
+}
+
+// SetLayer sets the trigger that pauses before the first line with gcode of the layer with the index.
+// The first layer is 0, the index mustn't be negative.
+func (pc *pauseConfigurator) SetLayer(index int) error {
+	return pc.trigger.SetLayer(index)
+}
+
+// SetHeight sets the trigger that pauses before the first move that extrudes at the height or above it,
+// the travels and the z hops don't pause. The height must be positive.
+func (pc *pauseConfigurator) SetHeight(z float64) error {
+	return pc.trigger.SetHeight(z)
+}
+
+// SetMode sets how the machine is paused, by default is PAUSE_MODE_FILAMENT_CHANGE.
+func (pc *pauseConfigurator) SetMode(mode PauseMode) error {
+
+	switch mode {
+	case PAUSE_MODE_FILAMENT_CHANGE, PAUSE_MODE_MANUAL:
+	default:
+		return fmt.Errorf("failed set mode, '%s' isn't supported", mode)
+	}
+
+	pc.pause.mode = mode
+
+	return nil
+}
+
+// SetPark sets the position where the nozzle is parked in the manual mode, in the units of the file. By default is 0,0.
+func (pc *pauseConfigurator) SetPark(x float64, y float64) error {
+	pc.pause.park = [2]float64{x, y}
+	return nil
+}
+
+// SetLift sets the distance in mm that the nozzle is lifted over the print before parking in the manual mode.
+// It mustn't be negative, by default is PAUSE_LIFT.
+func (pc *pauseConfigurator) SetLift(distance float64) error {
+
+	if distance < 0 {
+		return fmt.Errorf("failed set lift, it mustn't be negative but got %g", distance)
+	}
+
+	pc.pause.lift = distance
+
+	return nil
+}
+
+// SetRetract sets the length in mm retracted before parking and the length primed after the pause in the manual mode.
+// A prime longer than the retract purges the rest, to clean the nozzle after a color change.
+// They mustn't be negative, by default both are PAUSE_RETRACT.
+func (pc *pauseConfigurator) SetRetract(retract float64, prime float64) error {
+
+	if retract < 0 || prime < 0 {
+		return fmt.Errorf("failed set retract, the lengths mustn't be negative but got %g and %g", retract, prime)
+	}
+
+	pc.pause.retract = retract
+	pc.pause.prime = prime
+
+	return nil
+}
+
+// SetFeedrates sets the feedrates in mm/min of the travels and of the retraction and prime in the manual mode.
+// They must be positive, by default are PAUSE_TRAVEL_FEEDRATE and PAUSE_RETRACT_FEEDRATE.
+func (pc *pauseConfigurator) SetFeedrates(travel float64, retract float64) error {
+
+	if travel <= 0 || retract <= 0 {
+		return fmt.Errorf("failed set feedrates, they must be positive but got %g and %g", travel, retract)
+	}
+
+	pc.pause.travelFeedrate = travel
+	pc.pause.retractFeedrate = retract
+
+	return nil
+}
+
+// SetTemperature sets the temperature that the nozzle is heated to, waiting for it, after the pause in the manual mode.
+// Useful if the firmware cools the nozzle during long pauses. It mustn't be negative, 0 doesn't heat and is the default.
+func (pc *pauseConfigurator) SetTemperature(celsius float64) error {
+
+	if celsius < 0 {
+		return fmt.Errorf("failed set temperature, it mustn't be negative but got %g", celsius)
+	}
+
+	pc.pause.temperature = celsius
+
+	return nil
+}
+
+// SetPauseCommand sets the command that pauses the machine in the manual mode, like M25 or PAUSE. By default is PAUSE_COMMAND.
+func (pc *pauseConfigurator) SetPauseCommand(command string) error {
+
+	if strings.TrimSpace(stripComment(command)) == "" {
+		return fmt.Errorf("failed set pause command, it mustn't be empty")
+	}
+
+	pc.pause.command = strings.TrimSpace(command)
+
+	return nil
+}
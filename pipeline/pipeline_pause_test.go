@@ -0,0 +1,139 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPause(t *testing.T) {
+
+	const source = ";LAYER:0\nM82\nG1 Z0.2 F600\nG1 X10 Y20 E1 F1800\n;LAYER:1\nG1 Z0.4\nG1 X30 Y20 E2\n"
+
+	var cases = map[string]struct {
+		options []PauseConfigurationCallbackable
+		source  string
+		want    string
+	}{
+		"filament change": {
+			options: []PauseConfigurationCallbackable{
+				func(config PauseConfigurer) error { return config.SetLayer(1) },
+			},
+			source: source,
+			want:   ";LAYER:0\nM82\nG1 Z0.2 F600\nG1 X10 Y20 E1 F1800\n;LAYER:1\n; pause at layer 1\nM600\nG1 Z0.4\nG1 X30 Y20 E2\n",
+		},
+		"manual in absolute extrusion": {
+			options: []PauseConfigurationCallbackable{
+				func(config PauseConfigurer) error { return config.SetLayer(1) },
+				func(config PauseConfigurer) error { return config.SetMode(PAUSE_MODE_MANUAL) },
+				func(config PauseConfigurer) error { return config.SetPark(0, 200) },
+				func(config PauseConfigurer) error { return config.SetRetract(1, 3) },
+				func(config PauseConfigurer) error { return config.SetTemperature(215) },
+				func(config PauseConfigurer) error { return config.SetPauseCommand("M25") },
+			},
+			source: source,
+			want: ";LAYER:0\nM82\nG1 Z0.2 F600\nG1 X10 Y20 E1 F1800\n;LAYER:1\n; pause at layer 1\n" +
+				"M83\nG1 E-1 F1800\nG90\nG1 Z5.2 F3000\nG1 X0 Y200\nM25\nM109 S215\nG1 E3 F1800\nG1 X10 Y20 F3000\nG1 Z0.2\nM82\nG92 E1\nG1 F1800\n" +
+				"G1 Z0.4\nG1 X30 Y20 E2\n",
+		},
+		"manual at height in relative modes": {
+			options: []PauseConfigurationCallbackable{
+				func(config PauseConfigurer) error { return config.SetHeight(0.4) },
+				func(config PauseConfigurer) error { return config.SetMode(PAUSE_MODE_MANUAL) },
+				func(config PauseConfigurer) error { return config.SetLift(2) },
+				func(config PauseConfigurer) error { return config.SetFeedrates(6000, 2400) },
+			},
+			source: "M83\nG1 Z0.2 F600\nG1 X10 E1 F1500\nG91\nG1 Z0.2\nG1 X5 E1\n",
+			want: "M83\nG1 Z0.2 F600\nG1 X10 E1 F1500\nG91\nG1 Z0.2\n; pause at height 0.4\n" +
+				"M83\nG1 E-2 F2400\nG90\nG1 Z2.4 F6000\nG1 X0 Y0\nM0\nG1 E2 F2400\nG1 X10 Y0 F6000\nG1 Z0.4\nG91\nG1 F1500\n" +
+				"G1 X5 E1\n",
+		},
+		"manual in relative positioning and absolute extrusion": {
+			options: []PauseConfigurationCallbackable{
+				func(config PauseConfigurer) error { return config.SetLayer(1) },
+				func(config PauseConfigurer) error { return config.SetMode(PAUSE_MODE_MANUAL) },
+			},
+			source: ";LAYER:0\nG91\nM82\nG1 Z0.2 F600\nG1 X10 Y20 E1 F1800\n;LAYER:1\nG1 Z0.2\nG1 X20 E1\n",
+			want: ";LAYER:0\nG91\nM82\nG1 Z0.2 F600\nG1 X10 Y20 E1 F1800\n;LAYER:1\n; pause at layer 1\n" +
+				"M83\nG1 E-2 F1800\nG90\nG1 Z5.2 F3000\nG1 X0 Y0\nM0\nG1 E2 F1800\nG1 X10 Y20 F3000\nG1 Z0.2\nG91\nM82\nG92 E1\nG1 F1800\n" +
+				"G1 Z0.2\nG1 X20 E1\n",
+		},
+		"manual in inches": {
+			options: []PauseConfigurationCallbackable{
+				func(config PauseConfigurer) error { return config.SetLayer(1) },
+				func(config PauseConfigurer) error { return config.SetMode(PAUSE_MODE_MANUAL) },
+			},
+			source: ";LAYER:0\nG20\nM82\nG1 Z0.01 F30\nG1 X1 Y2 E0.1 F60\n;LAYER:1\nG1 Z0.02\n",
+			want: ";LAYER:0\nG20\nM82\nG1 Z0.01 F30\nG1 X1 Y2 E0.1 F60\n;LAYER:1\n; pause at layer 1\n" +
+				"M83\nG1 E-0.079 F70.866\nG90\nG1 Z0.207 F118.11\nG1 X0 Y0\nM0\nG1 E0.079 F70.866\nG1 X1 Y2 F118.11\nG1 Z0.01\nM82\nG92 E0.1\nG1 F60\n" +
+				"G1 Z0.02\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			transform, err := NewPause(tc.options...)
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			if got := run(t, transform, tc.source); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewPause_Errors(t *testing.T) {
+
+	layer := func(config PauseConfigurer) error { return config.SetLayer(1) }
+
+	var cases = map[string]struct {
+		options []PauseConfigurationCallbackable
+		message string
+	}{
+		"without trigger": {
+			message: "it needs a layer or a height",
+		},
+		"two triggers": {
+			options: []PauseConfigurationCallbackable{layer, func(config PauseConfigurer) error { return config.SetHeight(2) }},
+			message: "the insert has already a trigger",
+		},
+		"mode": {
+			options: []PauseConfigurationCallbackable{layer, func(config PauseConfigurer) error { return config.SetMode("stop") }},
+			message: "failed set mode, 'stop' isn't supported",
+		},
+		"lift": {
+			options: []PauseConfigurationCallbackable{layer, func(config PauseConfigurer) error { return config.SetLift(-1) }},
+			message: "failed set lift",
+		},
+		"retract": {
+			options: []PauseConfigurationCallbackable{layer, func(config PauseConfigurer) error { return config.SetRetract(1, -1) }},
+			message: "failed set retract",
+		},
+		"feedrates": {
+			options: []PauseConfigurationCallbackable{layer, func(config PauseConfigurer) error { return config.SetFeedrates(0, 1800) }},
+			message: "failed set feedrates",
+		},
+		"temperature": {
+			options: []PauseConfigurationCallbackable{layer, func(config PauseConfigurer) error { return config.SetTemperature(-1) }},
+			message: "failed set temperature",
+		},
+		"pause command": {
+			options: []PauseConfigurationCallbackable{layer, func(config PauseConfigurer) error { return config.SetPauseCommand(" ") }},
+			message: "failed set pause command",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewPause(tc.options...)
+			if err == nil {
+				t.Fatalf("got nil error, want error")
+			}
+			if !strings.Contains(err.Error(), tc.message) {
+				t.Errorf("got error '%v', want it containing '%s'", err, tc.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,466 @@
+// This file defines the Registry that creates the transforms by name, to load a Pipeline from a JSON configuration.
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mauroalderete/gcode-core/checksum"
+)
+
+const (
+	// TRANSFORM_TRANSLATE is the name of the transform created by NewTranslate
+	TRANSFORM_TRANSLATE = "translate"
+
+	// TRANSFORM_INSERT_AT_LAYER is the name of the transform created by NewInsertAtLayer
+	TRANSFORM_INSERT_AT_LAYER = "insert_at_layer"
+
+	// TRANSFORM_INSERT is the name of the transform created by NewInsert
+	TRANSFORM_INSERT = "insert"
+
+	// TRANSFORM_PAUSE is the name of the transform created by NewPause
+	TRANSFORM_PAUSE = "pause"
+
+	// TRANSFORM_SWEEP is the name of the transform created by NewSweep
+	TRANSFORM_SWEEP = "sweep"
+
+	// TRANSFORM_RENUMBER is the name of the transform created by NewRenumber
+	TRANSFORM_RENUMBER = "renumber"
+
+	// TRANSFORM_CHECKSUM is the name of the transform created by NewChecksum
+	TRANSFORM_CHECKSUM = "checksum"
+)
+
+//#region registry struct
+
+// TransformFactory creates a transform from his parameters in JSON.
+// The parameters are the empty object if the configuration doesn't set them.
+type TransformFactory func(parameters json.RawMessage) (Transform, error)
+
+// Registry stores the factories of the transforms by name.
+//
+// A configuration is a JSON array of steps, each step is an object with a single key, the name of the transform,
+// and his parameters as value:
+//
+//	[{"translate": {"x": 10}}, {"insert_at_layer": {"layer": 20, "gcode": "M600"}}, {"renumber": {}}, {"checksum": {}}]
+//
+// The zero value is an empty Registry ready to use, NewRegistry returns one with the built-in transforms.
+type Registry struct {
+	factories map[string]TransformFactory
+}
+
+// Register adds the factory of a transform. Doesn't accept a nil factory, nor an empty name or a name already registered.
+func (r *Registry) Register(name string, factory TransformFactory) error {
+
+	if factory == nil {
+		return fmt.Errorf("failed to register transform '%s', the factory mustn't be nil", name)
+	}
+
+	if name == "" {
+		return fmt.Errorf("failed to register transform, the name mustn't be empty")
+	}
+
+	if _, ok := r.factories[name]; ok {
+		return fmt.Errorf("failed to register transform '%s', the name is already registered", name)
+	}
+
+	if r.factories == nil {
+		r.factories = map[string]TransformFactory{}
+	}
+	r.factories[name] = factory
+
+	return nil
+}
+
+// Names returns the names of the transforms registered, sorted.
+func (r *Registry) Names() []string {
+
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Create returns a new transform of the name with the parameters.
+func (r *Registry) Create(name string, parameters json.RawMessage) (Transform, error) {
+
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("failed to create transform '%s', it isn't registered, the transforms available are: %s", name, strings.Join(r.Names(), ", "))
+	}
+
+	if len(bytes.TrimSpace(parameters)) == 0 || bytes.Equal(bytes.TrimSpace(parameters), []byte("null")) {
+		parameters = json.RawMessage("{}")
+	}
+
+	transform, err := factory(parameters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transform '%s': %w", name, err)
+	}
+
+	if transform == nil {
+		return nil, fmt.Errorf("failed to create transform '%s', the factory returned a nil transform", name)
+	}
+
+	return transform, nil
+}
+
+// Load reads a configuration and returns a Pipeline with a stage for each step, in the same order.
+//
+// The stages are named as the transforms, the second stage of the same transform is named with the suffix " #2" and so on.
+// The options are applied after the stages of the configuration, so they can add more stages or set how the pipeline runs.
+func (r *Registry) Load(reader io.Reader, options ...PipelineConfigurationCallbackable) (*Pipeline, error) {
+
+	decoder := json.NewDecoder(reader)
+
+	var steps []map[string]json.RawMessage
+	if err := decoder.Decode(&steps); err != nil {
+		return nil, fmt.Errorf("failed to load pipeline, the configuration must be an array of steps: %w", err)
+	}
+
+	if decoder.More() {
+		return nil, fmt.Errorf("failed to load pipeline, the configuration has data after the array of steps")
+	}
+
+	names := make([]string, len(steps))
+	transforms := make([]Transform, len(steps))
+	used := map[string]int{}
+
+	for i, step := range steps {
+
+		if len(step) != 1 {
+			return nil, fmt.Errorf("failed to load pipeline, the step %d must have a single transform but has %d", i+1, len(step))
+		}
+
+		for name, parameters := range step {
+			transform, err := r.Create(name, parameters)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load pipeline, the step %d is invalid: %w", i+1, err)
+			}
+
+			used[name]++
+			names[i] = name
+			if used[name] > 1 {
+				names[i] = fmt.Sprintf("%s #%d", name, used[name])
+			}
+			transforms[i] = transform
+		}
+	}
+
+	stages := func(config PipelineConfigurer) error {
+		for i := range transforms {
+			if err := config.AddTransform(names[i], transforms[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return New(append([]PipelineConfigurationCallbackable{stages}, options...)...)
+}
+
+//#endregion
+//#region constructor
+
+// NewRegistry returns a Registry with the built-in transforms: translate, insert_at_layer, insert, pause, sweep, renumber and checksum.
+func NewRegistry() *Registry {
+
+	registry := &Registry{factories: map[string]TransformFactory{}}
+
+	registry.factories[TRANSFORM_TRANSLATE] = newTranslate
+	registry.factories[TRANSFORM_INSERT_AT_LAYER] = newInsertAtLayer
+	registry.factories[TRANSFORM_INSERT] = newInsert
+	registry.factories[TRANSFORM_PAUSE] = newPause
+	registry.factories[TRANSFORM_SWEEP] = newSweep
+	registry.factories[TRANSFORM_RENUMBER] = newRenumber
+	registry.factories[TRANSFORM_CHECKSUM] = newChecksum
+
+	return registry
+}
+
+// Load reads a configuration with the built-in transforms, like NewRegistry().Load.
+func Load(reader io.Reader, options ...PipelineConfigurationCallbackable) (*Pipeline, error) {
+	return NewRegistry().Load(reader, options...)
+}
+
+// DecodeParameters decodes the parameters of a transform in v, the fields that v hasn't are rejected.
+// It helps the factories of the applications to validate his parameters like the built-in ones.
+func DecodeParameters(parameters json.RawMessage, v interface{}) error {
+
+	decoder := json.NewDecoder(bytes.NewReader(parameters))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("failed to decode parameters: %w", err)
+	}
+
+	return nil
+}
+
+//#endregion
+//#region built-in factories
+
+// newTranslate creates a translate transform from {"x": 10, "y": 0, "z": 0}
+func newTranslate(parameters json.RawMessage) (Transform, error) {
+
+	var p struct {
+		X float64 `json:"x"`
+		Y float64 `json:"y"`
+		Z float64 `json:"z"`
+	}
+	if err := DecodeParameters(parameters, &p); err != nil {
+		return nil, err
+	}
+
+	return NewTranslate([3]float64{p.X, p.Y, p.Z}), nil
+}
+
+// newInsertAtLayer creates an insert at layer transform from {"layer": 20, "gcode": "M600"}, the gcode can have several lines
+func newInsertAtLayer(parameters json.RawMessage) (Transform, error) {
+
+	var p struct {
+		Layer *int   `json:"layer"`
+		Gcode string `json:"gcode"`
+	}
+	if err := DecodeParameters(parameters, &p); err != nil {
+		return nil, err
+	}
+
+	if p.Layer == nil {
+		return nil, fmt.Errorf("the parameter 'layer' is required")
+	}
+
+	if *p.Layer < 0 {
+		return nil, fmt.Errorf("the parameter 'layer' mustn't be negative but got %d", *p.Layer)
+	}
+
+	if strings.TrimSpace(p.Gcode) == "" {
+		return nil, fmt.Errorf("the parameter 'gcode' is required")
+	}
+
+	return NewInsertAtLayer(*p.Layer, strings.Split(strings.TrimRight(p.Gcode, "\n"), "\n")), nil
+}
+
+// newInsert creates an insert transform from {"gcode": "M600", "layer": 20}, with a single trigger of
+// layer, height, every_layers, every_minutes, before and after
+func newInsert(parameters json.RawMessage) (Transform, error) {
+
+	var p struct {
+		Gcode        string   `json:"gcode"`
+		Layer        *int     `json:"layer"`
+		Height       *float64 `json:"height"`
+		EveryLayers  *int     `json:"every_layers"`
+		EveryMinutes *float64 `json:"every_minutes"`
+		Before       *string  `json:"before"`
+		After        *string  `json:"after"`
+	}
+	if err := DecodeParameters(parameters, &p); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(p.Gcode) == "" {
+		return nil, fmt.Errorf("the parameter 'gcode' is required")
+	}
+
+	return NewInsert(strings.Split(strings.TrimRight(p.Gcode, "\n"), "\n"), func(config InsertConfigurer) error {
+		var triggers []func() error
+		if p.Layer != nil {
+			triggers = append(triggers, func() error { return config.SetLayer(*p.Layer) })
+		}
+		if p.Height != nil {
+			triggers = append(triggers, func() error { return config.SetHeight(*p.Height) })
+		}
+		if p.EveryLayers != nil {
+			triggers = append(triggers, func() error { return config.SetEveryLayers(*p.EveryLayers) })
+		}
+		if p.EveryMinutes != nil {
+			triggers = append(triggers, func() error { return config.SetEvery(time.Duration(*p.EveryMinutes * float64(time.Minute))) })
+		}
+		if p.Before != nil {
+			triggers = append(triggers, func() error { return config.SetBefore(*p.Before) })
+		}
+		if p.After != nil {
+			triggers = append(triggers, func() error { return config.SetAfter(*p.After) })
+		}
+
+		if len(triggers) != 1 {
+			return fmt.Errorf("a single parameter of 'layer', 'height', 'every_layers', 'every_minutes', 'before' and 'after' is required but got %d", len(triggers))
+		}
+
+		return triggers[0]()
+	})
+}
+
+// newPause creates a pause transform from {"layer": 20} or {"height": 5.2, "mode": "manual", "park_x": 0, "park_y": 200},
+// the parameters that aren't set keep the defaults of NewPause
+func newPause(parameters json.RawMessage) (Transform, error) {
+
+	var p struct {
+		Layer           *int       `json:"layer"`
+		Height          *float64   `json:"height"`
+		Mode            *PauseMode `json:"mode"`
+		ParkX           float64    `json:"park_x"`
+		ParkY           float64    `json:"park_y"`
+		Lift            *float64   `json:"lift"`
+		Retract         *float64   `json:"retract"`
+		Prime           *float64   `json:"prime"`
+		TravelFeedrate  *float64   `json:"travel_feedrate"`
+		RetractFeedrate *float64   `json:"retract_feedrate"`
+		Temperature     float64    `json:"temperature"`
+		Command         *string    `json:"command"`
+	}
+	if err := DecodeParameters(parameters, &p); err != nil {
+		return nil, err
+	}
+
+	if (p.Layer == nil) == (p.Height == nil) {
+		return nil, fmt.Errorf("a single parameter of 'layer' and 'height' is required")
+	}
+
+	return NewPause(func(config PauseConfigurer) error {
+
+		if p.Layer != nil {
+			if err := config.SetLayer(*p.Layer); err != nil {
+				return err
+			}
+		} else if err := config.SetHeight(*p.Height); err != nil {
+			return err
+		}
+
+		if p.Mode != nil {
+			if err := config.SetMode(*p.Mode); err != nil {
+				return err
+			}
+		}
+
+		if err := config.SetPark(p.ParkX, p.ParkY); err != nil {
+			return err
+		}
+
+		if p.Lift != nil {
+			if err := config.SetLift(*p.Lift); err != nil {
+				return err
+			}
+		}
+
+		if p.Retract != nil || p.Prime != nil {
+			retract, prime := PAUSE_RETRACT, PAUSE_RETRACT
+			if p.Retract != nil {
+				retract, prime = *p.Retract, *p.Retract
+			}
+			if p.Prime != nil {
+				prime = *p.Prime
+			}
+			if err := config.SetRetract(retract, prime); err != nil {
+				return err
+			}
+		}
+
+		if p.TravelFeedrate != nil || p.RetractFeedrate != nil {
+			travel, retract := PAUSE_TRAVEL_FEEDRATE, PAUSE_RETRACT_FEEDRATE
+			if p.TravelFeedrate != nil {
+				travel = *p.TravelFeedrate
+			}
+			if p.RetractFeedrate != nil {
+				retract = *p.RetractFeedrate
+			}
+			if err := config.SetFeedrates(travel, retract); err != nil {
+				return err
+			}
+		}
+
+		if err := config.SetTemperature(p.Temperature); err != nil {
+			return err
+		}
+
+		if p.Command != nil {
+			return config.SetPauseCommand(*p.Command)
+		}
+
+		return nil
+	})
+}
+
+// newSweep creates a sweep transform from {"parameter": "temperature", "start": 230, "step": -5, "band_height": 10},
+// with the optional base and bands
+func newSweep(parameters json.RawMessage) (Transform, error) {
+
+	var p struct {
+		Parameter  SweepParameter `json:"parameter"`
+		Start      *float64       `json:"start"`
+		Step       *float64       `json:"step"`
+		BandHeight *float64       `json:"band_height"`
+		Base       float64        `json:"base"`
+		Bands      *int           `json:"bands"`
+	}
+	if err := DecodeParameters(parameters, &p); err != nil {
+		return nil, err
+	}
+
+	// the parameters are checked in order, so the error always reports the first one missing
+	required := []struct {
+		name  string
+		value *float64
+	}{{"start", p.Start}, {"step", p.Step}, {"band_height", p.BandHeight}}
+
+	for _, parameter := range required {
+		if parameter.value == nil {
+			return nil, fmt.Errorf("the parameter '%s' is required", parameter.name)
+		}
+	}
+
+	return NewSweep(p.Parameter, *p.Start, *p.Step, *p.BandHeight, func(config SweepConfigurer) error {
+		if err := config.SetBase(p.Base); err != nil {
+			return err
+		}
+		if p.Bands != nil {
+			return config.SetBands(*p.Bands)
+		}
+		return nil
+	})
+}
+
+// newRenumber creates a renumber transform from {"start": 1}, the lines are numbered from 1 by default
+func newRenumber(parameters json.RawMessage) (Transform, error) {
+
+	p := struct {
+		Start int `json:"start"`
+	}{Start: 1}
+	if err := DecodeParameters(parameters, &p); err != nil {
+		return nil, err
+	}
+
+	if p.Start < 0 {
+		return nil, fmt.Errorf("the parameter 'start' mustn't be negative but got %d", p.Start)
+	}
+
+	return NewRenumber(p.Start), nil
+}
+
+// newChecksum creates a checksum transform from {"algorithm": "xor"}, the algorithm is xor by default
+func newChecksum(parameters json.RawMessage) (Transform, error) {
+
+	p := struct {
+		Algorithm checksum.Algorithm `json:"algorithm"`
+	}{Algorithm: checksum.ALGORITHM_XOR}
+	if err := DecodeParameters(parameters, &p); err != nil {
+		return nil, err
+	}
+
+	factory, err := checksum.NewFactory(p.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("the parameter 'algorithm' is invalid: %w", err)
+	}
+
+	return NewChecksum(factory), nil
+}
+
+//#endregion
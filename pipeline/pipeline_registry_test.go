@@ -0,0 +1,320 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+
+	const config = `[{"translate": {"x": 10}}, {"insert_at_layer": {"layer": 1, "gcode": "M600"}}, {"renumber": {}}, {"checksum": {}}]`
+	const source = ";LAYER:0\nG1 Z0.2\nG1 X10 E1\n;LAYER:1\nG1 Z0.4\n"
+
+	p, err := Load(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	var output bytes.Buffer
+	if err := p.Run(context.Background(), &output, strings.NewReader(source)); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	want := []string{";LAYER:0", "N1 G1 Z0.2*", "N2 G1 X20 E1*", ";LAYER:1", "N3 M600*", "N4 G1 Z0.4*"}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %q, want %d lines", output.String(), len(want))
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("got line %q, want it starting with %q", lines[i], prefix)
+		}
+	}
+
+	metrics := p.Metrics()
+	names := []string{TRANSFORM_TRANSLATE, TRANSFORM_INSERT_AT_LAYER, TRANSFORM_RENUMBER, TRANSFORM_CHECKSUM}
+	if len(metrics) != len(names) {
+		t.Fatalf("got %d stages, want %d", len(metrics), len(names))
+	}
+	for i, m := range metrics {
+		if m.Name != names[i] {
+			t.Errorf("got stage %q, want %q", m.Name, names[i])
+		}
+	}
+}
+
+func TestLoad_Verbatim(t *testing.T) {
+
+	// the renumber and the checksum keep the text of the lines, only the translated coordinate is written again
+	const config = `[{"translate": {"x": 10}}, {"renumber": {}}, {"checksum": {}}]`
+	const source = "N7 G1 X123.4567 Y98.7654 E12345.67891 F1800*99 ; wall\nG28 \n"
+	const want = "N1 G1 X133.457 Y98.7654 E12345.67891 F1800*10 ; wall\nN2 G28*17\n"
+
+	p, err := Load(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	var output bytes.Buffer
+	if err := p.Run(context.Background(), &output, strings.NewReader(source)); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if output.String() != want {
+		t.Errorf("got %q, want %q", output.String(), want)
+	}
+}
+
+func TestLoad_RepeatedTransform(t *testing.T) {
+
+	p, err := Load(strings.NewReader(`[{"translate": {"x": 1}}, {"translate": {"y": 1}}, {"translate": null}]`), func(config PipelineConfigurer) error {
+		return config.SetConcurrent(true)
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	lines, err := p.Apply(context.Background(), []Line{ParseLine(1, "G1 X1 Y1")})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if len(lines) != 1 || lines[0].Text != "G1 X2 Y2" {
+		t.Errorf("got %+v, want the move translated by both stages", lines)
+	}
+
+	metrics := p.Metrics()
+	if len(metrics) != 3 || metrics[1].Name != "translate #2" || metrics[2].Name != "translate #3" {
+		t.Errorf("got metrics %+v, want the stages translate, translate #2 and translate #3", metrics)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+
+	var cases = map[string]struct {
+		config  string
+		message string
+	}{
+		"not an array":       {config: `{"translate": {}}`, message: "must be an array of steps"},
+		"malformed":          {config: `[{"translate": {}}`, message: "must be an array of steps"},
+		"trailing data":      {config: `[] []`, message: "has data after the array of steps"},
+		"empty step":         {config: `[{}]`, message: "the step 1 must have a single transform but has 0"},
+		"two transforms":     {config: `[{"renumber": {}, "checksum": {}}]`, message: "the step 1 must have a single transform but has 2"},
+		"unknown transform":  {config: `[{"renumber": {}}, {"scale": {}}]`, message: "the step 2 is invalid: failed to create transform 'scale', it isn't registered, the transforms available are: checksum, insert, insert_at_layer, pause, renumber, sweep, translate"},
+		"unknown parameter":  {config: `[{"translate": {"w": 1}}]`, message: `unknown field "w"`},
+		"wrong type":         {config: `[{"translate": {"x": "10"}}]`, message: "cannot unmarshal string"},
+		"missing layer":      {config: `[{"insert_at_layer": {"gcode": "M600"}}]`, message: "the parameter 'layer' is required"},
+		"negative layer":     {config: `[{"insert_at_layer": {"layer": -1, "gcode": "M600"}}]`, message: "the parameter 'layer' mustn't be negative but got -1"},
+		"missing gcode":      {config: `[{"insert_at_layer": {"layer": 1}}]`, message: "the parameter 'gcode' is required"},
+		"negative start":     {config: `[{"renumber": {"start": -1}}]`, message: "the parameter 'start' mustn't be negative but got -1"},
+		"unknown algorithm":  {config: `[{"checksum": {"algorithm": "md5"}}]`, message: "the parameter 'algorithm' is invalid"},
+		"parameters no json": {config: `[{"renumber": 5}]`, message: "failed to decode parameters"},
+		"insert gcode":       {config: `[{"insert": {"layer": 1}}]`, message: "the parameter 'gcode' is required"},
+		"insert no trigger":  {config: `[{"insert": {"gcode": "M600"}}]`, message: "is required but got 0"},
+		"insert triggers":    {config: `[{"insert": {"gcode": "M600", "layer": 1, "before": "M104"}}]`, message: "is required but got 2"},
+		"pause trigger":      {config: `[{"pause": {"mode": "manual"}}]`, message: "a single parameter of 'layer' and 'height' is required"},
+		"pause mode":         {config: `[{"pause": {"layer": 2, "mode": "stop"}}]`, message: "failed set mode"},
+		"pause prime":        {config: `[{"pause": {"layer": 2, "prime": -1}}]`, message: "failed set retract"},
+		"sweep parameter":    {config: `[{"sweep": {"parameter": "jerk", "start": 1, "step": 1, "band_height": 1}}]`, message: "the parameter 'jerk' isn't supported"},
+		"sweep step":         {config: `[{"sweep": {"parameter": "fan", "start": 1, "band_height": 1}}]`, message: "the parameter 'step' is required"},
+		"sweep required":     {config: `[{"sweep": {"parameter": "fan"}}]`, message: "the parameter 'start' is required"},
+		"sweep bands":        {config: `[{"sweep": {"parameter": "fan", "start": 1, "step": 1, "band_height": 1, "bands": 0}}]`, message: "failed set bands"},
+		"insert invalid":     {config: `[{"insert": {"gcode": "M600", "every_minutes": -5}}]`, message: "failed set every"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(strings.NewReader(tc.config))
+			if err == nil {
+				t.Fatalf("got nil error, want error")
+			}
+			if !strings.Contains(err.Error(), tc.message) {
+				t.Errorf("got error '%v', want it containing '%s'", err, tc.message)
+			}
+		})
+	}
+}
+
+func TestLoad_Insert(t *testing.T) {
+
+	p, err := Load(strings.NewReader(`[{"insert": {"gcode": "M117 {time}", "every_minutes": 1.5}}, {"insert": {"gcode": "M400", "after": "m104"}}]`))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	var output bytes.Buffer
+	if err := p.Run(context.Background(), &output, strings.NewReader("M104 S200\nG4 S60\nG4 S60\nG4 S60\n")); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if want := "M104 S200\nM400\nG4 S60\nG4 S60\nM117 2m0s\nG4 S60\n"; output.String() != want {
+		t.Errorf("got %q, want %q", output.String(), want)
+	}
+}
+
+func TestLoad_Pause(t *testing.T) {
+
+	config := `[{"pause": {"height": 0.4, "mode": "manual", "park_x": 5, "park_y": 200, "lift": 1, "retract": 1, "prime": 4,
+		"travel_feedrate": 6000, "temperature": 210, "command": "PAUSE"}}]`
+
+	p, err := Load(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	lines, err := p.Apply(context.Background(), []Line{ParseLine(1, "G1 Z0.4 F600"), ParseLine(2, "G1 X10 E1 F1200")})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	texts := []string{}
+	for _, line := range lines {
+		texts = append(texts, line.Text)
+	}
+
+	want := "G1 Z0.4 F600|; pause at height 0.4|M83|G1 E-1 F1800|G90|G1 Z1.4 F6000|G1 X5 Y200|PAUSE|M109 S210|G1 E4 F1800|" +
+		"G1 X0 Y0 F6000|G1 Z0.4|M82|G92 E0|G1 F600|G1 X10 E1 F1200"
+	if got := strings.Join(texts, "|"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLoad_Sweep(t *testing.T) {
+
+	p, err := Load(strings.NewReader(`[{"sweep": {"parameter": "temperature", "start": 220, "step": 10, "band_height": 1, "base": 0.5, "bands": 2}}]`))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	var output bytes.Buffer
+	if err := p.Run(context.Background(), &output, strings.NewReader(tower())); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	want := "G1 Z0.5 F600\nM104 S220 ; temperature band 0\nG1 X10 E1\nG1 Z0.6\nG1 X0\nG1 Z1\nG1 X10 E2\nG1 Z1.5\nM104 S230 ; temperature band 1\nG1 X0 E3\nG1 Z2\nG1 X10 E4\n"
+	if output.String() != want {
+		t.Errorf("got %q, want %q", output.String(), want)
+	}
+}
+
+// uppercase changes the text of the comment-only lines to upper case
+type uppercase struct {
+	prefix string
+}
+
+func (u *uppercase) Apply(line Line, state State, emit Emit) error {
+	if line.Block == nil {
+		line.Text = u.prefix + strings.ToUpper(line.Text)
+	}
+	return emit(line)
+}
+
+func (u *uppercase) Flush(state State, emit Emit) error {
+	return nil
+}
+
+func TestRegistry_Register(t *testing.T) {
+
+	registry := NewRegistry()
+
+	err := registry.Register("uppercase", func(parameters json.RawMessage) (Transform, error) {
+		var p struct {
+			Prefix string `json:"prefix"`
+		}
+		if err := DecodeParameters(parameters, &p); err != nil {
+			return nil, err
+		}
+		return &uppercase{prefix: p.Prefix}, nil
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	p, err := registry.Load(strings.NewReader(`[{"uppercase": {"prefix": ";"}}, {"renumber": {"start": 10}}]`))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	lines, err := p.Apply(context.Background(), []Line{ParseLine(1, "; start"), ParseLine(2, "G28")})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if len(lines) != 2 || lines[0].Text != ";; START" || lines[1].Text != "N10 G28" {
+		t.Errorf("got %+v, want the comment in upper case and the block numbered from 10", lines)
+	}
+
+	if _, err := NewRegistry().Load(strings.NewReader(`[{"uppercase": {}}]`)); err == nil {
+		t.Errorf("got nil error, want error, the transform is registered in another registry")
+	}
+}
+
+func TestRegistry_RegisterInvalid(t *testing.T) {
+
+	factory := func(parameters json.RawMessage) (Transform, error) {
+		return nil, nil
+	}
+
+	var cases = map[string]struct {
+		name    string
+		factory TransformFactory
+	}{
+		"nil factory": {name: "nil", factory: nil},
+		"empty name":  {name: "", factory: factory},
+		"built-in":    {name: TRANSFORM_RENUMBER, factory: factory},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := NewRegistry().Register(tc.name, tc.factory); err == nil {
+				t.Errorf("got nil error, want error")
+			}
+		})
+	}
+
+	registry := NewRegistry()
+	if err := registry.Register("nothing", factory); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if _, err := registry.Create("nothing", nil); err == nil || !strings.Contains(err.Error(), "nil transform") {
+		t.Errorf("got error %v, want the error of the nil transform", err)
+	}
+}
+
+func TestRegistry_ZeroValue(t *testing.T) {
+
+	var registry Registry
+
+	if _, err := registry.Create(TRANSFORM_RENUMBER, nil); err == nil {
+		t.Errorf("got nil error, want error, the zero value hasn't the built-in transforms")
+	}
+
+	err := registry.Register("uppercase", func(parameters json.RawMessage) (Transform, error) {
+		return &uppercase{}, nil
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if got := registry.Names(); !reflect.DeepEqual(got, []string{"uppercase"}) {
+		t.Errorf("got names %v, want [uppercase]", got)
+	}
+
+	p, err := registry.Load(strings.NewReader(`[{"uppercase": {}}]`))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	var output bytes.Buffer
+	if err := p.Run(context.Background(), &output, strings.NewReader("; hello\nG28\n")); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+	if want := "; HELLO\nG28\n"; output.String() != want {
+		t.Errorf("got %q, want %q", output.String(), want)
+	}
+}
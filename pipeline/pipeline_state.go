@@ -0,0 +1,83 @@
+// This file defines the Interpreter that tracks the state of the machine through the lines that a stage receives.
+package pipeline
+
+import (
+	"github.com/mauroalderete/gcode-core/block"
+	"github.com/mauroalderete/gcode-core/interpreter"
+	"github.com/mauroalderete/gcode-core/layer"
+)
+
+//#region state struct
+
+// State is the state of the machine before a line runs.
+//
+// The position, the positioning modes, the units, the feedrate and the tool are those of the interpreter.State,
+// in the coordinates of the file.
+type State struct {
+	interpreter.State
+
+	// Lines counts the lines received before this one, including the empty and comment-only lines
+	Lines int
+
+	// Layer is the layer in progress, valid if InLayer is true. It's false before the first layer, in the start gcode.
+	Layer   layer.Layer
+	InLayer bool
+
+	// blockOptions are the options of the pipeline to parse the blocks
+	blockOptions []block.BlockParserConfigurationCallbackable
+}
+
+// ParseLine returns the line with the number and the text, and his block parsed with the block options of the pipeline.
+// The transforms use it to parse the lines that they emit.
+func (s State) ParseLine(number int, text string) Line {
+	return ParseLine(number, text, s.blockOptions...)
+}
+
+//#endregion
+//#region interpreter struct
+
+// Interpreter tracks the state of the machine and the layers through the lines of a stream.
+type Interpreter struct {
+	state State
+
+	// machine tracks the state of the machine
+	machine interpreter.Interpreter
+
+	// segmenter detects the layers
+	segmenter *layer.Segmenter
+}
+
+// State returns the state after the lines updated until now.
+func (i *Interpreter) State() State {
+	return i.state
+}
+
+// Update advances the state with the next line of the stream.
+func (i *Interpreter) Update(line Line) {
+
+	b := interpreter.Block(line.Block)
+
+	i.segmenter.Add(line.Text, b)
+	i.machine.Add(line.Text, b)
+
+	i.state.Lines++
+	i.state.State = i.machine.State()
+	i.state.Layer, i.state.InLayer = i.segmenter.Current()
+}
+
+//#endregion
+//#region constructor
+
+// NewInterpreter returns an Interpreter at the beginning of a stream.
+func NewInterpreter() *Interpreter {
+	return &Interpreter{segmenter: layer.New()}
+}
+
+// newInterpreter returns an Interpreter at the beginning of a stream whose states parse the blocks with the options
+func newInterpreter(options []block.BlockParserConfigurationCallbackable) *Interpreter {
+	i := NewInterpreter()
+	i.state.blockOptions = options
+	return i
+}
+
+//#endregion
@@ -0,0 +1,240 @@
+// This file defines the sweep transform, that changes a parameter of the print by height bands to turn a tower into a calibration print.
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/mauroalderete/gcode-core/interpreter"
+)
+
+// SweepParameter is the parameter of the print that a sweep changes.
+type SweepParameter string
+
+const (
+	// SWEEP_TEMPERATURE sweeps the temperature of the hotend with M104
+	SWEEP_TEMPERATURE SweepParameter = "temperature"
+
+	// SWEEP_FAN sweeps the speed of the fan with M106, from 0 to 255
+	SWEEP_FAN SweepParameter = "fan"
+
+	// SWEEP_FLOW sweeps the flow percentage with M221
+	SWEEP_FLOW SweepParameter = "flow"
+
+	// SWEEP_SPEED sweeps the feedrate percentage with M220
+	SWEEP_SPEED SweepParameter = "speed"
+
+	// SWEEP_PRESSURE_ADVANCE sweeps the linear advance of Marlin with M900 K
+	SWEEP_PRESSURE_ADVANCE SweepParameter = "pressure_advance"
+
+	// SWEEP_PRESSURE_ADVANCE_KLIPPER sweeps the pressure advance of Klipper with SET_PRESSURE_ADVANCE
+	SWEEP_PRESSURE_ADVANCE_KLIPPER SweepParameter = "pressure_advance_klipper"
+
+	// SWEEP_RETRACTION sweeps the length of the retractions written in the file, the firmware retractions aren't changed
+	SWEEP_RETRACTION SweepParameter = "retraction"
+)
+
+// sweepCommands are the formats of the commands that set each parameter
+var sweepCommands = map[SweepParameter]string{
+	SWEEP_TEMPERATURE:              "M104 S%s",
+	SWEEP_FAN:                      "M106 S%s",
+	SWEEP_FLOW:                     "M221 S%s",
+	SWEEP_SPEED:                    "M220 S%s",
+	SWEEP_PRESSURE_ADVANCE:         "M900 K%s",
+	SWEEP_PRESSURE_ADVANCE_KLIPPER: "SET_PRESSURE_ADVANCE ADVANCE=%s",
+}
+
+//#region sweep struct
+
+// sweep changes a parameter by height bands
+type sweep struct {
+	parameter SweepParameter
+
+	// start is the value of the first band, step is added in each band of height bandHeight from base,
+	// bands limits the number of bands, 0 doesn't limit them
+	start      float64
+	step       float64
+	bandHeight float64
+	base       float64
+	bands      int
+
+	// band is the band in progress, -1 below the base
+	band int
+
+	// adjust is the length added to the retraction in progress, to add it to the next unretraction in relative extrusion
+	adjust    float64
+	retracted bool
+}
+
+func (t *sweep) Apply(line Line, state State, emit Emit) error {
+
+	if line.Block == nil || !isMove(line) {
+		return emit(line)
+	}
+
+	if t.parameter == SWEEP_RETRACTION {
+		return t.retraction(line, state, emit)
+	}
+
+	// the band changes with the first extrusion at his height, after the travels and the z hops
+	target := state.Target(line.Block)
+	if target[3] <= state.Position[3] {
+		return emit(line)
+	}
+
+	band := t.bandAt(target[2])
+	if band == t.band {
+		return emit(line)
+	}
+	t.band = band
+
+	if band >= 0 {
+		v, err := t.value(band)
+		if err != nil {
+			return err
+		}
+
+		text := fmt.Sprintf(sweepCommands[t.parameter]+" ; %s band %d", value(v), t.parameter, band)
+		if err := emit(state.ParseLine(0, text)); err != nil {
+			return err
+		}
+	}
+
+	return emit(line)
+}
+
+func (t *sweep) Flush(state State, emit Emit) error {
+	return nil
+}
+
+// retraction changes the length of the retractions of the bands, the moves of the extruder alone
+func (t *sweep) retraction(line Line, state State, emit Emit) error {
+
+	b := line.Block
+	if !interpreter.Has(b, 'E') || interpreter.Has(b, 'X') || interpreter.Has(b, 'Y') || interpreter.Has(b, 'Z') {
+		return emit(line)
+	}
+
+	delta := state.Target(b)[3] - state.Position[3]
+
+	switch {
+	case delta < 0:
+		band := t.bandAt(state.Position[2])
+		if band < 0 {
+			return emit(line)
+		}
+
+		length, err := t.value(band)
+		if err != nil {
+			return err
+		}
+
+		t.retracted, t.adjust = true, length+delta
+
+		e := state.Position[3] - length
+		if state.RelativeE {
+			e = -length
+		}
+		return emit(line.Rewrite(map[byte]float64{'E': e}, state.blockOptions...))
+
+	case delta > 0 && t.retracted:
+		t.retracted = false
+
+		// in absolute extrusion the unretraction returns to the same position whatever the length retracted
+		if !state.RelativeE || t.adjust == 0 {
+			return emit(line)
+		}
+		return emit(line.Rewrite(map[byte]float64{'E': delta + t.adjust}, state.blockOptions...))
+	}
+
+	return emit(line)
+}
+
+// bandAt returns the band of the height, -1 below the base
+func (t *sweep) bandAt(z float64) int {
+
+	if z < t.base-1e-6 {
+		return -1
+	}
+
+	band := int(math.Floor((z-t.base)/t.bandHeight + 1e-6))
+	if t.bands > 0 && band >= t.bands {
+		band = t.bands - 1
+	}
+
+	return band
+}
+
+// value returns the value of the parameter in the band
+func (t *sweep) value(band int) (float64, error) {
+
+	v := t.start + float64(band)*t.step
+	if v < 0 {
+		return 0, fmt.Errorf("failed to sweep %s, the band %d has the negative value %s", t.parameter, band, value(v))
+	}
+
+	return v, nil
+}
+
+//#endregion
+//#region constructor
+
+// NewSweep returns a transform that changes a parameter of the print by bands of height, to turn a tower sliced once into a calibration print.
+//
+// The first band starts at the base, 0 by default, and has the start value, each band adds the step to the previous one.
+// The command of the parameter is inserted before the first extrusion of each band, so the travels and z hops don't change the band.
+// The retraction is swept rewriting the length of the retractions written in the file.
+func NewSweep(parameter SweepParameter, start float64, step float64, bandHeight float64, options ...SweepConfigurationCallbackable) (Transform, error) {
+
+	if _, ok := sweepCommands[parameter]; !ok && parameter != SWEEP_RETRACTION {
+		return nil, fmt.Errorf("failed to create sweep, the parameter '%s' isn't supported", parameter)
+	}
+
+	if bandHeight <= 0 {
+		return nil, fmt.Errorf("failed to create sweep, the band height must be positive but got %g", bandHeight)
+	}
+
+	if start < 0 {
+		return nil, fmt.Errorf("failed to create sweep, the start value mustn't be negative but got %g", start)
+	}
+
+	t := &sweep{
+		parameter:  parameter,
+		start:      start,
+		step:       step,
+		bandHeight: bandHeight,
+		band:       -1,
+	}
+
+	configurator := &sweepConfigurator{sweep: t}
+
+	for _, option := range options {
+		err := option(configurator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	if t.bands > 0 {
+		if _, err := t.value(t.bands - 1); err != nil {
+			return nil, fmt.Errorf("failed to create sweep: %w", err)
+		}
+	}
+
+	return t, nil
+}
+
+//#endregion
+//#region private functions
+
+// value formats a value of a parameter with up to 6 decimals, the pressure advance needs more than the coordinates
+func value(v float64) string {
+	v = math.Round(v*1e6) / 1e6
+	if v == 0 {
+		v = 0
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+//#endregion
@@ -0,0 +1,47 @@
+// This file defines a sweepConfigurator as an object that implement the SweepConfigurer
+// interface to allow the caller to configure a new sweep transform.
+package pipeline
+
+import "fmt"
+
+// SweepConfigurer contains the configurable options that define the bands of a sweep transform.
+type SweepConfigurer interface {
+	// Set the height where the first band starts
+	SetBase(z float64) error
+
+	// Set the number of bands
+	SetBands(count int) error
+}
+
+// SweepConfigurationCallbackable is the signature of the callbacks that the NewSweep constructor waiting receives to configure the new transform.
+type SweepConfigurationCallbackable func(config SweepConfigurer) error
+
+// sweepConfigurator satisfy SweepConfigurer, it applies each option directly over the sweep transform in construction.
+type sweepConfigurator struct {
+	sweep *sweep
+}
+
+// SetBase sets the height where the first band starts, the parameter isn't changed below it, like in the base of a tower.
+// It mustn't be negative, by default is 0.
+func (sc *sweepConfigurator) SetBase(z float64) error {
+
+	if z < 0 {
+		return fmt.Errorf("failed set base, it mustn't be negative but got %g", z)
+	}
+
+	sc.sweep.base = z
+
+	return nil
+}
+
+// SetBands sets the number of bands, the heights over the last band keep his value. It must be positive, by default the bands aren't limited.
+func (sc *sweepConfigurator) SetBands(count int) error {
+
+	if count < 1 {
+		return fmt.Errorf("failed set bands, it must be positive but got %d", count)
+	}
+
+	sc.sweep.bands = count
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// tower returns a tower of 4 layers of 0.5 mm with a travel, a z hop and an extrusion in each one
+func tower() string {
+	return "G1 Z0.5 F600\nG1 X10 E1\nG1 Z0.6\nG1 X0\nG1 Z1\nG1 X10 E2\nG1 Z1.5\nG1 X0 E3\nG1 Z2\nG1 X10 E4\n"
+}
+
+func TestSweep(t *testing.T) {
+
+	var cases = map[string]struct {
+		parameter SweepParameter
+		start     float64
+		step      float64
+		options   []SweepConfigurationCallbackable
+		want      []string
+	}{
+		"temperature": {
+			parameter: SWEEP_TEMPERATURE, start: 230, step: -5,
+			want: []string{"M104 S230 ; temperature band 0", "M104 S225 ; temperature band 1", "M104 S220 ; temperature band 2"},
+		},
+		"fan": {
+			parameter: SWEEP_FAN, start: 0, step: 127.5,
+			want: []string{"M106 S0 ; fan band 0", "M106 S127.5 ; fan band 1", "M106 S255 ; fan band 2"},
+		},
+		"flow with base": {
+			parameter: SWEEP_FLOW, start: 90, step: 5,
+			options: []SweepConfigurationCallbackable{func(config SweepConfigurer) error { return config.SetBase(1) }},
+			want:    []string{"M221 S90 ; flow band 0", "M221 S95 ; flow band 1"},
+		},
+		"speed with bands": {
+			parameter: SWEEP_SPEED, start: 100, step: 50,
+			options: []SweepConfigurationCallbackable{func(config SweepConfigurer) error { return config.SetBands(2) }},
+			want:    []string{"M220 S100 ; speed band 0", "M220 S150 ; speed band 1"},
+		},
+		"pressure advance": {
+			parameter: SWEEP_PRESSURE_ADVANCE, start: 0, step: 0.0025,
+			want: []string{"M900 K0 ; pressure_advance band 0", "M900 K0.0025 ; pressure_advance band 1", "M900 K0.005 ; pressure_advance band 2"},
+		},
+		"pressure advance of klipper": {
+			parameter: SWEEP_PRESSURE_ADVANCE_KLIPPER, start: 0.02, step: 0.01,
+			want: []string{"SET_PRESSURE_ADVANCE ADVANCE=0.02 ; pressure_advance_klipper band 0", "SET_PRESSURE_ADVANCE ADVANCE=0.03 ; pressure_advance_klipper band 1", "SET_PRESSURE_ADVANCE ADVANCE=0.04 ; pressure_advance_klipper band 2"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			transform, err := NewSweep(tc.parameter, tc.start, tc.step, 1, tc.options...)
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			got := []string{}
+			lines := strings.Split(run(t, transform, tower()), "\n")
+			for i, text := range lines {
+				if strings.Contains(text, "band") {
+					got = append(got, text)
+
+					// the command is before the extrusion, after the travels
+					if !strings.Contains(lines[i+1], " E") {
+						t.Errorf("got %q after the command, want an extrusion", lines[i+1])
+					}
+				}
+			}
+
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSweep_Retraction(t *testing.T) {
+
+	var cases = map[string]struct {
+		source string
+		want   string
+	}{
+		"absolute extrusion": {
+			source: "G1 Z0.5\nG1 X10 E5\nG1 E4 F2400\nG1 X0\nG1 E5\nG1 Z2.5\nG1 X10 E6\nG1 E5\nG1 E6\n",
+			want:   "G1 Z0.5\nG1 X10 E5\nG1 E4.5 F2400\nG1 X0\nG1 E5\nG1 Z2.5\nG1 X10 E6\nG1 E4.5\nG1 E6\n",
+		},
+		"relative extrusion": {
+			source: "M83\nG1 Z0.5\nG1 X10 E5\nG1 E-1 F2400\nG1 X0\nG1 E1.2\nG1 Z2.5\nG1 X10 E1\nG1 E-1\nG1 E1\n",
+			want:   "M83\nG1 Z0.5\nG1 X10 E5\nG1 E-0.5 F2400\nG1 X0\nG1 E0.7\nG1 Z2.5\nG1 X10 E1\nG1 E-1.5\nG1 E1.5\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			transform, err := NewSweep(SWEEP_RETRACTION, 0.5, 0.5, 1)
+			if err != nil {
+				t.Fatalf("got error %v, want nil error", err)
+			}
+
+			if got := run(t, transform, tc.source); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSweep_NegativeValue(t *testing.T) {
+
+	transform, err := NewSweep(SWEEP_FLOW, 10, -10, 1)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	p, err := New(transforms("sweep", transform))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if _, err := p.Apply(context.Background(), []Line{ParseLine(1, "G1 Z0.5 X1 E1"), ParseLine(2, "G1 Z1.5 X1 E2"), ParseLine(3, "G1 Z2.5 X1 E3")}); err == nil || !strings.Contains(err.Error(), "the band 2 has the negative value -10") {
+		t.Errorf("got error %v, want the error of the negative value", err)
+	}
+}
+
+func TestNewSweep_Errors(t *testing.T) {
+
+	var cases = map[string]struct {
+		parameter  SweepParameter
+		start      float64
+		step       float64
+		bandHeight float64
+		options    []SweepConfigurationCallbackable
+		message    string
+	}{
+		"parameter": {
+			parameter: "jerk", bandHeight: 1,
+			message: "the parameter 'jerk' isn't supported",
+		},
+		"band height": {
+			parameter: SWEEP_FAN, bandHeight: 0,
+			message: "the band height must be positive",
+		},
+		"start": {
+			parameter: SWEEP_FAN, start: -1, bandHeight: 1,
+			message: "the start value mustn't be negative",
+		},
+		"base": {
+			parameter: SWEEP_FAN, bandHeight: 1,
+			options: []SweepConfigurationCallbackable{func(config SweepConfigurer) error { return config.SetBase(-1) }},
+			message: "failed set base",
+		},
+		"bands": {
+			parameter: SWEEP_FAN, bandHeight: 1,
+			options: []SweepConfigurationCallbackable{func(config SweepConfigurer) error { return config.SetBands(0) }},
+			message: "failed set bands",
+		},
+		"last band": {
+			parameter: SWEEP_TEMPERATURE, start: 20, step: -10, bandHeight: 1,
+			options: []SweepConfigurationCallbackable{func(config SweepConfigurer) error { return config.SetBands(4) }},
+			message: "the band 3 has the negative value -10",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewSweep(tc.parameter, tc.start, tc.step, tc.bandHeight, tc.options...)
+			if err == nil {
+				t.Fatalf("got nil error, want error")
+			}
+			if !strings.Contains(err.Error(), tc.message) {
+				t.Errorf("got error '%v', want it containing '%s'", err, tc.message)
+			}
+		})
+	}
+}
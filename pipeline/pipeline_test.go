@@ -0,0 +1,356 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+)
+
+// stripComments drops the lines without gcode
+var stripComments = TransformFunc(func(line Line, state State, emit Emit) error {
+	if line.Block == nil {
+		return nil
+	}
+	return emit(line)
+})
+
+// counter emits each line and a comment with the number of moves at the end
+type counter struct {
+	moves int
+}
+
+func (c *counter) Apply(line Line, state State, emit Emit) error {
+	if line.Block != nil && line.Block.Command().String() == "G1" {
+		c.moves++
+	}
+	return emit(line)
+}
+
+func (c *counter) Flush(state State, emit Emit) error {
+	return emit(Line{Text: fmt.Sprintf("; moves: %d", c.moves)})
+}
+
+// duplicate emits each move twice
+var duplicate = TransformFunc(func(line Line, state State, emit Emit) error {
+	if err := emit(line); err != nil {
+		return err
+	}
+	if line.Block != nil && line.Block.Command().String() == "G1" {
+		return emit(Line{Text: line.Text, Block: line.Block})
+	}
+	return nil
+})
+
+// modes runs a test with the stages in the goroutine of the caller and concurrently
+func modes(t *testing.T, test func(t *testing.T, mode PipelineConfigurationCallbackable)) {
+	for name, concurrent := range map[string]bool{"sync": false, "concurrent": true} {
+		concurrent := concurrent
+		t.Run(name, func(t *testing.T) {
+			test(t, func(config PipelineConfigurer) error {
+				if err := config.SetBufferSize(1); err != nil {
+					return err
+				}
+				return config.SetConcurrent(concurrent)
+			})
+		})
+	}
+}
+
+func transforms(entries ...interface{}) PipelineConfigurationCallbackable {
+	return func(config PipelineConfigurer) error {
+		for i := 0; i < len(entries); i += 2 {
+			if err := config.AddTransform(entries[i].(string), entries[i+1].(Transform)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestPipeline_Run(t *testing.T) {
+
+	const source = "; start\nG28\nG1 X10 Y10\n\nG1 X20 Y10 ; move\nM117 Hello\n"
+	const want = "G28\nG1 X10 Y10\nG1 X10 Y10\nG1 X20 Y10 ; move\nG1 X20 Y10 ; move\n; moves: 4\n"
+
+	modes(t, func(t *testing.T, mode PipelineConfigurationCallbackable) {
+
+		p, err := New(mode, transforms("strip", stripComments, "duplicate", duplicate, "count", &counter{}))
+		if err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+
+		var output bytes.Buffer
+		if err := p.Run(context.Background(), &output, strings.NewReader(source)); err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+
+		if output.String() != want {
+			t.Errorf("got %q, want %q", output.String(), want)
+		}
+
+		metrics := p.Metrics()
+		expected := []Metrics{{Name: "strip", In: 6, Out: 3}, {Name: "duplicate", In: 3, Out: 5}, {Name: "count", In: 5, Out: 6}}
+		if len(metrics) != len(expected) {
+			t.Fatalf("got %d metrics, want %d", len(metrics), len(expected))
+		}
+		for i, m := range metrics {
+			if m.Name != expected[i].Name || m.In != expected[i].In || m.Out != expected[i].Out || m.Duration < 0 {
+				t.Errorf("got metrics %+v, want %+v", m, expected[i])
+			}
+		}
+	})
+}
+
+func TestPipeline_Empty(t *testing.T) {
+
+	p, err := New()
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	lines, err := p.Apply(context.Background(), []Line{ParseLine(1, "G28"), ParseLine(2, "M117 Hello")})
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if len(lines) != 2 || lines[0].Block == nil || lines[1].Block != nil || lines[1].Text != "M117 Hello" {
+		t.Errorf("got %+v, want the lines as they are, the second one without block", lines)
+	}
+}
+
+func TestPipeline_State(t *testing.T) {
+
+	const source = "G21\nG90\nM83\nG28\n;LAYER:0\nG1 Z0.2 F600\nG1 X10 Y20 E1\nG91\nG1 X5 E0.5\n;LAYER:1\nG92 E0\nT1\n"
+
+	modes(t, func(t *testing.T, mode PipelineConfigurationCallbackable) {
+
+		states := []State{}
+		record := TransformFunc(func(line Line, state State, emit Emit) error {
+			states = append(states, state)
+			return emit(line)
+		})
+
+		var last State
+		p, err := New(mode, transforms("moves", stripComments, "record", record, "last", &flushState{state: &last}))
+		if err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+
+		if err := p.Run(context.Background(), &bytes.Buffer{}, strings.NewReader(source)); err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+
+		// the second stage doesn't receive the comments, but the first one detects the layers for his own
+		if len(states) != 10 {
+			t.Fatalf("got %d states, want 10", len(states))
+		}
+
+		before := states[8]
+		if before.Position != [4]float64{15, 20, 0.2, 1.5} || !before.Relative || before.Feedrate != 600 || !before.RelativeE || before.Lines != 8 {
+			t.Errorf("got state %+v before G92, want relative at 15,20,0.2,1.5 with F600", before)
+		}
+
+		if last.Position != [4]float64{15, 20, 0.2, 0} || last.Tool != 1 || last.Lines != 10 {
+			t.Errorf("got final state %+v, want 15,20,0.2,0 with T1", last)
+		}
+	})
+}
+
+// flushState stores the state received by Flush
+type flushState struct {
+	state *State
+}
+
+func (f *flushState) Apply(line Line, state State, emit Emit) error {
+	return emit(line)
+}
+
+func (f *flushState) Flush(state State, emit Emit) error {
+	*f.state = state
+	return nil
+}
+
+func TestInterpreter_Layers(t *testing.T) {
+
+	interpreter := NewInterpreter()
+
+	if interpreter.State().InLayer {
+		t.Errorf("got in a layer at the beginning, want before the first layer")
+	}
+
+	for i, text := range strings.Split(";LAYER:0\n;Z:0.2\nG1 X10 Y10 E1\n;LAYER:1\n;Z:0.4\nG1 X10 Y20 E2\nG20", "\n") {
+		interpreter.Update(ParseLine(i+1, text))
+	}
+
+	state := interpreter.State()
+	if !state.InLayer || state.Layer.Index != 1 || state.Layer.Z != 0.4 || state.Layer.Start != 3 || !state.Inches {
+		t.Errorf("got state %+v, want the layer 1 at 0.4 in inches", state)
+	}
+}
+
+func TestPipeline_Error(t *testing.T) {
+
+	failure := errors.New("boom")
+
+	failing := TransformFunc(func(line Line, state State, emit Emit) error {
+		if line.Number == 3 {
+			return failure
+		}
+		return emit(line)
+	})
+
+	failingFlush := &failOnFlush{err: failure}
+
+	var cases = map[string]struct {
+		option  PipelineConfigurationCallbackable
+		message string
+	}{
+		"apply": {
+			option:  transforms("before", duplicate, "fail", failing, "after", duplicate),
+			message: "failed to apply transform 'fail' to the line 3 'G1 X2': boom",
+		},
+		"flush": {
+			option:  transforms("before", duplicate, "fail", failingFlush),
+			message: "failed to apply transform 'fail' to the end of the stream: boom",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			modes(t, func(t *testing.T, mode PipelineConfigurationCallbackable) {
+
+				p, err := New(mode, tc.option)
+				if err != nil {
+					t.Fatalf("got error %v, want nil error", err)
+				}
+
+				lines := make([]Line, 100)
+				for i := range lines {
+					lines[i] = ParseLine(i+1, fmt.Sprintf("G1 X%d", i))
+				}
+
+				_, err = p.Apply(context.Background(), lines)
+				if !errors.Is(err, failure) {
+					t.Fatalf("got error %v, want the error of the transform", err)
+				}
+
+				if err.Error() != tc.message {
+					t.Errorf("got error '%v', want '%s'", err, tc.message)
+				}
+			})
+		})
+	}
+}
+
+// failOnFlush fails at the end of the stream
+type failOnFlush struct {
+	err error
+}
+
+func (f *failOnFlush) Apply(line Line, state State, emit Emit) error {
+	return emit(line)
+}
+
+func (f *failOnFlush) Flush(state State, emit Emit) error {
+	return f.err
+}
+
+// failingWriter fails on each write
+type failingWriter struct{}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestPipeline_WriteError(t *testing.T) {
+
+	modes(t, func(t *testing.T, mode PipelineConfigurationCallbackable) {
+
+		p, err := New(mode, transforms("duplicate", duplicate))
+		if err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+
+		source := strings.Repeat("G1 X10\n", 10000)
+		if err := p.Run(context.Background(), failingWriter{}, strings.NewReader(source)); err == nil {
+			t.Errorf("got nil error, want the error of the writer")
+		}
+	})
+}
+
+func TestPipeline_Cancel(t *testing.T) {
+
+	modes(t, func(t *testing.T, mode PipelineConfigurationCallbackable) {
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		count := 0
+		cancelling := TransformFunc(func(line Line, state State, emit Emit) error {
+			count++
+			if count == 10 {
+				cancel()
+			}
+			return emit(line)
+		})
+
+		p, err := New(mode, transforms("cancel", cancelling, "duplicate", duplicate))
+		if err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+
+		source := strings.Repeat("G1 X10\n", 10000)
+		err = p.Run(ctx, &bytes.Buffer{}, strings.NewReader(source))
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v, want context.Canceled", err)
+		}
+
+		if count >= 10000 {
+			t.Errorf("got %d lines processed, want the pipeline stopped", count)
+		}
+	})
+}
+
+func TestPipeline_InvalidConfiguration(t *testing.T) {
+
+	var cases = map[string]PipelineConfigurationCallbackable{
+		"nil transform": func(config PipelineConfigurer) error {
+			return config.AddTransform("nil", nil)
+		},
+		"empty name":      transforms("", duplicate),
+		"duplicated name": transforms("duplicate", duplicate, "duplicate", duplicate),
+		"buffer size": func(config PipelineConfigurer) error {
+			return config.SetBufferSize(-1)
+		},
+	}
+
+	for name, option := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(option); err == nil {
+				t.Errorf("got nil error, want error")
+			}
+		})
+	}
+}
+
+func TestLine(t *testing.T) {
+
+	b, err := gcodeblock.Parse("N3 G1 X10 Y20*33 ; move")
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	line := ParseLine(7, "G1 X1").WithBlock(b)
+	if line.Number != 7 || line.Text != "N3 G1 X10 Y20*33 ; move" {
+		t.Errorf("got %+v, want the line 7 written from the block", line)
+	}
+
+	if inserted := NewLine(b); inserted.Number != 0 || inserted.Text != line.Text {
+		t.Errorf("got %+v, want an inserted line written from the block", inserted)
+	}
+}
@@ -0,0 +1,215 @@
+// This file defines the built-in transforms.
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/checksum"
+	"github.com/mauroalderete/gcode-core/gcode"
+	"github.com/mauroalderete/gcode-core/interpreter"
+)
+
+// numbered matches the line number and the checksum of a line
+var numbered = regexp.MustCompile(`^N\d+\s*|\s*\*\d+$`)
+
+// checksummed matches the checksum at the end of the code of a line
+var checksummed = regexp.MustCompile(`\s*\*\d+$`)
+
+// word matches each word of the code of a line, like G1, X10 or E-.5
+var word = regexp.MustCompile(`[A-Za-z]\s*[-+]?(?:\d+\.?\d*|\.\d+)`)
+
+//#region translate transform
+
+// translate adds an offset to the coordinates of the moves
+type translate struct {
+	offset [3]float64
+}
+
+// NewTranslate returns a transform that adds the offset to the X, Y and Z written by the absolute moves, G0 to G3,
+// and by the position resets, G92. The relative moves and the centers of the arcs aren't changed.
+//
+// The line numbers and the checksums of the translated blocks are removed, they aren't valid anymore.
+func NewTranslate(offset [3]float64) Transform {
+	return &translate{offset: offset}
+}
+
+func (t *translate) Apply(line Line, state State, emit Emit) error {
+
+	if line.Block == nil || line.Block.Command().Word() != 'G' {
+		return emit(line)
+	}
+
+	number, ok := gcode.NumericAddress(line.Block.Command())
+	if !ok || !(number == 92 || (number >= 0 && number <= 3 && number == math.Trunc(number) && !state.Relative)) {
+		return emit(line)
+	}
+
+	values := map[byte]float64{}
+	for axis, word := range []byte{'X', 'Y', 'Z'} {
+		if v, ok := interpreter.Parameter(line.Block, word); ok && t.offset[axis] != 0 {
+			values[word] = v + t.offset[axis]
+		}
+	}
+
+	if len(values) == 0 {
+		return emit(line)
+	}
+
+	return emit(line.Rewrite(values, state.blockOptions...))
+}
+
+func (t *translate) Flush(state State, emit Emit) error {
+	return nil
+}
+
+//#endregion
+//#region insert at layer transform
+
+// NewInsertAtLayer returns a transform that inserts the lines before the first line with gcode of the layer with the index,
+// the first layer is 0. The layers are detected like the layer package does, with the markers of the slicers or from the moves.
+//
+// It's the insert transform with the layer trigger, see NewInsert for the variables of the lines.
+func NewInsertAtLayer(layer int, lines []string) Transform {
+	return &insert{lines: lines, trigger: triggerLayer, layer: layer, lastLayer: -1}
+}
+
+//#endregion
+//#region renumber transform
+
+// renumber numbers the lines with gcode
+type renumber struct {
+	next int
+}
+
+// NewRenumber returns a transform that numbers each line with gcode consecutively from start, replacing his old number.
+// The M110 set the number of their own line, to keep the numbering consecutive.
+//
+// The checksums are removed, they aren't valid anymore.
+func NewRenumber(start int) Transform {
+	return &renumber{next: start}
+}
+
+func (t *renumber) Apply(line Line, state State, emit Emit) error {
+
+	if !hasGcode(line) {
+		return emit(line)
+	}
+
+	code, comment := split(line)
+	if isCommand(line, 'M', 110) {
+		code = fmt.Sprintf("M110 N%d", t.next)
+	}
+
+	text := fmt.Sprintf("N%d %s", t.next, code)
+	if comment != "" {
+		text += " " + comment
+	}
+	t.next++
+
+	return emit(state.ParseLine(line.Number, text))
+}
+
+func (t *renumber) Flush(state State, emit Emit) error {
+	return nil
+}
+
+//#endregion
+//#region checksum transform
+
+// appendChecksum appends the checksum to the lines with gcode
+type appendChecksum struct {
+	factory checksum.Factory
+}
+
+// NewChecksum returns a transform that appends to each line with gcode his checksum, computed with the hashes of the factory.
+// The old checksums are replaced. The checksum covers the line number, so it must run after a renumber.
+func NewChecksum(factory checksum.Factory) Transform {
+	return &appendChecksum{factory: factory}
+}
+
+func (t *appendChecksum) Apply(line Line, state State, emit Emit) error {
+
+	if !hasGcode(line) {
+		return emit(line)
+	}
+
+	// the checksum covers the exact text of the line before the asterisk, with his line number
+	code := stripComment(line.Text)
+	comment := strings.TrimSpace(line.Text[len(code):])
+	code = checksummed.ReplaceAllString(strings.TrimSpace(code), "")
+
+	value, err := t.factory.Compute([]byte(code))
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("%s*%d", code, value)
+	if comment != "" {
+		text += " " + comment
+	}
+
+	return emit(state.ParseLine(line.Number, text))
+}
+
+func (t *appendChecksum) Flush(state State, emit Emit) error {
+	return nil
+}
+
+//#endregion
+//#region private functions
+
+// hasGcode returns true if the line has gcode, even if it couldn't be parsed
+func hasGcode(line Line) bool {
+	return line.Block != nil || strings.TrimSpace(stripComment(line.Text)) != ""
+}
+
+// isCommand returns true if the block of the line has the command with the word and the number
+func isCommand(line Line, word byte, number float64) bool {
+	if line.Block == nil {
+		return false
+	}
+	n, ok := gcode.NumericAddress(line.Block.Command())
+	return ok && line.Block.Command().Word() == word && n == number
+}
+
+// split returns the code of a line as it's written, without the line number and the checksum, and his comment
+func split(line Line) (string, string) {
+
+	code := stripComment(line.Text)
+	comment := strings.TrimSpace(line.Text[len(code):])
+
+	return strings.TrimSpace(numbered.ReplaceAllString(strings.TrimSpace(code), "")), comment
+}
+
+// stripComment removes the comment of a line, the semicolons between quotes aren't comments
+func stripComment(text string) string {
+
+	quoted := false
+	for i, c := range text {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return strings.TrimRight(text[:i], " \t")
+			}
+		}
+	}
+
+	return strings.TrimRight(text, " \t")
+}
+
+// coordinate formats a coordinate with up to 3 decimals
+func coordinate(v float64) string {
+	v = math.Round(v*1000) / 1000
+	if v == 0 {
+		v = 0
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+//#endregion
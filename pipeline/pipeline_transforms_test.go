@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mauroalderete/gcode-core/checksum"
+)
+
+// run applies a transform to the source and returns the output
+func run(t *testing.T, transform Transform, source string) string {
+	t.Helper()
+
+	p, err := New(transforms("transform", transform))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	var output bytes.Buffer
+	if err := p.Run(context.Background(), &output, strings.NewReader(source)); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	return output.String()
+}
+
+func TestTranslate(t *testing.T) {
+
+	const source = "N5 G1 X10 Y20 Z0.2 E1*99 ; move\nG2 X5 Y5 I1 J1\nG92 X0\nG91\nG1 X1 Y1\nG90\nG0 Z5\nM104 S200\n"
+	const want = "G1 X20.5 Y20 Z0.2 E1 ; move\nG2 X15.5 Y5 I1 J1\nG92 X10.5\nG91\nG1 X1 Y1\nG90\nG0 Z5\nM104 S200\n"
+
+	if got := run(t, NewTranslate([3]float64{10.5, 0, 0}), source); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTranslate_Verbatim(t *testing.T) {
+
+	// only the translated coordinates are written again, the extrusion and the feedrate keep all their decimals
+	const source = "G1 X10.12345 Y20.50 E1.23456 F1800.5\nG0 X-1.5 Z0.30 F9000 ; travel\n"
+	const want = "G1 X12.123 Y20.50 E1.23456 F1800.5\nG0 X0.5 Z0.30 F9000 ; travel\n"
+
+	if got := run(t, NewTranslate([3]float64{2, 0, 0}), source); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestInsertAtLayer(t *testing.T) {
+
+	const source = ";LAYER:0\nG1 Z0.2\nG1 X10 E1\n;LAYER:1\n; before\nG1 Z0.4\nG1 X20 E2\n;LAYER:2\nG1 Z0.6\n"
+	const want = ";LAYER:0\nG1 Z0.2\nG1 X10 E1\n;LAYER:1\n; before\nM600\nM117 Color\nG1 Z0.4\nG1 X20 E2\n;LAYER:2\nG1 Z0.6\n"
+
+	if got := run(t, NewInsertAtLayer(1, []string{"M600", "M117 Color"}), source); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got := run(t, NewInsertAtLayer(7, []string{"M600"}), source); got != source {
+		t.Errorf("got %q, want the source without changes", got)
+	}
+}
+
+func TestRenumber(t *testing.T) {
+
+	const source = "; start\nM110 N0\nN7 G28*12\nM117 Hello ; message\n\nG1 X10\n"
+	const want = "; start\nN1 M110 N1\nN2 G28\nN3 M117 Hello ; message\n\nN4 G1 X10\n"
+
+	if got := run(t, NewRenumber(1), source); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+
+	crc16, err := checksum.NewFactory(checksum.ALGORITHM_CRC16_CCITT)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	xor, err := checksum.NewFactory(checksum.ALGORITHM_XOR)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	var cases = map[string]struct {
+		factory checksum.Factory
+		source  string
+		want    string
+	}{
+		"crc16": {
+			factory: crc16,
+			source:  "N2 G1 X10*5 ; move\n; comment\nN4 G92 E0\n",
+			want:    "N2 G1 X10*22376 ; move\n; comment\nN4 G92 E0*38517\n",
+		},
+		"xor": {
+			factory: xor,
+			source:  "N2 G1 X10\n",
+			want:    "N2 G1 X10*83\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := run(t, NewChecksum(tc.factory), tc.source); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRenumberAndChecksum(t *testing.T) {
+
+	xor, err := checksum.NewFactory(checksum.ALGORITHM_XOR)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	p, err := New(transforms("renumber", NewRenumber(1), "checksum", NewChecksum(xor)))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	var output bytes.Buffer
+	if err := p.Run(context.Background(), &output, strings.NewReader("G28\nM117 Hello\n")); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+
+	for _, text := range lines {
+		star := strings.LastIndex(text, "*")
+		if star < 0 {
+			t.Fatalf("got %q, want a line with checksum", text)
+		}
+
+		value, err := xor.Compute([]byte(text[:star]))
+		if err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+
+		if text[star+1:] != strconv.FormatUint(uint64(value), 10) {
+			t.Errorf("got %q, want the checksum %d", text, value)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package thumbnail_test
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/thumbnail"
+)
+
+func Example() {
+
+	// encode a small preview
+	preview := &bytes.Buffer{}
+	png.Encode(preview, image.NewGray(image.Rect(0, 0, 16, 12)))
+
+	th, err := thumbnail.New(thumbnail.FORMAT_PNG, preview.Bytes())
+	if err != nil {
+		fmt.Printf("failed to create the thumbnail: %v", err)
+		return
+	}
+
+	// inject it into a gcode file
+	gcode := &bytes.Buffer{}
+	err = thumbnail.Insert(gcode, strings.NewReader("; generated by PrusaSlicer\nG28\n"), th)
+	if err != nil {
+		fmt.Printf("failed to insert the thumbnail: %v", err)
+		return
+	}
+
+	// and extract it again
+	thumbnails, err := thumbnail.Extract(gcode)
+	if err != nil {
+		fmt.Printf("failed to extract the thumbnails: %v", err)
+		return
+	}
+
+	for _, t := range thumbnails {
+		img, _ := t.Image()
+		fmt.Printf("%s %dx%d, decoded as %v\n", t.Format, t.Width, t.Height, img.Bounds().Size())
+	}
+
+	// Output:
+	// PNG 16x12, decoded as (16,12)
+}
@@ -0,0 +1,131 @@
+// qoi package implements a decoder for the [QOI] image format.
+//
+// This package is only to internal use by thumbnail package.
+//
+// [QOI]: https://qoiformat.org/qoi-specification.pdf
+package qoi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+const (
+	// MAGIC is the first four bytes of any qoi image
+	MAGIC = "qoif"
+
+	// headerSize is the size in bytes of the header
+	headerSize = 14
+
+	// chunk tags
+	opIndex = 0x00
+	opDiff  = 0x40
+	opLuma  = 0x80
+	opRun   = 0xc0
+	opRGB   = 0xfe
+	opRGBA  = 0xff
+	opMask  = 0xc0
+)
+
+//#region package functions
+
+// DecodeConfig returns the dimensions of a qoi image without decoding it.
+func DecodeConfig(data []byte) (image.Config, error) {
+
+	if len(data) < headerSize || string(data[0:4]) != MAGIC {
+		return image.Config{}, fmt.Errorf("data isn't a qoi image")
+	}
+
+	width := binary.BigEndian.Uint32(data[4:])
+	height := binary.BigEndian.Uint32(data[8:])
+
+	if width == 0 || height == 0 || uint64(width)*uint64(height) > 400_000_000 {
+		return image.Config{}, fmt.Errorf("qoi image has invalid dimensions %dx%d", width, height)
+	}
+
+	return image.Config{
+		ColorModel: color.NRGBAModel,
+		Width:      int(width),
+		Height:     int(height),
+	}, nil
+}
+
+// Decode returns the image stored in data.
+func Decode(data []byte) (image.Image, error) {
+
+	config, err := DecodeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, config.Width, config.Height))
+
+	var index [64]color.NRGBA
+	px := color.NRGBA{A: 255}
+	run := 0
+	pos := headerSize
+
+	for i := 0; i < len(img.Pix); i += 4 {
+
+		if run > 0 {
+			run--
+		} else {
+			if pos >= len(data) {
+				return nil, fmt.Errorf("qoi image is truncated")
+			}
+
+			b := data[pos]
+			pos++
+
+			switch {
+			case b == opRGB:
+				if pos+3 > len(data) {
+					return nil, fmt.Errorf("qoi image is truncated")
+				}
+				px.R, px.G, px.B = data[pos], data[pos+1], data[pos+2]
+				pos += 3
+			case b == opRGBA:
+				if pos+4 > len(data) {
+					return nil, fmt.Errorf("qoi image is truncated")
+				}
+				px = color.NRGBA{R: data[pos], G: data[pos+1], B: data[pos+2], A: data[pos+3]}
+				pos += 4
+			case b&opMask == opIndex:
+				px = index[b]
+			case b&opMask == opDiff:
+				px.R += (b>>4)&0x03 - 2
+				px.G += (b>>2)&0x03 - 2
+				px.B += b&0x03 - 2
+			case b&opMask == opLuma:
+				if pos >= len(data) {
+					return nil, fmt.Errorf("qoi image is truncated")
+				}
+				dg := b&0x3f - 32
+				px.R += dg - 8 + (data[pos]>>4)&0x0f
+				px.G += dg
+				px.B += dg - 8 + data[pos]&0x0f
+				pos++
+			case b&opMask == opRun:
+				run = int(b & 0x3f)
+			}
+
+			index[hash(px)] = px
+		}
+
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = px.R, px.G, px.B, px.A
+	}
+
+	return img, nil
+}
+
+//#endregion
+//#region private functions
+
+// hash returns the position of a pixel in the index of seen pixels
+func hash(px color.NRGBA) byte {
+	return byte((int(px.R)*3 + int(px.G)*5 + int(px.B)*7 + int(px.A)*11) % 64)
+}
+
+//#endregion
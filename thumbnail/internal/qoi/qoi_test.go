@@ -0,0 +1,55 @@
+package qoi
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+
+	data := []byte{
+		'q', 'o', 'i', 'f', 0, 0, 0, 3, 0, 0, 0, 2, 4, 0, // 3x2 pixels, rgba
+		0xff, 255, 0, 0, 255, // red
+		0xc0,            // run of 1 red
+		0xfe, 0, 255, 0, // green
+		0x32,       // index of red
+		0x5a,       // diff of red: r-1, g+0, b+0
+		0xa2, 0x88, // luma of the last: dg+2, dr-dg 0, db-dg 0
+		0, 0, 0, 0, 0, 0, 0, 1, // end marker
+	}
+
+	img, err := Decode(data)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	want := []color.NRGBA{
+		{R: 255, A: 255}, {R: 255, A: 255}, {G: 255, A: 255},
+		{R: 255, A: 255}, {R: 254, A: 255}, {R: 0, G: 2, B: 2, A: 255},
+	}
+
+	for i, c := range want {
+		got := img.At(i%3, i/3).(color.NRGBA)
+		if got != c {
+			t.Errorf("pixel %d: got %v, want %v", i, got, c)
+		}
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+
+	var cases = map[string][]byte{
+		"empty":      {},
+		"magic":      []byte("qoixxxxxxxxxxxxxxxxxx"),
+		"dimensions": {'q', 'o', 'i', 'f', 0, 0, 0, 0, 0, 0, 0, 2, 4, 0},
+		"truncated":  {'q', 'o', 'i', 'f', 0, 0, 0, 2, 0, 0, 0, 2, 4, 0, 0xfe, 1},
+	}
+
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Decode(data); err == nil {
+				t.Errorf("got nil error, want error")
+			}
+		})
+	}
+}
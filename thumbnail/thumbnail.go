@@ -0,0 +1,275 @@
+// thumbnail package extracts and injects the preview images that slicers embed into the comments of a gcode file.
+//
+// PrusaSlicer, Cura and Orca Slicer store each image encoded in base64, split in comment-only lines
+// enclosed by a begin and an end comment:
+//
+//	; thumbnail begin 16x16 428
+//	; iVBORw0KGgoAAAANSUhEUgAAABAAAAAQCAYAAAAf8/9hAAAA...
+//	; thumbnail end
+//
+// The keyword of the begin comment declares the image format: "thumbnail" is a PNG image,
+// "thumbnail_JPG" is a JPG image and "thumbnail_QOI" is a QOI image.
+// The begin comment also declares the dimensions of the image and the length of the base64 text.
+package thumbnail
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mauroalderete/gcode-core/block/gcodeblock"
+	"github.com/mauroalderete/gcode-core/thumbnail/internal/qoi"
+)
+
+const (
+	// LINE_LENGTH is the maximum length of the base64 text stored in each comment line
+	LINE_LENGTH = 78
+)
+
+// Format is the image format of a thumbnail.
+type Format string
+
+const (
+	FORMAT_PNG Format = "PNG"
+	FORMAT_JPG Format = "JPG"
+	FORMAT_QOI Format = "QOI"
+)
+
+// beginRegex matches the comment that opens a thumbnail and captures the format, the dimensions and the length of the base64 text
+var beginRegex = regexp.MustCompile(`^;\s*thumbnail(?:_(PNG|JPG|QOI))?\s+begin\s+(\d+)x(\d+)\s+(\d+)\s*$`)
+
+// endRegex matches the comment that closes a thumbnail
+var endRegex = regexp.MustCompile(`^;\s*thumbnail(?:_(?:PNG|JPG|QOI))?\s+end\s*$`)
+
+//#region thumbnail struct
+
+// Thumbnail is an image embedded into a gcode file.
+type Thumbnail struct {
+	// Format is the image format declared
+	Format Format
+
+	// Width and Height are the dimensions declared
+	Width  int
+	Height int
+
+	// Data is the image encoded in his format
+	Data []byte
+}
+
+// Image decodes the image data according to his format.
+func (t *Thumbnail) Image() (image.Image, error) {
+
+	var img image.Image
+	var err error
+
+	switch t.Format {
+	case FORMAT_PNG:
+		img, err = png.Decode(bytes.NewReader(t.Data))
+	case FORMAT_JPG:
+		img, err = jpeg.Decode(bytes.NewReader(t.Data))
+	case FORMAT_QOI:
+		img, err = qoi.Decode(t.Data)
+	default:
+		return nil, fmt.Errorf("thumbnail format %s isn't supported", t.Format)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s thumbnail: %w", t.Format, err)
+	}
+
+	return img, nil
+}
+
+// Lines returns the comment lines that embed the thumbnail into a gcode file, without the end of line characters.
+func (t *Thumbnail) Lines() []string {
+
+	text := base64.StdEncoding.EncodeToString(t.Data)
+
+	keyword := "thumbnail"
+	if t.Format != FORMAT_PNG {
+		keyword += "_" + string(t.Format)
+	}
+
+	lines := []string{fmt.Sprintf("; %s begin %dx%d %d", keyword, t.Width, t.Height, len(text))}
+
+	for len(text) > 0 {
+		n := LINE_LENGTH
+		if len(text) < n {
+			n = len(text)
+		}
+		lines = append(lines, "; "+text[:n])
+		text = text[n:]
+	}
+
+	return append(lines, fmt.Sprintf("; %s end", keyword))
+}
+
+//#endregion
+//#region constructor
+
+// New returns a new Thumbnail from an image encoded in the given format.
+//
+// The dimensions are taken from the image, so data must be a valid image of that format.
+func New(format Format, data []byte) (*Thumbnail, error) {
+
+	var config image.Config
+	var err error
+
+	switch format {
+	case FORMAT_PNG:
+		config, err = png.DecodeConfig(bytes.NewReader(data))
+	case FORMAT_JPG:
+		config, err = jpeg.DecodeConfig(bytes.NewReader(data))
+	case FORMAT_QOI:
+		config, err = qoi.DecodeConfig(data)
+	default:
+		return nil, fmt.Errorf("thumbnail format %s isn't supported", format)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the %s image: %w", format, err)
+	}
+
+	return &Thumbnail{
+		Format: format,
+		Width:  config.Width,
+		Height: config.Height,
+		Data:   data,
+	}, nil
+}
+
+//#endregion
+//#region package functions
+
+// Extract scans the comments of a gcode file and returns all thumbnails found, in the order they appear.
+//
+// It returns an error if a thumbnail isn't closed, if his base64 text is invalid or if it hasn't the length declared.
+// The image data isn't decoded, it can be done later with the Image method.
+func Extract(r io.Reader) ([]*Thumbnail, error) {
+
+	var thumbnails []*Thumbnail
+	var current *Thumbnail
+	var text strings.Builder
+	var length, begin int
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), gcodeblock.SCANNER_MAX_LINE_SIZE)
+
+	for line := 1; scanner.Scan(); line++ {
+
+		comment := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(comment, ";") {
+			if current != nil && comment != "" {
+				return nil, fmt.Errorf("thumbnail begun at line %d is interrupted by gcode at line %d", begin, line)
+			}
+			continue
+		}
+
+		if current == nil {
+			match := beginRegex.FindStringSubmatch(comment)
+			if match == nil {
+				continue
+			}
+
+			current = &Thumbnail{Format: FORMAT_PNG}
+			if match[1] != "" {
+				current.Format = Format(match[1])
+			}
+			current.Width, _ = strconv.Atoi(match[2])
+			current.Height, _ = strconv.Atoi(match[3])
+			length, _ = strconv.Atoi(match[4])
+			begin = line
+			text.Reset()
+			continue
+		}
+
+		if endRegex.MatchString(comment) {
+			if text.Len() != length {
+				return nil, fmt.Errorf("thumbnail begun at line %d has %d base64 characters, want %d", begin, text.Len(), length)
+			}
+
+			data, err := base64.StdEncoding.DecodeString(text.String())
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode thumbnail begun at line %d: %w", begin, err)
+			}
+
+			current.Data = data
+			thumbnails = append(thumbnails, current)
+			current = nil
+			continue
+		}
+
+		text.WriteString(strings.TrimSpace(comment[1:]))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read gcode: %w", err)
+	}
+
+	if current != nil {
+		return nil, fmt.Errorf("thumbnail begun at line %d isn't closed", begin)
+	}
+
+	return thumbnails, nil
+}
+
+// Insert copies the gcode file from r to w, inserting the comment lines of the thumbnails before the first line with gcode.
+//
+// Is said, the thumbnails are placed after the header comments that slicers write at the beginning of the file.
+// If the file hasn't any gcode, the thumbnails are placed at the end.
+func Insert(w io.Writer, r io.Reader, thumbnails ...*Thumbnail) error {
+
+	for _, t := range thumbnails {
+		if t == nil {
+			return fmt.Errorf("failed to insert thumbnails, they mustn't be nil")
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	inserted := false
+
+	insert := func() {
+		for _, t := range thumbnails {
+			for _, line := range t.Lines() {
+				bw.WriteString(line)
+				bw.WriteByte('\n')
+			}
+		}
+		inserted = true
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), gcodeblock.SCANNER_MAX_LINE_SIZE)
+
+	for scanner.Scan() {
+		if !inserted && gcodeblock.HasGcode(scanner.Text()) {
+			insert()
+		}
+		bw.WriteString(scanner.Text())
+		bw.WriteByte('\n')
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read gcode: %w", err)
+	}
+
+	if !inserted {
+		insert()
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write gcode: %w", err)
+	}
+
+	return nil
+}
+
+//#endregion
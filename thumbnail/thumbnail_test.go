@@ -0,0 +1,210 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mockPNG(t *testing.T, width int, height int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 10), G: uint8(y * 10), B: 128, A: 255})
+		}
+	}
+
+	out := &bytes.Buffer{}
+	if err := png.Encode(out, img); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	return out.Bytes()
+}
+
+func mockJPG(t *testing.T) []byte {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+	if err := jpeg.Encode(out, image.NewGray(image.Rect(0, 0, 8, 4)), nil); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	return out.Bytes()
+}
+
+var mockQOI = []byte{
+	'q', 'o', 'i', 'f', 0, 0, 0, 2, 0, 0, 0, 1, 4, 0,
+	0xff, 255, 0, 0, 255,
+	0xc0,
+	0, 0, 0, 0, 0, 0, 0, 1,
+}
+
+const mockGcode = "; generated by PrusaSlicer\n" +
+	"\n" +
+	"M73 P0 R10\n" +
+	"G28 ; home\n"
+
+func TestLines(t *testing.T) {
+
+	th := &Thumbnail{Format: FORMAT_QOI, Width: 2, Height: 1, Data: mockQOI}
+
+	want := []string{
+		"; thumbnail_QOI begin 2x1 40",
+		"; cW9pZgAAAAIAAAABBAD//wAA/8AAAAAAAAAAAQ==",
+		"; thumbnail_QOI end",
+	}
+
+	if got := th.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	long := &Thumbnail{Format: FORMAT_PNG, Width: 32, Height: 32, Data: mockPNG(t, 32, 32)}
+	lines := long.Lines()
+
+	if !strings.HasPrefix(lines[0], "; thumbnail begin 32x32 ") || lines[len(lines)-1] != "; thumbnail end" {
+		t.Errorf("got begin %q and end %q, want PNG thumbnail comments", lines[0], lines[len(lines)-1])
+	}
+
+	for _, line := range lines[1 : len(lines)-1] {
+		if len(line) > LINE_LENGTH+2 {
+			t.Errorf("got line of %d chars, want at most %d", len(line), LINE_LENGTH+2)
+		}
+	}
+}
+
+func TestInsertAndExtract(t *testing.T) {
+
+	var thumbnails []*Thumbnail
+
+	for _, source := range []struct {
+		format Format
+		data   []byte
+	}{
+		{FORMAT_PNG, mockPNG(t, 16, 12)},
+		{FORMAT_JPG, mockJPG(t)},
+		{FORMAT_QOI, mockQOI},
+	} {
+		th, err := New(source.format, source.data)
+		if err != nil {
+			t.Fatalf("got error %v, want nil error", err)
+		}
+		thumbnails = append(thumbnails, th)
+	}
+
+	out := &bytes.Buffer{}
+	if err := Insert(out, strings.NewReader(mockGcode), thumbnails...); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	if lines[0] != "; generated by PrusaSlicer" || lines[2] != "; thumbnail begin 16x12 "+strings.Fields(lines[2])[4] {
+		t.Errorf("got first lines %q, want the thumbnails after the header", lines[:3])
+	}
+	if !strings.HasSuffix(out.String(), "; thumbnail_QOI end\nM73 P0 R10\nG28 ; home\n") {
+		t.Errorf("got %q, want the gcode after the thumbnails", out.String())
+	}
+
+	extracted, err := Extract(out)
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if !reflect.DeepEqual(extracted, thumbnails) {
+		t.Fatalf("got %d thumbnails extracted, want %d equal to the inserted", len(extracted), len(thumbnails))
+	}
+
+	for _, th := range extracted {
+		img, err := th.Image()
+		if err != nil {
+			t.Errorf("got error %v decoding %s, want nil error", err, th.Format)
+			continue
+		}
+		if img.Bounds().Dx() != th.Width || img.Bounds().Dy() != th.Height {
+			t.Errorf("got %s image of %v, want %dx%d", th.Format, img.Bounds(), th.Width, th.Height)
+		}
+	}
+}
+
+func TestInsert_WithoutGcode(t *testing.T) {
+
+	th := &Thumbnail{Format: FORMAT_QOI, Width: 2, Height: 1, Data: mockQOI}
+
+	out := &bytes.Buffer{}
+	if err := Insert(out, strings.NewReader("; only comments\n"), th); err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	want := "; only comments\n" + strings.Join(th.Lines(), "\n") + "\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	if err := Insert(out, strings.NewReader(mockGcode), nil); err == nil {
+		t.Errorf("got nil error inserting a nil thumbnail, want error")
+	}
+}
+
+func TestExtract_Cura(t *testing.T) {
+
+	th := &Thumbnail{Format: FORMAT_QOI, Width: 2, Height: 1, Data: mockQOI}
+	lines := th.Lines()
+
+	// Cura doesn't write a space after the semicolon
+	source := ";FLAVOR:Marlin\n;thumbnail_QOI begin 2x1 40\n;" + strings.TrimPrefix(lines[1], "; ") + "\n;thumbnail_QOI end\nG28\n"
+
+	extracted, err := Extract(strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("got error %v, want nil error", err)
+	}
+
+	if len(extracted) != 1 || !reflect.DeepEqual(extracted[0], th) {
+		t.Errorf("got %v, want %v", extracted, th)
+	}
+}
+
+func TestExtract_Errors(t *testing.T) {
+
+	var cases = map[string]string{
+		"not closed":   "; thumbnail begin 2x1 4\n; AAAA\n",
+		"interrupted":  "; thumbnail begin 2x1 4\n; AAAA\nG28\n; thumbnail end\n",
+		"wrong length": "; thumbnail begin 2x1 8\n; AAAA\n; thumbnail end\n",
+		"bad base64":   "; thumbnail begin 2x1 4\n; A*AA\n; thumbnail end\n",
+	}
+
+	for name, source := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Extract(strings.NewReader(source)); err == nil {
+				t.Errorf("got nil error, want error")
+			}
+		})
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+
+	var cases = map[string]struct {
+		format Format
+		data   []byte
+	}{
+		"unknown format": {Format("BMP"), mockQOI},
+		"invalid png":    {FORMAT_PNG, mockQOI},
+		"invalid jpg":    {FORMAT_JPG, mockQOI},
+		"invalid qoi":    {FORMAT_QOI, []byte("qoif")},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(tc.format, tc.data); err == nil {
+				t.Errorf("got nil error, want error")
+			}
+		})
+	}
+}